@@ -20,9 +20,29 @@ type Options struct {
 	// EnablePorts enables the common-port scan collector.
 	EnablePorts bool
 
+	// EnableMTU enables path MTU discovery.
+	EnableMTU bool
+
 	// Timeout is the overall timeout for all collectors.
 	// If zero or negative, DefaultTimeout is used.
 	Timeout time.Duration
+
+	// PingMode selects the ping method: "auto" (default),
+	// "icmp", "tcp" or "http". Auto falls back to TCP
+	// connect and HTTP(S) HEAD when ICMP is blocked.
+	PingMode string
+
+	// PingPort is the TCP port used by tcp pings. If zero,
+	// the first responsive common port is used.
+	PingPort int
+
+	// TraceProtocol selects traceroute probes: "udp" (default),
+	// "icmp" or "tcp".
+	TraceProtocol string
+
+	// TraceMaxHops limits the traceroute length. If zero, 30
+	// hops are tried.
+	TraceMaxHops int
 }
 
 // DefaultTimeout is the fallback timeout used when
@@ -43,8 +63,17 @@ func Run(ctx context.Context, target string, opts Options) (*Report, error) {
 
 	report, err := collector.Collect(ctx, target, collector.Options{
 		EnablePorts: opts.EnablePorts,
+		EnableMTU:   opts.EnableMTU,
 		NoAgent:     true,
 		Timeout:     opts.Timeout,
+		Ping: collector.PingOptions{
+			Mode: opts.PingMode,
+			Port: opts.PingPort,
+		},
+		Trace: collector.TraceOptions{
+			Protocol: opts.TraceProtocol,
+			MaxHops:  opts.TraceMaxHops,
+		},
 	})
 	if err != nil {
 		return nil, err
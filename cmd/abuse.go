@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+var abuseOutput string
+
+var abuseCmd = &cobra.Command{
+	Use:   "abuse [flags] <ip|domain>",
+	Short: "Find where to report abuse from an address",
+	Long: `Look up the abuse contact for an IP address or domain, most
+authoritative first: the registry's RDAP abuse entity, the RIR's abuse-c
+role or ARIN's abuse points of contact, an incident response team, the
+registrar's abuse address, and the abuse.net contact database.
+
+Examples:
+  ng abuse 192.0.2.10
+  ng abuse example.com --output json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAbuse,
+}
+
+func init() {
+	abuseCmd.Flags().StringVar(&abuseOutput, "output", "text",
+		"Output format: text, json")
+}
+
+func runAbuse(cmd *cobra.Command, args []string) error {
+	if abuseOutput != "text" && abuseOutput != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", abuseOutput)
+	}
+
+	target, err := validateTarget(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	whoisOpts, err := whoisOptions()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), 20*time.Second)
+	defer cancel()
+
+	contacts, err := collector.LookupAbuse(ctx, target, whoisOpts)
+	if err != nil {
+		return fmt.Errorf("abuse lookup failed: %w", err)
+	}
+
+	if abuseOutput == "json" {
+		data, err := json.MarshalIndent(contacts, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal abuse contacts: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Abuse contact for %s: %s\n", target, contacts[0].Email)
+	for i, c := range contacts {
+		line := fmt.Sprintf("  %d. %-32s %s", i+1, c.Email, c.Source)
+		if details := abuseContactDetails(c); details != "" {
+			line += " (" + details + ")"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// abuseContactDetails names who published a contact, e.g.
+// "RIPE, OPS4-RIPE, Abuse-C Role"
+func abuseContactDetails(c model.AbuseContact) string {
+	var parts []string
+	if c.Registry != "" {
+		parts = append(parts, strings.ToUpper(c.Registry))
+	}
+	for _, v := range []string{c.Handle, c.Name} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -72,6 +73,41 @@ func showConfig() error {
 	fmt.Println("netgaze configuration:")
 	fmt.Printf("  Default Timeout: %s\n", config.DefaultTimeout)
 	fmt.Printf("  Enable Port Scan: %v\n", config.EnablePorts)
+	if config.WhoisTimeout != "" {
+		fmt.Printf("  WHOIS Timeout: %s\n", config.WhoisTimeout)
+	}
+	if len(config.WhoisServers) > 0 {
+		keys := make([]string, 0, len(config.WhoisServers))
+		for key := range config.WhoisServers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("  WHOIS Servers:")
+		for _, key := range keys {
+			fmt.Printf("    %s -> %s\n", key, config.WhoisServers[key])
+		}
+	}
+	if config.RPKIVRPFile != "" {
+		fmt.Printf("  RPKI VRP File: %s\n", config.RPKIVRPFile)
+	}
+	if geo := config.Geo; len(geo.Providers) > 0 || geo.IPAPIKey != "" || geo.IPInfoToken != "" || len(geo.MMDBPaths) > 0 || geo.Vantage != nil {
+		fmt.Println("  Geolocation:")
+		if len(geo.Providers) > 0 {
+			fmt.Printf("    Providers: %s\n", strings.Join(geo.Providers, " -> "))
+		}
+		if geo.IPAPIKey != "" {
+			fmt.Printf("    ip-api Key: %s\n", maskKey(geo.IPAPIKey))
+		}
+		if geo.IPInfoToken != "" {
+			fmt.Printf("    ipinfo Token: %s\n", maskKey(geo.IPInfoToken))
+		}
+		if len(geo.MMDBPaths) > 0 {
+			fmt.Printf("    MMDB Path: %s\n", strings.Join(geo.MMDBPaths, ", "))
+		}
+		if v := geo.Vantage; v != nil {
+			fmt.Printf("    Vantage: %s\n", strings.TrimSpace(fmt.Sprintf("%s %v,%v", v.Name, v.Lat, v.Lon)))
+		}
+	}
 
 	return nil
 }
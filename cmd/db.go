@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/asndb"
+)
+
+var (
+	dbUpdateFrom   []string
+	dbLookupOutput string
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the offline IP-to-ASN database",
+	Long: `The offline database answers ASN lookups for targets and traceroute
+hops without any network traffic. When it exists, netgaze uses it before
+asking Team Cymru over DNS.
+
+It is stored at $XDG_DATA_HOME/netgaze/asn.db
+(default ~/.local/share/netgaze/asn.db). Without a subcommand, ng db shows
+what the current database holds.`,
+	Args: cobra.NoArgs,
+	RunE: runDBInfo,
+}
+
+var dbUpdateCmd = &cobra.Command{
+	Use:   "update --from <file> [--from <file>...]",
+	Short: "Build the database from downloaded datasets",
+	Long: `Build the offline database from one or more IP-to-ASN datasets, which
+may be gzipped:
+
+  ip2asn     ip2asn-combined.tsv, ip2asn-v4.tsv or ip2asn-v6.tsv from
+             iptoasn.com (ranges with AS number, country and name)
+  RIPE RIS   riswhoisdump.IPv4 / riswhoisdump.IPv6 (prefix, origin AS
+             and how many peers see it)
+
+Files are applied in order, so import ip2asn first and a RIS dump after
+it to keep the AS names while using RIS's more specific routes. The
+previous database is replaced only once the new one is complete.
+
+Examples:
+  ng db update --from ip2asn-combined.tsv.gz
+  ng db update --from ip2asn-v4.tsv --from riswhoisdump.IPv4.gz`,
+	Args: cobra.NoArgs,
+	RunE: runDBUpdate,
+}
+
+var dbLookupCmd = &cobra.Command{
+	Use:   "lookup [flags] <ip>... | -",
+	Short: "Look addresses up in the offline database",
+	Long: `Print the origin AS of each address from the offline database, one
+tab-separated line per address (ip, ASN, prefix, country, name). With "-"
+addresses are read from stdin, one per line, for enriching large batches.
+
+Examples:
+  ng db lookup 8.8.8.8 2606:4700::1111
+  cut -d' ' -f1 access.log | sort -u | ng db lookup -`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runDBLookup,
+}
+
+func init() {
+	dbUpdateCmd.Flags().StringArrayVar(&dbUpdateFrom, "from", nil,
+		"Dataset to import (ip2asn TSV or RIPE RIS dump, optionally gzipped); repeatable")
+	dbUpdateCmd.MarkFlagRequired("from")
+	dbCmd.AddCommand(dbUpdateCmd)
+
+	dbLookupCmd.Flags().StringVar(&dbLookupOutput, "output", "text",
+		"Output format: text, json (one object per line)")
+	dbCmd.AddCommand(dbLookupCmd)
+}
+
+func runDBInfo(cmd *cobra.Command, args []string) error {
+	path, err := asndb.DefaultPath()
+	if err != nil {
+		return err
+	}
+	db, err := asndb.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("No ASN database at %s\n", path)
+		fmt.Println("Build one with: ng db update --from <ip2asn.tsv | riswhoisdump>")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	info := db.Info()
+	fmt.Printf("ASN database: %s\n", path)
+	fmt.Printf("  Built:    %s\n", info.Built.Local().Format(time.RFC1123))
+	fmt.Printf("  Prefixes: %d\n", info.Prefixes)
+	fmt.Printf("  ASNs:     %d\n", info.ASNs)
+	fmt.Printf("  Source:   %s\n", info.Source)
+	return nil
+}
+
+func runDBUpdate(cmd *cobra.Command, args []string) error {
+	path, err := asndb.DefaultPath()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	builder := asndb.NewBuilder()
+	var sources []string
+	for _, file := range dbUpdateFrom {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		n, err := builder.Import(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", file, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no IP-to-ASN entries found in %s", file)
+		}
+		fmt.Printf("Imported %d entries from %s\n", n, file)
+		sources = append(sources, filepath.Base(file))
+	}
+
+	if err := builder.WriteFile(path, strings.Join(sources, ", ")); err != nil {
+		return fmt.Errorf("failed to write ASN database: %w", err)
+	}
+	fmt.Printf("Wrote %d prefixes to %s in %s\n", builder.Prefixes(), path, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// dbLookupResult is one line of ng db lookup --output json
+type dbLookupResult struct {
+	IP      string `json:"ip"`
+	ASN     uint32 `json:"asn,omitempty"`
+	Prefix  string `json:"prefix,omitempty"`
+	Country string `json:"country,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func runDBLookup(cmd *cobra.Command, args []string) error {
+	if dbLookupOutput != "text" && dbLookupOutput != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", dbLookupOutput)
+	}
+
+	db, err := asndb.OpenDefault()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+
+	lookup := func(s string) error {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil
+		}
+		res := dbLookupResult{IP: s}
+		if addr, err := netip.ParseAddr(s); err != nil {
+			res.Error = "invalid IP address"
+		} else if rec, ok := db.Lookup(addr); !ok {
+			res.Error = "not found"
+		} else {
+			res.ASN, res.Prefix, res.Country, res.Name = rec.ASN, rec.Prefix.String(), rec.Country, rec.Name
+		}
+
+		if dbLookupOutput == "json" {
+			return enc.Encode(res)
+		}
+		if res.Error != "" {
+			_, err := fmt.Fprintf(out, "%s\t-\t%s\n", s, res.Error)
+			return err
+		}
+		_, err := fmt.Fprintf(out, "%s\t%d\t%s\t%s\t%s\n", res.IP, res.ASN, res.Prefix, res.Country, res.Name)
+		return err
+	}
+
+	if len(args) == 1 && args[0] == "-" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if err := lookup(scanner.Text()); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+	for _, arg := range args {
+		if err := lookup(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
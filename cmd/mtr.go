@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/ui"
+)
+
+var (
+	mtrInterval time.Duration
+	mtrCycles   int
+	mtrReport   bool
+	mtrOutput   string
+)
+
+var mtrCmd = &cobra.Command{
+	Use:   "mtr [flags] <ip|domain|url>",
+	Short: "Continuously trace a path with per-hop statistics",
+	Long: `Trace the path to a target over and over, keeping per-hop loss and
+latency statistics (sent, received, loss%, last, avg, best, worst, stddev).
+
+On a terminal a live table is shown until interrupted. With --report, or
+when output is piped, --cycles traces are run and the final table is
+written as text or JSON.
+
+Examples:
+  ng mtr 1.1.1.1
+  ng mtr example.com --trace-proto tcp --trace-port 443
+  ng mtr 8.8.8.8 --report --cycles 20 --output json > mtr.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runMTR,
+}
+
+func init() {
+	mtrCmd.Flags().DurationVar(&mtrInterval, "interval", 1*time.Second,
+		"Time between trace cycles")
+	mtrCmd.Flags().IntVar(&mtrCycles, "cycles", 0,
+		"Number of trace cycles (default: until interrupted, 10 in report mode)")
+	mtrCmd.Flags().BoolVar(&mtrReport, "report", false,
+		"Run --cycles traces and print the final table instead of the live view")
+	mtrCmd.Flags().StringVar(&mtrOutput, "output", "text",
+		"Report output format: text, json")
+	addTraceFlags(mtrCmd)
+}
+
+func runMTR(cmd *cobra.Command, args []string) error {
+	normalizedTarget, err := validateTarget(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	if mtrOutput != "text" && mtrOutput != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", mtrOutput)
+	}
+	if mtrInterval < 100*time.Millisecond {
+		return fmt.Errorf("interval must be at least 100ms")
+	}
+
+	traceOpts, err := traceOptions()
+	if err != nil {
+		return err
+	}
+	// One probe per hop per cycle, answered before the next cycle,
+	// unless asked otherwise
+	if !cmd.Flags().Changed("probes-per-hop") {
+		traceOpts.ProbesPerHop = 1
+	}
+	if !cmd.Flags().Changed("trace-wait") {
+		traceOpts.Wait = mtrInterval
+	}
+
+	live := !mtrReport && isatty.IsTerminal(os.Stdout.Fd())
+	cycles := mtrCycles
+	if !live && cycles == 0 {
+		cycles = 10
+	}
+
+	mtr, err := collector.NewMTR(normalizedTarget, collector.MTROptions{
+		Trace:    traceOpts,
+		Interval: mtrInterval,
+		Cycles:   cycles,
+	})
+	if err != nil {
+		return fmt.Errorf("mtr failed: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	if live {
+		return runMTRTUI(ctx, normalizedTarget, mtr)
+	}
+
+	if err := mtr.Run(ctx, nil); err != nil {
+		return fmt.Errorf("mtr failed: %w", err)
+	}
+
+	report := mtr.Snapshot()
+	if mtrOutput == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal mtr report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("mtr to %s (%s), %s probes, %d cycles\n", report.Target, report.IP, report.Protocol, report.Cycles)
+	fmt.Println(ui.FormatMTRTable(report))
+	return nil
+}
+
+func runMTRTUI(ctx context.Context, target string, mtr *collector.MTR) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	updates := make(chan model.MTRReport, 16)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(updates)
+		errs <- mtr.Run(ctx, func(report model.MTRReport) {
+			select {
+			case updates <- report:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	if err := ui.RunMTRTUI(target, updates); err != nil {
+		return err
+	}
+
+	// The first trace failing (e.g. no raw socket and no traceroute
+	// binary) ends the run before anything is shown
+	cancel()
+	if err := <-errs; err != nil {
+		return fmt.Errorf("mtr failed: %w", err)
+	}
+	return nil
+}
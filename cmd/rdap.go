@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/ui"
+)
+
+var (
+	rdapOutput          string
+	rdapUpdateBootstrap bool
+)
+
+var rdapCmd = &cobra.Command{
+	Use:   "rdap [flags] <domain|ip|ASN>",
+	Short: "Look up registration data over RDAP",
+	Long: `Query the Registration Data Access Protocol for a domain, IP address or
+autonomous system (e.g. AS13335). The right server is found through the
+IANA bootstrap registry; a snapshot is built in, and --update-bootstrap
+downloads the current files to $XDG_CACHE_HOME/netgaze/rdap
+(default ~/.cache/netgaze/rdap).
+
+Examples:
+  ng rdap example.com
+  ng rdap 8.8.8.8 --output json
+  ng rdap AS13335
+  ng rdap --update-bootstrap`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if rdapUpdateBootstrap {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runRDAP,
+}
+
+func init() {
+	rdapCmd.Flags().StringVar(&rdapOutput, "output", "text",
+		"Output format: text, json")
+	rdapCmd.Flags().BoolVar(&rdapUpdateBootstrap, "update-bootstrap", false,
+		"Download the current IANA bootstrap files before the lookup")
+}
+
+func runRDAP(cmd *cobra.Command, args []string) error {
+	if rdapOutput != "text" && rdapOutput != "json" {
+		return fmt.Errorf("invalid output format: %s (valid: text, json)", rdapOutput)
+	}
+
+	if rdapUpdateBootstrap {
+		dir, err := collector.RDAPBootstrapDir()
+		if err != nil {
+			return err
+		}
+		if err := collector.UpdateRDAPBootstrap(cmd.Context(), dir); err != nil {
+			return fmt.Errorf("bootstrap update failed: %w", err)
+		}
+		fmt.Printf("Updated RDAP bootstrap files in %s\n", dir)
+		if len(args) == 0 {
+			return nil
+		}
+	}
+
+	target, err := validateTarget(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
+	defer cancel()
+
+	result, err := collector.LookupRDAP(ctx, target)
+	if err != nil {
+		return fmt.Errorf("RDAP lookup failed: %w", err)
+	}
+
+	if rdapOutput == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal RDAP result: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Println(ui.FormatRDAP(result))
+	return nil
+}
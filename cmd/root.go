@@ -3,9 +3,12 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -16,68 +19,144 @@ import (
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/history"
 	"github.com/typicalfo/netgaze/internal/model"
 	"github.com/typicalfo/netgaze/internal/ui"
 )
 
 var (
 	enablePorts bool
+	enableMTU   bool
 	output      string
 	noStyle     bool
 	timeout     time.Duration
+	pingMode    string
+	pingPort    int
+
+	// domain registration alerts
+	newDomainDays  int
+	expiryWarnDays int
+
+	// traceroute engine flags (shared by all commands that trace)
+	traceProto      string
+	traceMaxHops    int
+	traceProbes     int
+	traceWait       time.Duration
+	tracePort       int
+	traceMode       string
+	traceFlows      int
+	traceNoFallback bool
 
 	// traceroute subcommand flags
 	tracerouteOutFile  string
 	tracerouteBaseFile string
 	tracerouteNewFile  string
 	tracerouteDiffFile string
+
+	tracerouteRTTThreshold    time.Duration
+	tracerouteRTTThresholdPct float64
 )
 
+// errSignificantTraceChange makes `ng tc` exit non-zero for cron jobs
+var errSignificantTraceChange = errors.New("significant traceroute changes detected")
+
+// Exit statuses for domain alerts; other failures exit 1
+const (
+	exitNewDomain      = 3
+	exitDomainExpiring = 4
+)
+
+// ExitError reports a condition worth a specific exit status, such as a
+// newly registered domain, after the report has been printed
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode returns the process exit status for an error from Execute
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
 var rootCmd = &cobra.Command{
-	Use:   "ng [flags] <ip|domain|url>",
+	Use:   "ng [flags] <ip|domain|url|ASN>",
 	Short: "Network info gathering tool",
 	Long: `netgaze performs common network diagnostics and compiles the data
 
 Mode:
   - Deterministic, offline templated output (no AI)
 
+An AS number such as AS15169 describes the autonomous system instead:
+its name, registry, country, announced prefixes and registration.
+
+Exit status is 3 when the target domain was registered within
+--new-domain-days and 4 when it expires within --expiry-warn-days.
+
 Examples:
   ng 1.1.1.1
   ng tui google.com --ports
   ng example.com
+  ng AS15169
   `,
 	Args:         cobra.ExactArgs(1),
 	SilenceUsage: true,
 	RunE:         runNetgaze,
 }
 
+// runTUI and collectReport are replaced in tests
+var (
+	runTUI        = ui.RunTUI
+	collectReport = collector.Collect
+)
+
 var tuiCmd = &cobra.Command{
 	Use:   "tui [flags] <ip|domain|url>",
 	Short: "Launch interactive TUI mode",
-	Long:  `Launch the terminal user interface for interactive network analysis.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runNetgaze,
+	Long: `Launch the terminal user interface for interactive network analysis.
+It takes the same collection flags as the default command.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runNetgaze,
 }
 
 var tracerouteOutputCmd = &cobra.Command{
 	Use:   "to [flags] <ip|domain|url>",
 	Short: "Run traceroute and write JSON output",
-	Long:  `Perform a traceroute and save the hop list to a JSON file.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runTracerouteOutput,
+	Long: `Perform a traceroute and store it in the trace history
+($XDG_DATA_HOME/netgaze/traces, default ~/.local/share/netgaze/traces).
+Use -o to also write the hop list to a JSON file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTracerouteOutput,
 }
 
 var tracerouteCompareCmd = &cobra.Command{
 	Use:   "tc [flags] <ip|domain|url>",
 	Short: "Run traceroute and compare with a baseline",
-	Long:  `Perform a traceroute, compare it with a baseline JSON file, and save the new trace.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runTracerouteCompare,
+	Long: `Perform a traceroute, compare it with a baseline JSON file, and save the new trace.
+
+Hops are aligned rather than compared by position, so an inserted or
+removed hop does not make every later hop look changed. The command exits
+non-zero when hops were inserted, removed or changed, the AS path changed,
+the destination stopped answering, or the destination's latency grew past
+both --rtt-threshold and --rtt-threshold-pct. Latency increases at
+intermediate hops are reported but do not affect the exit status.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTracerouteCompare,
 }
 
 func init() {
 	rootCmd.Flags().BoolVar(&enablePorts, "ports", false,
 		"Enable port scan of common ports (not enabled by default)")
+	rootCmd.Flags().BoolVar(&enableMTU, "mtu", false,
+		"Enable path MTU discovery (not enabled by default)")
 	rootCmd.Flags().StringVar(&output, "output", "text",
 		"Output format: text, md, json, raw (for piping or automation)")
 	rootCmd.Flags().BoolVar(&noStyle, "no-style", false,
@@ -87,6 +166,14 @@ func init() {
 		"Legacy alias for --output json")
 	rootCmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second,
 		"Global timeout for all operations")
+	rootCmd.Flags().StringVar(&pingMode, "ping-mode", "auto",
+		"Ping method: auto (ICMP with TCP/HTTP fallback), icmp, tcp, http")
+	rootCmd.Flags().IntVar(&pingPort, "ping-port", 0,
+		"TCP port for tcp ping (default: first responsive common port)")
+	rootCmd.Flags().IntVar(&newDomainDays, "new-domain-days", 30,
+		"Flag domains registered fewer than this many days ago")
+	rootCmd.Flags().IntVar(&expiryWarnDays, "expiry-warn-days", 30,
+		"Flag domains expiring within this many days")
 
 	// Hide the legacy --json flag from help but keep for compatibility
 	rootCmd.Flags().MarkHidden("json")
@@ -94,16 +181,29 @@ func init() {
 	// Add flags to TUI command
 	tuiCmd.Flags().BoolVar(&enablePorts, "ports", false,
 		"Enable port scan of common ports (not enabled by default)")
+	tuiCmd.Flags().BoolVar(&enableMTU, "mtu", false,
+		"Enable path MTU discovery (not enabled by default)")
 	tuiCmd.Flags().DurationVar(&timeout, "timeout", 15*time.Second,
 		"Global timeout for all operations")
+	tuiCmd.Flags().StringVar(&pingMode, "ping-mode", "auto",
+		"Ping method: auto (ICMP with TCP/HTTP fallback), icmp, tcp, http")
+	tuiCmd.Flags().IntVar(&pingPort, "ping-port", 0,
+		"TCP port for tcp ping (default: first responsive common port)")
+
+	// Traceroute engine flags
+	for _, c := range []*cobra.Command{rootCmd, tuiCmd, tracerouteOutputCmd, tracerouteCompareCmd} {
+		addTraceFlags(c)
+	}
 
 	// Traceroute output flags
-	tracerouteOutputCmd.Flags().StringVarP(&tracerouteOutFile, "out", "o", "", "Output JSON file for traceroute (default: traceroute-<target>-<timestamp>.json)")
+	tracerouteOutputCmd.Flags().StringVarP(&tracerouteOutFile, "out", "o", "", "Also write the hop list to this JSON file (runs are always kept in the trace history)")
 
 	// Traceroute compare flags
-	tracerouteCompareCmd.Flags().StringVarP(&tracerouteBaseFile, "base", "b", "", "Baseline traceroute JSON file (optional; auto-detected if omitted)")
-	tracerouteCompareCmd.Flags().StringVarP(&tracerouteNewFile, "new", "n", "", "Output JSON file for new traceroute (default: traceroute-new.json)")
+	tracerouteCompareCmd.Flags().StringVarP(&tracerouteBaseFile, "base", "b", "", "Baseline traceroute JSON file (default: latest run in the trace history)")
+	tracerouteCompareCmd.Flags().StringVarP(&tracerouteNewFile, "new", "n", "", "Also write the new hop list to this JSON file")
 	tracerouteCompareCmd.Flags().StringVarP(&tracerouteDiffFile, "diff", "d", "", "Write human-readable comparison output to this file")
+	tracerouteCompareCmd.Flags().DurationVar(&tracerouteRTTThreshold, "rtt-threshold", 20*time.Millisecond, "Latency increase that counts as a regression")
+	tracerouteCompareCmd.Flags().Float64Var(&tracerouteRTTThresholdPct, "rtt-threshold-pct", 50, "Relative latency increase (percent) that counts as a regression")
 
 	// Add subcommands
 	rootCmd.AddCommand(configCmd)
@@ -111,48 +211,136 @@ func init() {
 	rootCmd.AddCommand(tuiCmd)
 	rootCmd.AddCommand(tracerouteOutputCmd)
 	rootCmd.AddCommand(tracerouteCompareCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(mtrCmd)
+	rootCmd.AddCommand(traceCmd)
+	rootCmd.AddCommand(rdapCmd)
+	rootCmd.AddCommand(abuseCmd)
+	rootCmd.AddCommand(dbCmd)
 }
 
 func Execute() error {
 	return rootCmd.Execute()
 }
 
-func runTracerouteOutput(cmd *cobra.Command, args []string) error {
-	target := args[0]
+// addTraceFlags registers the traceroute engine flags on cmd
+func addTraceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&traceProto, "trace-proto", collector.TraceProtoUDP,
+		"Traceroute probe protocol: udp, icmp, tcp")
+	cmd.Flags().IntVar(&traceMaxHops, "max-hops", 30,
+		"Maximum number of traceroute hops")
+	cmd.Flags().IntVar(&traceProbes, "probes-per-hop", 3,
+		"Traceroute probes sent per hop")
+	cmd.Flags().DurationVar(&traceWait, "trace-wait", 3*time.Second,
+		"Time to wait for each traceroute probe reply")
+	cmd.Flags().IntVar(&tracePort, "trace-port", 0,
+		"Traceroute destination port (default: 33434 for udp, 80 for tcp)")
+	cmd.Flags().StringVar(&traceMode, "trace-mode", collector.TraceModeClassic,
+		"Traceroute mode: classic, paris (constant flow), multipath (enumerate load-balanced paths)")
+	cmd.Flags().IntVar(&traceFlows, "trace-flows", 16,
+		"Flows traced in multipath mode")
+	cmd.Flags().BoolVar(&traceNoFallback, "no-system-traceroute", false,
+		"Do not fall back to the system traceroute binary")
+}
 
-	normalizedTarget, err := validateTarget(target)
-	if err != nil {
-		return fmt.Errorf("invalid target: %w", err)
+// traceOptions builds collector trace options from the trace flags
+func traceOptions() (collector.TraceOptions, error) {
+	switch traceProto {
+	case collector.TraceProtoUDP, collector.TraceProtoICMP, collector.TraceProtoTCP:
+	default:
+		return collector.TraceOptions{}, fmt.Errorf("invalid trace protocol: %s (valid: udp, icmp, tcp)", traceProto)
+	}
+	switch traceMode {
+	case collector.TraceModeClassic, collector.TraceModeParis, collector.TraceModeMultipath:
+	default:
+		return collector.TraceOptions{}, fmt.Errorf("invalid trace mode: %s (valid: classic, paris, multipath)", traceMode)
+	}
+	if traceMode != collector.TraceModeClassic && traceProto == collector.TraceProtoTCP {
+		return collector.TraceOptions{}, fmt.Errorf("%s mode supports udp and icmp probes only", traceMode)
+	}
+	if traceFlows < 1 || traceFlows > 64 {
+		return collector.TraceOptions{}, fmt.Errorf("trace flows must be between 1 and 64")
+	}
+	if traceMaxHops < 1 || traceMaxHops > 64 {
+		return collector.TraceOptions{}, fmt.Errorf("max hops must be between 1 and 64")
+	}
+	if traceProbes < 1 || traceProbes > 10 {
+		return collector.TraceOptions{}, fmt.Errorf("probes per hop must be between 1 and 10")
 	}
 
-	// Perform traceroute using collector's helper
-	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
-	defer cancel()
+	return collector.TraceOptions{
+		Protocol:         traceProto,
+		MaxHops:          traceMaxHops,
+		ProbesPerHop:     traceProbes,
+		Wait:             traceWait,
+		Port:             tracePort,
+		Mode:             traceMode,
+		Flows:            traceFlows,
+		NoSystemFallback: traceNoFallback,
+	}, nil
+}
 
-	hops, err := collector.Traceroute(ctx, normalizedTarget, timeout)
+// whoisOptions reads WHOIS server overrides and the per-server timeout
+// from the config file
+func whoisOptions() (collector.WhoisOptions, error) {
+	config, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("traceroute failed: %w", err)
+		return collector.WhoisOptions{}, err
 	}
 
-	if tracerouteOutFile == "" {
-		timestamp := time.Now().UTC().Format("20060102-150405")
-		tracerouteOutFile = fmt.Sprintf("traceroute-%s-%s.json", normalizedTarget, timestamp)
+	opts := collector.WhoisOptions{Servers: config.WhoisServers}
+	if config.WhoisTimeout != "" {
+		opts.HopTimeout, err = time.ParseDuration(config.WhoisTimeout)
+		if err != nil || opts.HopTimeout <= 0 {
+			return collector.WhoisOptions{}, fmt.Errorf("invalid whois_timeout in config: %s", config.WhoisTimeout)
+		}
 	}
+	return opts, nil
+}
 
-	data, err := json.MarshalIndent(hops, "", "  ")
+// rpkiOptions reads the VRP export used for route origin validation
+// from the config file
+func rpkiOptions() (collector.RPKIOptions, error) {
+	config, err := loadConfig()
 	if err != nil {
-		return fmt.Errorf("failed to marshal traceroute: %w", err)
+		return collector.RPKIOptions{}, err
 	}
+	return collector.RPKIOptions{VRPFile: config.RPKIVRPFile}, nil
+}
 
-	if err := os.WriteFile(tracerouteOutFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write traceroute file: %w", err)
+// geoOptions reads the geolocation provider chain from the config file
+func geoOptions() (collector.GeoOptions, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return collector.GeoOptions{}, err
 	}
 
-	fmt.Printf("Traceroute saved to %s\n", tracerouteOutFile)
-	return nil
+	geo := config.Geo
+	for _, name := range geo.Providers {
+		if !slices.Contains(collector.GeoProviderNames, name) {
+			return collector.GeoOptions{}, fmt.Errorf("invalid geo provider in config: %s (valid: %s)",
+				name, strings.Join(collector.GeoProviderNames, ", "))
+		}
+		if name == collector.GeoProviderMMDB && len(geo.MMDBPaths) == 0 {
+			return collector.GeoOptions{}, fmt.Errorf("geo provider mmdb needs geo.mmdb_path in config")
+		}
+	}
+	opts := collector.GeoOptions{
+		Providers:   geo.Providers,
+		IPAPIKey:    geo.IPAPIKey,
+		IPInfoToken: geo.IPInfoToken,
+		MMDBPaths:   geo.MMDBPaths,
+	}
+	if v := geo.Vantage; v != nil {
+		if v.Lat < -90 || v.Lat > 90 || v.Lon < -180 || v.Lon > 180 || (v.Lat == 0 && v.Lon == 0) {
+			return collector.GeoOptions{}, fmt.Errorf("invalid geo.vantage in config: %v,%v", v.Lat, v.Lon)
+		}
+		opts.Vantage = &collector.Vantage{Name: v.Name, Lat: v.Lat, Lon: v.Lon}
+	}
+	return opts, nil
 }
 
-func runTracerouteCompare(cmd *cobra.Command, args []string) error {
+func runTracerouteOutput(cmd *cobra.Command, args []string) error {
 	target := args[0]
 
 	normalizedTarget, err := validateTarget(target)
@@ -160,81 +348,165 @@ func runTracerouteCompare(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid target: %w", err)
 	}
 
-	// Determine baseline file: explicit --base, most recent for target, or fresh run
-	basePath := tracerouteBaseFile
-	if basePath == "" {
-		// Try most recent traceroute JSON for this target in current directory
-		pattern := fmt.Sprintf("traceroute-%s-*.json", normalizedTarget)
-		matches, err := filepath.Glob(pattern)
-		if err == nil && len(matches) > 0 {
-			// Use lexicographically last as most recent
-			sort.Strings(matches)
-			basePath = matches[len(matches)-1]
-		}
+	run, err := runTrace(cmd.Context(), normalizedTarget)
+	if err != nil {
+		return err
 	}
 
-	if basePath == "" {
-		// No baseline available; run traceroute output once to create it
-		fmt.Println("No baseline traceroute found; running initial traceroute...")
-		if err := runTracerouteOutput(cmd, args); err != nil {
+	path, err := saveTraceRun(run)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Traceroute saved to %s\n", path)
+
+	if tracerouteOutFile != "" {
+		if err := writeTraceHops(tracerouteOutFile, run.Hops); err != nil {
 			return err
 		}
-		// After creating baseline, use default output filename
-		basePath = tracerouteOutFile
+		fmt.Printf("Traceroute saved to %s\n", tracerouteOutFile)
 	}
+	return nil
+}
 
-	// Load baseline hops
-	baseData, err := os.ReadFile(basePath)
+// runTrace traces target with the trace flags and wraps the result as a
+// history run
+func runTrace(ctx context.Context, target string) (*model.TraceRun, error) {
+	traceOpts, err := traceOptions()
 	if err != nil {
-		return fmt.Errorf("failed to read baseline file: %w", err)
+		return nil, err
 	}
 
-	var baseHops []model.TraceHop
-	if err := json.Unmarshal(baseData, &baseHops); err != nil {
-		return fmt.Errorf("failed to parse baseline file: %w", err)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	hops, err := collector.TracerouteWithOptions(ctx, target, timeout, traceOpts)
+	if err != nil {
+		return nil, fmt.Errorf("traceroute failed: %w", err)
 	}
 
-	// Run new traceroute
-	ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
-	defer cancel()
+	run := &model.TraceRun{
+		Target:    target,
+		Protocol:  traceOpts.Protocol,
+		Timestamp: started,
+		Hops:      hops,
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		run.IP = ip.String()
+	} else if ips, err := net.LookupIP(target); err == nil && len(ips) > 0 {
+		run.IP = ips[0].String()
+	}
+	return run, nil
+}
 
-	newHops, err := collector.Traceroute(ctx, normalizedTarget, timeout)
+// saveTraceRun stores run in the traceroute history
+func saveTraceRun(run *model.TraceRun) (string, error) {
+	store, err := historyStore()
 	if err != nil {
-		return fmt.Errorf("traceroute failed: %w", err)
+		return "", err
 	}
+	return store.Save(run)
+}
 
-	if tracerouteNewFile == "" {
-		tracerouteNewFile = "traceroute-new.json"
+func historyStore() (*history.Store, error) {
+	dir, err := history.DefaultDir()
+	if err != nil {
+		return nil, err
 	}
+	return history.Open(dir), nil
+}
 
-	data, err := json.MarshalIndent(newHops, "", "  ")
+// writeTraceHops writes the bare hop list, the format `ng to -o` has
+// always produced
+func writeTraceHops(path string, hops []model.TraceHop) error {
+	data, err := json.MarshalIndent(hops, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to marshal new traceroute: %w", err)
+		return fmt.Errorf("failed to marshal traceroute: %w", err)
 	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write traceroute file: %w", err)
+	}
+	return nil
+}
 
-	if err := os.WriteFile(tracerouteNewFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write new traceroute file: %w", err)
+func runTracerouteCompare(cmd *cobra.Command, args []string) error {
+	target := args[0]
+
+	normalizedTarget, err := validateTarget(target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
 	}
 
-	// Compare and print summary
-	fmt.Println("Traceroute comparison:")
-	for i := range baseHops {
-		if i >= len(newHops) {
-			fmt.Printf("Hop %d: removed (was %s)\n", baseHops[i].Hop, baseHops[i].IP)
-			continue
+	store, err := historyStore()
+	if err != nil {
+		return err
+	}
+
+	// Baseline: explicit --base, latest stored run, or a legacy
+	// traceroute-<target>-*.json file in the current directory
+	var base *model.TraceRun
+	basePath := tracerouteBaseFile
+	if basePath != "" {
+		if base, err = history.Load(basePath); err != nil {
+			return fmt.Errorf("failed to load baseline: %w", err)
+		}
+	} else {
+		base, basePath, err = store.Latest(normalizedTarget)
+		if errors.Is(err, history.ErrNoRuns) {
+			pattern := fmt.Sprintf("traceroute-%s-*.json", normalizedTarget)
+			if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+				// Use lexicographically last as most recent
+				sort.Strings(matches)
+				basePath = matches[len(matches)-1]
+				base, err = history.Load(basePath)
+			}
 		}
-		if baseHops[i].IP != newHops[i].IP {
-			fmt.Printf("Hop %d changed: %s -> %s\n", baseHops[i].Hop, baseHops[i].IP, newHops[i].IP)
+		if err != nil && !errors.Is(err, history.ErrNoRuns) {
+			return fmt.Errorf("failed to load baseline: %w", err)
 		}
 	}
-	if len(newHops) > len(baseHops) {
-		for i := len(baseHops); i < len(newHops); i++ {
-			fmt.Printf("Hop %d: new (%s)\n", newHops[i].Hop, newHops[i].IP)
+
+	if base == nil {
+		// No baseline available; record one for next time
+		fmt.Println("No baseline traceroute found; running initial traceroute...")
+		return runTracerouteOutput(cmd, args)
+	}
+
+	// Run new traceroute
+	run, err := runTrace(cmd.Context(), normalizedTarget)
+	if err != nil {
+		return err
+	}
+
+	newPath, err := saveTraceRun(run)
+	if err != nil {
+		return err
+	}
+	if tracerouteNewFile != "" {
+		if err := writeTraceHops(tracerouteNewFile, run.Hops); err != nil {
+			return err
 		}
+		newPath = tracerouteNewFile
 	}
+	baseHops, newHops := base.Hops, run.Hops
 
-	return nil
+	// Align the traces and report path and latency changes
+	diff := collector.DiffTraces(baseHops, newHops, collector.TraceDiffOptions{
+		RTTThresholdMs:  float64(tracerouteRTTThreshold.Microseconds()) / 1000,
+		RTTThresholdPct: tracerouteRTTThresholdPct,
+	})
+	summary := fmt.Sprintf("Traceroute comparison (%s vs %s):\n%s\n", basePath, newPath, collector.FormatTraceDiff(diff))
+	fmt.Print(summary)
 
+	if tracerouteDiffFile != "" {
+		if err := os.WriteFile(tracerouteDiffFile, []byte(summary), 0644); err != nil {
+			return fmt.Errorf("failed to write diff file: %w", err)
+		}
+	}
+
+	if diff.Significant() {
+		return errSignificantTraceChange
+	}
 	return nil
 }
 
@@ -257,24 +529,78 @@ func runNetgaze(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// Check if TUI mode is explicitly requested (via subcommand)
-	if cmd.HasParent() && cmd.Parent().Name() == "tui" {
-		// Run with TUI (no AI in this version)
-		return ui.RunTUI(normalizedTarget, true, enablePorts, timeout, nil)
+	traceOpts, err := traceOptions()
+	if err != nil {
+		return err
 	}
 
-	// Run collection and output to stdout
-	report, err := collector.Collect(cmd.Context(), normalizedTarget, collector.Options{
+	whoisOpts, err := whoisOptions()
+	if err != nil {
+		return err
+	}
+	whoisOpts.NewDomainDays = newDomainDays
+	whoisOpts.ExpiryWarnDays = expiryWarnDays
+
+	rpkiOpts, err := rpkiOptions()
+	if err != nil {
+		return err
+	}
+
+	geoOpts, err := geoOptions()
+	if err != nil {
+		return err
+	}
+
+	opts := collector.Options{
 		EnablePorts: enablePorts,
+		EnableMTU:   enableMTU,
 		NoAgent:     true,
 		Timeout:     timeout,
-	})
+		Ping: collector.PingOptions{
+			Mode: pingMode,
+			Port: pingPort,
+		},
+		Trace: traceOpts,
+		Whois: whoisOpts,
+		RPKI:  rpkiOpts,
+		Geo:   geoOpts,
+	}
+
+	// Check if TUI mode is explicitly requested (via subcommand)
+	if cmd.Name() == "tui" {
+		// Run with TUI (no AI in this version)
+		return runTUI(normalizedTarget, true, enablePorts, timeout, func(ctx context.Context) (*model.Report, error) {
+			return collectReport(ctx, normalizedTarget, opts)
+		})
+	}
+
+	// Run collection and output to stdout
+	report, err := collectReport(cmd.Context(), normalizedTarget, opts)
 	if err != nil {
 		return fmt.Errorf("collection failed: %w", err)
 	}
 
 	// Output based on format
-	return outputReport(report, output)
+	if err := outputReport(report, output); err != nil {
+		return err
+	}
+	return domainAlertError(report)
+}
+
+// domainAlertError turns the WHOIS date flags into an exit status. A new
+// domain is the stronger signal, so it wins when both apply.
+func domainAlertError(report *model.Report) error {
+	code := 0
+	switch {
+	case report.Whois.NewlyRegistered:
+		code = exitNewDomain
+	case report.Whois.ExpiringSoon:
+		code = exitDomainExpiring
+	default:
+		return nil
+	}
+	alerts := strings.Join(domainAlerts(report), ", ")
+	return &ExitError{Code: code, Err: fmt.Errorf("%s: %s", report.Whois.Domain, alerts)}
 }
 
 func outputReport(report *model.Report, format string) error {
@@ -309,21 +635,123 @@ func outputMarkdown(report *model.Report) error {
 		md.WriteString(fmt.Sprintf("**IPs:** %s\n\n", strings.Join(report.IPv4, ", ")))
 	}
 
+	// Autonomous system target
+	if info := report.AS; info != nil {
+		md.WriteString(fmt.Sprintf("**AS:** %s\n\n", asLabel(info)))
+		if registry := asRegistryLabel(info); registry != "" {
+			md.WriteString(fmt.Sprintf("**Registry:** %s\n\n", registry))
+		}
+		if len(info.Prefixes) > 0 {
+			md.WriteString(fmt.Sprintf("**Prefixes:** %d IPv4, %d IPv6\n\n", info.IPv4Prefixes, info.IPv6Prefixes))
+			for _, p := range asPrefixList(info) {
+				md.WriteString(fmt.Sprintf("- %s\n", p))
+			}
+			md.WriteString("\n")
+		}
+	}
+
 	// Geolocation
 	if report.Geo.Country != "" {
 		md.WriteString(fmt.Sprintf("**Location:** %s, %s, %s\n\n", report.Geo.City, report.Geo.Region, report.Geo.Country))
 		if report.Geo.ISP != "" {
 			md.WriteString(fmt.Sprintf("**ISP:** %s\n\n", report.Geo.ISP))
 		}
+		if len(report.Geo.Sources) > 0 {
+			md.WriteString(fmt.Sprintf("**Geo Source:** %s\n\n", geoSourceLabel(report)))
+		}
+		if c := report.GeoCheck; c != nil {
+			md.WriteString(fmt.Sprintf("**Geo Confidence:** %s\n\n", geoConfidenceLabel(c)))
+			for _, f := range c.Findings {
+				md.WriteString(fmt.Sprintf("- %s\n", f))
+			}
+			if len(c.Findings) > 0 {
+				md.WriteString("\n")
+			}
+		}
 		if report.Geo.ASN != "" {
 			md.WriteString(fmt.Sprintf("**ASN:** %s\n\n", report.Geo.ASN))
 		}
+		if report.Geo.Prefix != "" {
+			md.WriteString(fmt.Sprintf("**Prefix:** %s\n\n", prefixLabel(report)))
+		}
+		if report.RPKI != nil {
+			md.WriteString(fmt.Sprintf("**RPKI:** %s\n\n", rpkiLabel(report.RPKI)))
+		}
+	}
+
+	// WHOIS
+	if report.Whois.Domain != "" {
+		md.WriteString(fmt.Sprintf("**Domain:** %s", report.Whois.Domain))
+		if report.Whois.Registrar != "" {
+			md.WriteString(fmt.Sprintf(" (%s)", report.Whois.Registrar))
+		}
+		md.WriteString("\n\n")
+		if report.Whois.Created != "" {
+			md.WriteString(fmt.Sprintf("**Registered:** %s\n\n", whoisDateLabel(report.Whois.Created, report.Whois.CreatedAt, report.Whois.AgeDays, false)))
+		}
+		if report.Whois.Expires != "" {
+			md.WriteString(fmt.Sprintf("**Expires:** %s\n\n", whoisDateLabel(report.Whois.Expires, report.Whois.ExpiresAt, report.Whois.DaysToExpiry, true)))
+		}
+		if alerts := domainAlerts(report); len(alerts) > 0 {
+			md.WriteString(fmt.Sprintf("**Alerts:** %s\n\n", strings.Join(alerts, ", ")))
+		}
+	}
+
+	if report.AS != nil && report.Whois.OrgName != "" {
+		md.WriteString(fmt.Sprintf("**Org:** %s\n\n", report.Whois.OrgName))
+	}
+
+	if len(report.AbuseContacts) > 0 {
+		md.WriteString(fmt.Sprintf("**Abuse:** %s\n\n", abuseContactLabel(report)))
 	}
 
 	// Ping
 	if report.Ping.Success {
-		md.WriteString(fmt.Sprintf("**Ping:** %d/%d packets, %s avg\n\n",
-			report.Ping.PacketsReceived, report.Ping.PacketsSent, report.Ping.AvgRtt))
+		md.WriteString(fmt.Sprintf("**Ping:** %d/%d packets, %s avg (%s)\n\n",
+			report.Ping.PacketsReceived, report.Ping.PacketsSent, report.Ping.AvgRtt, pingMethodLabel(report)))
+	}
+
+	// Path MTU
+	if report.MTU.PathMTU > 0 {
+		md.WriteString(fmt.Sprintf("**Path MTU:** %s\n\n", mtuSummary(report)))
+	}
+
+	// Traceroute
+	if report.Trace.Success && len(report.Trace.Hops) > 0 {
+		md.WriteString("## Path\n\n")
+		md.WriteString("| Hop | Host | RTT | Loss | AS | Country | Class |\n")
+		md.WriteString("|-----|------|-----|------|----|---------|-------|\n")
+		boundaries := false
+		for _, hop := range report.Trace.Hops {
+			host := hop.IP
+			if hop.Host != "" {
+				host = fmt.Sprintf("%s (%s)", strings.TrimSuffix(hop.Host, "."), hop.IP)
+			}
+			if len(hop.Responders) > 1 {
+				host += fmt.Sprintf(" +%d more", len(hop.Responders)-1)
+			}
+			if len(hop.MPLS) > 0 {
+				host += " (MPLS " + ui.FormatMPLS(hop.MPLS) + ")"
+			}
+			as := ""
+			if hop.ASN != "" {
+				as = ui.ASLabel(hop)
+			}
+			if hop.ASBoundary {
+				// Entering a new AS
+				as = "**" + as + "**"
+				boundaries = true
+			}
+			md.WriteString(fmt.Sprintf("| %d | %s | %s | %.0f%% | %s | %s | %s |\n",
+				hop.Hop, host, hop.RTT, hop.LossPct, as, hop.Country, hop.Class))
+		}
+		md.WriteString("\n")
+		if report.Trace.Graph != nil {
+			md.WriteString(fmt.Sprintf("**Multipath:** %s\n\n", ui.MultipathSummary(report.Trace.Graph)))
+		}
+		if boundaries {
+			md.WriteString("Bold AS entries mark where the path enters a new network.\n\n")
+		}
 	}
 
 	// Ports
@@ -380,6 +808,21 @@ func outputPlainText(report *model.Report) error {
 		}
 	}
 
+	if info := report.AS; info != nil {
+		fmt.Println()
+		fmt.Println("Autonomous System:")
+		fmt.Printf("  AS: %s\n", asLabel(info))
+		if registry := asRegistryLabel(info); registry != "" {
+			fmt.Printf("  Registry: %s\n", registry)
+		}
+		if len(info.Prefixes) > 0 {
+			fmt.Printf("  Prefixes: %d IPv4, %d IPv6\n", info.IPv4Prefixes, info.IPv6Prefixes)
+			for _, p := range asPrefixList(info) {
+				fmt.Printf("    %s\n", p)
+			}
+		}
+	}
+
 	if report.Ping.PacketsSent > 0 {
 		fmt.Println()
 		fmt.Println("Ping:")
@@ -388,6 +831,9 @@ func outputPlainText(report *model.Report) error {
 			if report.Ping.AvgRtt != "" {
 				fmt.Printf("  RTT: min %s, avg %s, max %s\n", report.Ping.MinRtt, report.Ping.AvgRtt, report.Ping.MaxRtt)
 			}
+			if report.Ping.Method != "" {
+				fmt.Printf("  Method: %s\n", pingMethodLabel(report))
+			}
 		} else {
 			fmt.Printf("  Packets: %d/%d received, %.1f%% loss (failed)\n", report.Ping.PacketsReceived, report.Ping.PacketsSent, report.Ping.PacketLossPct)
 			if report.Ping.Error != "" {
@@ -401,11 +847,27 @@ func outputPlainText(report *model.Report) error {
 		fmt.Println("Traceroute:")
 		if report.Trace.Success {
 			fmt.Printf("  Hops: %d\n", len(report.Trace.Hops))
+			if report.Trace.Graph != nil {
+				fmt.Printf("  Multipath: %s\n", ui.MultipathSummary(report.Trace.Graph))
+			}
+			for _, line := range strings.Split(ui.FormatTraceHops(report.Trace.Hops), "\n") {
+				fmt.Printf("  %s\n", line)
+			}
 		} else if report.Trace.Error != "" {
 			fmt.Printf("  Error: %s\n", report.Trace.Error)
 		}
 	}
 
+	if report.MTU.PathMTU > 0 || report.MTU.Error != "" {
+		fmt.Println()
+		fmt.Println("Path MTU:")
+		if report.MTU.PathMTU > 0 {
+			fmt.Printf("  MTU: %s\n", mtuSummary(report))
+		} else {
+			fmt.Printf("  Error: %s\n", report.MTU.Error)
+		}
+	}
+
 	if report.Whois.Domain != "" || report.Whois.NetName != "" || report.Whois.OrgName != "" {
 		fmt.Println()
 		fmt.Println("WHOIS:")
@@ -415,18 +877,47 @@ func outputPlainText(report *model.Report) error {
 		if report.Whois.Registrar != "" {
 			fmt.Printf("  Registrar: %s\n", report.Whois.Registrar)
 		}
+		if report.Whois.Created != "" {
+			fmt.Printf("  Created: %s\n", whoisDateLabel(report.Whois.Created, report.Whois.CreatedAt, report.Whois.AgeDays, false))
+		}
 		if report.Whois.Expires != "" {
-			fmt.Printf("  Expires: %s\n", report.Whois.Expires)
+			fmt.Printf("  Expires: %s\n", whoisDateLabel(report.Whois.Expires, report.Whois.ExpiresAt, report.Whois.DaysToExpiry, true))
+		}
+		if alerts := domainAlerts(report); len(alerts) > 0 {
+			fmt.Printf("  Alerts: %s\n", strings.Join(alerts, ", "))
 		}
 		if report.Whois.NetName != "" {
 			fmt.Printf("  NetName: %s\n", report.Whois.NetName)
 		}
+		if n := report.Whois.Network; n != nil {
+			fmt.Printf("  Network: %s\n", whoisNetworkLabel(n))
+		}
+		if p := report.Whois.Parent; p != nil {
+			fmt.Printf("  Parent: %s\n", whoisNetworkLabel(p))
+		}
 		if report.Whois.OrgName != "" {
 			fmt.Printf("  Org: %s\n", report.Whois.OrgName)
 		}
+		if len(report.AbuseContacts) > 0 {
+			fmt.Printf("  Abuse: %s\n", abuseContactLabel(report))
+		}
 		if report.Whois.Country != "" {
 			fmt.Printf("  Country: %s\n", report.Whois.Country)
 		}
+		if report.Whois.Source != "" {
+			source := report.Whois.Source
+			if report.Whois.Registry != "" {
+				source += " (" + strings.ToUpper(report.Whois.Registry) + ")"
+			}
+			fmt.Printf("  Source: %s\n", source)
+		}
+		if len(report.WhoisChain) > 1 {
+			servers := make([]string, 0, len(report.WhoisChain))
+			for _, resp := range report.WhoisChain {
+				servers = append(servers, resp.Server)
+			}
+			fmt.Printf("  Chain: %s\n", strings.Join(servers, " -> "))
+		}
 	}
 
 	if report.Geo.ASN != "" || report.Geo.City != "" || report.Geo.Country != "" {
@@ -442,6 +933,12 @@ func outputPlainText(report *model.Report) error {
 			}
 			fmt.Printf("  ASN: %s\n", asn)
 		}
+		if report.Geo.Prefix != "" {
+			fmt.Printf("  Prefix: %s\n", prefixLabel(report))
+		}
+		if report.RPKI != nil {
+			fmt.Printf("  RPKI: %s\n", rpkiLabel(report.RPKI))
+		}
 		var locationParts []string
 		if report.Geo.City != "" {
 			locationParts = append(locationParts, report.Geo.City)
@@ -458,6 +955,15 @@ func outputPlainText(report *model.Report) error {
 		if report.Geo.ISP != "" {
 			fmt.Printf("  ISP: %s\n", report.Geo.ISP)
 		}
+		if len(report.Geo.Sources) > 0 {
+			fmt.Printf("  Source: %s\n", geoSourceLabel(report))
+		}
+		if c := report.GeoCheck; c != nil {
+			fmt.Printf("  Confidence: %s\n", geoConfidenceLabel(c))
+			for _, f := range c.Findings {
+				fmt.Printf("  Finding: %s\n", f)
+			}
+		}
 	}
 
 	if len(report.Ports.Scanned) > 0 {
@@ -569,12 +1075,29 @@ func outputText(report *model.Report) error {
 		fmt.Println()
 	}
 
+	// Autonomous system target
+	if info := report.AS; info != nil {
+		asRows := [][]string{{labelStyle.Render("AS"), valueStyle.Render(asLabel(info))}}
+		if registry := asRegistryLabel(info); registry != "" {
+			asRows = append(asRows, []string{labelStyle.Render("Registry"), valueStyle.Render(registry)})
+		}
+		if len(info.Prefixes) > 0 {
+			asRows = append(asRows, []string{labelStyle.Render("Prefixes"), valueStyle.Render(fmt.Sprintf("%d IPv4, %d IPv6", info.IPv4Prefixes, info.IPv6Prefixes))})
+		}
+
+		fmt.Println(newTable(asRows...).Render())
+		if len(info.Prefixes) > 0 {
+			fmt.Println(valueStyle.Render(strings.Join(asPrefixList(info), "\n")))
+		}
+		fmt.Println()
+	}
+
 	// Ping statistics
 	if report.Ping.PacketsSent > 0 {
 		var pingValue string
 		if report.Ping.Success {
 			pingValue = successStyle.Render(fmt.Sprintf("%d/%d packets", report.Ping.PacketsReceived, report.Ping.PacketsSent)) +
-				valueStyle.Render(fmt.Sprintf(", %.1f%% loss, avg %s", report.Ping.PacketLossPct, report.Ping.AvgRtt))
+				valueStyle.Render(fmt.Sprintf(", %.1f%% loss, avg %s via %s", report.Ping.PacketLossPct, report.Ping.AvgRtt, pingMethodLabel(report)))
 		} else {
 			pingValue = errorStyle.Render(fmt.Sprintf("%d/%d packets", report.Ping.PacketsReceived, report.Ping.PacketsSent)) +
 				valueStyle.Render(fmt.Sprintf(", %.1f%% loss (failed)", report.Ping.PacketLossPct))
@@ -588,10 +1111,16 @@ func outputText(report *model.Report) error {
 
 	// Traceroute summary
 	if report.Trace.Success && len(report.Trace.Hops) > 0 {
-		traceValue := valueStyle.Render(fmt.Sprintf("%d hops to %s", len(report.Trace.Hops), report.Target))
+		traceSummary := fmt.Sprintf("%d hops to %s", len(report.Trace.Hops), report.Target)
+		if report.Trace.Graph != nil {
+			traceSummary += "; multipath: " + ui.MultipathSummary(report.Trace.Graph)
+		}
+		traceValue := valueStyle.Render(traceSummary)
 		traceTable := newTable([]string{labelStyle.Render("Traceroute"), traceValue})
 
 		fmt.Println(traceTable.Render())
+		// Hop lines are wider than the table; print them underneath
+		fmt.Println(valueStyle.Render(ui.FormatTraceHops(report.Trace.Hops)))
 		fmt.Println()
 	} else if report.Trace.Error != "" {
 		traceErr := errorStyle.Render(report.Trace.Error)
@@ -601,6 +1130,19 @@ func outputText(report *model.Report) error {
 		fmt.Println()
 	}
 
+	// Path MTU
+	if report.MTU.PathMTU > 0 {
+		mtuTable := newTable([]string{labelStyle.Render("Path MTU"), valueStyle.Render(mtuSummary(report))})
+
+		fmt.Println(mtuTable.Render())
+		fmt.Println()
+	} else if report.MTU.Error != "" {
+		mtuTable := newTable([]string{labelStyle.Render("Path MTU"), errorStyle.Render(report.MTU.Error)})
+
+		fmt.Println(mtuTable.Render())
+		fmt.Println()
+	}
+
 	// WHOIS info
 	if report.Whois.Domain != "" || report.Whois.NetName != "" {
 		var whoisValue strings.Builder
@@ -610,7 +1152,7 @@ func outputText(report *model.Report) error {
 				whoisValue.WriteString(fmt.Sprintf(" (%s)", report.Whois.Registrar))
 			}
 			if report.Whois.Expires != "" {
-				whoisValue.WriteString(fmt.Sprintf(" expires %s", report.Whois.Expires))
+				whoisValue.WriteString(fmt.Sprintf(" expires %s", whoisDateLabel(report.Whois.Expires, report.Whois.ExpiresAt, report.Whois.DaysToExpiry, true)))
 			}
 		} else {
 			whoisValue.WriteString(report.Whois.NetName)
@@ -620,9 +1162,16 @@ func outputText(report *model.Report) error {
 			if report.Whois.Country != "" {
 				whoisValue.WriteString(fmt.Sprintf(" [%s]", report.Whois.Country))
 			}
+			if len(report.AbuseContacts) > 0 {
+				whoisValue.WriteString(" abuse " + abuseContactLabel(report))
+			}
 		}
 
-		whoisTable := newTable([]string{labelStyle.Render("WHOIS"), valueStyle.Render(whoisValue.String())})
+		whoisRows := [][]string{{labelStyle.Render("WHOIS"), valueStyle.Render(whoisValue.String())}}
+		if alerts := domainAlerts(report); len(alerts) > 0 {
+			whoisRows = append(whoisRows, []string{labelStyle.Render("Alerts"), errorStyle.Render(strings.Join(alerts, ", "))})
+		}
+		whoisTable := newTable(whoisRows...)
 
 		fmt.Println(whoisTable.Render())
 		fmt.Println()
@@ -647,6 +1196,12 @@ func outputText(report *model.Report) error {
 			}
 			geoRows = append(geoRows, []string{labelStyle.Render("ASN"), valueStyle.Render(asnValue)})
 		}
+		if report.Geo.Prefix != "" {
+			geoRows = append(geoRows, []string{labelStyle.Render("Prefix"), valueStyle.Render(prefixLabel(report))})
+		}
+		if report.RPKI != nil {
+			geoRows = append(geoRows, []string{labelStyle.Render("RPKI"), valueStyle.Render(rpkiLabel(report.RPKI))})
+		}
 
 		if report.Geo.City != "" {
 			loc := report.Geo.City
@@ -661,6 +1216,15 @@ func outputText(report *model.Report) error {
 			}
 			geoRows = append(geoRows, []string{labelStyle.Render("Location"), valueStyle.Render(loc)})
 		}
+		if len(report.Geo.Sources) > 0 {
+			geoRows = append(geoRows, []string{labelStyle.Render("Geo Source"), valueStyle.Render(geoSourceLabel(report))})
+		}
+		if c := report.GeoCheck; c != nil {
+			geoRows = append(geoRows, []string{labelStyle.Render("Confidence"), valueStyle.Render(geoConfidenceLabel(c))})
+			for _, f := range c.Findings {
+				geoRows = append(geoRows, []string{labelStyle.Render("Finding"), errorStyle.Render(f)})
+			}
+		}
 
 		if len(geoRows) > 0 {
 			geoTable := newTable(geoRows...)
@@ -703,6 +1267,224 @@ func outputText(report *model.Report) error {
 	return nil
 }
 
+// whoisNetworkLabel describes a registered network as
+// "range (name, handle)", leaving out whatever is missing
+func whoisNetworkLabel(n *model.WhoisNetwork) string {
+	var names []string
+	for _, v := range []string{n.Name, n.Handle} {
+		if v != "" {
+			names = append(names, v)
+		}
+	}
+	switch {
+	case n.Range == "":
+		return strings.Join(names, ", ")
+	case len(names) == 0:
+		return n.Range
+	}
+	return fmt.Sprintf("%s (%s)", n.Range, strings.Join(names, ", "))
+}
+
+// prefixLabel describes the announced prefix, e.g.
+// "8.8.8.0/24 (arin, allocated 2023-12-28)"
+func prefixLabel(report *model.Report) string {
+	var details []string
+	if report.Geo.Registry != "" {
+		details = append(details, report.Geo.Registry)
+	}
+	if report.Geo.Allocated != "" {
+		details = append(details, "allocated "+report.Geo.Allocated)
+	}
+	if len(details) == 0 {
+		return report.Geo.Prefix
+	}
+	return fmt.Sprintf("%s (%s)", report.Geo.Prefix, strings.Join(details, ", "))
+}
+
+// geoConfidenceLabel gives the confidence score and what the RTT
+// allows, e.g. "20/100 (1.9ms from Frankfurt: within 190 km, claimed
+// 16480 km)"
+func geoConfidenceLabel(c *model.GeoCheck) string {
+	label := fmt.Sprintf("%d/100", c.Confidence)
+	if c.Vantage == "" {
+		return label
+	}
+	return fmt.Sprintf("%s (%gms from %s: within %.0f km, claimed %.0f km)",
+		label, c.MinRTTMs, c.Vantage, c.MaxDistanceKm, c.DistanceKm)
+}
+
+// geoSourceLabel names the geolocation providers and what each
+// supplied, e.g. "mmdb (city, country, lat, lon), ip-api (isp)"
+func geoSourceLabel(report *model.Report) string {
+	byProvider := make(map[string][]string)
+	var providers []string
+	for _, field := range []string{"city", "region", "region_code", "country", "country_code", "lat", "lon", "timezone", "org", "isp"} {
+		p, ok := report.Geo.Sources[field]
+		if !ok {
+			continue
+		}
+		if _, seen := byProvider[p]; !seen {
+			providers = append(providers, p)
+		}
+		byProvider[p] = append(byProvider[p], field)
+	}
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	parts := make([]string, 0, len(providers))
+	for _, p := range providers {
+		parts = append(parts, fmt.Sprintf("%s (%s)", p, strings.Join(byProvider[p], ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// asPrefixLimit caps the prefixes listed for an AS target outside JSON
+const asPrefixLimit = 20
+
+// asLabel names an AS target, e.g. "AS15169 (GOOGLE) [US]"
+func asLabel(info *model.ASInfo) string {
+	label := "AS" + info.ASN
+	if info.Name != "" {
+		label += " (" + info.Name + ")"
+	}
+	if info.Country != "" {
+		label += " [" + info.Country + "]"
+	}
+	return label
+}
+
+// asRegistryLabel gives the RIR and allocation date, e.g.
+// "ARIN, allocated 2000-03-30"
+func asRegistryLabel(info *model.ASInfo) string {
+	var parts []string
+	if info.Registry != "" {
+		parts = append(parts, strings.ToUpper(info.Registry))
+	}
+	if info.Allocated != "" {
+		parts = append(parts, "allocated "+info.Allocated)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// asPrefixList returns the first prefixes of an AS target, with a note
+// of how many more the JSON output holds
+func asPrefixList(info *model.ASInfo) []string {
+	if len(info.Prefixes) <= asPrefixLimit {
+		return info.Prefixes
+	}
+	list := append([]string{}, info.Prefixes[:asPrefixLimit]...)
+	return append(list, fmt.Sprintf("... %d more (--output json lists all)", len(info.Prefixes)-asPrefixLimit))
+}
+
+// rpkiLabel gives the validation state and the ROA behind it, e.g.
+// "valid (AS15169 8.8.8.0/24 max /24)" or "invalid: origin AS not
+// authorised (ROA AS13335 1.1.1.0/24 max /24)"
+func rpkiLabel(r *model.RPKIResult) string {
+	label := r.State
+	if r.Reason != "" {
+		label += ": " + r.Reason
+	}
+	if r.Approximate {
+		label += " for " + r.Prefix + ", which may not be the announced route"
+	}
+	if len(r.ROAs) == 0 {
+		return label
+	}
+	roa := r.ROAs[0]
+	detail := fmt.Sprintf("AS%s %s max /%d", roa.ASN, roa.Prefix, roa.MaxLength)
+	if r.State == "invalid" {
+		detail = "ROA " + detail
+	}
+	if more := len(r.ROAs) - 1; more > 0 {
+		detail += fmt.Sprintf(", +%d more", more)
+	}
+	return fmt.Sprintf("%s (%s)", label, detail)
+}
+
+// abuseContactLabel shows the best abuse contact and where it came
+// from, e.g. "abuse@ripe.net (abuse-c)"
+func abuseContactLabel(report *model.Report) string {
+	c := report.AbuseContacts[0]
+	return fmt.Sprintf("%s (%s)", c.Email, c.Source)
+}
+
+// domainAlerts describes the WHOIS date flags, e.g.
+// "newly registered (12 days old)" or "expiring in 5 days"
+func domainAlerts(report *model.Report) []string {
+	w := report.Whois
+	var alerts []string
+	if w.NewlyRegistered && w.AgeDays != nil {
+		alerts = append(alerts, fmt.Sprintf("newly registered (%s old)", dayCount(*w.AgeDays)))
+	}
+	if w.ExpiringSoon && w.DaysToExpiry != nil {
+		if *w.DaysToExpiry < 0 {
+			alerts = append(alerts, fmt.Sprintf("expired %s ago", dayCount(-*w.DaysToExpiry)))
+		} else {
+			alerts = append(alerts, fmt.Sprintf("expiring in %s", dayCount(*w.DaysToExpiry)))
+		}
+	}
+	return alerts
+}
+
+// whoisDateLabel shows a normalised date with the day count from
+// now, falling back to the registry's own text
+func whoisDateLabel(raw string, t time.Time, days *int, future bool) string {
+	if t.IsZero() {
+		return raw
+	}
+	label := t.Format("2006-01-02")
+	if days == nil {
+		return label
+	}
+	switch {
+	case !future:
+		return fmt.Sprintf("%s (%s ago)", label, dayCount(*days))
+	case *days < 0:
+		return fmt.Sprintf("%s (expired %s ago)", label, dayCount(-*days))
+	default:
+		return fmt.Sprintf("%s (in %s)", label, dayCount(*days))
+	}
+}
+
+func dayCount(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
+
+// mtuSummary describes the discovered path MTU, e.g.
+// "1420 (udp, Fragmentation Needed from 10.0.0.1)".
+func mtuSummary(report *model.Report) string {
+	details := []string{report.MTU.Method}
+	if report.MTU.FragNeededFrom != "" {
+		details = append(details, "Fragmentation Needed from "+report.MTU.FragNeededFrom)
+	}
+	if report.MTU.BlackHole {
+		details = append(details, "possible MTU black hole")
+	}
+	if report.MTU.Incomplete {
+		details = append(details, "search cut short, at least this")
+	}
+	summary := fmt.Sprintf("%d (%s)", report.MTU.PathMTU, strings.Join(details, ", "))
+	if report.MTU.LocalMTU > 0 && report.MTU.LocalMTU != report.MTU.PathMTU {
+		summary += fmt.Sprintf(", local MTU %d", report.MTU.LocalMTU)
+	}
+	return summary
+}
+
+// pingMethodLabel describes how the ping stats were measured, e.g. "tcp/443".
+func pingMethodLabel(report *model.Report) string {
+	switch report.Ping.Method {
+	case "":
+		return "icmp"
+	case collector.PingMethodTCP:
+		return fmt.Sprintf("%s/%d", report.Ping.Method, report.Ping.Port)
+	default:
+		return report.Ping.Method
+	}
+}
+
 func validateTarget(target string) (string, error) {
 	target = strings.TrimSpace(target)
 	if target == "" {
@@ -712,6 +1494,10 @@ func validateTarget(target string) (string, error) {
 }
 
 func validateFlags() error {
+	if newDomainDays < 1 || expiryWarnDays < 1 {
+		return fmt.Errorf("--new-domain-days and --expiry-warn-days must be at least 1")
+	}
+
 	// Validate output format
 	validOutputs := []string{"text", "md", "json", "raw"}
 	valid := false
@@ -725,6 +1511,16 @@ func validateFlags() error {
 		return fmt.Errorf("invalid output format: %s (valid: %s)", output, strings.Join(validOutputs, ", "))
 	}
 
+	// Validate ping mode
+	switch pingMode {
+	case collector.PingModeAuto, collector.PingModeICMP, collector.PingModeTCP, collector.PingModeHTTP:
+	default:
+		return fmt.Errorf("invalid ping mode: %s (valid: auto, icmp, tcp, http)", pingMode)
+	}
+	if pingPort < 0 || pingPort > 65535 {
+		return fmt.Errorf("ping port must be between 0 and 65535")
+	}
+
 	// Validate timeout
 	if timeout < 1*time.Second || timeout > 5*time.Minute {
 		return fmt.Errorf("timeout must be between 1s and 5m")
@@ -740,8 +1536,55 @@ func validateFlags() error {
 
 // Config file location: ~/.config/netgaze/config.json
 type Config struct {
-	DefaultTimeout string `json:"default_timeout"`
-	EnablePorts    bool   `json:"enable_ports"`
+	DefaultTimeout string            `json:"default_timeout"`
+	EnablePorts    bool              `json:"enable_ports"`
+	WhoisServers   map[string]string `json:"whois_servers,omitempty"`
+	WhoisTimeout   string            `json:"whois_timeout,omitempty"`
+	RPKIVRPFile    string            `json:"rpki_vrp_file,omitempty"`
+	Geo            GeoConfig         `json:"geo,omitzero"`
+}
+
+// GeoConfig chooses and configures the geolocation providers
+type GeoConfig struct {
+	Providers   []string       `json:"providers,omitempty"` // fallback chain, first asked first
+	IPAPIKey    string         `json:"ipapi_key,omitempty"` // ip-api pro
+	IPInfoToken string         `json:"ipinfo_token,omitempty"`
+	MMDBPaths   pathList       `json:"mmdb_path,omitempty"` // MaxMind or DB-IP lite City/Country/ASN files
+	Vantage     *VantageConfig `json:"vantage,omitempty"`   // where netgaze runs, for the RTT check
+}
+
+// VantageConfig is the known location of the machine netgaze runs on
+type VantageConfig struct {
+	Name string  `json:"name,omitempty"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// pathList is one path or a list of them in the config file
+type pathList []string
+
+func (p *pathList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*p = pathList{one}
+		if one == "" {
+			*p = nil
+		}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("geo.mmdb_path: expected a path or a list of paths")
+	}
+	*p = many
+	return nil
+}
+
+func (p pathList) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 {
+		return json.Marshal(p[0])
+	}
+	return json.Marshal([]string(p))
 }
 
 func getConfigPath() string {
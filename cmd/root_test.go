@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/ui"
+)
+
+// tuiRun is what a stubbed TUI saw: the target, the options the
+// collection ran with and the screen it rendered
+type tuiRun struct {
+	target string
+	opts   collector.Options
+	view   string
+}
+
+// runTUICommand runs `ng tui` with args against a canned report. The
+// stubbed TUI collects through the command's CollectFunc and renders the
+// result with the real model.
+func runTUICommand(t *testing.T, report *model.Report, args ...string) *tuiRun {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	run := &tuiRun{}
+	oldRun, oldCollect := runTUI, collectReport
+	t.Cleanup(func() { runTUI, collectReport = oldRun, oldCollect })
+
+	collectReport = func(ctx context.Context, target string, opts collector.Options) (*model.Report, error) {
+		run.opts = opts
+		return report, nil
+	}
+	runTUI = func(target string, noAgent bool, enablePorts bool, timeout time.Duration, collect ui.CollectFunc) error {
+		run.target = target
+		got, err := collect(context.Background())
+		if err != nil {
+			return err
+		}
+		m := ui.InitialModel(target, noAgent, enablePorts, timeout)
+		m.SetReport(got)
+		next, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 60})
+		run.view = next.(ui.Model).View()
+		return nil
+	}
+
+	rootCmd.SetArgs(append([]string{"tui"}, args...))
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("ng tui error = %v", err)
+	}
+	if run.target == "" {
+		t.Fatal("ng tui did not start the TUI")
+	}
+	return run
+}
+
+func tuiTraceReport() *model.Report {
+	report := &model.Report{Target: "192.0.2.1", Errors: map[string]string{}}
+	report.Trace.Hops = []model.TraceHop{
+		{Hop: 1, IP: "192.168.1.1", RTT: "1.2ms", Class: "private"},
+		{
+			Hop: 2, IP: "203.0.113.9", RTT: "9.8ms", ASN: "64500", ASName: "EXAMPLE-TRANSIT", ASBoundary: true,
+			MPLS:       []model.MPLSLabel{{Label: 24000, TTL: 1, Bottom: true}},
+			Interfaces: []model.HopInterface{{Role: "incoming", Name: "xe-0/0/1", IP: "10.1.1.1", MTU: 9000}},
+		},
+	}
+	return report
+}
+
+func TestTUICommandShowsTrace(t *testing.T) {
+	run := runTUICommand(t, tuiTraceReport(), "192.0.2.1")
+	if run.target != "192.0.2.1" {
+		t.Errorf("TUI started for %q", run.target)
+	}
+	for _, want := range []string{
+		"== AS64500 EXAMPLE-TRANSIT ==",
+		"MPLS 24000 ttl=1 S",
+		"incoming if xe-0/0/1 10.1.1.1 mtu 9000",
+	} {
+		if !strings.Contains(run.view, want) {
+			t.Errorf("ng tui view is missing %q:\n%s", want, run.view)
+		}
+	}
+}
+
+func TestTUICommandHonoursCollectionFlags(t *testing.T) {
+	t.Cleanup(func() {
+		pingMode, pingPort, enableMTU, traceProto, traceMaxHops = "auto", 0, false, collector.TraceProtoUDP, 30
+	})
+
+	run := runTUICommand(t, tuiTraceReport(), "192.0.2.1",
+		"--ping-mode", "tcp", "--ping-port", "443", "--mtu", "--trace-proto", "icmp", "--max-hops", "12")
+	o := run.opts
+	if o.Ping.Mode != collector.PingModeTCP || o.Ping.Port != 443 || !o.EnableMTU {
+		t.Errorf("Expected the ping and MTU flags in the collection options, got %+v", o)
+	}
+	if o.Trace.Protocol != collector.TraceProtoICMP || o.Trace.MaxHops != 12 {
+		t.Errorf("Expected the trace flags in the collection options, got %+v", o.Trace)
+	}
+}
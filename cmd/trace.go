@@ -0,0 +1,398 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/history"
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/ui"
+)
+
+var (
+	traceHistoryLimit    int
+	traceHistoryTimeline bool
+
+	traceImportFormat   string
+	traceImportTarget   string
+	traceImportOutFile  string
+	traceImportNoEnrich bool
+	traceImportNoSave   bool
+
+	traceExportFormat  string
+	traceExportOutFile string
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace",
+	Short: "Work with stored traceroutes",
+	Long: `Commands for the traceroute history written by ng to and ng tc.
+
+Runs are stored per target under $XDG_DATA_HOME/netgaze/traces
+(default ~/.local/share/netgaze/traces).`,
+}
+
+var traceHistoryCmd = &cobra.Command{
+	Use:   "history [flags] [ip|domain|url]",
+	Short: "List stored traceroutes and path changes for a target",
+	Long: `List the stored traceroute runs for a target, oldest first, with a note
+on each run that differs from the one before it. With --timeline the full
+set of changes between consecutive runs is printed as well.
+
+Without a target, the targets that have stored runs are listed.
+
+Examples:
+  ng trace history
+  ng trace history example.com
+  ng trace history 1.1.1.1 --timeline --limit 50`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTraceHistory,
+}
+
+var traceImportCmd = &cobra.Command{
+	Use:   "import [flags] <file>",
+	Short: "Import a traceroute saved by another tool",
+	Long: `Parse traceroute output from another tool, enrich the hops with PTR
+names, ASN and country, print them and store them in the trace history so
+ng tc and ng trace history treat them like netgaze's own runs.
+
+Supported formats (detected automatically unless --format is given):
+  mtr         mtr --json
+  tracert     Windows tracert text
+  traceroute  BSD, macOS or Linux traceroute text
+  scamper     scamper warts-json (sc_warts2json), one or more traces
+
+Examples:
+  ng trace import customer-mtr.json
+  ng trace import tracert.txt --target example.com
+  ng trace import trace.txt -o baseline.json --no-save
+  ng tc example.com --base baseline.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTraceImport,
+}
+
+var traceExportCmd = &cobra.Command{
+	Use:   "export [flags] <target|file>...",
+	Short: "Draw traceroute paths as a Graphviz or Mermaid graph",
+	Long: `Render one or more traceroutes as a Graphviz DOT or Mermaid graph for
+incident reports. Each argument is either a file (a stored run, an
+ng to -o hop list, or any format ng trace import reads) or a target whose
+latest stored run is used.
+
+Hops answered by the same address are merged into one node, labelled with
+its address, name, AS and RTT. With several traces, edges are coloured
+per trace.
+
+Examples:
+  ng trace export example.com | dot -Tsvg > path.svg
+  ng trace export example.com 1.1.1.1 --format mermaid
+  ng trace export before.json after.json -o paths.dot`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runTraceExport,
+}
+
+func init() {
+	traceExportCmd.Flags().StringVar(&traceExportFormat, "format", collector.PathFormatDOT,
+		"Graph format: dot, mermaid")
+	traceExportCmd.Flags().StringVarP(&traceExportOutFile, "out", "o", "",
+		"Write the graph to this file instead of stdout")
+	traceCmd.AddCommand(traceExportCmd)
+
+	traceImportCmd.Flags().StringVar(&traceImportFormat, "format", "",
+		"Input format: mtr, tracert, traceroute, scamper (default: detect)")
+	traceImportCmd.Flags().StringVar(&traceImportTarget, "target", "",
+		"Target the trace was run against (default: taken from the file)")
+	traceImportCmd.Flags().StringVarP(&traceImportOutFile, "out", "o", "",
+		"Also write the hop list to this JSON file (usable as ng tc --base)")
+	traceImportCmd.Flags().BoolVar(&traceImportNoEnrich, "no-enrich", false,
+		"Skip PTR, ASN and country lookups")
+	traceImportCmd.Flags().BoolVar(&traceImportNoSave, "no-save", false,
+		"Do not store the trace in the trace history")
+	traceCmd.AddCommand(traceImportCmd)
+
+	traceHistoryCmd.Flags().IntVar(&traceHistoryLimit, "limit", 0,
+		"Only show the most recent N runs (default: all)")
+	traceHistoryCmd.Flags().BoolVar(&traceHistoryTimeline, "timeline", false,
+		"Print every path change between consecutive runs")
+	traceCmd.AddCommand(traceHistoryCmd)
+}
+
+func runTraceHistory(cmd *cobra.Command, args []string) error {
+	store, err := historyStore()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		targets, err := store.Targets()
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			fmt.Printf("No stored traceroutes in %s\n", store.Dir())
+			return nil
+		}
+		for _, t := range targets {
+			fmt.Println(t)
+		}
+		return nil
+	}
+
+	normalizedTarget, err := validateTarget(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	entries, err := store.List(normalizedTarget)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No stored traceroutes for %s\n", normalizedTarget)
+		return nil
+	}
+	if traceHistoryLimit > 0 && len(entries) > traceHistoryLimit {
+		entries = entries[len(entries)-traceHistoryLimit:]
+	}
+
+	runs := make([]*model.TraceRun, 0, len(entries))
+	for _, e := range entries {
+		run, err := history.Load(e.Path)
+		if err != nil {
+			return err
+		}
+		run.Timestamp = e.Timestamp
+		runs = append(runs, run)
+	}
+
+	changes := history.Timeline(runs, collector.TraceDiffOptions{})
+	changedAt := make(map[int]*collector.TraceDiff, len(changes))
+	for _, c := range changes {
+		changedAt[c.Index] = c.Diff
+	}
+
+	fmt.Printf("Traceroute history for %s (%d runs)\n", normalizedTarget, len(runs))
+	for i, run := range runs {
+		note := ""
+		if diff, ok := changedAt[i]; ok {
+			note = "  changed: " + traceChangeSummary(diff)
+		}
+		line := fmt.Sprintf("  %s  %2d hops  %-11s %s%s",
+			run.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			len(run.Hops), traceReachLabel(run.Hops), traceASPathLabel(run.Hops), note)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+
+	if !traceHistoryTimeline {
+		return nil
+	}
+
+	fmt.Println()
+	if len(changes) == 0 {
+		fmt.Println("Timeline: no path changes")
+		return nil
+	}
+	fmt.Println("Timeline:")
+	for _, c := range changes {
+		fmt.Printf("  %s -> %s\n",
+			c.From.Local().Format("2006-01-02 15:04:05"), c.To.Local().Format("2006-01-02 15:04:05"))
+		for _, line := range strings.Split(collector.FormatTraceDiff(c.Diff), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	return nil
+}
+
+func traceReachLabel(hops []model.TraceHop) string {
+	if len(hops) == 0 || hops[len(hops)-1].Timeout {
+		return "unreached"
+	}
+	return "reached"
+}
+
+func traceASPathLabel(hops []model.TraceHop) string {
+	var path []string
+	for _, h := range hops {
+		if h.ASN != "" && (len(path) == 0 || path[len(path)-1] != "AS"+h.ASN) {
+			path = append(path, "AS"+h.ASN)
+		}
+	}
+	return strings.Join(path, " ")
+}
+
+// traceChangeSummary condenses a diff to a few words, e.g.
+// "2 hops, AS path"
+func traceChangeSummary(d *collector.TraceDiff) string {
+	hops := 0
+	for _, h := range d.Hops {
+		switch h.Kind {
+		case collector.HopChanged, collector.HopInserted, collector.HopRemoved:
+			hops++
+		}
+	}
+
+	var parts []string
+	if hops == 1 {
+		parts = append(parts, "1 hop")
+	} else if hops > 1 {
+		parts = append(parts, fmt.Sprintf("%d hops", hops))
+	}
+	if d.ASPathChanged {
+		parts = append(parts, "AS path")
+	}
+	if d.DestinationLost {
+		parts = append(parts, "destination lost")
+	}
+	if d.DestinationRegression {
+		parts = append(parts, "latency")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func runTraceImport(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read traceroute file: %w", err)
+	}
+
+	runs, err := collector.ImportTraces(data, traceImportFormat)
+	if err != nil {
+		return err
+	}
+	if traceImportOutFile != "" && len(runs) > 1 {
+		return fmt.Errorf("%s holds %d traces; --out needs a single trace", path, len(runs))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	for i, run := range runs {
+		if traceImportTarget != "" {
+			run.Target = traceImportTarget
+		}
+		if run.Timestamp.IsZero() {
+			// Only scamper records when the trace ran
+			run.Timestamp = info.ModTime()
+		}
+
+		if !traceImportNoEnrich {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			collector.EnrichTraceHops(ctx, run.Hops)
+			cancel()
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		label := run.Target
+		if label == "" {
+			label = "unknown target"
+		}
+		fmt.Printf("Traceroute to %s (%d hops)\n", label, len(run.Hops))
+		fmt.Println(ui.FormatTraceHops(run.Hops))
+
+		if !traceImportNoSave {
+			if run.Target == "" {
+				return fmt.Errorf("%s does not name the target; use --target or --no-save", path)
+			}
+			normalizedTarget, err := validateTarget(run.Target)
+			if err != nil {
+				return fmt.Errorf("invalid target: %w", err)
+			}
+			run.Target = normalizedTarget
+
+			saved, err := saveTraceRun(run)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Traceroute saved to %s\n", saved)
+		}
+
+		if traceImportOutFile != "" {
+			if err := writeTraceHops(traceImportOutFile, run.Hops); err != nil {
+				return err
+			}
+			fmt.Printf("Traceroute saved to %s\n", traceImportOutFile)
+		}
+	}
+	return nil
+}
+
+func runTraceExport(cmd *cobra.Command, args []string) error {
+	var runs []*model.TraceRun
+	for _, arg := range args {
+		loaded, err := loadExportRuns(arg)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, loaded...)
+	}
+
+	graph, err := collector.FormatPaths(runs, traceExportFormat)
+	if err != nil {
+		return err
+	}
+
+	if traceExportOutFile == "" {
+		fmt.Print(graph)
+		return nil
+	}
+	if err := os.WriteFile(traceExportOutFile, []byte(graph), 0644); err != nil {
+		return fmt.Errorf("failed to write graph: %w", err)
+	}
+	fmt.Printf("Graph saved to %s\n", traceExportOutFile)
+	return nil
+}
+
+// loadExportRuns reads the traces named by arg: a file if one exists at
+// that path, otherwise the latest stored run for a target
+func loadExportRuns(arg string) ([]*model.TraceRun, error) {
+	if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+		// Other tools' JSON also decodes as a run, just without hops
+		if run, err := history.Load(arg); err == nil && len(run.Hops) > 0 {
+			if run.Target == "" {
+				run.Target = filepath.Base(arg)
+			}
+			return []*model.TraceRun{run}, nil
+		}
+
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read traceroute file: %w", err)
+		}
+		runs, err := collector.ImportTraces(data, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		return runs, nil
+	}
+
+	normalizedTarget, err := validateTarget(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	store, err := historyStore()
+	if err != nil {
+		return nil, err
+	}
+	run, _, err := store.Latest(normalizedTarget)
+	if errors.Is(err, history.ErrNoRuns) {
+		return nil, fmt.Errorf("no stored traceroute for %s; run ng to %s first", normalizedTarget, normalizedTarget)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if run.Target == "" {
+		run.Target = normalizedTarget
+	}
+	return []*model.TraceRun{run}, nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/ui"
+)
+
+var (
+	watchMode        string
+	watchPort        int
+	watchInterval    time.Duration
+	watchWindow      int
+	watchCount       int
+	watchReportEvery time.Duration
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [flags] <ip|domain|url>",
+	Short: "Continuously ping a target with rolling statistics",
+	Long: `Ping a target on an interval until interrupted, keeping rolling loss,
+latency percentiles and outage windows.
+
+On a terminal a live view with a latency sparkline is shown. When output
+is piped, one NDJSON stats line is written every --report-every.
+
+Examples:
+  ng watch 1.1.1.1
+  ng watch example.com --mode tcp --port 443
+  ng watch 8.8.8.8 --report-every 1m >> watch.ndjson`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&watchMode, "mode", "auto",
+		"Probe method: auto (ICMP, TCP if ICMP is blocked), icmp, tcp")
+	watchCmd.Flags().IntVar(&watchPort, "port", 0,
+		"TCP port for tcp probes (default: first responsive common port)")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 1*time.Second,
+		"Time between probes")
+	watchCmd.Flags().IntVar(&watchWindow, "window", 300,
+		"Number of recent probes used for loss and latency stats")
+	watchCmd.Flags().IntVar(&watchCount, "count", 0,
+		"Stop after this many probes (default: run until interrupted)")
+	watchCmd.Flags().DurationVar(&watchReportEvery, "report-every", 10*time.Second,
+		"Interval between NDJSON lines when output is not a terminal")
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	normalizedTarget, err := validateTarget(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+
+	switch watchMode {
+	case collector.PingModeAuto, collector.PingModeICMP, collector.PingModeTCP:
+	default:
+		return fmt.Errorf("invalid watch mode: %s (valid: auto, icmp, tcp)", watchMode)
+	}
+	if watchInterval < 100*time.Millisecond {
+		return fmt.Errorf("interval must be at least 100ms")
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	watcher, err := collector.NewWatcher(ctx, normalizedTarget, collector.WatchOptions{
+		Mode:     watchMode,
+		Port:     watchPort,
+		Interval: watchInterval,
+		Window:   watchWindow,
+		Count:    watchCount,
+	})
+	if err != nil {
+		return fmt.Errorf("watch failed: %w", err)
+	}
+
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		return runWatchTUI(ctx, normalizedTarget, watcher)
+	}
+
+	return runWatchNDJSON(ctx, watcher)
+}
+
+func runWatchTUI(ctx context.Context, target string, watcher *collector.Watcher) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	updates := make(chan model.WatchStats, 16)
+	go func() {
+		defer close(updates)
+		watcher.Run(ctx, func(stats model.WatchStats) {
+			select {
+			case updates <- stats:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return ui.RunWatchTUI(target, updates)
+}
+
+func runWatchNDJSON(ctx context.Context, watcher *collector.Watcher) error {
+	enc := json.NewEncoder(os.Stdout)
+	lastReport := time.Now()
+	reported := 0
+
+	err := watcher.Run(ctx, func(stats model.WatchStats) {
+		if time.Since(lastReport) < watchReportEvery {
+			return
+		}
+		lastReport = time.Now()
+		reported = stats.Sent
+		enc.Encode(stats)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Always finish with a summary line covering the last probes
+	final := watcher.Snapshot()
+	if final.Sent == reported {
+		return nil
+	}
+	return enc.Encode(final)
+}
@@ -0,0 +1,281 @@
+// Package asndb is an offline IP-to-ASN database: a path-compressed
+// radix trie built from public routing datasets and stored in a flat
+// file that is searched in place, without decoding it first.
+package asndb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File layout, all integers little-endian:
+//
+//	header   magic[8] built(int64) nodes(u32) ases(u32) names(u32) prefixes(u32) sourceLen(u16) source
+//	nodes    key[16] bits(u8) pad[3] as(u32) child0(u32) child1(u32)
+//	ases     asn(u32) country[2] nameLen(u16) nameOff(u32)
+//	names    concatenated AS names
+//
+// IPv4 prefixes are stored IPv4-mapped (::ffff:0:0/96). Node 0 is the
+// root; a child index of 0 means no child and an AS index of 0 no value.
+const (
+	magic      = "NGASNDB1"
+	headerSize = 8 + 8 + 4*4 + 2
+	nodeSize   = 32
+	asSize     = 12
+)
+
+// ErrNotFound is returned by OpenDefault when no database has been built
+var ErrNotFound = errors.New("no ASN database; build one with ng db update --from <file>")
+
+// Record is what the database knows about an address
+type Record struct {
+	Prefix  netip.Prefix // most specific prefix containing the address
+	ASN     uint32
+	Country string // registered country of the AS, when the dataset has it
+	Name    string // AS description, when the dataset has it
+}
+
+// AS is what the database knows about one autonomous system
+type AS struct {
+	ASN      uint32
+	Country  string
+	Name     string
+	Prefixes []netip.Prefix // every prefix it originates, in address order
+}
+
+// Info describes a database file
+type Info struct {
+	Built    time.Time
+	Prefixes int
+	ASNs     int
+	Source   string // files it was built from
+}
+
+// DB is an opened database
+type DB struct {
+	nodes []byte
+	ases  []byte
+	names []byte
+	info  Info
+}
+
+// DefaultPath returns $XDG_DATA_HOME/netgaze/asn.db, falling back to
+// ~/.local/share/netgaze/asn.db
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "netgaze", "asn.db"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "netgaze", "asn.db"), nil
+}
+
+// OpenDefault opens the database at DefaultPath
+func OpenDefault() (*DB, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	db, err := Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return db, err
+}
+
+// Open reads a database file
+func Open(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// Parse checks a database image and returns it ready for lookups. The
+// whole trie is validated here so Lookup never reads out of bounds.
+func Parse(data []byte) (*DB, error) {
+	if len(data) < headerSize || string(data[:8]) != magic {
+		return nil, errors.New("not an ASN database")
+	}
+	le := binary.LittleEndian
+	nodeCount := int(le.Uint32(data[16:]))
+	asCount := int(le.Uint32(data[20:]))
+	namesLen := int(le.Uint32(data[24:]))
+	prefixes := int(le.Uint32(data[28:]))
+	sourceLen := int(le.Uint16(data[32:]))
+
+	off := headerSize + sourceLen
+	want := off + nodeCount*nodeSize + asCount*asSize + namesLen
+	if nodeCount == 0 || len(data) != want {
+		return nil, fmt.Errorf("truncated ASN database (%d bytes, want %d)", len(data), want)
+	}
+
+	db := &DB{
+		info: Info{
+			Built:    time.Unix(int64(le.Uint64(data[8:])), 0).UTC(),
+			Prefixes: prefixes,
+			ASNs:     asCount,
+			Source:   string(data[headerSize:off]),
+		},
+	}
+	db.nodes = data[off : off+nodeCount*nodeSize]
+	off += nodeCount * nodeSize
+	db.ases = data[off : off+asCount*asSize]
+	off += asCount * asSize
+	db.names = data[off:]
+
+	// Nodes are numbered depth-first, so a child always comes after its
+	// parent; anything else could send Lookup round in a loop
+	for i := 0; i < nodeCount; i++ {
+		n := db.nodes[i*nodeSize:]
+		if n[16] > 128 || int(le.Uint32(n[20:])) > asCount ||
+			!validChild(le.Uint32(n[24:]), i, nodeCount) || !validChild(le.Uint32(n[28:]), i, nodeCount) {
+			return nil, fmt.Errorf("corrupt ASN database node %d", i)
+		}
+	}
+	for i := 0; i < asCount; i++ {
+		a := db.ases[i*asSize:]
+		if int(le.Uint32(a[8:]))+int(le.Uint16(a[6:])) > namesLen {
+			return nil, fmt.Errorf("corrupt ASN database entry %d", i)
+		}
+	}
+	return db, nil
+}
+
+// validChild reports whether child is a usable link from node i; 0 means
+// no child, as the root is never one
+func validChild(child uint32, i, nodeCount int) bool {
+	return child == 0 || (int(child) > i && int(child) < nodeCount)
+}
+
+// Info describes the database
+func (db *DB) Info() Info {
+	return db.info
+}
+
+// Lookup returns the most specific prefix containing addr
+func (db *DB) Lookup(addr netip.Addr) (Record, bool) {
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return Record{}, false
+	}
+	key := addr.As16()
+	le := binary.LittleEndian
+
+	best, bestNode := uint32(0), 0
+	idx := 0
+	for {
+		n := db.nodes[idx*nodeSize:]
+		bits := int(n[16])
+		if commonBits(key[:], n[:16], bits) < bits {
+			break
+		}
+		// A v6 route covering ::ffff:0:0/96 does not route IPv4
+		if as := le.Uint32(n[20:]); as != 0 && (!addr.Is4() || bits >= 96) {
+			best, bestNode = as, idx
+		}
+		if bits == 128 {
+			break
+		}
+		next := int(le.Uint32(n[24+4*bitAt(key[:], bits):]))
+		if next == 0 {
+			break
+		}
+		idx = next
+	}
+	if best == 0 {
+		return Record{}, false
+	}
+
+	n := db.nodes[bestNode*nodeSize:]
+	a := db.ases[(best-1)*asSize:]
+	rec := Record{
+		Prefix: nodePrefix([16]byte(n[:16]), int(n[16])),
+		ASN:    le.Uint32(a),
+	}
+	if a[4] != 0 {
+		rec.Country = string(a[4:6])
+	}
+	nameOff, nameLen := le.Uint32(a[8:]), le.Uint16(a[6:])
+	rec.Name = string(db.names[nameOff : nameOff+uint32(nameLen)])
+	return rec, true
+}
+
+// LookupAS returns an AS with every prefix it originates. It scans the
+// whole file, so it is meant for one-off questions rather than bulk use.
+func (db *DB) LookupAS(asn uint32) (AS, bool) {
+	le := binary.LittleEndian
+	ref := uint32(0)
+	for i := 0; i < len(db.ases)/asSize; i++ {
+		if le.Uint32(db.ases[i*asSize:]) == asn {
+			ref = uint32(i + 1)
+			break
+		}
+	}
+	if ref == 0 {
+		return AS{}, false
+	}
+
+	a := db.ases[(ref-1)*asSize:]
+	as := AS{ASN: asn}
+	if a[4] != 0 {
+		as.Country = string(a[4:6])
+	}
+	nameOff, nameLen := le.Uint32(a[8:]), le.Uint16(a[6:])
+	as.Name = string(db.names[nameOff : nameOff+uint32(nameLen)])
+
+	// Nodes are stored depth-first, which is address order
+	for i := 0; i < len(db.nodes)/nodeSize; i++ {
+		n := db.nodes[i*nodeSize:]
+		if le.Uint32(n[20:]) == ref {
+			as.Prefixes = append(as.Prefixes, nodePrefix([16]byte(n[:16]), int(n[16])))
+		}
+	}
+	return as, true
+}
+
+// nodePrefix turns a stored key back into an IPv4 or IPv6 prefix
+func nodePrefix(key [16]byte, bits int) netip.Prefix {
+	addr := netip.AddrFrom16(key)
+	if addr.Is4In6() && bits >= 96 {
+		return netip.PrefixFrom(addr.Unmap(), bits-96)
+	}
+	return netip.PrefixFrom(addr, bits)
+}
+
+// bitAt returns bit i of key, most significant first
+func bitAt(key []byte, i int) int {
+	return int(key[i/8]>>(7-i%8)) & 1
+}
+
+// commonBits counts the leading bits a and b share, up to max
+func commonBits(a, b []byte, max int) int {
+	n := 0
+	for i := 0; i < 16 && n < max; i++ {
+		if x := a[i] ^ b[i]; x != 0 {
+			for x&0x80 == 0 {
+				x <<= 1
+				n++
+			}
+			break
+		}
+		n += 8
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
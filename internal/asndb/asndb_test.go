@@ -0,0 +1,232 @@
+package asndb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ip2asnSample = `1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+1.0.1.0	1.0.3.255	0	None	Not routed
+8.8.8.0	8.8.8.255	15169	US	GOOGLE
+8.0.0.0	8.127.255.255	3356	US	LEVEL3
+193.0.0.0	193.0.7.255	3333	NL	RIPE-NCC-AS Reseaux IP Europeens Network Coordination Centre (RIPE NCC)
+2001:4860::	2001:4860:ffff:ffff:ffff:ffff:ffff:ffff	15169	US	GOOGLE
+2606:4700::	2606:4700:ffff:ffff:ffff:ffff:ffff:ffff	13335	US	CLOUDFLARENET
+`
+
+const risSample = `% This file contains the prefixes seen by the RIPE RIS route collectors
+
+13335	1.1.1.0/24	340
+64500	1.1.1.0/24	2
+{64500,64501}	192.0.2.0/24	5
+15169	2001:4860::/32	330
+3356	0.0.0.0/0	3
+`
+
+func buildDB(t *testing.T, inputs ...string) *DB {
+	t.Helper()
+	b := NewBuilder()
+	for _, in := range inputs {
+		if _, err := b.Import(strings.NewReader(in)); err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+	}
+	db, err := Parse(b.Bytes("test", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return db
+}
+
+func TestLookupIP2ASN(t *testing.T) {
+	db := buildDB(t, ip2asnSample)
+
+	tests := []struct {
+		addr   string
+		prefix string
+		asn    uint32
+		name   string
+	}{
+		{"8.8.8.8", "8.8.8.0/24", 15169, "GOOGLE"},
+		{"8.8.4.4", "8.0.0.0/9", 3356, "LEVEL3"},
+		{"1.0.0.1", "1.0.0.0/24", 13335, "CLOUDFLARENET"},
+		{"193.0.6.139", "193.0.0.0/21", 3333, "RIPE-NCC-AS Reseaux IP Europeens Network Coordination Centre (RIPE NCC)"},
+		{"2001:4860:4860::8888", "2001:4860::/32", 15169, "GOOGLE"},
+		{"::ffff:8.8.8.8", "8.8.8.0/24", 15169, "GOOGLE"},
+	}
+	for _, tt := range tests {
+		rec, ok := db.Lookup(netip.MustParseAddr(tt.addr))
+		if !ok {
+			t.Errorf("Lookup(%s) found nothing", tt.addr)
+			continue
+		}
+		if rec.Prefix.String() != tt.prefix || rec.ASN != tt.asn || rec.Name != tt.name || rec.Country == "" {
+			t.Errorf("Lookup(%s) = %+v, want %s AS%d %s", tt.addr, rec, tt.prefix, tt.asn, tt.name)
+		}
+	}
+
+	for _, addr := range []string{"1.0.1.1", "9.9.9.9", "2a00::1"} {
+		if rec, ok := db.Lookup(netip.MustParseAddr(addr)); ok {
+			t.Errorf("Lookup(%s) = %+v, want no match", addr, rec)
+		}
+	}
+
+	info := db.Info()
+	if info.ASNs != 4 || info.Source != "test" || info.Prefixes == 0 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestLookupRIS(t *testing.T) {
+	// ip2asn first for the names, RIS on top for the routes
+	db := buildDB(t, ip2asnSample, risSample)
+
+	rec, ok := db.Lookup(netip.MustParseAddr("1.1.1.1"))
+	if !ok || rec.ASN != 13335 || rec.Prefix.String() != "1.1.1.0/24" {
+		t.Errorf("Expected the origin seen by most peers, got %+v", rec)
+	}
+	if rec.Name != "CLOUDFLARENET" {
+		t.Errorf("Expected the AS name from the ip2asn import, got %q", rec.Name)
+	}
+
+	// Covered by the RIS default route only
+	if rec, ok := db.Lookup(netip.MustParseAddr("9.9.9.9")); !ok || rec.ASN != 3356 || rec.Prefix.String() != "0.0.0.0/0" {
+		t.Errorf("Expected the default route, got %+v", rec)
+	}
+	// AS sets are skipped, and the IPv4 default does not cover IPv6
+	if rec, ok := db.Lookup(netip.MustParseAddr("192.0.2.1")); !ok || rec.ASN != 3356 {
+		t.Errorf("Expected the AS set to be skipped, got %+v", rec)
+	}
+	if rec, ok := db.Lookup(netip.MustParseAddr("2a00::1")); ok {
+		t.Errorf("Expected no IPv6 match, got %+v", rec)
+	}
+}
+
+func TestLookupAS(t *testing.T) {
+	db := buildDB(t, ip2asnSample, risSample)
+
+	as, ok := db.LookupAS(15169)
+	if !ok || as.Name != "GOOGLE" || as.Country != "US" {
+		t.Fatalf("LookupAS(15169) = %+v, %v", as, ok)
+	}
+	if got := fmt.Sprint(as.Prefixes); got != "[8.8.8.0/24 2001:4860::/32]" {
+		t.Errorf("Expected the IPv4 and IPv6 prefixes in order, got %s", got)
+	}
+
+	if as, ok := db.LookupAS(64500); ok {
+		t.Errorf("Expected AS64500 to be unknown (it only appears in an AS set and as a minority origin), got %+v", as)
+	}
+}
+
+func TestImportGzipAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(ip2asnSample))
+	gz.Close()
+
+	b := NewBuilder()
+	n, err := b.Import(&buf)
+	if err != nil || n != 6 {
+		t.Fatalf("Import(gzip) = %d, %v; want 6 entries", n, err)
+	}
+
+	if _, err := NewBuilder().Import(strings.NewReader("<html>not a dataset</html>\n")); err == nil {
+		t.Error("Expected an error for an unrecognised file")
+	}
+}
+
+func TestWriteFileAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netgaze", "asn.db")
+
+	if err := NewBuilder().WriteFile(path, "empty"); err == nil {
+		t.Error("Expected an error writing an empty database")
+	}
+
+	b := NewBuilder()
+	b.Import(strings.NewReader(ip2asnSample))
+	if err := b.WriteFile(path, "ip2asn-combined.tsv"); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if rec, ok := db.Lookup(netip.MustParseAddr("8.8.8.8")); !ok || rec.ASN != 15169 {
+		t.Errorf("unexpected lookup after reopening: %+v", rec)
+	}
+
+	data, _ := os.ReadFile(path)
+	for _, bad := range [][]byte{nil, []byte("NGASNDB1"), data[:len(data)-1]} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Expected Parse to reject %d bytes", len(bad))
+		}
+	}
+	corrupt := append([]byte{}, data...)
+	corrupt[headerSize+len("ip2asn-combined.tsv")+24] = 0xff // child index of the root
+	if _, err := Parse(corrupt); err == nil {
+		t.Error("Expected Parse to reject an out-of-range child")
+	}
+	loop := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(loop[headerSize+len("ip2asn-combined.tsv")+nodeSize+24:], 1) // node 1 pointing at itself
+	if _, err := Parse(loop); err == nil {
+		t.Error("Expected Parse to reject a child that is not after its parent")
+	}
+}
+
+func TestOpenDefault(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	if _, err := OpenDefault(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("OpenDefault() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRangePrefixes(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       string
+	}{
+		{"1.0.1.0", "1.0.3.255", "1.0.1.0/24 1.0.2.0/23"},
+		{"10.0.0.0", "10.255.255.255", "10.0.0.0/8"},
+		{"0.0.0.0", "255.255.255.255", "0.0.0.0/0"},
+		{"192.0.2.7", "192.0.2.7", "192.0.2.7/32"},
+		{"2001:db8::", "2001:db8::1:ffff", "2001:db8::/111"},
+	}
+	for _, tt := range tests {
+		got := fmt.Sprint(RangePrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end)))
+		if got != "["+tt.want+"]" {
+			t.Errorf("RangePrefixes(%s, %s) = %s, want [%s]", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkLookup builds a table the size of a full IPv4 routing table
+func BenchmarkLookup(b *testing.B) {
+	builder := NewBuilder()
+	for i := 0; i < 1<<20; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(i >> 12), byte(i >> 4), byte(i << 4), 0})
+		builder.Add(netip.PrefixFrom(addr, 20+i%5), uint32(64512+i%1000), "US", "TEST")
+	}
+	db, err := Parse(builder.Bytes("bench", time.Now()))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{byte(i * 7), byte(i * 13), byte(i * 31), byte(i)})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Lookup(addrs[i%len(addrs)])
+	}
+}
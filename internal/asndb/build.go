@@ -0,0 +1,345 @@
+package asndb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Builder collects prefixes in memory and writes them as a database
+type Builder struct {
+	root     *node
+	ases     []asEntry
+	asIndex  map[uint32]uint32 // ASN -> index+1 into ases
+	prefixes int
+}
+
+type node struct {
+	key   [16]byte
+	bits  int
+	as    uint32 // index+1 into Builder.ases, 0 for none
+	child [2]*node
+}
+
+type asEntry struct {
+	asn     uint32
+	country string
+	name    string
+}
+
+// NewBuilder returns an empty builder
+func NewBuilder() *Builder {
+	return &Builder{root: &node{}, asIndex: make(map[uint32]uint32)}
+}
+
+// Prefixes returns how many distinct prefixes have been added
+func (b *Builder) Prefixes() int {
+	return b.prefixes
+}
+
+// Add maps prefix to asn. A later Add for the same prefix replaces the
+// earlier one; country and name fill in whatever the AS is missing.
+func (b *Builder) Add(prefix netip.Prefix, asn uint32, country, name string) {
+	prefix = prefix.Masked()
+	if !prefix.IsValid() {
+		return
+	}
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	b.insert(prefix.Addr().As16(), bits, b.asRef(asn, country, name))
+}
+
+func (b *Builder) asRef(asn uint32, country, name string) uint32 {
+	if len(country) != 2 {
+		country = ""
+	}
+	if ref, ok := b.asIndex[asn]; ok {
+		e := &b.ases[ref-1]
+		if e.country == "" {
+			e.country = country
+		}
+		if e.name == "" {
+			e.name = name
+		}
+		return ref
+	}
+	b.ases = append(b.ases, asEntry{asn: asn, country: strings.ToUpper(country), name: name})
+	ref := uint32(len(b.ases))
+	b.asIndex[asn] = ref
+	return ref
+}
+
+// insert adds key/bits to the path-compressed trie
+func (b *Builder) insert(key [16]byte, bits int, as uint32) {
+	n := b.root
+	for {
+		if n.bits == bits {
+			if n.as == 0 {
+				b.prefixes++
+			}
+			n.as = as
+			return
+		}
+
+		dir := bitAt(key[:], n.bits)
+		c := n.child[dir]
+		if c == nil {
+			n.child[dir] = &node{key: key, bits: bits, as: as}
+			b.prefixes++
+			return
+		}
+
+		common := commonBits(c.key[:], key[:], min(c.bits, bits))
+		if common == c.bits {
+			n = c
+			continue
+		}
+
+		leaf := &node{key: key, bits: bits, as: as}
+		b.prefixes++
+		if common == bits {
+			// The new prefix encloses the child
+			leaf.child[bitAt(c.key[:], bits)] = c
+			n.child[dir] = leaf
+			return
+		}
+		split := &node{key: maskKey(key, common), bits: common}
+		split.child[bitAt(key[:], common)] = leaf
+		split.child[bitAt(c.key[:], common)] = c
+		n.child[dir] = split
+		return
+	}
+}
+
+func maskKey(key [16]byte, bits int) [16]byte {
+	for i := bits; i < 128; i++ {
+		key[i/8] &^= 0x80 >> (i % 8)
+	}
+	return key
+}
+
+// Import reads an ip2asn TSV file (range_start, range_end, AS number,
+// country, description) or a RIPE RIS dump (AS number, prefix, peers
+// seeing it), either optionally gzipped, and returns how many entries
+// were added. Where RIS sees several origins for one prefix, the one
+// seen by the most peers wins.
+func (b *Builder) Import(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(2); err == nil && head[0] == 0x1f && head[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	type risOrigin struct {
+		asn   uint32
+		peers int
+	}
+	ris := make(map[netip.Prefix]risOrigin)
+	added := 0
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '%' || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			fields = strings.Fields(line)
+		}
+		if len(fields) < 3 {
+			return added, fmt.Errorf("line %d: unrecognised entry %q", lineNo, line)
+		}
+
+		if strings.Contains(fields[1], "/") {
+			// RIS: 13335	1.1.1.0/24	340
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(fields[1]))
+			if err != nil {
+				return added, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			asn, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 10, 32)
+			if err != nil {
+				// AS sets such as {64500,64501} have no single origin
+				continue
+			}
+			peers, _ := strconv.Atoi(strings.TrimSpace(fields[2]))
+			prefix = prefix.Masked()
+			if cur, ok := ris[prefix]; !ok || peers > cur.peers {
+				ris[prefix] = risOrigin{asn: uint32(asn), peers: peers}
+			}
+			continue
+		}
+
+		// ip2asn: 1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+		start, err1 := netip.ParseAddr(strings.TrimSpace(fields[0]))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(fields[1]))
+		asn, err3 := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 32)
+		if err1 != nil || err2 != nil || err3 != nil || start.Is4() != end.Is4() || end.Less(start) {
+			return added, fmt.Errorf("line %d: unrecognised entry %q", lineNo, line)
+		}
+		if asn == 0 {
+			// Not routed
+			continue
+		}
+		var country, name string
+		if len(fields) > 3 {
+			country = strings.TrimSpace(fields[3])
+		}
+		if len(fields) > 4 {
+			name = strings.TrimSpace(fields[4])
+		}
+		for _, p := range RangePrefixes(start, end) {
+			b.Add(p, uint32(asn), country, name)
+		}
+		added++
+	}
+	if err := scanner.Err(); err != nil {
+		return added, err
+	}
+
+	for prefix, origin := range ris {
+		b.Add(prefix, origin.asn, "", "")
+		added++
+	}
+	return added, nil
+}
+
+// RangePrefixes splits the address range start-end, which must be in
+// order and of one family, into the fewest prefixes
+func RangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for {
+		// Widen the block while it stays aligned and inside the range
+		bits := start.BitLen()
+		for bits > 0 {
+			p := netip.PrefixFrom(start, bits-1)
+			if p.Masked().Addr() != start || LastAddr(p).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+		block := netip.PrefixFrom(start, bits)
+		out = append(out, block)
+
+		last := LastAddr(block)
+		if last.Compare(end) >= 0 || !last.Next().IsValid() {
+			return out
+		}
+		start = last.Next()
+	}
+}
+
+// LastAddr returns the highest address in a prefix
+func LastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for bit := p.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// Bytes serialises the trie; source records which files it came from
+func (b *Builder) Bytes(source string, built time.Time) []byte {
+	if len(source) > 0xffff {
+		source = source[:0xffff]
+	}
+
+	// Number the nodes depth-first so the root is 0
+	var order []*node
+	index := make(map[*node]uint32)
+	var walk func(n *node)
+	walk = func(n *node) {
+		index[n] = uint32(len(order))
+		order = append(order, n)
+		for _, c := range n.child {
+			if c != nil {
+				walk(c)
+			}
+		}
+	}
+	walk(b.root)
+
+	var names []byte
+	le := binary.LittleEndian
+	out := make([]byte, headerSize, headerSize+len(source)+len(order)*nodeSize+len(b.ases)*asSize)
+	copy(out, magic)
+	le.PutUint64(out[8:], uint64(built.Unix()))
+	le.PutUint32(out[16:], uint32(len(order)))
+	le.PutUint32(out[20:], uint32(len(b.ases)))
+	le.PutUint32(out[28:], uint32(b.prefixes))
+	le.PutUint16(out[32:], uint16(len(source)))
+	out = append(out, source...)
+
+	for _, n := range order {
+		var rec [nodeSize]byte
+		copy(rec[:16], n.key[:])
+		rec[16] = byte(n.bits)
+		le.PutUint32(rec[20:], n.as)
+		for i, c := range n.child {
+			if c != nil {
+				le.PutUint32(rec[24+4*i:], index[c])
+			}
+		}
+		out = append(out, rec[:]...)
+	}
+	for _, e := range b.ases {
+		var rec [asSize]byte
+		le.PutUint32(rec[0:], e.asn)
+		copy(rec[4:6], e.country)
+		name := e.name
+		if len(name) > 0xffff {
+			name = name[:0xffff]
+		}
+		le.PutUint16(rec[6:], uint16(len(name)))
+		le.PutUint32(rec[8:], uint32(len(names)))
+		names = append(names, name...)
+		out = append(out, rec[:]...)
+	}
+	le.PutUint32(out[24:], uint32(len(names)))
+	return append(out, names...)
+}
+
+// WriteFile stores the database at path, replacing any previous one only
+// once the new file is complete
+func (b *Builder) WriteFile(path, source string) error {
+	if b.prefixes == 0 {
+		return fmt.Errorf("no prefixes to write")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".asn-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b.Bytes(source, time.Now())); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,228 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Where an abuse contact came from, as recorded in AbuseContact.Source
+const (
+	AbuseSourceRDAP          = "rdap"           // entity with the abuse role
+	AbuseSourceAbuseC        = "abuse-c"        // RIR abuse-c role (RIPE, APNIC, AFRINIC, LACNIC)
+	AbuseSourceResourceAbuse = "resource-abuse" // ARIN RAbuse on the network
+	AbuseSourceOrgAbuse      = "org-abuse"      // ARIN OrgAbuseEmail of the holder
+	AbuseSourceMailbox       = "abuse-mailbox"  // mailbox on the organisation itself
+	AbuseSourceIRT           = "irt"            // incident response team object
+	AbuseSourceRegistrar     = "registrar"      // ICANN "Registrar Abuse Contact Email"
+	AbuseSourceAbuseNet      = "abuse.net"      // <domain>.contacts.abuse.net TXT
+	AbuseSourceWhoisText     = "whois-text"     // address on a WHOIS line mentioning abuse
+)
+
+// abuseSourceRank orders sources from most to least authoritative
+var abuseSourceRank = map[string]int{
+	AbuseSourceRDAP:          0,
+	AbuseSourceAbuseC:        1,
+	AbuseSourceResourceAbuse: 1,
+	AbuseSourceOrgAbuse:      2,
+	AbuseSourceMailbox:       2,
+	AbuseSourceIRT:           3,
+	AbuseSourceRegistrar:     4,
+	AbuseSourceAbuseNet:      5,
+	AbuseSourceWhoisText:     6,
+}
+
+// abuseNetZone serves the abuse.net contact database over DNS
+const abuseNetZone = "contacts.abuse.net"
+
+// collectAbuse ranks the abuse contacts found by the WHOIS collector and
+// adds the abuse.net entry for the target's domain, or for the PTR name
+// of an IP. The DNS lookup is best effort: most domains have no entry.
+func collectAbuse(ctx context.Context, target string, report *model.Report, r hopResolver) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	contacts := registryAbuseContacts(report)
+	if domain := abuseDomain(target, report.PTR); domain != "" {
+		if emails, err := lookupAbuseNet(ctx, r, domain); err == nil {
+			for _, email := range emails {
+				contacts = append(contacts, model.AbuseContact{Email: email, Source: AbuseSourceAbuseNet, Name: domain})
+			}
+		}
+	}
+
+	report.AbuseContacts = rankAbuseContacts(contacts)
+	if len(report.AbuseContacts) > 0 {
+		report.Whois.AbuseEmails = nil
+		for _, c := range report.AbuseContacts {
+			report.Whois.AbuseEmails = append(report.Whois.AbuseEmails, c.Email)
+		}
+	}
+}
+
+// LookupAbuse finds the abuse contacts for an IP address or domain,
+// most authoritative first
+func LookupAbuse(ctx context.Context, target string, opts WhoisOptions) ([]model.AbuseContact, error) {
+	report := &model.Report{Target: target, Errors: make(map[string]string)}
+
+	if ip := net.ParseIP(target); ip != nil {
+		ptrCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		if names, err := net.DefaultResolver.LookupAddr(ptrCtx, ip.String()); err == nil {
+			report.PTR = names
+		}
+		cancel()
+	}
+
+	collectWhoisWithOptions(ctx, target, opts, report)
+	collectAbuse(ctx, target, report, net.DefaultResolver)
+
+	if len(report.AbuseContacts) == 0 {
+		if msg := report.Errors["whois"]; msg != "" {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, fmt.Errorf("no abuse contact found for %s", target)
+	}
+	return report.AbuseContacts, nil
+}
+
+// registryAbuseContacts gathers the contacts the registration data names
+func registryAbuseContacts(report *model.Report) []model.AbuseContact {
+	var contacts []model.AbuseContact
+
+	if r := report.RDAP; r != nil {
+		for i := range r.Entities {
+			e := &r.Entities[i]
+			if !hasRole(e.Roles, "abuse") {
+				continue
+			}
+			for _, email := range e.Emails {
+				contacts = append(contacts, model.AbuseContact{
+					Email:    strings.ToLower(email),
+					Source:   AbuseSourceRDAP,
+					Handle:   e.Handle,
+					Name:     entityName(e),
+					Registry: rdapServerRegistry(r.Server),
+				})
+			}
+		}
+		return contacts
+	}
+
+	w := report.Whois
+	if a := w.Abuse; a != nil {
+		source := a.Source
+		if source == "" {
+			source = AbuseSourceAbuseC
+		}
+		for _, email := range a.Emails {
+			contacts = append(contacts, model.AbuseContact{
+				Email:    email,
+				Source:   source,
+				Handle:   a.Handle,
+				Name:     a.Name,
+				Registry: w.Registry,
+			})
+		}
+	}
+
+	for _, resp := range report.WhoisChain {
+		if resp.Error != "" {
+			continue
+		}
+		lower := strings.ToLower(resp.Raw)
+		if email := extractField(resp.Raw, lower, []string{`registrar abuse contact email:\s*(.+)`}); strings.Contains(email, "@") {
+			contacts = append(contacts, model.AbuseContact{
+				Email:  strings.ToLower(email),
+				Source: AbuseSourceRegistrar,
+				Name:   w.Registrar,
+			})
+		}
+	}
+
+	for _, email := range w.AbuseEmails {
+		contacts = append(contacts, model.AbuseContact{Email: email, Source: AbuseSourceWhoisText})
+	}
+	return contacts
+}
+
+// rankAbuseContacts orders contacts by source and keeps each address
+// once, under its most authoritative source
+func rankAbuseContacts(contacts []model.AbuseContact) []model.AbuseContact {
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return abuseSourceRank[contacts[i].Source] < abuseSourceRank[contacts[j].Source]
+	})
+
+	var ranked []model.AbuseContact
+	seen := make(map[string]bool)
+	for _, c := range contacts {
+		if c.Email == "" || seen[c.Email] {
+			continue
+		}
+		seen[c.Email] = true
+		ranked = append(ranked, c)
+	}
+	return ranked
+}
+
+// abuseDomain is the registered domain to look up at abuse.net: the
+// target's own, or that of an IP's PTR name
+func abuseDomain(target string, ptr []string) string {
+	name := target
+	if net.ParseIP(target) != nil {
+		if len(ptr) == 0 {
+			return ""
+		}
+		name = ptr[0]
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return ""
+	}
+	return domain
+}
+
+// lookupAbuseNet queries the abuse.net contact database, which answers
+// one TXT record per address
+func lookupAbuseNet(ctx context.Context, r hopResolver, domain string) ([]string, error) {
+	records, err := r.LookupTXT(ctx, domain+"."+abuseNetZone)
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, rec := range records {
+		for _, field := range strings.Fields(rec) {
+			if strings.Contains(field, "@") {
+				emails = append(emails, strings.ToLower(field))
+			}
+		}
+	}
+	return dedupe(emails), nil
+}
+
+// abuseLineEmails returns the addresses on lines that mention abuse,
+// leaving out admin, tech and other contacts
+func abuseLineEmails(data string) []string {
+	var emails []string
+	for _, line := range strings.Split(data, "\n") {
+		if strings.Contains(strings.ToLower(line), "abuse") {
+			emails = append(emails, extractEmails(line)...)
+		}
+	}
+	return dedupe(emails)
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// whoisReport parses a port-43 response the way the WHOIS collector does
+func whoisReport(raw string) *model.Report {
+	report := &model.Report{Errors: make(map[string]string)}
+	applyWhoisChain([]model.WhoisResponse{{Server: "whois.example", Raw: raw}}, report)
+	return report
+}
+
+func TestCollectAbuseARIN(t *testing.T) {
+	report := whoisReport(arinWhoisSample)
+	r := &fakeHopResolver{queries: make(map[string]int)}
+
+	collectAbuse(context.Background(), "8.8.8.8", report, r)
+
+	got := report.AbuseContacts
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 abuse contacts, got %+v", got)
+	}
+	if got[0].Email != "network-abuse@google.com" || got[0].Source != AbuseSourceOrgAbuse || got[0].Handle != "ABUSE5250-ARIN" || got[0].Registry != RegistryARIN {
+		t.Errorf("Expected Google's OrgAbuse contact first, got %+v", got[0])
+	}
+	// Level 3's abuse address is only on a line mentioning abuse
+	if got[1].Email != "ipaddressing@lumen.com" || got[1].Source != AbuseSourceWhoisText {
+		t.Errorf("Expected the parent's address last, got %+v", got[1])
+	}
+	for _, email := range report.Whois.AbuseEmails {
+		if email == "arin-contact@google.com" {
+			t.Errorf("Expected the tech contact left out, got %v", report.Whois.AbuseEmails)
+		}
+	}
+	if len(r.queries) != 0 {
+		t.Errorf("Expected no abuse.net query without a PTR name, got %v", r.queries)
+	}
+}
+
+func TestCollectAbuseRIPE(t *testing.T) {
+	report := whoisReport(ripeWhoisSample)
+	collectAbuse(context.Background(), "193.0.6.139", report, &fakeHopResolver{queries: make(map[string]int)})
+
+	got := report.AbuseContacts
+	if len(got) == 0 || got[0].Email != "abuse@ripe.net" || got[0].Source != AbuseSourceAbuseC || got[0].Registry != RegistryRIPE {
+		t.Errorf("Expected the abuse-c mailbox first, got %+v", got)
+	}
+}
+
+func TestCollectAbuseRDAP(t *testing.T) {
+	result, err := parseRDAP([]byte(rdapNetworkSample))
+	if err != nil {
+		t.Fatalf("parseRDAP() error = %v", err)
+	}
+	result.Server = "https://rdap.arin.net/registry/"
+
+	report := &model.Report{Errors: make(map[string]string), RDAP: result}
+	applyRDAPToWhois(result, report)
+	collectAbuse(context.Background(), "8.8.8.8", report, &fakeHopResolver{queries: make(map[string]int)})
+
+	got := report.AbuseContacts
+	if len(got) != 1 || got[0].Source != AbuseSourceRDAP || got[0].Handle != "ABUSE5250-ARIN" || got[0].Registry != RegistryARIN {
+		t.Errorf("Expected the RDAP abuse entity, got %+v", got)
+	}
+}
+
+func TestCollectAbuseDomain(t *testing.T) {
+	report := whoisReport("Domain Name: EXAMPLE.COM\nRegistrar: Example Registrar, Inc.\nRegistrar Abuse Contact Email: abuse@registrar.example\nRegistrar Abuse Contact Phone: +1.5555550100\nAdmin Email: admin@example.com\n")
+	r := &fakeHopResolver{
+		txt:     map[string]string{"example.com.contacts.abuse.net": "abuse@example.com"},
+		queries: make(map[string]int),
+	}
+
+	collectAbuse(context.Background(), "www.example.com", report, r)
+
+	got := report.AbuseContacts
+	if len(got) != 2 {
+		t.Fatalf("Expected registrar and abuse.net contacts, got %+v", got)
+	}
+	if got[0].Email != "abuse@registrar.example" || got[0].Source != AbuseSourceRegistrar || got[0].Name != "Example Registrar, Inc." {
+		t.Errorf("Expected the registrar's abuse address first, got %+v", got[0])
+	}
+	if got[1].Email != "abuse@example.com" || got[1].Source != AbuseSourceAbuseNet {
+		t.Errorf("Expected the abuse.net entry second, got %+v", got[1])
+	}
+}
+
+func TestAbuseDomain(t *testing.T) {
+	tests := []struct {
+		target string
+		ptr    []string
+		want   string
+	}{
+		{"mail.example.co.uk", nil, "example.co.uk"},
+		{"192.0.2.1", []string{"host-1.isp.example.net."}, "example.net"},
+		{"192.0.2.1", nil, ""},
+		{"localhost", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := abuseDomain(tt.target, tt.ptr); got != tt.want {
+			t.Errorf("abuseDomain(%q, %v) = %q, want %q", tt.target, tt.ptr, got, tt.want)
+		}
+	}
+}
+
+func TestRankAbuseContacts(t *testing.T) {
+	ranked := rankAbuseContacts([]model.AbuseContact{
+		{Email: "noc@example.net", Source: AbuseSourceWhoisText},
+		{Email: "abuse@example.net", Source: AbuseSourceIRT},
+		{Email: "noc@example.net", Source: AbuseSourceAbuseC},
+	})
+
+	data, _ := json.Marshal(ranked)
+	want := `[{"email":"noc@example.net","source":"abuse-c"},{"email":"abuse@example.net","source":"irt"}]`
+	if string(data) != want {
+		t.Errorf("rankAbuseContacts() = %s, want %s", data, want)
+	}
+}
@@ -3,14 +3,92 @@ package collector
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/typicalfo/netgaze/internal/asndb"
 	"github.com/typicalfo/netgaze/internal/model"
 )
 
+// Where Geo.ASN came from
+const (
+	ASNSourceCymru = "cymru" // Team Cymru DNS
+	ASNSourceDB    = "asndb" // offline database
+	ASNSourceMMDB  = "mmdb"  // MaxMind or DB-IP ASN database
+)
+
 func collectASN(ctx context.Context, target string, report *model.Report) error {
+	return collectASNWithOptions(ctx, target, GeoOptions{}, report)
+}
+
+// collectASNWithOptions also asks the ASN databases among
+// opts.MMDBPaths when the offline database has no answer
+func collectASNWithOptions(ctx context.Context, target string, opts GeoOptions, report *model.Report) error {
+	return collectASNWithResolver(ctx, target, report, net.DefaultResolver, defaultASNDB(), openGeoDBs(opts.MMDBPaths)...)
+}
+
+var (
+	asnDBOnce sync.Once
+	asnDB     *asndb.DB
+)
+
+// defaultASNDB opens the offline ASN database once, if one has been
+// built; without it every lookup goes to Team Cymru
+func defaultASNDB() *asndb.DB {
+	asnDBOnce.Do(func() {
+		asnDB, _ = asndb.OpenDefault()
+	})
+	return asnDB
+}
+
+// lookupASNDB answers from the offline database, naming the origin the
+// same way the Team Cymru zone does
+func lookupASNDB(db *asndb.DB, ip net.IP) (*cymruOrigin, string, bool) {
+	if db == nil {
+		return nil, "", false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, "", false
+	}
+	rec, ok := db.Lookup(addr)
+	if !ok {
+		return nil, "", false
+	}
+	origin := &cymruOrigin{
+		ASN:     strconv.FormatUint(uint64(rec.ASN), 10),
+		Prefix:  rec.Prefix.String(),
+		Country: rec.Country,
+	}
+	return origin, rec.Name, true
+}
+
+// lookupMMDBASN answers from the first MaxMind DB file with AS fields
+// for ip; the network is the one the file groups the address in, which
+// is close to, but not always, the announced prefix
+func lookupMMDBASN(dbs []geoDB, ip net.IP) (*cymruOrigin, string, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, "", false
+	}
+	for _, db := range dbs {
+		record, network, found, err := db.Lookup(addr.Unmap())
+		if err != nil || !found {
+			continue
+		}
+		if asn, name, ok := asnFromMMDB(record); ok {
+			return &cymruOrigin{ASN: strconv.FormatUint(asn, 10), Prefix: network.String()}, name, true
+		}
+	}
+	return nil, "", false
+}
+
+func collectASNWithResolver(ctx context.Context, target string, report *model.Report, r hopResolver, db *asndb.DB, geoDBs ...geoDB) error {
 	// Create context with 8-second timeout
 	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
@@ -23,36 +101,90 @@ func collectASN(ctx context.Context, target string, report *model.Report) error
 		return nil
 	}
 
+	// The offline databases need no network at all
+	origin, name, ok := lookupASNDB(db, ip)
+	source := ASNSourceDB
+	if !ok {
+		origin, name, ok = lookupMMDBASN(geoDBs, ip)
+		source = ASNSourceMMDB
+	}
+	if ok {
+		if name == "" {
+			// RIS dumps carry no AS names; ask for one, but don't wait long
+			nameCtx, cancel := context.WithTimeout(ctx, time.Second)
+			name, _ = lookupCymruASName(nameCtx, r, origin.ASN)
+			cancel()
+		}
+		report.Geo.IP = ip.String()
+		report.Geo.ASN = origin.ASN
+		report.Geo.Prefix = origin.Prefix
+		if origin.Country != "" {
+			report.Geo.CountryCode = origin.Country
+		}
+		report.Geo.ASName = name
+		report.Geo.Org = name
+		report.Geo.ASNSource = source
+		return nil
+	}
+
 	// Perform Team Cymru DNS lookup
-	resultChan := make(chan string, 1)
-	errorChan := make(chan error, 1)
-
-	go func() {
-		result, err := lookupTeamCymru(ip)
-		if err != nil {
-			errorChan <- err
-			return
+	result, err := lookupTeamCymru(ctx, r, ip)
+	if err != nil {
+		if ctx.Err() != nil {
+			report.Errors["asn"] = "ASN DNS lookup timeout"
+		} else {
+			report.Errors["asn"] = fmt.Sprintf("ASN DNS lookup failed: %v", err)
 		}
-		resultChan <- result
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case result := <-resultChan:
-		parseTeamCymruResult(result, report)
-	case err := <-errorChan:
-		report.Errors["asn"] = fmt.Sprintf("ASN DNS lookup failed: %v", err)
 		// Don't return error for ASN - it's optional
 		return nil
-	case <-ctx.Done():
-		report.Errors["asn"] = "ASN DNS lookup timeout"
-		// Don't return error for ASN - it's optional
+	}
+	parseTeamCymruResult(result, report)
+	if report.Geo.ASN == "" {
+		report.Errors["asn"] = fmt.Sprintf("unexpected Team Cymru answer: %q", result)
 		return nil
 	}
+	if report.Geo.IP == "" {
+		report.Geo.IP = ip.String()
+	}
+	report.Geo.ASNSource = ASNSourceCymru
+
+	// The origin zone does not carry the AS description
+	if report.Geo.ASName == "" {
+		if name, err := lookupCymruASName(ctx, r, report.Geo.ASN); err == nil && name != "" {
+			report.Geo.ASName = name
+			report.Geo.Org = name
+		}
+	}
 
 	return nil
 }
 
+// mergeASN copies what the ASN lookup found in from into report, after
+// geolocation. Its AS fields replace the provider's AS string; the AS
+// name only stands in for the organisation, and the registry country for
+// the location, when no geolocation provider had one.
+func mergeASN(report, from *model.Report) {
+	dst, src := &report.Geo, &from.Geo
+	if src.ASN != "" {
+		dst.ASN = src.ASN
+		dst.ASName = src.ASName
+		dst.Prefix = src.Prefix
+		dst.Registry = src.Registry
+		dst.Allocated = src.Allocated
+		dst.ASNSource = src.ASNSource
+	}
+	for _, f := range []struct{ to, from *string }{
+		{&dst.IP, &src.IP},
+		{&dst.CountryCode, &src.CountryCode},
+		{&dst.Org, &src.Org},
+	} {
+		if *f.to == "" {
+			*f.to = *f.from
+		}
+	}
+	maps.Copy(report.Errors, from.Errors)
+}
+
 func getTargetIP(target string) (net.IP, error) {
 	// If target is already an IP, return it
 	if ip := net.ParseIP(target); ip != nil {
@@ -80,16 +212,20 @@ func getTargetIP(target string) (net.IP, error) {
 	return ips[0], nil
 }
 
-func lookupTeamCymru(ip net.IP) (string, error) {
+// lookupTeamCymru asks the Team Cymru origin zone which AS announces ip
+func lookupTeamCymru(ctx context.Context, r hopResolver, ip net.IP) (string, error) {
 	// Reverse IP for DNS lookup
 	reversedIP, err := reverseIP(ip)
 	if err != nil {
 		return "", err
 	}
 
-	// Query Team Cymru DNS
-	query := fmt.Sprintf("%s.origin.asn.cymru.com", reversedIP)
-	txtRecords, err := net.LookupTXT(query)
+	// Query Team Cymru DNS; IPv6 has its own zone
+	zone := "origin.asn.cymru.com"
+	if ip.To4() == nil {
+		zone = "origin6.asn.cymru.com"
+	}
+	txtRecords, err := r.LookupTXT(ctx, reversedIP+"."+zone)
 	if err != nil {
 		return "", err
 	}
@@ -101,6 +237,8 @@ func lookupTeamCymru(ip net.IP) (string, error) {
 	return txtRecords[0], nil
 }
 
+// reverseIP returns the DNS label form of ip: reversed octets for IPv4,
+// reversed nibbles for IPv6 (as in ip6.arpa)
 func reverseIP(ip net.IP) (string, error) {
 	if ip.To4() != nil {
 		// IPv4
@@ -108,47 +246,61 @@ func reverseIP(ip net.IP) (string, error) {
 		return fmt.Sprintf("%d.%d.%d.%d", ipv4[3], ipv4[2], ipv4[1], ipv4[0]), nil
 	}
 
-	// IPv6 (simplified - full implementation would be more complex)
 	ipv6 := ip.To16()
 	if ipv6 == nil {
 		return "", fmt.Errorf("invalid IP address")
 	}
 
-	// For IPv6, we'll use a simplified approach
-	// In practice, you'd want to implement full nibble-wise reversal
-	return fmt.Sprintf("%s", ip.String()), nil
+	const hexDigits = "0123456789abcdef"
+	nibbles := make([]byte, 0, 63)
+	for i := len(ipv6) - 1; i >= 0; i-- {
+		if len(nibbles) > 0 {
+			nibbles = append(nibbles, '.')
+		}
+		nibbles = append(nibbles, hexDigits[ipv6[i]&0x0f], '.', hexDigits[ipv6[i]>>4])
+	}
+	return string(nibbles), nil
 }
 
 func parseTeamCymruResult(result string, report *model.Report) {
-	// Parse Team Cymru format: "ASN | IP | BGP Prefix | Country | Registry | Allocated | AS Name"
-	// Example: "15169 | 8.8.8.8 | 8.8.8.0/24 | US | arin | 2012-03-30 | GOOGLE-CLOUD-PLATFORM"
+	// Two layouts reach here. The origin zone answers
+	// "ASN | BGP Prefix | Country | Registry | Allocated", e.g.
+	// "15169 | 8.8.8.0/24 | US | arin | 2023-12-28"; the verbose bulk
+	// format adds the IP and AS name:
+	// "15169 | 8.8.8.8 | 8.8.8.0/24 | US | arin | 2012-03-30 | GOOGLE-CLOUD-PLATFORM"
 
-	parts := strings.Split(result, " | ")
-	if len(parts) < 7 {
-		return
+	parts := strings.Split(result, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
 	}
 
-	// Extract ASN
-	asn := strings.TrimSpace(parts[0])
-	if asn != "" {
-		report.Geo.ASN = asn
+	var asn, ip, prefix, country, registry, allocated, asName string
+	switch {
+	case len(parts) >= 7:
+		asn, ip, prefix, country, registry, allocated, asName = parts[0], parts[1], parts[2], parts[3], parts[4], parts[5], parts[6]
+	case len(parts) >= 5:
+		asn, prefix, country, registry, allocated = parts[0], parts[1], parts[2], parts[3], parts[4]
+	default:
+		return
 	}
 
-	// Extract country
-	country := strings.TrimSpace(parts[3])
+	// Multi-origin prefixes list several ASNs; keep the first
+	if fields := strings.Fields(asn); len(fields) > 0 {
+		report.Geo.ASN = fields[0]
+	}
 	if country != "" {
 		report.Geo.CountryCode = country
 	}
-
-	// Extract AS name
-	asName := strings.TrimSpace(parts[6])
 	if asName != "" {
 		report.Geo.ASName = asName
 		report.Geo.Org = asName
 	}
+	report.Geo.Prefix = prefix
+	report.Geo.Registry = registry
+	report.Geo.Allocated = allocated
 
 	// Store the IP being queried
-	if len(parts) > 1 {
-		report.Geo.IP = strings.TrimSpace(parts[1])
+	if ip != "" {
+		report.Geo.IP = ip
 	}
 }
@@ -3,9 +3,12 @@ package collector
 import (
 	"context"
 	"net"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/typicalfo/netgaze/internal/asndb"
 	"github.com/typicalfo/netgaze/internal/model"
 )
 
@@ -120,6 +123,18 @@ func TestReverseIP(t *testing.T) {
 			want:    "1.1.168.192",
 			wantErr: false,
 		},
+		{
+			name:    "IPv6 address",
+			ip:      "2001:4860:4860::8888",
+			want:    "8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2",
+			wantErr: false,
+		},
+		{
+			name:    "IPv6 mixed nibbles",
+			ip:      "2a00:1450:4001:82b::200e",
+			want:    "e.0.0.2.0.0.0.0.0.0.0.0.0.0.0.0.b.2.8.0.1.0.0.4.0.5.4.1.0.0.a.2",
+			wantErr: false,
+		},
 		{
 			name:    "invalid IP",
 			ip:      "invalid",
@@ -238,6 +253,134 @@ func TestParseTeamCymruResult(t *testing.T) {
 	}
 }
 
+func TestParseTeamCymruOrigin(t *testing.T) {
+	report := &model.Report{Errors: make(map[string]string)}
+	parseTeamCymruResult("13335 209242 | 2606:4700::/32 | US | arin | 2011-11-01", report)
+
+	g := report.Geo
+	if g.ASN != "13335" || g.Prefix != "2606:4700::/32" || g.CountryCode != "US" || g.Registry != "arin" || g.Allocated != "2011-11-01" {
+		t.Errorf("unexpected origin fields: %+v", g)
+	}
+	if g.ASName != "" || g.IP != "" {
+		t.Errorf("Expected no AS name or IP from the origin zone, got %+v", g)
+	}
+}
+
+func TestCollectASNWithResolver(t *testing.T) {
+	r := &fakeHopResolver{
+		txt: map[string]string{
+			"8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2.origin6.asn.cymru.com": "15169 | 2001:4860::/32 | US | arin | 2005-03-14",
+			"AS15169.asn.cymru.com": "15169 | US | arin | 2000-03-30 | GOOGLE, US",
+		},
+		queries: make(map[string]int),
+	}
+	report := &model.Report{Errors: make(map[string]string)}
+
+	if err := collectASNWithResolver(context.Background(), "2001:4860:4860::8888", report, r, nil); err != nil {
+		t.Fatalf("collectASNWithResolver() error = %v", err)
+	}
+
+	g := report.Geo
+	if g.ASN != "15169" || g.Prefix != "2001:4860::/32" || g.Registry != "arin" || g.Allocated != "2005-03-14" {
+		t.Errorf("unexpected origin fields: %+v", g)
+	}
+	if g.ASName != "GOOGLE, US" || g.Org != "GOOGLE, US" {
+		t.Errorf("Expected the AS description from the AS zone, got %q", g.ASName)
+	}
+	if g.IP != "2001:4860:4860::8888" {
+		t.Errorf("Expected the queried IP, got %q", g.IP)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", report.Errors)
+	}
+}
+
+func testASNDB(t *testing.T) *asndb.DB {
+	t.Helper()
+	b := asndb.NewBuilder()
+	b.Add(netip.MustParsePrefix("8.8.8.0/24"), 15169, "US", "GOOGLE")
+	b.Add(netip.MustParsePrefix("193.0.0.0/21"), 3333, "", "")
+	db, err := asndb.Parse(b.Bytes("test", time.Now()))
+	if err != nil {
+		t.Fatalf("asndb.Parse() error = %v", err)
+	}
+	return db
+}
+
+func TestCollectASNFromDB(t *testing.T) {
+	r := &fakeHopResolver{
+		txt:     map[string]string{"AS3333.asn.cymru.com": "3333 | NL | ripencc | 1993-09-01 | RIPE-NCC-AS, NL"},
+		queries: make(map[string]int),
+	}
+
+	report := &model.Report{Errors: make(map[string]string)}
+	collectASNWithResolver(context.Background(), "8.8.8.8", report, r, testASNDB(t))
+	g := report.Geo
+	if g.ASN != "15169" || g.Prefix != "8.8.8.0/24" || g.ASName != "GOOGLE" || g.CountryCode != "US" || g.ASNSource != ASNSourceDB {
+		t.Errorf("unexpected geo from the database: %+v", g)
+	}
+	if len(r.queries) != 0 {
+		t.Errorf("Expected no DNS queries, got %v", r.queries)
+	}
+
+	// Without a name in the database the AS zone is still asked
+	report = &model.Report{Errors: make(map[string]string)}
+	collectASNWithResolver(context.Background(), "193.0.6.139", report, r, testASNDB(t))
+	if report.Geo.ASN != "3333" || report.Geo.ASName != "RIPE-NCC-AS, NL" {
+		t.Errorf("unexpected geo: %+v", report.Geo)
+	}
+	if len(r.queries) != 1 {
+		t.Errorf("Expected only the AS name query, got %v", r.queries)
+	}
+}
+
+func TestCollectASNFromMMDB(t *testing.T) {
+	r := &fakeHopResolver{queries: make(map[string]int)}
+	city := &fakeGeoDB{
+		network: netip.MustParsePrefix("1.1.1.0/24"),
+		record:  map[string]any{"country": map[string]any{"iso_code": "AU"}},
+	}
+	asn := &fakeGeoDB{
+		network: netip.MustParsePrefix("1.1.1.0/24"),
+		record: map[string]any{
+			"autonomous_system_number":       uint64(13335),
+			"autonomous_system_organization": "CLOUDFLARENET",
+		},
+	}
+
+	// The offline ASN database comes first
+	report := &model.Report{Errors: make(map[string]string)}
+	collectASNWithResolver(context.Background(), "8.8.8.8", report, r, testASNDB(t), city, asn)
+	if report.Geo.ASNSource != ASNSourceDB {
+		t.Errorf("Expected the offline database to answer, got %+v", report.Geo)
+	}
+
+	report = &model.Report{Errors: make(map[string]string)}
+	collectASNWithResolver(context.Background(), "1.1.1.1", report, r, testASNDB(t), city, asn)
+	g := report.Geo
+	if g.ASN != "13335" || g.ASName != "CLOUDFLARENET" || g.Prefix != "1.1.1.0/24" || g.ASNSource != ASNSourceMMDB {
+		t.Errorf("unexpected geo from the MaxMind database: %+v", g)
+	}
+	if len(r.queries) != 0 || len(report.Errors) != 0 {
+		t.Errorf("Expected no DNS queries or errors, got %v, %v", r.queries, report.Errors)
+	}
+}
+
+func TestEnrichTraceHopsFromDB(t *testing.T) {
+	r := &fakeHopResolver{queries: make(map[string]int)}
+	hops := []model.TraceHop{{Hop: 1, IP: "8.8.8.8"}}
+
+	enrichTraceHops(context.Background(), hops, r, testASNDB(t))
+	if hops[0].ASN != "15169" || hops[0].ASName != "GOOGLE" || hops[0].Country != "US" {
+		t.Errorf("unexpected hop: %+v", hops[0])
+	}
+	for q := range r.queries {
+		if strings.Contains(q, "cymru") {
+			t.Errorf("Expected no Team Cymru query, got %s", q)
+		}
+	}
+}
+
 func TestCollectASN_Timeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
 	defer cancel()
@@ -253,3 +396,41 @@ func TestCollectASN_Timeout(t *testing.T) {
 		t.Errorf("collectASN() unexpected error = %v", err)
 	}
 }
+
+func TestMergeASN(t *testing.T) {
+	asn := &model.Report{Errors: map[string]string{}}
+	asn.Geo.IP = "8.8.8.8"
+	asn.Geo.ASN = "15169"
+	asn.Geo.ASName = "GOOGLE"
+	asn.Geo.Org = "GOOGLE"
+	asn.Geo.CountryCode = "US"
+	asn.Geo.Prefix = "8.8.8.0/24"
+	asn.Geo.ASNSource = ASNSourceDB
+
+	// A provider's organisation and country win over the AS registry's
+	report := &model.Report{Errors: map[string]string{"geo": "partial"}}
+	report.Geo.IP = "8.8.8.8"
+	report.Geo.ASN = "AS15169 Google LLC"
+	report.Geo.Org = "Google LLC"
+	report.Geo.CountryCode = "AU"
+	report.Geo.Sources = map[string]string{"org": "ip-api", "country_code": "ip-api"}
+	mergeASN(report, asn)
+	g := report.Geo
+	if g.ASN != "15169" || g.ASName != "GOOGLE" || g.Prefix != "8.8.8.0/24" || g.ASNSource != ASNSourceDB {
+		t.Errorf("Expected the ASN lookup's AS fields, got %+v", g)
+	}
+	if g.Org != "Google LLC" || g.CountryCode != "AU" || g.Sources["org"] != "ip-api" {
+		t.Errorf("Expected the provider's org and country to stay, got %+v", g)
+	}
+
+	// Without a provider the AS name stands in
+	report = &model.Report{Errors: map[string]string{}}
+	asn.Errors["asn"] = "slow"
+	mergeASN(report, asn)
+	if report.Geo.Org != "GOOGLE" || report.Geo.CountryCode != "US" || report.Geo.IP != "8.8.8.8" {
+		t.Errorf("Expected the AS name and registry country, got %+v", report.Geo)
+	}
+	if report.Errors["asn"] != "slow" {
+		t.Errorf("Expected the ASN error to be kept, got %v", report.Errors)
+	}
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/asndb"
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// IsASNTarget reports whether target names an autonomous system, e.g.
+// "AS15169" or "as15169"
+func IsASNTarget(target string) bool {
+	return asnTargetRe.MatchString(target)
+}
+
+// collectAS describes an autonomous system given as the target: its
+// registration, the prefixes it originates and the RIR's RDAP or WHOIS
+// record. There is nothing to resolve, ping or trace.
+func collectAS(ctx context.Context, target string, opts Options, report *model.Report) {
+	m := asnTargetRe.FindStringSubmatch(target)
+	n, err := strconv.ParseUint(m[1], 10, 32)
+	if err != nil {
+		report.Errors["asn"] = fmt.Sprintf("invalid AS number %q", target)
+		return
+	}
+	asn := strconv.FormatUint(n, 10)
+
+	// The AS lookup gets a report of its own, as WHOIS writes to the
+	// same error map; it is merged once both are done
+	asReport := &model.Report{Errors: make(map[string]string)}
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error { return collectASInfo(gctx, asn, asReport, net.DefaultResolver, defaultASNDB()) })
+	g.Go(func() error { return collectWhoisWithOptions(gctx, "AS"+asn, opts.Whois, report) })
+	g.Wait()
+	report.AS = asReport.AS
+	maps.Copy(report.Errors, asReport.Errors)
+
+	fillASFromWhois(report)
+	collectAbuse(ctx, target, report, net.DefaultResolver)
+}
+
+// collectASInfo fills report.AS from the offline database and Team Cymru
+func collectASInfo(ctx context.Context, asn string, report *model.Report, r hopResolver, db *asndb.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
+	defer cancel()
+
+	info := &model.ASInfo{ASN: asn}
+	report.AS = info
+
+	known := false
+	if db != nil {
+		n, _ := strconv.ParseUint(asn, 10, 32)
+		if as, ok := db.LookupAS(uint32(n)); ok {
+			known = true
+			info.Name = as.Name
+			info.Country = as.Country
+			for _, p := range as.Prefixes {
+				info.Prefixes = append(info.Prefixes, p.String())
+				if p.Addr().Is4() {
+					info.IPv4Prefixes++
+				} else {
+					info.IPv6Prefixes++
+				}
+			}
+		}
+	}
+
+	// Team Cymru adds the registry and allocation date the datasets lack
+	cymru, err := lookupCymruAS(ctx, r, asn)
+	if err == nil {
+		known = true
+		info.Registry = cymru.Registry
+		info.Allocated = cymru.Allocated
+		if info.Name == "" {
+			info.Name = cymru.Name
+		}
+		if info.Country == "" {
+			info.Country = cymru.Country
+		}
+	}
+
+	switch {
+	case !known && ctx.Err() != nil:
+		report.Errors["asn"] = "AS lookup timeout"
+	case !known:
+		report.Errors["asn"] = fmt.Sprintf("AS lookup failed: %v", err)
+	case db == nil:
+		report.Errors["as_prefixes"] = "announced prefixes need the offline ASN database (ng db update --from <file>)"
+	}
+	// Don't return error for ASN - it's optional
+	return nil
+}
+
+// fillASFromWhois completes the AS description from its registration
+// when neither the database nor Team Cymru had it
+func fillASFromWhois(report *model.Report) {
+	info := report.AS
+	if info == nil {
+		return
+	}
+	if info.Name == "" {
+		info.Name = report.Whois.NetName
+	}
+	if info.Country == "" {
+		info.Country = report.Whois.Country
+	}
+	if info.Registry == "" {
+		info.Registry = report.Whois.Registry
+	}
+}
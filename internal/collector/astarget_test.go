@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func TestIsASNTarget(t *testing.T) {
+	for target, want := range map[string]bool{
+		"AS15169":     true,
+		"as3333":      true,
+		"AS":          false,
+		"ASN15169":    false,
+		"15169":       false,
+		"as15169.com": false,
+	} {
+		if got := IsASNTarget(target); got != want {
+			t.Errorf("IsASNTarget(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
+
+func TestCollectASInfo(t *testing.T) {
+	r := &fakeHopResolver{
+		txt: map[string]string{
+			"AS15169.asn.cymru.com": "15169 | US | arin | 2000-03-30 | GOOGLE, US",
+			"AS3333.asn.cymru.com":  "3333 | NL | ripencc | 1993-09-01 | RIPE-NCC-AS, NL",
+		},
+		queries: make(map[string]int),
+	}
+	db := testASNDB(t)
+
+	report := &model.Report{Errors: make(map[string]string)}
+	collectASInfo(context.Background(), "15169", report, r, db)
+	want := model.ASInfo{ASN: "15169", Name: "GOOGLE", Country: "US", Registry: "arin", Allocated: "2000-03-30", IPv4Prefixes: 1}
+	got := *report.AS
+	if got.Name != want.Name || got.Country != want.Country || got.Registry != want.Registry || got.Allocated != want.Allocated || got.IPv4Prefixes != 1 {
+		t.Errorf("collectASInfo() = %+v, want %+v", got, want)
+	}
+	if len(got.Prefixes) != 1 || got.Prefixes[0] != "8.8.8.0/24" {
+		t.Errorf("Expected the prefix from the database, got %v", got.Prefixes)
+	}
+
+	// RIS-only entries have no name; Team Cymru's is used
+	report = &model.Report{Errors: make(map[string]string)}
+	collectASInfo(context.Background(), "3333", report, r, db)
+	if report.AS.Name != "RIPE-NCC-AS, NL" || report.AS.Country != "NL" || len(report.AS.Prefixes) != 1 {
+		t.Errorf("unexpected AS info: %+v", report.AS)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", report.Errors)
+	}
+}
+
+func TestCollectASInfoWithoutDB(t *testing.T) {
+	r := &fakeHopResolver{
+		txt:     map[string]string{"AS15169.asn.cymru.com": "15169 | US | arin | 2000-03-30 | GOOGLE, US"},
+		queries: make(map[string]int),
+	}
+
+	report := &model.Report{Errors: make(map[string]string)}
+	collectASInfo(context.Background(), "15169", report, r, nil)
+	if report.AS.Name != "GOOGLE, US" || report.AS.Registry != "arin" || len(report.AS.Prefixes) != 0 {
+		t.Errorf("unexpected AS info: %+v", report.AS)
+	}
+	if !strings.Contains(report.Errors["as_prefixes"], "ng db update") {
+		t.Errorf("Expected a hint about the offline database, got %v", report.Errors)
+	}
+
+	report = &model.Report{Errors: make(map[string]string)}
+	collectASInfo(context.Background(), "64500", report, r, nil)
+	if report.Errors["asn"] == "" {
+		t.Errorf("Expected an asn error for an unknown AS, got %v", report.Errors)
+	}
+}
+
+func TestFillASFromWhois(t *testing.T) {
+	report := &model.Report{Errors: make(map[string]string), AS: &model.ASInfo{ASN: "64500"}}
+	report.Whois.NetName = "EXAMPLE-AS"
+	report.Whois.Country = "DE"
+	report.Whois.Registry = RegistryRIPE
+
+	fillASFromWhois(report)
+	if report.AS.Name != "EXAMPLE-AS" || report.AS.Country != "DE" || report.AS.Registry != RegistryRIPE {
+		t.Errorf("unexpected AS info: %+v", report.AS)
+	}
+}
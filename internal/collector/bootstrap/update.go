@@ -0,0 +1,19 @@
+//go:build ignore
+
+// Command update replaces the embedded RDAP bootstrap snapshot with the
+// current IANA files, unmodified. Run it through go generate in
+// internal/collector.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/typicalfo/netgaze/internal/collector"
+)
+
+func main() {
+	if err := collector.UpdateRDAPBootstrap(context.Background(), "bootstrap"); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package collector
 import (
 	"context"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/typicalfo/netgaze/internal/model"
@@ -11,8 +12,14 @@ import (
 
 type Options struct {
 	EnablePorts bool
+	EnableMTU   bool
 	NoAgent     bool
 	Timeout     time.Duration
+	Ping        PingOptions
+	Trace       TraceOptions
+	Whois       WhoisOptions
+	RPKI        RPKIOptions
+	Geo         GeoOptions
 }
 
 func Collect(ctx context.Context, target string, opts Options) (*model.Report, error) {
@@ -25,27 +32,40 @@ func Collect(ctx context.Context, target string, opts Options) (*model.Report, e
 		Errors:     make(map[string]string),
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
+	// An AS number describes the network itself
+	if IsASNTarget(target) {
+		collectAS(ctx, target, opts, report)
+		report.DurationMs = time.Since(report.ResolvedAt).Milliseconds()
+		return report, nil
+	}
 
 	// DNS first (needed by other collectors)
-	g.Go(func() error { return collectDNS(ctx, target, report) })
-	if err := g.Wait(); err != nil {
+	if err := collectDNS(ctx, target, report); err != nil {
 		return nil, fmt.Errorf("DNS resolution failed: %w", err)
 	}
 
-	// Reset errgroup for parallel collectors
-	g, ctx = errgroup.WithContext(ctx)
+	// Parallel collectors get their own group; its context is
+	// cancelled by Wait, so later steps keep using ctx.
+	g, gctx := errgroup.WithContext(ctx)
 
 	// Always run these collectors
-	g.Go(func() error { return collectPing(ctx, target, report) })
-	g.Go(func() error { return collectTraceroute(ctx, target, report) })
-	g.Go(func() error { return collectWhois(ctx, target, report) })
-	g.Go(func() error { return collectASN(ctx, target, report) })
-	g.Go(func() error { return collectGeo(ctx, target, report) })
+	g.Go(func() error { return collectPingWithOptions(gctx, target, opts.Ping, report) })
+	g.Go(func() error { return collectTracerouteWithOptions(gctx, target, opts.Trace, report) })
+	g.Go(func() error { return collectWhoisWithOptions(gctx, target, opts.Whois, report) })
+	// The ASN lookup and geolocation both fill report.Geo, so the ASN
+	// lookup gets a report of its own that is merged once both are done
+	asnReport := &model.Report{Errors: make(map[string]string)}
+	g.Go(func() error { return collectASNWithOptions(gctx, target, opts.Geo, asnReport) })
+	g.Go(func() error { return collectGeoWithOptions(gctx, target, opts.Geo, report) })
 
 	// Port scan only when explicitly requested
 	if opts.EnablePorts {
-		g.Go(func() error { return collectPorts(ctx, target, report) })
+		g.Go(func() error { return collectPorts(gctx, target, report) })
+	}
+
+	// Path MTU discovery only when explicitly requested
+	if opts.EnableMTU {
+		g.Go(func() error { return collectMTU(gctx, target, report) })
 	}
 
 	// Wait for all collectors
@@ -53,6 +73,17 @@ func Collect(ctx context.Context, target string, opts Options) (*model.Report, e
 		// Log but don't fail - individual errors are in report.Errors
 	}
 
+	mergeASN(report, asnReport)
+
+	// Route origin validation (depends on the ASN lookup)
+	collectRPKI(ctx, report, opts.RPKI)
+
+	// Geolocation plausibility (depends on geo and ping)
+	checkGeo(report, opts.Geo.Vantage)
+
+	// Abuse contacts (depends on WHOIS and the PTR name)
+	collectAbuse(ctx, target, report, net.DefaultResolver)
+
 	// TLS collection (depends on port scan results)
 	if opts.EnablePorts && len(report.Ports.Open) > 0 && contains(report.Ports.Open, 443) {
 		if err := collectTLS(ctx, target, report); err != nil {
@@ -0,0 +1,254 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/asndb"
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/sync/errgroup"
+)
+
+// Address classes recorded in TraceHop.Class
+const (
+	HopClassPublic    = "public"
+	HopClassPrivate   = "private"
+	HopClassCGNAT     = "cgnat"
+	HopClassLoopback  = "loopback"
+	HopClassLinkLocal = "link-local"
+)
+
+const (
+	hopEnrichTimeout = 5 * time.Second
+	hopLookupLimit   = 16
+)
+
+// cgnatNet is the RFC 6598 shared address space used by carrier-grade NAT
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// hopResolver is the subset of *net.Resolver used for hop enrichment
+type hopResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// cymruOrigin is one answer from the Team Cymru origin zone
+type cymruOrigin struct {
+	ASN       string
+	Prefix    string
+	Country   string
+	Registry  string
+	Allocated string
+}
+
+// hopInfo is everything learned about one responder address
+type hopInfo struct {
+	host   string
+	origin *cymruOrigin
+}
+
+// EnrichTraceHops fills in PTR names, origin AS, country and address class
+// for every hop, then marks where the path enters a new AS. Lookups run
+// concurrently; whatever has not finished when ctx ends is left empty.
+func EnrichTraceHops(ctx context.Context, hops []model.TraceHop) {
+	enrichTraceHops(ctx, hops, net.DefaultResolver, defaultASNDB())
+}
+
+func enrichTraceHops(ctx context.Context, hops []model.TraceHop, r hopResolver, db *asndb.DB) {
+	var mu sync.Mutex
+	infos := make(map[string]*hopInfo)
+	asNames := make(map[string]string)
+
+	// Look each responder up once, even when it shows up at several TTLs
+	g := new(errgroup.Group)
+	g.SetLimit(hopLookupLimit)
+	for i := range hops {
+		ip := net.ParseIP(hops[i].IP)
+		if ip == nil {
+			continue
+		}
+		hops[i].Class = classifyHopIP(ip)
+
+		if _, seen := infos[hops[i].IP]; seen {
+			continue
+		}
+		info := &hopInfo{}
+		infos[hops[i].IP] = info
+
+		public := hops[i].Class == HopClassPublic
+		g.Go(func() error {
+			var host string
+			if names, err := r.LookupAddr(ctx, ip.String()); err == nil && len(names) > 0 {
+				host = names[0]
+			}
+
+			var origin *cymruOrigin
+			var asName string
+			if public {
+				var ok bool
+				if origin, asName, ok = lookupASNDB(db, ip); !ok {
+					origin, _ = lookupCymruOrigin(ctx, r, ip)
+				}
+			}
+
+			mu.Lock()
+			info.host = host
+			info.origin = origin
+			if asName != "" {
+				asNames[origin.ASN] = asName
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	var asns []string
+	for _, info := range infos {
+		if info.origin == nil {
+			continue
+		}
+		if _, ok := asNames[info.origin.ASN]; !ok {
+			asNames[info.origin.ASN] = ""
+			asns = append(asns, info.origin.ASN)
+		}
+	}
+	for _, asn := range asns {
+		g.Go(func() error {
+			name, _ := lookupCymruASName(ctx, r, asn)
+			mu.Lock()
+			asNames[asn] = name
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	for i := range hops {
+		info, ok := infos[hops[i].IP]
+		if !ok {
+			continue
+		}
+		if hops[i].Host == "" {
+			hops[i].Host = info.host
+		}
+		if info.origin != nil {
+			hops[i].ASN = info.origin.ASN
+			hops[i].ASName = asNames[info.origin.ASN]
+			hops[i].Country = info.origin.Country
+		}
+	}
+
+	markASBoundaries(hops)
+}
+
+// markASBoundaries flags each hop whose AS differs from the last hop with
+// a known AS. Private and silent hops do not end an AS run.
+func markASBoundaries(hops []model.TraceHop) {
+	var current string
+	for i := range hops {
+		hops[i].ASBoundary = false
+		if hops[i].ASN == "" {
+			continue
+		}
+		if hops[i].ASN != current {
+			hops[i].ASBoundary = true
+			current = hops[i].ASN
+		}
+	}
+}
+
+// classifyHopIP tells public addresses from the ones that never appear
+// in the global routing table
+func classifyHopIP(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return HopClassLoopback
+	case ip.IsLinkLocalUnicast():
+		return HopClassLinkLocal
+	case ip.IsPrivate():
+		return HopClassPrivate
+	case cgnatNet.Contains(ip):
+		return HopClassCGNAT
+	}
+	return HopClassPublic
+}
+
+// lookupCymruOrigin asks the Team Cymru origin zone which AS announces ip
+func lookupCymruOrigin(ctx context.Context, r hopResolver, ip net.IP) (*cymruOrigin, error) {
+	record, err := lookupTeamCymru(ctx, r, ip)
+	if err != nil {
+		return nil, err
+	}
+	return parseCymruOrigin(record)
+}
+
+// parseCymruOrigin parses an origin answer such as
+// "15169 | 8.8.8.0/24 | US | arin | 2014-03-14". Multi-origin prefixes
+// list several ASNs in the first field; the first one is kept.
+func parseCymruOrigin(record string) (*cymruOrigin, error) {
+	parts := strings.Split(record, "|")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("unexpected origin record: %q", record)
+	}
+
+	asns := strings.Fields(parts[0])
+	if len(asns) == 0 {
+		return nil, fmt.Errorf("no ASN in origin record: %q", record)
+	}
+
+	origin := &cymruOrigin{
+		ASN:     asns[0],
+		Prefix:  strings.TrimSpace(parts[1]),
+		Country: strings.TrimSpace(parts[2]),
+	}
+	if len(parts) >= 5 {
+		origin.Registry = strings.TrimSpace(parts[3])
+		origin.Allocated = strings.TrimSpace(parts[4])
+	}
+	return origin, nil
+}
+
+// cymruAS is a Team Cymru AS record
+type cymruAS struct {
+	ASN       string
+	Country   string
+	Registry  string
+	Allocated string
+	Name      string
+}
+
+// lookupCymruAS returns the registration of an AS from the Team Cymru
+// AS zone
+func lookupCymruAS(ctx context.Context, r hopResolver, asn string) (*cymruAS, error) {
+	records, err := r.LookupTXT(ctx, "AS"+asn+".asn.cymru.com")
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no TXT records found")
+	}
+
+	// "15169 | US | arin | 2000-03-30 | GOOGLE, US"
+	parts := strings.Split(records[0], "|")
+	if len(parts) < 5 {
+		return nil, fmt.Errorf("unexpected AS record: %q", records[0])
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return &cymruAS{ASN: parts[0], Country: parts[1], Registry: parts[2], Allocated: parts[3], Name: parts[4]}, nil
+}
+
+// lookupCymruASName returns the registered name of an AS, e.g. "GOOGLE, US"
+func lookupCymruASName(ctx context.Context, r hopResolver, asn string) (string, error) {
+	as, err := lookupCymruAS(ctx, r, asn)
+	if err != nil {
+		return "", err
+	}
+	return as.Name, nil
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// fakeHopResolver answers PTR and TXT queries from fixed tables
+type fakeHopResolver struct {
+	mu      sync.Mutex
+	ptr     map[string]string
+	txt     map[string]string
+	queries map[string]int
+}
+
+func (f *fakeHopResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	f.count(addr)
+	if name, ok := f.ptr[addr]; ok {
+		return []string{name}, nil
+	}
+	return nil, errors.New("no PTR")
+}
+
+func (f *fakeHopResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	f.count(name)
+	if record, ok := f.txt[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("no TXT")
+}
+
+func (f *fakeHopResolver) count(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[key]++
+}
+
+func TestEnrichTraceHops(t *testing.T) {
+	r := &fakeHopResolver{
+		ptr: map[string]string{
+			"203.0.113.1":  "edge1.isp.example.",
+			"198.51.100.7": "core.cdn.example.",
+		},
+		txt: map[string]string{
+			"1.113.0.203.origin.asn.cymru.com":  "64500 | 203.0.113.0/24 | US | arin | 2010-01-01",
+			"2.113.0.203.origin.asn.cymru.com":  "64500 | 203.0.113.0/24 | US | arin | 2010-01-01",
+			"7.100.51.198.origin.asn.cymru.com": "64501 64502 | 198.51.100.0/24 | DE | ripencc | 2011-01-01",
+			"AS64500.asn.cymru.com":             "64500 | US | arin | 2000-01-01 | EXAMPLE-ISP, US",
+			"AS64501.asn.cymru.com":             "64501 | DE | ripencc | 2000-01-01 | EXAMPLE-CDN, DE",
+		},
+		queries: make(map[string]int),
+	}
+
+	hops := []model.TraceHop{
+		{Hop: 1, IP: "192.168.1.1"},
+		{Hop: 2, IP: "100.64.0.1"},
+		{Hop: 3, IP: "203.0.113.1"},
+		{Hop: 4, Timeout: true},
+		{Hop: 5, IP: "203.0.113.2"},
+		{Hop: 6, IP: "10.10.10.10"},
+		{Hop: 7, IP: "198.51.100.7"},
+		{Hop: 8, IP: "198.51.100.7"},
+	}
+
+	enrichTraceHops(context.Background(), hops, r, nil)
+
+	wantClass := []string{HopClassPrivate, HopClassCGNAT, HopClassPublic, "", HopClassPublic, HopClassPrivate, HopClassPublic, HopClassPublic}
+	for i, want := range wantClass {
+		if hops[i].Class != want {
+			t.Errorf("hop %d class = %q, want %q", hops[i].Hop, hops[i].Class, want)
+		}
+	}
+
+	if hops[2].Host != "edge1.isp.example." || hops[2].ASN != "64500" || hops[2].ASName != "EXAMPLE-ISP, US" || hops[2].Country != "US" {
+		t.Errorf("unexpected hop 3 enrichment: %+v", hops[2])
+	}
+	if hops[6].ASN != "64501" || hops[6].Country != "DE" {
+		t.Errorf("Expected first origin AS at hop 7: %+v", hops[6])
+	}
+	if hops[0].ASN != "" || hops[1].ASN != "" {
+		t.Errorf("Expected no AS lookup for private or CGNAT hops: %+v %+v", hops[0], hops[1])
+	}
+	if r.queries["1.1.168.192.origin.asn.cymru.com"] != 0 || r.queries["1.0.64.100.origin.asn.cymru.com"] != 0 {
+		t.Error("Expected private hops not to be sent to Team Cymru")
+	}
+	if r.queries["198.51.100.7"] != 1 || r.queries["AS64500.asn.cymru.com"] != 1 {
+		t.Errorf("Expected each address and AS to be looked up once: %v", r.queries)
+	}
+
+	// Boundaries: entering 64500 at hop 3, staying in it across the
+	// silent and private hops, then entering 64501 at hop 7
+	wantBoundary := []bool{false, false, true, false, false, false, true, false}
+	for i, want := range wantBoundary {
+		if hops[i].ASBoundary != want {
+			t.Errorf("hop %d boundary = %v, want %v", hops[i].Hop, hops[i].ASBoundary, want)
+		}
+	}
+}
+
+func TestClassifyHopIP(t *testing.T) {
+	tests := map[string]string{
+		"8.8.8.8":         HopClassPublic,
+		"10.0.0.1":        HopClassPrivate,
+		"172.16.5.4":      HopClassPrivate,
+		"192.168.0.1":     HopClassPrivate,
+		"100.64.0.1":      HopClassCGNAT,
+		"100.127.255.254": HopClassCGNAT,
+		"100.128.0.1":     HopClassPublic,
+		"127.0.0.1":       HopClassLoopback,
+		"169.254.1.1":     HopClassLinkLocal,
+		"fd00::1":         HopClassPrivate,
+		"fe80::1":         HopClassLinkLocal,
+		"2001:4860::8888": HopClassPublic,
+	}
+
+	for ip, want := range tests {
+		if got := classifyHopIP(net.ParseIP(ip)); got != want {
+			t.Errorf("classifyHopIP(%s) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestParseCymruOrigin(t *testing.T) {
+	origin, err := parseCymruOrigin("15169 | 8.8.8.0/24 | US | arin | 2014-03-14")
+	if err != nil {
+		t.Fatalf("parseCymruOrigin() error = %v", err)
+	}
+	if origin.ASN != "15169" || origin.Prefix != "8.8.8.0/24" || origin.Country != "US" || origin.Registry != "arin" || origin.Allocated != "2014-03-14" {
+		t.Errorf("unexpected origin: %+v", origin)
+	}
+
+	if _, err := parseCymruOrigin("garbage"); err == nil {
+		t.Error("Expected an error for a malformed record")
+	}
+	if _, err := parseCymruOrigin(" | 8.8.8.0/24 | US"); err == nil {
+		t.Error("Expected an error for a record without an ASN")
+	}
+}
@@ -3,16 +3,22 @@ package collector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/typicalfo/netgaze/internal/model"
 )
 
 func collectGeo(ctx context.Context, target string, report *model.Report) error {
+	return collectGeoWithOptions(ctx, target, GeoOptions{}, report)
+}
+
+func collectGeoWithOptions(ctx context.Context, target string, opts GeoOptions, report *model.Report) error {
 	// Create context with 8-second timeout
 	ctx, cancel := context.WithTimeout(ctx, 8*time.Second)
 	defer cancel()
@@ -25,36 +31,135 @@ func collectGeo(ctx context.Context, target string, report *model.Report) error
 		return nil
 	}
 
-	// Run geolocation lookup in goroutine to respect context
-	resultChan := make(chan *GeoResponse, 1)
-	errorChan := make(chan error, 1)
+	providers, err := geoProviders(opts)
+	if err != nil {
+		report.Errors["geo"] = fmt.Sprintf("Geolocation setup failed: %v", err)
+		return nil
+	}
 
-	go func() {
-		resp, err := lookupGeolocation(ctx, ip.String())
-		if err != nil {
-			errorChan <- err
-			return
+	// Several configured providers are all asked, so their answers can
+	// be compared
+	resp, sources, answers, errs := lookupGeo(ctx, providers, ip, len(opts.Providers) > 1)
+	if resp == nil {
+		if ctx.Err() != nil {
+			report.Errors["geo"] = "Geolocation lookup timeout"
+		} else {
+			report.Errors["geo"] = fmt.Sprintf("Geolocation lookup failed: %v", errors.Join(errs...))
 		}
-		resultChan <- resp
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case resp := <-resultChan:
-		populateGeoData(resp, report)
-	case err := <-errorChan:
-		report.Errors["geo"] = fmt.Sprintf("Geolocation lookup failed: %v", err)
-		// Don't return error for geolocation - it's optional
-		return nil
-	case <-ctx.Done():
-		report.Errors["geo"] = "Geolocation lookup timeout"
 		// Don't return error for geolocation - it's optional
 		return nil
 	}
 
+	populateGeoData(resp, report)
+	report.Geo.Sources = sources
+	if len(answers) > 1 {
+		report.GeoCheck = &model.GeoCheck{Answers: answers}
+	}
 	return nil
 }
 
+// lookupGeoChain asks each provider in turn until the location is
+// complete, filling only the fields earlier providers left empty.
+// sources maps each filled field (by its JSON name) to the provider that
+// supplied it. The answer is nil when no provider had anything.
+func lookupGeoChain(ctx context.Context, providers []GeoProvider, ip net.IP) (*GeoResponse, map[string]string, []error) {
+	resp, sources, _, errs := lookupGeo(ctx, providers, ip, false)
+	return resp, sources, errs
+}
+
+// lookupGeo is lookupGeoChain that, with all set, asks every provider
+// at once instead of stopping early. The merge still follows the chain
+// order. Each answer with a location is also returned on its own.
+func lookupGeo(ctx context.Context, providers []GeoProvider, ip net.IP, all bool) (*GeoResponse, map[string]string, []model.GeoAnswer, []error) {
+	resps := make([]*GeoResponse, len(providers))
+	lookupErrs := make([]error, len(providers))
+	if all {
+		var wg sync.WaitGroup
+		for i, p := range providers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resps[i], lookupErrs[i] = p.Lookup(ctx, ip)
+			}()
+		}
+		wg.Wait()
+	}
+
+	merged := &GeoResponse{Status: "success", Query: ip.String()}
+	sources := make(map[string]string)
+	var answers []model.GeoAnswer
+	var errs []error
+
+	for i, p := range providers {
+		if !all {
+			if geoComplete(merged) || ctx.Err() != nil {
+				break
+			}
+			resps[i], lookupErrs[i] = p.Lookup(ctx, ip)
+		}
+		if lookupErrs[i] != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), lookupErrs[i]))
+			continue
+		}
+		resp := resps[i]
+		mergeGeo(merged, resp, p.Name(), sources)
+		if resp.CountryCode != "" || resp.Lat != 0 || resp.Lon != 0 {
+			answers = append(answers, model.GeoAnswer{
+				Provider:    p.Name(),
+				City:        resp.City,
+				CountryCode: resp.CountryCode,
+				Latitude:    resp.Lat,
+				Longitude:   resp.Lon,
+			})
+		}
+	}
+
+	if len(sources) == 0 {
+		if len(errs) == 0 {
+			errs = append(errs, fmt.Errorf("no provider knows %s", ip))
+		}
+		return nil, nil, nil, errs
+	}
+	return merged, sources, answers, errs
+}
+
+// geoComplete reports whether later providers have nothing essential
+// to add
+func geoComplete(g *GeoResponse) bool {
+	return g.CountryCode != "" && g.City != "" && (g.Lat != 0 || g.Lon != 0)
+}
+
+// mergeGeo copies the fields dst lacks from src, crediting provider
+func mergeGeo(dst, src *GeoResponse, provider string, sources map[string]string) {
+	for _, f := range []struct {
+		key      string
+		to, from *string
+	}{
+		{"city", &dst.City, &src.City},
+		{"region", &dst.Region, &src.Region},
+		{"region_code", &dst.RegionCode, &src.RegionCode},
+		{"country", &dst.Country, &src.Country},
+		{"country_code", &dst.CountryCode, &src.CountryCode},
+		{"org", &dst.Org, &src.Org},
+		{"isp", &dst.ISP, &src.ISP},
+		{"timezone", &dst.Timezone, &src.Timezone},
+	} {
+		if *f.to == "" && *f.from != "" {
+			*f.to = *f.from
+			sources[f.key] = provider
+		}
+	}
+	// Coordinates only make sense as a pair
+	if dst.Lat == 0 && dst.Lon == 0 && (src.Lat != 0 || src.Lon != 0) {
+		dst.Lat, dst.Lon = src.Lat, src.Lon
+		sources["lat"] = provider
+		sources["lon"] = provider
+	}
+	if dst.AS == "" && src.AS != "" {
+		dst.AS = src.AS
+	}
+}
+
 func getGeoTargetIP(target string) (net.IP, error) {
 	// If target is already an IP, return it
 	if ip := net.ParseIP(target); ip != nil {
@@ -87,9 +192,11 @@ func lookupGeolocation(ctx context.Context, ip string) (*GeoResponse, error) {
 	client := &http.Client{
 		Timeout: 3 * time.Second,
 	}
+	return fetchIPAPI(ctx, client, fmt.Sprintf("http://ip-api.com/json/%s", ip))
+}
 
-	// Make request to ip-api.com
-	url := fmt.Sprintf("http://ip-api.com/json/%s", ip)
+// fetchIPAPI queries an ip-api.com JSON endpoint
+func fetchIPAPI(ctx context.Context, client *http.Client, url string) (*GeoResponse, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -146,7 +253,8 @@ func populateGeoData(resp *GeoResponse, report *model.Report) {
 	}
 }
 
-// GeoResponse represents the response from ip-api.com
+// GeoResponse represents the response from ip-api.com; other providers
+// are converted to it
 type GeoResponse struct {
 	Status      string  `json:"status"`
 	Country     string  `json:"country"`
@@ -2,6 +2,9 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -278,3 +281,93 @@ func TestCollectGeo_Timeout(t *testing.T) {
 		t.Errorf("collectGeo() unexpected error = %v", err)
 	}
 }
+
+// fakeGeoProvider answers with a fixed response or error
+type fakeGeoProvider struct {
+	name  string
+	resp  *GeoResponse
+	err   error
+	calls int
+}
+
+func (f *fakeGeoProvider) Name() string { return f.name }
+
+func (f *fakeGeoProvider) Lookup(ctx context.Context, ip net.IP) (*GeoResponse, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	resp := *f.resp
+	return &resp, nil
+}
+
+func TestLookupGeoChain(t *testing.T) {
+	ip := net.ParseIP("8.8.8.8")
+	failing := &fakeGeoProvider{name: "mmdb", err: errors.New("no data for 8.8.8.8")}
+	countryOnly := &fakeGeoProvider{name: "ipinfo", resp: &GeoResponse{CountryCode: "US", Org: "Google LLC"}}
+	full := &fakeGeoProvider{name: "ip-api", resp: &GeoResponse{
+		City: "Mountain View", Country: "United States", CountryCode: "XX", Lat: 37.4, Lon: -122.1, ISP: "Google LLC",
+	}}
+	unused := &fakeGeoProvider{name: "spare", resp: &GeoResponse{City: "Elsewhere"}}
+
+	resp, sources, errs := lookupGeoChain(context.Background(), []GeoProvider{failing, countryOnly, full, unused}, ip)
+	if resp == nil {
+		t.Fatalf("Expected an answer, got errors %v", errs)
+	}
+	if resp.CountryCode != "US" || resp.City != "Mountain View" || resp.Org != "Google LLC" || resp.Lat != 37.4 || resp.Query != "8.8.8.8" {
+		t.Errorf("unexpected merged answer: %+v", resp)
+	}
+	want := map[string]string{
+		"country_code": "ipinfo", "org": "ipinfo",
+		"city": "ip-api", "country": "ip-api", "isp": "ip-api", "lat": "ip-api", "lon": "ip-api",
+	}
+	if len(sources) != len(want) {
+		t.Errorf("sources = %v, want %v", sources, want)
+	}
+	for k, v := range want {
+		if sources[k] != v {
+			t.Errorf("sources[%s] = %q, want %q", k, sources[k], v)
+		}
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "mmdb: no data") {
+		t.Errorf("Expected the mmdb failure to be kept, got %v", errs)
+	}
+	if unused.calls != 0 {
+		t.Error("Expected the chain to stop once the location was complete")
+	}
+
+	resp, _, errs = lookupGeoChain(context.Background(), []GeoProvider{failing}, ip)
+	if resp != nil || len(errs) != 1 {
+		t.Errorf("Expected no answer when every provider fails, got %+v %v", resp, errs)
+	}
+}
+
+func TestLookupGeoAll(t *testing.T) {
+	ip := net.ParseIP("1.1.1.1")
+	first := &fakeGeoProvider{name: "mmdb", resp: &GeoResponse{City: "Sydney", CountryCode: "AU", Lat: -33.8688, Lon: 151.2093}}
+	second := &fakeGeoProvider{name: "ipinfo", resp: &GeoResponse{City: "San Francisco", CountryCode: "US", Lat: 37.7749, Lon: -122.4194}}
+	asnOnly := &fakeGeoProvider{name: "asn", resp: &GeoResponse{AS: "AS13335 CLOUDFLARENET"}}
+	failing := &fakeGeoProvider{name: "ip-api", err: errors.New("timeout")}
+
+	resp, sources, answers, errs := lookupGeo(context.Background(), []GeoProvider{first, second, asnOnly, failing}, ip, true)
+	if resp == nil || resp.City != "Sydney" || sources["city"] != "mmdb" {
+		t.Fatalf("Expected the chain order to decide the merge, got %+v %v", resp, sources)
+	}
+	if second.calls != 1 || failing.calls != 1 {
+		t.Error("Expected every provider to be asked")
+	}
+	if len(answers) != 2 || answers[0].Provider != "mmdb" || answers[1].CountryCode != "US" {
+		t.Errorf("unexpected answers: %+v", answers)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected the ip-api failure, got %v", errs)
+	}
+}
+
+func TestCollectGeoWithOptionsSources(t *testing.T) {
+	report := &model.Report{Errors: make(map[string]string)}
+	collectGeoWithOptions(context.Background(), "8.8.8.8", GeoOptions{Providers: []string{"bogus"}}, report)
+	if !strings.Contains(report.Errors["geo"], "unknown geo provider") {
+		t.Errorf("Expected a setup error, got %v", report.Errors)
+	}
+}
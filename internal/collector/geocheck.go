@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Vantage is where netgaze runs from, for the speed-of-light check
+type Vantage struct {
+	Name     string // e.g. "Frankfurt"; the coordinates are shown without one
+	Lat, Lon float64
+}
+
+const (
+	// fiberKmPerMs is how far light gets through fibre in a millisecond,
+	// about two thirds of c; a round trip covers the distance twice
+	fiberKmPerMs = 200
+	// geoSlackKm allows for databases placing an address at the nearest
+	// large city, or at the middle of its country
+	geoSlackKm = 250
+	// geoSpreadKm is how far apart two providers may put an address and
+	// still agree
+	geoSpreadKm = 500
+
+	earthRadiusKm = 6371
+)
+
+// checkGeo rates the geolocation once the collectors are done, as it
+// needs the ping RTT as well as the location. Confidence starts at 100
+// and loses 60 when the RTT rules the location out, 30 when providers
+// disagree on the country, 20 when their coordinates are more than
+// geoSpreadKm apart, and 20 each for having no second provider and no
+// RTT check to corroborate it.
+func checkGeo(report *model.Report, vantage *Vantage) {
+	geo := report.Geo
+	if len(geo.Sources) == 0 {
+		// No provider located it; the country from the ASN registry is
+		// not a location
+		return
+	}
+	located := geo.Latitude != 0 || geo.Longitude != 0
+	check := report.GeoCheck
+	if check == nil {
+		check = &model.GeoCheck{}
+	}
+	confidence := 100
+
+	if len(check.Answers) > 1 {
+		confidence -= compareGeoAnswers(check)
+	} else {
+		confidence -= 20
+	}
+
+	rtt, ok := minPingRTT(report)
+	if vantage != nil && located && ok {
+		confidence -= checkGeoRTT(check, report, vantage, rtt)
+	} else {
+		confidence -= 20
+	}
+
+	check.Confidence = max(confidence, 0)
+	report.GeoCheck = check
+}
+
+// compareGeoAnswers records where the providers disagree and returns
+// the confidence it costs
+func compareGeoAnswers(check *model.GeoCheck) int {
+	cost := 0
+
+	byCountry := make(map[string][]string)
+	for _, a := range check.Answers {
+		if a.CountryCode != "" {
+			byCountry[a.CountryCode] = append(byCountry[a.CountryCode], a.Provider)
+		}
+	}
+	if len(byCountry) > 1 {
+		var parts []string
+		for _, cc := range slices.Sorted(maps.Keys(byCountry)) {
+			parts = append(parts, fmt.Sprintf("%s (%s)", cc, strings.Join(byCountry[cc], ", ")))
+		}
+		check.Findings = append(check.Findings, "providers disagree on the country: "+strings.Join(parts, ", "))
+		cost += 30
+	}
+
+	var farA, farB model.GeoAnswer
+	for i, a := range check.Answers {
+		for _, b := range check.Answers[i+1:] {
+			if !hasCoordinates(a) || !hasCoordinates(b) {
+				continue
+			}
+			if d := distanceKm(a.Latitude, a.Longitude, b.Latitude, b.Longitude); d > check.SpreadKm {
+				check.SpreadKm = math.Round(d)
+				farA, farB = a, b
+			}
+		}
+	}
+	if check.SpreadKm > geoSpreadKm {
+		check.Findings = append(check.Findings, fmt.Sprintf("providers place it %.0f km apart: %s (%s), %s (%s)",
+			check.SpreadKm, answerPlace(farA), farA.Provider, answerPlace(farB), farB.Provider))
+		cost += 20
+	}
+	return cost
+}
+
+// checkGeoRTT compares the distance from the vantage point with how far
+// a packet can travel in half the minimum RTT, and returns the
+// confidence the result costs
+func checkGeoRTT(check *model.GeoCheck, report *model.Report, vantage *Vantage, rtt time.Duration) int {
+	geo := report.Geo
+	check.Vantage = vantage.Name
+	if check.Vantage == "" {
+		check.Vantage = fmt.Sprintf("%.4f,%.4f", vantage.Lat, vantage.Lon)
+	}
+	ms := float64(rtt.Microseconds()) / 1000
+	check.MinRTTMs = ms
+	check.MaxDistanceKm = math.Round(ms * fiberKmPerMs / 2)
+	check.DistanceKm = math.Round(distanceKm(vantage.Lat, vantage.Lon, geo.Latitude, geo.Longitude))
+	if check.DistanceKm <= check.MaxDistanceKm+geoSlackKm {
+		return 0
+	}
+
+	check.Implausible = true
+	place := answerPlace(model.GeoAnswer{City: geo.City, CountryCode: geo.CountryCode, Latitude: geo.Latitude, Longitude: geo.Longitude})
+	finding := fmt.Sprintf("geolocation implausible: a %s RTT from %s allows at most %.0f km, but %s is %.0f km away",
+		formatDuration(rtt), check.Vantage, check.MaxDistanceKm, place, check.DistanceKm)
+
+	// Another provider may have it right; anycast and cloud ranges are
+	// often placed at the operator's headquarters
+	var fits []string
+	for _, a := range check.Answers {
+		if hasCoordinates(a) && distanceKm(vantage.Lat, vantage.Lon, a.Latitude, a.Longitude) <= check.MaxDistanceKm+geoSlackKm {
+			fits = append(fits, fmt.Sprintf("%s (%s)", answerPlace(a), a.Provider))
+		}
+	}
+	if len(fits) > 0 {
+		finding += "; the RTT fits " + strings.Join(fits, ", ")
+	}
+	check.Findings = append(check.Findings, finding)
+	return 60
+}
+
+// minPingRTT is the fastest successful ping
+func minPingRTT(report *model.Report) (time.Duration, bool) {
+	if !report.Ping.Success || report.Ping.MinRtt == "" {
+		return 0, false
+	}
+	rtt, err := time.ParseDuration(report.Ping.MinRtt)
+	if err != nil || rtt <= 0 {
+		return 0, false
+	}
+	return rtt, true
+}
+
+func hasCoordinates(a model.GeoAnswer) bool {
+	return a.Latitude != 0 || a.Longitude != 0
+}
+
+// answerPlace names a location as "Sydney, AU", falling back to the
+// country code or the coordinates
+func answerPlace(a model.GeoAnswer) string {
+	switch {
+	case a.City != "" && a.CountryCode != "":
+		return a.City + ", " + a.CountryCode
+	case a.City != "":
+		return a.City
+	case a.CountryCode != "":
+		return a.CountryCode
+	}
+	return fmt.Sprintf("%.4f,%.4f", a.Latitude, a.Longitude)
+}
+
+// distanceKm is the great-circle distance between two points
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(min(h, 1)))
+}
@@ -0,0 +1,129 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+var frankfurt = &Vantage{Name: "Frankfurt", Lat: 50.1109, Lon: 8.6821}
+
+// locatedReport is a report for an address the providers put at
+// lat,lon, pinged in minRTT
+func locatedReport(city, cc string, lat, lon float64, minRTT string) *model.Report {
+	report := &model.Report{Errors: make(map[string]string)}
+	report.Geo.City = city
+	report.Geo.CountryCode = cc
+	report.Geo.Latitude = lat
+	report.Geo.Longitude = lon
+	report.Geo.Sources = map[string]string{"city": "mmdb"}
+	if minRTT != "" {
+		report.Ping.Success = true
+		report.Ping.MinRtt = minRTT
+	}
+	return report
+}
+
+func TestDistanceKm(t *testing.T) {
+	if d := distanceKm(50.1109, 8.6821, -33.8688, 151.2093); d < 16400 || d > 16600 {
+		t.Errorf("Frankfurt to Sydney = %.0f km, want about 16500", d)
+	}
+	if d := distanceKm(1, 2, 1, 2); d != 0 {
+		t.Errorf("distance to itself = %v", d)
+	}
+}
+
+func TestCheckGeoImplausible(t *testing.T) {
+	report := locatedReport("Sydney", "AU", -33.8688, 151.2093, "2.1ms")
+	checkGeo(report, frankfurt)
+
+	c := report.GeoCheck
+	if c == nil || !c.Implausible {
+		t.Fatalf("Expected an implausible location, got %+v", c)
+	}
+	if c.MaxDistanceKm != 210 || c.DistanceKm < 16400 || c.MinRTTMs != 2.1 || c.Vantage != "Frankfurt" {
+		t.Errorf("unexpected check: %+v", c)
+	}
+	if len(c.Findings) != 1 || !strings.HasPrefix(c.Findings[0], "geolocation implausible: a 2.1ms RTT from Frankfurt allows at most 210 km, but Sydney, AU") {
+		t.Errorf("unexpected findings: %q", c.Findings)
+	}
+	if c.Confidence != 20 {
+		t.Errorf("Confidence = %d, want 20", c.Confidence)
+	}
+}
+
+func TestCheckGeoPlausible(t *testing.T) {
+	// Amsterdam is 360 km from Frankfurt: within reach of a 7ms RTT
+	report := locatedReport("Amsterdam", "NL", 52.3676, 4.9041, "7.0ms")
+	checkGeo(report, frankfurt)
+	c := report.GeoCheck
+	if c.Implausible || len(c.Findings) != 0 || c.Confidence != 80 {
+		t.Errorf("unexpected check: %+v", c)
+	}
+
+	// Without a vantage point or a ping there is nothing to measure
+	for _, tt := range []struct {
+		vantage *Vantage
+		rtt     string
+	}{{nil, "7.0ms"}, {frankfurt, ""}} {
+		report = locatedReport("Amsterdam", "NL", 52.3676, 4.9041, tt.rtt)
+		checkGeo(report, tt.vantage)
+		if c := report.GeoCheck; c.Confidence != 60 || c.MaxDistanceKm != 0 {
+			t.Errorf("unexpected check without an RTT check: %+v", c)
+		}
+	}
+
+	// Nothing to rate when no provider answered
+	report = &model.Report{Errors: make(map[string]string)}
+	report.Geo.CountryCode = "US"
+	checkGeo(report, frankfurt)
+	if report.GeoCheck != nil {
+		t.Errorf("Expected no check, got %+v", report.GeoCheck)
+	}
+}
+
+func TestCheckGeoAnswers(t *testing.T) {
+	report := locatedReport("Sydney", "AU", -33.8688, 151.2093, "1.8ms")
+	report.GeoCheck = &model.GeoCheck{Answers: []model.GeoAnswer{
+		{Provider: "mmdb", City: "Sydney", CountryCode: "AU", Latitude: -33.8688, Longitude: 151.2093},
+		{Provider: "ipinfo", City: "Frankfurt am Main", CountryCode: "DE", Latitude: 50.1155, Longitude: 8.6842},
+		{Provider: "ip-api", CountryCode: "DE"},
+	}}
+	checkGeo(report, frankfurt)
+
+	c := report.GeoCheck
+	if c.SpreadKm < 16400 {
+		t.Errorf("SpreadKm = %v", c.SpreadKm)
+	}
+	want := []string{
+		"providers disagree on the country: AU (mmdb), DE (ipinfo, ip-api)",
+		"providers place it",
+		"geolocation implausible",
+	}
+	if len(c.Findings) != len(want) {
+		t.Fatalf("findings = %q", c.Findings)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(c.Findings[i], w) {
+			t.Errorf("finding %d = %q, want %q...", i, c.Findings[i], w)
+		}
+	}
+	if !strings.HasSuffix(c.Findings[2], "the RTT fits Frankfurt am Main, DE (ipinfo)") {
+		t.Errorf("Expected the plausible answer to be named, got %q", c.Findings[2])
+	}
+	if c.Confidence != 0 {
+		t.Errorf("Confidence = %d, want 0", c.Confidence)
+	}
+
+	// Agreeing providers corroborate each other
+	report = locatedReport("Amsterdam", "NL", 52.3676, 4.9041, "7.0ms")
+	report.GeoCheck = &model.GeoCheck{Answers: []model.GeoAnswer{
+		{Provider: "mmdb", City: "Amsterdam", CountryCode: "NL", Latitude: 52.3676, Longitude: 4.9041},
+		{Provider: "ipinfo", City: "Haarlem", CountryCode: "NL", Latitude: 52.3874, Longitude: 4.6462},
+	}}
+	checkGeo(report, frankfurt)
+	if c := report.GeoCheck; c.Confidence != 100 || len(c.Findings) != 0 || c.SpreadKm > 20 {
+		t.Errorf("unexpected check: %+v", c)
+	}
+}
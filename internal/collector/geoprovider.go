@@ -0,0 +1,356 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/mmdb"
+)
+
+// Geolocation provider names, as used in the geo.providers setting
+const (
+	GeoProviderIPAPI  = "ip-api" // ip-api.com; the pro endpoint when a key is set
+	GeoProviderIPInfo = "ipinfo" // ipinfo.io
+	GeoProviderMMDB   = "mmdb"   // local MaxMind DB files
+)
+
+// GeoProviderNames lists the providers geo.providers accepts
+var GeoProviderNames = []string{GeoProviderMMDB, GeoProviderIPInfo, GeoProviderIPAPI}
+
+// GeoOptions configure where geolocation comes from
+type GeoOptions struct {
+	// Providers is the fallback chain, first asked first. Empty means
+	// mmdb (when MMDBPaths is set), ipinfo (when IPInfoToken is set),
+	// then ip-api.
+	Providers   []string
+	IPAPIKey    string // ip-api pro key; switches to HTTPS
+	IPInfoToken string
+	// MMDBPaths are City, Country or ASN databases, consulted together;
+	// an ASN database also serves the ASN collector
+	MMDBPaths []string
+	// Vantage is where netgaze runs; without it the location is not
+	// checked against the ping RTT
+	Vantage *Vantage
+}
+
+// GeoProvider looks up where an address is. Answers use ip-api.com's
+// shape; fields a provider does not know are left empty.
+type GeoProvider interface {
+	Name() string
+	Lookup(ctx context.Context, ip net.IP) (*GeoResponse, error)
+}
+
+// geoProviders builds the chain described by opts
+func geoProviders(opts GeoOptions) ([]GeoProvider, error) {
+	names := opts.Providers
+	if len(names) == 0 {
+		if len(opts.MMDBPaths) > 0 {
+			names = append(names, GeoProviderMMDB)
+		}
+		if opts.IPInfoToken != "" {
+			names = append(names, GeoProviderIPInfo)
+		}
+		names = append(names, GeoProviderIPAPI)
+	}
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	var providers []GeoProvider
+	for _, name := range names {
+		switch name {
+		case GeoProviderIPAPI:
+			providers = append(providers, &ipAPIProvider{client: client, key: opts.IPAPIKey})
+		case GeoProviderIPInfo:
+			providers = append(providers, &ipinfoProvider{client: client, token: opts.IPInfoToken})
+		case GeoProviderMMDB:
+			if len(opts.MMDBPaths) == 0 {
+				return nil, fmt.Errorf("geo provider mmdb needs geo.mmdb_path")
+			}
+			p := &mmdbProvider{}
+			for _, path := range opts.MMDBPaths {
+				r, err := openMMDB(path)
+				if err != nil {
+					return nil, err
+				}
+				p.dbs = append(p.dbs, r)
+			}
+			providers = append(providers, p)
+		default:
+			return nil, fmt.Errorf("unknown geo provider %q (valid: %s)", name, strings.Join(GeoProviderNames, ", "))
+		}
+	}
+	return providers, nil
+}
+
+// ipAPIProvider queries ip-api.com: plain HTTP for the free service,
+// HTTPS on pro.ip-api.com with a key
+type ipAPIProvider struct {
+	client  *http.Client
+	key     string
+	baseURL string // for tests
+}
+
+func (p *ipAPIProvider) Name() string {
+	if p.key != "" {
+		return GeoProviderIPAPI + "-pro"
+	}
+	return GeoProviderIPAPI
+}
+
+func (p *ipAPIProvider) Lookup(ctx context.Context, ip net.IP) (*GeoResponse, error) {
+	base := p.baseURL
+	if base == "" {
+		base = "http://ip-api.com"
+		if p.key != "" {
+			base = "https://pro.ip-api.com"
+		}
+	}
+	u := base + "/json/" + ip.String()
+	if p.key != "" {
+		u += "?key=" + url.QueryEscape(p.key)
+	}
+	return fetchIPAPI(ctx, p.client, u)
+}
+
+// ipinfoProvider queries ipinfo.io, with a token when one is set
+type ipinfoProvider struct {
+	client  *http.Client
+	token   string
+	baseURL string // for tests
+}
+
+func (p *ipinfoProvider) Name() string {
+	return GeoProviderIPInfo
+}
+
+// ipinfoResponse is ipinfo.io's JSON answer
+type ipinfoResponse struct {
+	IP       string `json:"ip"`
+	City     string `json:"city"`
+	Region   string `json:"region"`
+	Country  string `json:"country"` // ISO code
+	Loc      string `json:"loc"`     // "lat,lon"
+	Org      string `json:"org"`     // "AS15169 Google LLC"
+	Timezone string `json:"timezone"`
+	Bogon    bool   `json:"bogon"`
+	Error    *struct {
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *ipinfoProvider) Lookup(ctx context.Context, ip net.IP) (*GeoResponse, error) {
+	base := p.baseURL
+	if base == "" {
+		base = "https://ipinfo.io"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/"+ip.String()+"/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "netgaze/1.0")
+	req.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var info ipinfoResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&info)
+	if info.Error != nil {
+		return nil, fmt.Errorf("API error: %s", strings.TrimSpace(info.Error.Title+": "+info.Error.Message))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status: %d", resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", decodeErr)
+	}
+	if info.Bogon {
+		return nil, fmt.Errorf("%s is a bogon address", ip)
+	}
+
+	geo := &GeoResponse{
+		Status:      "success",
+		Query:       info.IP,
+		City:        info.City,
+		Region:      info.Region,
+		CountryCode: info.Country,
+		Timezone:    info.Timezone,
+		AS:          info.Org,
+	}
+	if lat, lon, ok := strings.Cut(info.Loc, ","); ok {
+		geo.Lat, _ = strconv.ParseFloat(lat, 64)
+		geo.Lon, _ = strconv.ParseFloat(lon, 64)
+	}
+	// "AS15169 Google LLC" names the holder after the AS number
+	if asn, name, ok := strings.Cut(info.Org, " "); ok && strings.HasPrefix(asn, "AS") {
+		geo.Org = name
+	} else {
+		geo.Org = info.Org
+	}
+	return geo, nil
+}
+
+// geoDB is an opened MaxMind DB file
+type geoDB interface {
+	Lookup(addr netip.Addr) (map[string]any, netip.Prefix, bool, error)
+}
+
+var (
+	mmdbMu    sync.Mutex
+	mmdbFiles = make(map[string]*mmdb.Reader)
+)
+
+// openMMDB reads a database once per process; the geo and ASN collectors
+// share it
+func openMMDB(path string) (*mmdb.Reader, error) {
+	mmdbMu.Lock()
+	defer mmdbMu.Unlock()
+	if r, ok := mmdbFiles[path]; ok {
+		return r, nil
+	}
+	r, err := mmdb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	mmdbFiles[path] = r
+	return r, nil
+}
+
+// openGeoDBs opens the databases that can be opened; the geo collector
+// reports the others
+func openGeoDBs(paths []string) []geoDB {
+	var dbs []geoDB
+	for _, path := range paths {
+		if r, err := openMMDB(path); err == nil {
+			dbs = append(dbs, r)
+		}
+	}
+	return dbs
+}
+
+// mmdbProvider answers from MaxMind DB files: GeoLite2/GeoIP2 City,
+// Country and ASN, and the DB-IP lite files in the same layouts. Each
+// file fills what the ones before it left empty.
+type mmdbProvider struct {
+	dbs []geoDB
+}
+
+func (p *mmdbProvider) Name() string {
+	return GeoProviderMMDB
+}
+
+func (p *mmdbProvider) Lookup(ctx context.Context, ip net.IP) (*GeoResponse, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return nil, fmt.Errorf("invalid address %s", ip)
+	}
+	addr = addr.Unmap()
+
+	geo := &GeoResponse{Status: "success", Query: addr.String()}
+	found := false
+	for _, db := range p.dbs {
+		record, _, ok, err := db.Lookup(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = true
+			mergeGeo(geo, geoFromMMDB(record), p.Name(), make(map[string]string))
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no data for %s", addr)
+	}
+	return geo, nil
+}
+
+// geoFromMMDB reads the GeoIP2 City layout:
+//
+//	city.names.en, subdivisions[0].{iso_code,names.en},
+//	country.{iso_code,names.en}, location.{latitude,longitude,time_zone}
+//
+// and the AS fields of the ASN and ISP layouts, which the commercial
+// City databases nest under traits
+func geoFromMMDB(record map[string]any) *GeoResponse {
+	geo := &GeoResponse{Status: "success"}
+
+	geo.City = mmdbName(mmdbMap(record, "city"))
+	geo.Country = mmdbName(mmdbMap(record, "country"))
+	geo.CountryCode, _ = mmdbMap(record, "country")["iso_code"].(string)
+	if subs, ok := record["subdivisions"].([]any); ok && len(subs) > 0 {
+		if sub, ok := subs[0].(map[string]any); ok {
+			geo.Region = mmdbName(sub)
+			geo.RegionCode, _ = sub["iso_code"].(string)
+		}
+	}
+
+	location := mmdbMap(record, "location")
+	geo.Lat = mmdbFloat(location["latitude"])
+	geo.Lon = mmdbFloat(location["longitude"])
+	geo.Timezone, _ = location["time_zone"].(string)
+
+	for _, m := range []map[string]any{record, mmdbMap(record, "traits")} {
+		if geo.ISP == "" {
+			geo.ISP, _ = m["isp"].(string)
+		}
+		if geo.Org == "" {
+			geo.Org, _ = m["organization"].(string)
+		}
+	}
+	if asn, name, ok := asnFromMMDB(record); ok {
+		geo.AS = strings.TrimSpace(fmt.Sprintf("AS%d %s", asn, name))
+		if geo.Org == "" {
+			geo.Org = name
+		}
+	}
+	return geo
+}
+
+// asnFromMMDB reads autonomous_system_number and
+// autonomous_system_organization, at the top level (ASN and ISP
+// databases) or under traits
+func asnFromMMDB(record map[string]any) (uint64, string, bool) {
+	for _, m := range []map[string]any{record, mmdbMap(record, "traits")} {
+		if asn, ok := m["autonomous_system_number"].(uint64); ok && asn != 0 {
+			name, _ := m["autonomous_system_organization"].(string)
+			return asn, name, true
+		}
+	}
+	return 0, "", false
+}
+
+func mmdbMap(m map[string]any, key string) map[string]any {
+	v, _ := m[key].(map[string]any)
+	return v
+}
+
+// mmdbName returns the English name of a city, country or subdivision
+func mmdbName(m map[string]any) string {
+	name, _ := mmdbMap(m, "names")["en"].(string)
+	return name
+}
+
+func mmdbFloat(v any) float64 {
+	switch f := v.(type) {
+	case float64:
+		return f
+	case float32:
+		return float64(f)
+	}
+	return 0
+}
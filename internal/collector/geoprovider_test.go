@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIPAPIProvider(t *testing.T) {
+	var gotPath, gotKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotKey = r.URL.Path, r.URL.Query().Get("key")
+		w.Write([]byte(`{"status":"success","country":"United States","countryCode":"US","region":"CA","city":"Mountain View","lat":37.4056,"lon":-122.0775,"isp":"Google LLC","as":"AS15169 Google LLC","query":"8.8.8.8"}`))
+	}))
+	defer srv.Close()
+
+	free := &ipAPIProvider{client: srv.Client(), baseURL: srv.URL}
+	resp, err := free.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	if err != nil || resp.City != "Mountain View" || resp.ISP != "Google LLC" {
+		t.Fatalf("Lookup() = %+v, %v", resp, err)
+	}
+	if gotPath != "/json/8.8.8.8" || gotKey != "" || free.Name() != "ip-api" {
+		t.Errorf("unexpected free request: %s key=%q name=%s", gotPath, gotKey, free.Name())
+	}
+
+	pro := &ipAPIProvider{client: srv.Client(), baseURL: srv.URL, key: "s3cret"}
+	if _, err := pro.Lookup(context.Background(), net.ParseIP("8.8.8.8")); err != nil {
+		t.Fatal(err)
+	}
+	if gotKey != "s3cret" || pro.Name() != "ip-api-pro" {
+		t.Errorf("unexpected pro request: key=%q name=%s", gotKey, pro.Name())
+	}
+}
+
+func TestIPAPIProviderFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"fail","message":"reserved range","query":"10.0.0.1"}`))
+	}))
+	defer srv.Close()
+
+	p := &ipAPIProvider{client: srv.Client(), baseURL: srv.URL}
+	if _, err := p.Lookup(context.Background(), net.ParseIP("10.0.0.1")); err == nil || !strings.Contains(err.Error(), "reserved range") {
+		t.Errorf("Expected the API error, got %v", err)
+	}
+}
+
+func TestIPInfoProvider(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/8.8.8.8/json":
+			w.Write([]byte(`{"ip":"8.8.8.8","hostname":"dns.google","city":"Mountain View","region":"California","country":"US","loc":"37.4056,-122.0775","org":"AS15169 Google LLC","postal":"94043","timezone":"America/Los_Angeles"}`))
+		case "/10.0.0.1/json":
+			w.Write([]byte(`{"ip":"10.0.0.1","bogon":true}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"title":"Rate limit exceeded","message":"Upgrade to increase your usage limits"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	p := &ipinfoProvider{client: srv.Client(), baseURL: srv.URL, token: "tok"}
+	resp, err := p.Lookup(context.Background(), net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.City != "Mountain View" || resp.Region != "California" || resp.CountryCode != "US" || resp.Timezone != "America/Los_Angeles" {
+		t.Errorf("unexpected location: %+v", resp)
+	}
+	if resp.Lat != 37.4056 || resp.Lon != -122.0775 {
+		t.Errorf("unexpected coordinates: %v,%v", resp.Lat, resp.Lon)
+	}
+	if resp.Org != "Google LLC" || resp.AS != "AS15169 Google LLC" {
+		t.Errorf("unexpected org: %q / %q", resp.Org, resp.AS)
+	}
+	if auth != "Bearer tok" {
+		t.Errorf("Expected the token as a bearer, got %q", auth)
+	}
+
+	if _, err := p.Lookup(context.Background(), net.ParseIP("10.0.0.1")); err == nil || !strings.Contains(err.Error(), "bogon") {
+		t.Errorf("Expected a bogon error, got %v", err)
+	}
+	if _, err := p.Lookup(context.Background(), net.ParseIP("1.1.1.1")); err == nil || !strings.Contains(err.Error(), "Rate limit") {
+		t.Errorf("Expected the rate limit error, got %v", err)
+	}
+}
+
+func TestGeoFromMMDB(t *testing.T) {
+	record := map[string]any{
+		"city":      map[string]any{"names": map[string]any{"en": "Frankfurt am Main", "de": "Frankfurt am Main"}},
+		"continent": map[string]any{"code": "EU"},
+		"country":   map[string]any{"iso_code": "DE", "names": map[string]any{"en": "Germany"}},
+		"location":  map[string]any{"latitude": 50.1169, "longitude": float32(8.6837), "time_zone": "Europe/Berlin"},
+		"subdivisions": []any{
+			map[string]any{"iso_code": "HE", "names": map[string]any{"en": "Hesse"}},
+		},
+	}
+
+	geo := geoFromMMDB(record)
+	if geo.City != "Frankfurt am Main" || geo.Country != "Germany" || geo.CountryCode != "DE" ||
+		geo.Region != "Hesse" || geo.RegionCode != "HE" || geo.Timezone != "Europe/Berlin" {
+		t.Errorf("unexpected geo: %+v", geo)
+	}
+	if geo.Lat != 50.1169 || geo.Lon < 8.68 || geo.Lon > 8.69 {
+		t.Errorf("unexpected coordinates: %v,%v", geo.Lat, geo.Lon)
+	}
+
+	// A Country database has no city or location
+	geo = geoFromMMDB(map[string]any{"country": map[string]any{"iso_code": "NL"}})
+	if geo.CountryCode != "NL" || geo.City != "" || geo.Lat != 0 {
+		t.Errorf("unexpected geo: %+v", geo)
+	}
+}
+
+func TestGeoFromMMDBASN(t *testing.T) {
+	// GeoLite2-ASN and DB-IP ASN lite
+	geo := geoFromMMDB(map[string]any{
+		"autonomous_system_number":       uint64(15169),
+		"autonomous_system_organization": "GOOGLE",
+	})
+	if geo.AS != "AS15169 GOOGLE" || geo.Org != "GOOGLE" || geo.City != "" {
+		t.Errorf("unexpected geo: %+v", geo)
+	}
+
+	// GeoIP2 Enterprise nests them under traits, next to isp and organization
+	geo = geoFromMMDB(map[string]any{
+		"country": map[string]any{"iso_code": "US"},
+		"traits": map[string]any{
+			"autonomous_system_number":       uint64(7922),
+			"autonomous_system_organization": "COMCAST-7922",
+			"isp":                            "Comcast Cable",
+			"organization":                   "Comcast Business",
+		},
+	})
+	if geo.AS != "AS7922 COMCAST-7922" || geo.ISP != "Comcast Cable" || geo.Org != "Comcast Business" {
+		t.Errorf("unexpected geo: %+v", geo)
+	}
+}
+
+// fakeGeoDB answers every address inside network with record
+type fakeGeoDB struct {
+	network netip.Prefix
+	record  map[string]any
+}
+
+func (f *fakeGeoDB) Lookup(addr netip.Addr) (map[string]any, netip.Prefix, bool, error) {
+	if !f.network.Contains(addr) {
+		return nil, netip.Prefix{}, false, nil
+	}
+	return f.record, f.network, true, nil
+}
+
+func TestMMDBProvider(t *testing.T) {
+	city := &fakeGeoDB{
+		network: netip.MustParsePrefix("8.8.8.0/24"),
+		record: map[string]any{
+			"city":     map[string]any{"names": map[string]any{"en": "Mountain View"}},
+			"country":  map[string]any{"iso_code": "US", "names": map[string]any{"en": "United States"}},
+			"location": map[string]any{"latitude": 37.386, "longitude": -122.0838},
+		},
+	}
+	asn := &fakeGeoDB{
+		network: netip.MustParsePrefix("8.8.0.0/16"),
+		record: map[string]any{
+			"autonomous_system_number":       uint64(15169),
+			"autonomous_system_organization": "GOOGLE",
+		},
+	}
+	p := &mmdbProvider{dbs: []geoDB{city, asn}}
+
+	// IPv4-mapped addresses are looked up as IPv4
+	geo, err := p.Lookup(context.Background(), net.ParseIP("8.8.8.8").To16())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geo.Query != "8.8.8.8" || geo.City != "Mountain View" || geo.CountryCode != "US" || geo.AS != "AS15169 GOOGLE" || geo.Org != "GOOGLE" {
+		t.Errorf("unexpected geo: %+v", geo)
+	}
+
+	// Only the ASN database knows this one
+	geo, err = p.Lookup(context.Background(), net.ParseIP("8.8.4.4"))
+	if err != nil || geo.AS != "AS15169 GOOGLE" || geo.City != "" {
+		t.Errorf("Lookup(8.8.4.4) = %+v, %v", geo, err)
+	}
+
+	if _, err := p.Lookup(context.Background(), net.ParseIP("1.1.1.1")); err == nil {
+		t.Error("Expected an error for an address no database knows")
+	}
+}
+
+func TestGeoProviders(t *testing.T) {
+	names := func(ps []GeoProvider) string {
+		var out []string
+		for _, p := range ps {
+			out = append(out, p.Name())
+		}
+		return strings.Join(out, ",")
+	}
+
+	ps, err := geoProviders(GeoOptions{})
+	if err != nil || names(ps) != "ip-api" {
+		t.Errorf("default chain = %s, %v", names(ps), err)
+	}
+	ps, err = geoProviders(GeoOptions{IPInfoToken: "t", IPAPIKey: "k"})
+	if err != nil || names(ps) != "ipinfo,ip-api-pro" {
+		t.Errorf("chain with credentials = %s, %v", names(ps), err)
+	}
+	ps, err = geoProviders(GeoOptions{Providers: []string{"ip-api", "ipinfo"}})
+	if err != nil || names(ps) != "ip-api,ipinfo" {
+		t.Errorf("configured chain = %s, %v", names(ps), err)
+	}
+
+	for _, opts := range []GeoOptions{
+		{Providers: []string{"maxmind"}},
+		{Providers: []string{"mmdb"}},
+		{MMDBPaths: []string{"/nonexistent/GeoLite2-City.mmdb"}},
+	} {
+		if _, err := geoProviders(opts); err == nil {
+			t.Errorf("geoProviders(%+v) succeeded, want an error", opts)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/icmp"
+)
+
+// RFC 5837 interface roles, from the top two bits of the C-Type
+var interfaceRoles = [4]string{"incoming", "sub-ip", "outgoing", "next-hop"}
+
+// icmpExtensions returns the MPLS label stack and interface information
+// carried in an ICMP error's RFC 4884 extension structure
+func icmpExtensions(msg *icmp.Message) ([]model.MPLSLabel, []model.HopInterface) {
+	if msg == nil {
+		return nil, nil
+	}
+
+	var exts []icmp.Extension
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		exts = body.Extensions
+	case *icmp.DstUnreach:
+		exts = body.Extensions
+	}
+
+	var labels []model.MPLSLabel
+	var ifaces []model.HopInterface
+	for _, ext := range exts {
+		switch ext := ext.(type) {
+		case *icmp.MPLSLabelStack:
+			for _, l := range ext.Labels {
+				labels = append(labels, model.MPLSLabel{Label: l.Label, TC: l.TC, Bottom: l.S, TTL: l.TTL})
+			}
+		case *icmp.InterfaceInfo:
+			iface := model.HopInterface{Role: interfaceRoles[(ext.Type>>6)&3]}
+			if ext.Interface != nil {
+				iface.Index = ext.Interface.Index
+				iface.Name = ext.Interface.Name
+				iface.MTU = ext.Interface.MTU
+			}
+			if ext.Addr != nil {
+				iface.IP = ext.Addr.IP.String()
+			}
+			ifaces = append(ifaces, iface)
+		}
+	}
+	return labels, ifaces
+}
+
+// quotedTTL returns the TTL (or hop limit) of the probe as quoted back in
+// a Time Exceeded message. Routers normally quote 1; more means hops
+// along the way forwarded the probe without decrementing the IP TTL.
+func quotedTTL(msg *icmp.Message, v6 bool) int {
+	if msg == nil {
+		return 0
+	}
+	body, ok := msg.Body.(*icmp.TimeExceeded)
+	if !ok {
+		return 0
+	}
+	if v6 {
+		if len(body.Data) < 40 {
+			return 0
+		}
+		return int(body.Data[7])
+	}
+	if len(body.Data) < 20 {
+		return 0
+	}
+	return int(body.Data[8])
+}
+
+// parseMPLSField parses the label stack Linux traceroute -e prints,
+// e.g. "<MPLS:L=24000,E=0,S=0,T=1/L=16,E=0,S=1,T=1>"
+func parseMPLSField(field string) ([]model.MPLSLabel, error) {
+	inner, ok := strings.CutPrefix(field, "<MPLS:")
+	if !ok || !strings.HasSuffix(inner, ">") {
+		return nil, fmt.Errorf("not an MPLS label stack: %q", field)
+	}
+
+	var labels []model.MPLSLabel
+	for _, entry := range strings.Split(strings.TrimSuffix(inner, ">"), "/") {
+		var l model.MPLSLabel
+		for _, kv := range strings.Split(entry, ",") {
+			key, value, _ := strings.Cut(kv, "=")
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("bad MPLS field %q", kv)
+			}
+			switch key {
+			case "L":
+				l.Label = n
+			case "E":
+				l.TC = n
+			case "S":
+				l.Bottom = n == 1
+			case "T":
+				l.TTL = n
+			}
+		}
+		labels = append(labels, l)
+	}
+	return labels, nil
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"net"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// timeExceededWithExtensions builds a Time Exceeded reply quoting an IPv4
+// probe with the given TTL and carrying RFC 4950 and RFC 5837 objects
+func timeExceededWithExtensions(t *testing.T, ttl int) *icmp.Message {
+	t.Helper()
+
+	quoted := make([]byte, 28)
+	quoted[0] = 0x45
+	quoted[8] = byte(ttl)
+	quoted[9] = protocolUDP
+	copy(quoted[12:16], net.ParseIP("192.0.2.1").To4())
+	copy(quoted[16:20], net.ParseIP("198.51.100.1").To4())
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeTimeExceeded,
+		Body: &icmp.TimeExceeded{
+			Data: quoted,
+			Extensions: []icmp.Extension{
+				&icmp.MPLSLabelStack{
+					Class: 1,
+					Type:  1,
+					Labels: []icmp.MPLSLabel{
+						{Label: 24000, TC: 0, S: false, TTL: 1},
+						{Label: 16, TC: 5, S: true, TTL: 1},
+					},
+				},
+				&icmp.InterfaceInfo{
+					Class: 2,
+					Type:  0x0f, // incoming, ifindex + address + name + MTU
+					Interface: &net.Interface{
+						Index: 512,
+						Name:  "xe-0/0/1",
+						MTU:   9000,
+					},
+					Addr: &net.IPAddr{IP: net.ParseIP("10.1.1.1").To4()},
+				},
+			},
+		},
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	parsed, err := icmp.ParseMessage(protocolICMP, b)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return parsed
+}
+
+func TestICMPExtensions(t *testing.T) {
+	msg := timeExceededWithExtensions(t, 1)
+
+	labels, ifaces := icmpExtensions(msg)
+
+	want := []model.MPLSLabel{
+		{Label: 24000, TTL: 1},
+		{Label: 16, TC: 5, Bottom: true, TTL: 1},
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("Expected %d labels, got %+v", len(want), labels)
+	}
+	for i := range want {
+		if labels[i] != want[i] {
+			t.Errorf("label %d = %+v, want %+v", i, labels[i], want[i])
+		}
+	}
+
+	if len(ifaces) != 1 {
+		t.Fatalf("Expected 1 interface, got %+v", ifaces)
+	}
+	iface := ifaces[0]
+	if iface.Role != "incoming" || iface.Index != 512 || iface.Name != "xe-0/0/1" || iface.MTU != 9000 || iface.IP != "10.1.1.1" {
+		t.Errorf("unexpected interface: %+v", iface)
+	}
+
+	// The quoted probe is still there for matching
+	body := msg.Body.(*icmp.TimeExceeded)
+	if len(body.Data) < 28 {
+		t.Errorf("Expected the quoted datagram to survive, got %d bytes", len(body.Data))
+	}
+}
+
+func TestICMPExtensionsAbsent(t *testing.T) {
+	labels, ifaces := icmpExtensions(nil)
+	if labels != nil || ifaces != nil {
+		t.Error("Expected nothing for a nil message")
+	}
+
+	echo := &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 1, Seq: 1}}
+	if labels, ifaces := icmpExtensions(echo); labels != nil || ifaces != nil {
+		t.Error("Expected nothing for an echo reply")
+	}
+}
+
+func TestQuotedTTL(t *testing.T) {
+	if got := quotedTTL(timeExceededWithExtensions(t, 3), false); got != 3 {
+		t.Errorf("quotedTTL() = %d, want 3", got)
+	}
+	if got := quotedTTL(&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{}}, false); got != 0 {
+		t.Errorf("quotedTTL() = %d for an echo reply, want 0", got)
+	}
+}
+
+func TestParseMPLSField(t *testing.T) {
+	labels, err := parseMPLSField("<MPLS:L=24000,E=0,S=0,T=1/L=16,E=5,S=1,T=254>")
+	if err != nil {
+		t.Fatalf("parseMPLSField() error = %v", err)
+	}
+	if len(labels) != 2 {
+		t.Fatalf("Expected 2 labels, got %+v", labels)
+	}
+	if labels[0] != (model.MPLSLabel{Label: 24000, TTL: 1}) {
+		t.Errorf("unexpected outer label %+v", labels[0])
+	}
+	if labels[1] != (model.MPLSLabel{Label: 16, TC: 5, Bottom: true, TTL: 254}) {
+		t.Errorf("unexpected inner label %+v", labels[1])
+	}
+
+	for _, bad := range []string{"<MPLS:L=x>", "MPLS:L=1", "<MPLS:L=1"} {
+		if _, err := parseMPLSField(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseTracerouteMPLS(t *testing.T) {
+	output := ` 3  10.0.0.1  5.1 ms <MPLS:L=24000,E=0,S=1,T=1>  5.2 ms <MPLS:L=24000,E=0,S=1,T=1>  10.0.0.9  6.0 ms`
+
+	hops, err := parseTracerouteOutput(output)
+	if err != nil || len(hops) != 1 {
+		t.Fatalf("parseTracerouteOutput() = %+v, %v", hops, err)
+	}
+	hop := hops[0]
+	if hop.Sent != 3 || len(hop.Responders) != 2 {
+		t.Errorf("unexpected hop summary: %+v", hop)
+	}
+	if len(hop.MPLS) != 1 || hop.MPLS[0].Label != 24000 {
+		t.Errorf("Expected the first responder's label stack, got %+v", hop.MPLS)
+	}
+	if hop.Probes[2].MPLS != nil {
+		t.Errorf("Expected no labels on the third probe, got %+v", hop.Probes[2].MPLS)
+	}
+}
@@ -0,0 +1,234 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/asndb"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// MTROptions controls a continuous traceroute run
+type MTROptions struct {
+	Trace    TraceOptions  // probe settings; ProbesPerHop defaults to 1 per cycle
+	Interval time.Duration // time between cycle starts
+	Cycles   int           // stop after this many cycles; 0 runs until cancelled
+}
+
+const defaultMTRInterval = 1 * time.Second
+
+// MTR traces a path repeatedly and keeps per-hop statistics
+type MTR struct {
+	target string
+	ip     net.IP
+	opts   MTROptions
+	cycles int
+	hops   []*mtrHopStats
+	names  map[string]model.TraceHop // enrichment per responder
+
+	resolver hopResolver
+	db       *asndb.DB
+}
+
+// mtrHopStats accumulates one TTL's results. Mean and m2 follow
+// Welford's method so the standard deviation needs no sample history.
+type mtrHopStats struct {
+	ip         string
+	responders []string
+
+	sent     int
+	received int
+
+	last  float64
+	best  float64
+	worst float64
+	mean  float64
+	m2    float64
+}
+
+// NewMTR resolves the target and prepares a continuous traceroute
+func NewMTR(target string, opts MTROptions) (*MTR, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultMTRInterval
+	}
+	if opts.Trace.ProbesPerHop <= 0 {
+		opts.Trace.ProbesPerHop = 1
+	}
+	opts.Trace = opts.Trace.withDefaults()
+
+	ip, err := resolveTargetIP(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve target: %w", err)
+	}
+
+	return &MTR{
+		target: target,
+		ip:     ip,
+		opts:   opts,
+		names:  make(map[string]model.TraceHop),
+
+		resolver: net.DefaultResolver,
+		db:       defaultASNDB(),
+	}, nil
+}
+
+// Run traces the path every opts.Interval until ctx is cancelled or
+// opts.Cycles cycles have completed. onUpdate is called with a fresh
+// snapshot after every cycle. Only a failure of the first cycle is
+// returned; later failures are skipped.
+func (m *MTR) Run(ctx context.Context, onUpdate func(model.MTRReport)) error {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for cycle := 0; m.opts.Cycles == 0 || cycle < m.opts.Cycles; cycle++ {
+		if cycle > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		hops, _, err := traceOnce(ctx, m.ip, m.opts.Trace)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if cycle == 0 {
+				return err
+			}
+			continue
+		}
+
+		m.Add(hops)
+		m.resolveNames(ctx)
+
+		if onUpdate != nil {
+			onUpdate(m.Snapshot())
+		}
+	}
+
+	return nil
+}
+
+// Add records the probes of one trace cycle
+func (m *MTR) Add(hops []model.TraceHop) {
+	m.cycles++
+
+	for _, hop := range hops {
+		if hop.Hop < 1 {
+			continue
+		}
+		for len(m.hops) < hop.Hop {
+			m.hops = append(m.hops, &mtrHopStats{})
+		}
+		stats := m.hops[hop.Hop-1]
+
+		for _, p := range hop.Probes {
+			stats.add(p)
+		}
+	}
+}
+
+func (s *mtrHopStats) add(p model.TraceProbe) {
+	s.sent++
+	if p.Timeout {
+		return
+	}
+
+	s.received++
+	if p.IP != "" {
+		s.ip = p.IP
+		if !slices.Contains(s.responders, p.IP) {
+			s.responders = append(s.responders, p.IP)
+		}
+	}
+
+	rtt := p.RTTMs
+	s.last = rtt
+	if s.received == 1 || rtt < s.best {
+		s.best = rtt
+	}
+	if rtt > s.worst {
+		s.worst = rtt
+	}
+
+	delta := rtt - s.mean
+	s.mean += delta / float64(s.received)
+	s.m2 += delta * (rtt - s.mean)
+}
+
+// resolveNames looks up PTR names and origin ASes for responders not
+// seen before, concurrently and within hopEnrichTimeout
+func (m *MTR) resolveNames(ctx context.Context) {
+	var fresh []model.TraceHop
+	for _, stats := range m.hops {
+		for _, ip := range stats.responders {
+			if _, ok := m.names[ip]; !ok {
+				m.names[ip] = model.TraceHop{}
+				fresh = append(fresh, model.TraceHop{IP: ip})
+			}
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hopEnrichTimeout)
+	defer cancel()
+	enrichTraceHops(ctx, fresh, m.resolver, m.db)
+	for _, hop := range fresh {
+		m.names[hop.IP] = hop
+	}
+}
+
+// Snapshot returns the current per-hop statistics. Hops past the last
+// one that ever answered are dropped, except the first of them.
+func (m *MTR) Snapshot() model.MTRReport {
+	report := model.MTRReport{
+		Target:    m.target,
+		IP:        m.ip.String(),
+		Protocol:  m.opts.Trace.Protocol,
+		Timestamp: time.Now().UTC(),
+		Cycles:    m.cycles,
+		Hops:      []model.MTRHop{},
+	}
+
+	last := -1
+	for i, stats := range m.hops {
+		if stats.received > 0 {
+			last = i
+		}
+	}
+	limit := min(last+2, len(m.hops))
+
+	for i, stats := range m.hops[:limit] {
+		hop := model.MTRHop{
+			Hop:        i + 1,
+			IP:         stats.ip,
+			Host:       m.names[stats.ip].Host,
+			ASN:        m.names[stats.ip].ASN,
+			ASName:     m.names[stats.ip].ASName,
+			Responders: slices.Clone(stats.responders),
+			Sent:       stats.sent,
+			Received:   stats.received,
+		}
+		if stats.sent > 0 {
+			hop.LossPct = roundMs(float64(stats.sent-stats.received) / float64(stats.sent) * 100)
+		}
+		if stats.received > 0 {
+			hop.LastMs = roundMs(stats.last)
+			hop.AvgMs = roundMs(stats.mean)
+			hop.BestMs = roundMs(stats.best)
+			hop.WorstMs = roundMs(stats.worst)
+			hop.StdDevMs = roundMs(math.Sqrt(stats.m2 / float64(stats.received)))
+		}
+		report.Hops = append(report.Hops, hop)
+	}
+
+	return report
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func newTestMTR() *MTR {
+	return &MTR{
+		target: "192.0.2.9",
+		ip:     net.ParseIP("192.0.2.9"),
+		opts:   MTROptions{Trace: TraceOptions{}.withDefaults()},
+		names:  make(map[string]model.TraceHop),
+	}
+}
+
+func mtrCycle(probes ...[]model.TraceProbe) []model.TraceHop {
+	hops := make([]model.TraceHop, len(probes))
+	for i, p := range probes {
+		hops[i] = model.TraceHop{Hop: i + 1, Probes: p}
+		summarizeTraceHop(&hops[i])
+	}
+	return hops
+}
+
+func TestMTRStats(t *testing.T) {
+	m := newTestMTR()
+
+	lost := model.TraceProbe{Timeout: true}
+	m.Add(mtrCycle(
+		[]model.TraceProbe{{IP: "10.0.0.1", RTTMs: 2}},
+		[]model.TraceProbe{{IP: "10.0.1.1", RTTMs: 10}},
+		[]model.TraceProbe{lost},
+	))
+	m.Add(mtrCycle(
+		[]model.TraceProbe{{IP: "10.0.0.1", RTTMs: 4}},
+		[]model.TraceProbe{lost},
+		[]model.TraceProbe{lost},
+	))
+	m.Add(mtrCycle(
+		[]model.TraceProbe{{IP: "10.0.0.1", RTTMs: 6}},
+		[]model.TraceProbe{{IP: "10.0.1.2", RTTMs: 20}},
+		[]model.TraceProbe{lost},
+	))
+
+	report := m.Snapshot()
+	if report.Cycles != 3 {
+		t.Errorf("Expected 3 cycles, got %d", report.Cycles)
+	}
+	if len(report.Hops) != 3 {
+		t.Fatalf("Expected 3 hops, got %d", len(report.Hops))
+	}
+
+	first := report.Hops[0]
+	if first.Sent != 3 || first.Received != 3 || first.LossPct != 0 {
+		t.Errorf("unexpected hop 1 counts: %+v", first)
+	}
+	if first.LastMs != 6 || first.AvgMs != 4 || first.BestMs != 2 || first.WorstMs != 6 {
+		t.Errorf("unexpected hop 1 latency: %+v", first)
+	}
+	// Population standard deviation of 2, 4, 6
+	if first.StdDevMs != 1.63 {
+		t.Errorf("Expected stddev 1.63, got %v", first.StdDevMs)
+	}
+
+	second := report.Hops[1]
+	if second.LossPct != 33.33 || second.IP != "10.0.1.2" || len(second.Responders) != 2 {
+		t.Errorf("unexpected hop 2: %+v", second)
+	}
+
+	if third := report.Hops[2]; third.Received != 0 || third.LossPct != 100 || third.AvgMs != 0 {
+		t.Errorf("unexpected hop 3: %+v", third)
+	}
+}
+
+func TestMTRSnapshotTrimsSilentTail(t *testing.T) {
+	m := newTestMTR()
+
+	lost := []model.TraceProbe{{Timeout: true}}
+	m.Add(mtrCycle(
+		[]model.TraceProbe{{IP: "10.0.0.1", RTTMs: 1}},
+		lost, lost, lost, lost,
+	))
+
+	report := m.Snapshot()
+	if len(report.Hops) != 2 {
+		t.Errorf("Expected the last responder plus one silent hop, got %d hops", len(report.Hops))
+	}
+
+	empty := newTestMTR().Snapshot()
+	if empty.Hops == nil || len(empty.Hops) != 0 {
+		t.Errorf("Expected an empty hop list before the first cycle, got %v", empty.Hops)
+	}
+}
+
+func TestMTRRunLoopback(t *testing.T) {
+	m, err := NewMTR("127.0.0.1", MTROptions{
+		Trace:    TraceOptions{MaxHops: 3, Wait: time.Second, NoSystemFallback: true},
+		Interval: 100 * time.Millisecond,
+		Cycles:   3,
+	})
+	if err != nil {
+		t.Fatalf("NewMTR() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updates := 0
+	if err := m.Run(ctx, func(model.MTRReport) { updates++ }); err != nil {
+		// Raw sockets need root or CAP_NET_RAW
+		t.Skipf("native traceroute unavailable: %v", err)
+	}
+
+	if updates != 3 {
+		t.Errorf("Expected 3 updates, got %d", updates)
+	}
+	report := m.Snapshot()
+	if len(report.Hops) != 1 || report.Hops[0].Sent != 3 || report.Hops[0].IP != "127.0.0.1" {
+		t.Errorf("unexpected loopback mtr report: %+v", report.Hops)
+	}
+}
+
+func TestMTRResolveNames(t *testing.T) {
+	r := &fakeHopResolver{
+		ptr:     map[string]string{"10.0.0.1": "gw.example.", "8.8.8.8": "dns.google."},
+		queries: make(map[string]int),
+	}
+	m := newTestMTR()
+	m.resolver = r
+	m.db = testASNDB(t)
+
+	cycle := mtrCycle(
+		[]model.TraceProbe{{IP: "10.0.0.1", RTTMs: 1}},
+		[]model.TraceProbe{{IP: "8.8.8.8", RTTMs: 9}},
+	)
+	m.Add(cycle)
+	m.resolveNames(context.Background())
+	m.Add(cycle)
+	m.resolveNames(context.Background())
+
+	report := m.Snapshot()
+	if report.Timestamp.Location() != time.UTC {
+		t.Errorf("Expected a UTC timestamp, got %v", report.Timestamp)
+	}
+	if h := report.Hops[0]; h.Host != "gw.example." || h.ASN != "" {
+		t.Errorf("unexpected hop 1: %+v", h)
+	}
+	if h := report.Hops[1]; h.Host != "dns.google." || h.ASN != "15169" || h.ASName != "GOOGLE" {
+		t.Errorf("unexpected hop 2: %+v", h)
+	}
+	// Responders are looked up once, not every cycle
+	if r.queries["10.0.0.1"] != 1 || r.queries["8.8.8.8"] != 1 {
+		t.Errorf("Expected one PTR query per responder, got %v", r.queries)
+	}
+}
@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Path MTU probe methods recorded in report.MTU.Method
+const (
+	MTUMethodICMP = "icmp"
+	MTUMethodUDP  = "udp"
+	MTUMethodTCP  = "tcp"
+)
+
+const (
+	mtuProbeWait    = 1 * time.Second
+	mtuProbeRetries = 2
+
+	// Each method gets its own budget, so a black-holed path that
+	// exhausts one still leaves time for the next
+	mtuMethodBudget = 8 * time.Second
+
+	// Smallest packet every IPv4/IPv6 link must carry
+	minIPv4MTU = 576
+	minIPv6MTU = 1280
+	maxIPMTU   = 65535
+)
+
+type mtuProbeStatus int
+
+const (
+	mtuProbeOK      mtuProbeStatus = iota // destination answered
+	mtuProbeTooBig                        // Fragmentation Needed / Packet Too Big
+	mtuProbeTimeout                       // no answer at all
+)
+
+// mtuProbeResult is the outcome of sending one DF-flagged packet
+type mtuProbeResult struct {
+	Status mtuProbeStatus
+	MTU    int    // next-hop MTU from the ICMP error, 0 if unknown
+	From   string // address that signalled the error, empty if local
+}
+
+// mtuProber sends a single DF-flagged packet of the given total IP size
+type mtuProber interface {
+	Probe(ctx context.Context, size int) (mtuProbeResult, error)
+	Close() error
+}
+
+// mtuSearchResult is the outcome of a path MTU binary search
+type mtuSearchResult struct {
+	PathMTU        int
+	FragNeededFrom string
+	FragNeededMTU  int
+	BlackHole      bool
+	Incomplete     bool // the budget ran out; PathMTU is a lower bound
+	Probes         int
+}
+
+func collectMTU(ctx context.Context, target string, report *model.Report) error {
+	// One budget per method: ICMP, UDP and TCP
+	ctx, cancel := context.WithTimeout(ctx, 3*mtuMethodBudget)
+	defer cancel()
+
+	ip, err := resolveTargetIP(target)
+	if err != nil {
+		report.Errors["mtu"] = fmt.Sprintf("Failed to resolve target for MTU discovery: %v", err)
+		report.MTU.Error = err.Error()
+		// Don't return error for MTU discovery - it's optional
+		return nil
+	}
+
+	localMTU := localMTUFor(ip)
+	report.MTU.LocalMTU = localMTU
+
+	minSize := minIPv4MTU
+	if ip.To4() == nil {
+		minSize = minIPv6MTU
+	}
+	maxSize := localMTU
+	if maxSize <= 0 || maxSize > maxIPMTU {
+		maxSize = maxIPMTU
+	}
+	if maxSize < minSize {
+		minSize = maxSize
+	}
+
+	var errs []error
+	for _, method := range []string{MTUMethodICMP, MTUMethodUDP} {
+		prober, err := newDatagramProber(ip, method)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s probe: %w", method, err))
+			continue
+		}
+
+		mctx, mcancel := context.WithTimeout(ctx, mtuMethodBudget)
+		result, err := searchPathMTU(mctx, prober, minSize, maxSize)
+		mcancel()
+		prober.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s probe: %w", method, err))
+			continue
+		}
+
+		applyMTUResult(report, method, result)
+		return nil
+	}
+
+	// Datagram probes got nowhere; probe with TCP segments instead
+	mctx, mcancel := context.WithTimeout(ctx, mtuMethodBudget)
+	defer mcancel()
+	port, err := discoverTCPPort(mctx, ip.String(), tcpPingPorts)
+	if err == nil {
+		var prober mtuProber
+		var peerMax int
+		prober, peerMax, err = newTCPProber(mctx, ip, port)
+		if err == nil {
+			var result *mtuSearchResult
+			result, err = searchPathMTU(mctx, prober, min(minSize, peerMax), min(maxSize, peerMax))
+			prober.Close()
+			if err == nil {
+				applyMTUResult(report, MTUMethodTCP, result)
+				return nil
+			}
+		}
+	}
+	errs = append(errs, fmt.Errorf("tcp probe: %w", err))
+
+	err = errors.Join(errs...)
+	report.Errors["mtu"] = fmt.Sprintf("Path MTU discovery failed: %v", err)
+	report.MTU.Error = err.Error()
+	// Don't return error for MTU discovery - it's optional
+	return nil
+}
+
+func applyMTUResult(report *model.Report, method string, result *mtuSearchResult) {
+	report.MTU.Method = method
+	report.MTU.PathMTU = result.PathMTU
+	report.MTU.FragNeededFrom = result.FragNeededFrom
+	report.MTU.FragNeededMTU = result.FragNeededMTU
+	report.MTU.BlackHole = result.BlackHole
+	report.MTU.Incomplete = result.Incomplete
+	report.MTU.Probes = result.Probes
+}
+
+// searchPathMTU binary-searches the largest packet size that reaches the
+// destination with DF set. minSize must get through for the search to
+// be meaningful. When ctx ends after minSize got through, the sizes
+// confirmed so far are returned as an incomplete result.
+func searchPathMTU(ctx context.Context, p mtuProber, minSize, maxSize int) (*mtuSearchResult, error) {
+	result := &mtuSearchResult{}
+	sawTimeout, sawTooBig := false, false
+
+	probe := func(size int) (mtuProbeResult, error) {
+		var r mtuProbeResult
+		var err error
+		for attempt := 0; attempt <= mtuProbeRetries; attempt++ {
+			if ctx.Err() != nil {
+				return r, ctx.Err()
+			}
+			result.Probes++
+			r, err = p.Probe(ctx, size)
+			if err != nil || r.Status != mtuProbeTimeout {
+				break
+			}
+		}
+		if err == nil && r.Status == mtuProbeTooBig {
+			sawTooBig = true
+			if r.From != "" {
+				result.FragNeededFrom = r.From
+				result.FragNeededMTU = r.MTU
+			}
+		}
+		return r, err
+	}
+
+	// Most paths carry the full local MTU; check that first
+	r, err := probe(maxSize)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status == mtuProbeOK {
+		result.PathMTU = maxSize
+		return result, nil
+	}
+
+	hi := maxSize - 1
+	if r.Status == mtuProbeTooBig && r.MTU >= minSize && r.MTU < maxSize {
+		hi = r.MTU
+	}
+	if r.Status == mtuProbeTimeout {
+		sawTimeout = true
+	}
+
+	r, err = probe(minSize)
+	if err != nil {
+		return nil, err
+	}
+	if r.Status != mtuProbeOK {
+		return nil, fmt.Errorf("no reply to %d byte probe", minSize)
+	}
+	lo := minSize
+
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		r, err := probe(mid)
+		if err != nil {
+			if ctx.Err() == nil {
+				return nil, err
+			}
+			result.Incomplete = true
+			break
+		}
+
+		switch r.Status {
+		case mtuProbeOK:
+			lo = mid
+		case mtuProbeTooBig:
+			if r.MTU >= lo && r.MTU < mid {
+				hi = r.MTU
+			} else {
+				hi = mid - 1
+			}
+		case mtuProbeTimeout:
+			sawTimeout = true
+			hi = mid - 1
+		}
+	}
+
+	result.PathMTU = lo
+	// Oversized probes vanished without anyone sending Fragmentation Needed
+	result.BlackHole = sawTimeout && !sawTooBig
+	return result, nil
+}
+
+// localMTUFor returns the MTU of the interface used to reach ip, or 0
+func localMTUFor(ip net.IP) int {
+	network := "udp4"
+	if ip.To4() == nil {
+		network = "udp6"
+	}
+
+	// Connecting a UDP socket picks the route without sending anything
+	conn, err := net.Dial(network, net.JoinHostPort(ip.String(), "33434"))
+	if err != nil {
+		return 0
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(localIP) {
+				return iface.MTU
+			}
+		}
+	}
+
+	return 0
+}
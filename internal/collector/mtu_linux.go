@@ -0,0 +1,440 @@
+//go:build linux
+
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// datagramProber sends DF-flagged ICMP echo or UDP packets on a connected
+// datagram socket and reads ICMP errors from the socket error queue.
+type datagramProber struct {
+	fd     int
+	v6     bool
+	method string
+	seq    int
+}
+
+func newDatagramProber(ip net.IP, method string) (mtuProber, error) {
+	v6 := ip.To4() == nil
+
+	family, proto := unix.AF_INET, unix.IPPROTO_UDP
+	if v6 {
+		family = unix.AF_INET6
+	}
+	if method == MTUMethodICMP {
+		proto = unix.IPPROTO_ICMP
+		if v6 {
+			proto = unix.IPPROTO_ICMPV6
+		}
+	}
+
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, fmt.Errorf("socket: %w", err)
+	}
+
+	p := &datagramProber{fd: fd, v6: v6, method: method}
+	if err := p.setup(ip); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *datagramProber) setup(ip net.IP) error {
+	// PMTUDISC_PROBE sets DF but ignores any cached path MTU so every
+	// size in the search really goes on the wire.
+	var err error
+	if p.v6 {
+		err = unix.SetsockoptInt(p.fd, unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE)
+		if err == nil {
+			err = unix.SetsockoptInt(p.fd, unix.IPPROTO_IPV6, unix.IPV6_RECVERR, 1)
+		}
+	} else {
+		err = unix.SetsockoptInt(p.fd, unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+		if err == nil {
+			err = unix.SetsockoptInt(p.fd, unix.IPPROTO_IP, unix.IP_RECVERR, 1)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("setsockopt: %w", err)
+	}
+
+	// UDP probes go to the traceroute base port, which is almost never open
+	port := 33434
+	if p.method == MTUMethodICMP {
+		port = 0
+	}
+
+	var sa unix.Sockaddr
+	if p.v6 {
+		sa6 := &unix.SockaddrInet6{Port: port}
+		copy(sa6.Addr[:], ip.To16())
+		sa = sa6
+	} else {
+		sa4 := &unix.SockaddrInet4{Port: port}
+		copy(sa4.Addr[:], ip.To4())
+		sa = sa4
+	}
+
+	if err := unix.Connect(p.fd, sa); err != nil {
+		return fmt.Errorf("connect: %w", err)
+	}
+	return nil
+}
+
+func (p *datagramProber) headerLen() int {
+	// IP header plus the 8-byte UDP or ICMP header
+	if p.v6 {
+		return 40 + 8
+	}
+	return 20 + 8
+}
+
+func (p *datagramProber) Probe(ctx context.Context, size int) (mtuProbeResult, error) {
+	payloadLen := size - p.headerLen()
+	if payloadLen < 0 {
+		payloadLen = 0
+	}
+
+	p.drain()
+	p.seq++
+
+	packet, err := p.packet(payloadLen)
+	if err != nil {
+		return mtuProbeResult{}, err
+	}
+
+	if _, err := unix.Write(p.fd, packet); err != nil {
+		if errors.Is(err, unix.EMSGSIZE) {
+			// Larger than the local route MTU; the kernel refused to send it
+			mtu, _ := p.routeMTU()
+			return mtuProbeResult{Status: mtuProbeTooBig, MTU: mtu}, nil
+		}
+		return mtuProbeResult{}, fmt.Errorf("send: %w", err)
+	}
+
+	deadline := time.Now().Add(mtuProbeWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return mtuProbeResult{Status: mtuProbeTimeout}, nil
+		}
+
+		fds := []unix.PollFd{{Fd: int32(p.fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, int(wait.Milliseconds())+1)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return mtuProbeResult{}, fmt.Errorf("poll: %w", err)
+		}
+		if n == 0 {
+			continue
+		}
+
+		if fds[0].Revents&unix.POLLERR != 0 {
+			if r, ok := p.readError(); ok {
+				return r, nil
+			}
+		}
+		if fds[0].Revents&unix.POLLIN != 0 && p.readReply() {
+			return mtuProbeResult{Status: mtuProbeOK}, nil
+		}
+	}
+}
+
+func (p *datagramProber) packet(payloadLen int) ([]byte, error) {
+	payload := make([]byte, payloadLen)
+	if p.method != MTUMethodICMP {
+		return payload, nil
+	}
+
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if p.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: 0, Seq: p.seq & 0xffff, Data: payload},
+	}
+	// The kernel fills in the echo ID and checksum on ping sockets
+	return msg.Marshal(nil)
+}
+
+// readReply reports whether a datagram answering the current probe arrived
+func (p *datagramProber) readReply() bool {
+	buf := make([]byte, maxIPMTU)
+	n, _, err := unix.Recvfrom(p.fd, buf, unix.MSG_DONTWAIT)
+	if err != nil {
+		return false
+	}
+	if p.method != MTUMethodICMP {
+		return true
+	}
+
+	proto := 1 // ICMP
+	if p.v6 {
+		proto = 58 // ICMPv6
+	}
+	msg, err := icmp.ParseMessage(proto, buf[:n])
+	if err != nil {
+		return false
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	return ok && echo.Seq == p.seq&0xffff &&
+		(msg.Type == ipv4.ICMPTypeEchoReply || msg.Type == ipv6.ICMPTypeEchoReply)
+}
+
+// readError reads one ICMP error from the socket error queue
+func (p *datagramProber) readError() (mtuProbeResult, bool) {
+	buf := make([]byte, 512)
+	oob := make([]byte, 512)
+	_, oobn, _, _, err := unix.Recvmsg(p.fd, buf, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+	if err != nil {
+		return mtuProbeResult{}, false
+	}
+
+	cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return mtuProbeResult{}, false
+	}
+
+	for _, cmsg := range cmsgs {
+		isErr := (cmsg.Header.Level == unix.SOL_IP && cmsg.Header.Type == unix.IP_RECVERR) ||
+			(cmsg.Header.Level == unix.SOL_IPV6 && cmsg.Header.Type == unix.IPV6_RECVERR)
+		if !isErr || len(cmsg.Data) < int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+			continue
+		}
+
+		ee := (*unix.SockExtendedErr)(unsafe.Pointer(&cmsg.Data[0]))
+		from := offenderAddr(cmsg.Data[unsafe.Sizeof(*ee):])
+
+		switch syscall.Errno(ee.Errno) {
+		case unix.EMSGSIZE:
+			return mtuProbeResult{Status: mtuProbeTooBig, MTU: int(ee.Info), From: from}, true
+		case unix.ECONNREFUSED:
+			// Port unreachable from the destination: the probe arrived
+			return mtuProbeResult{Status: mtuProbeOK}, true
+		default:
+			// Host/network unreachable and friends; treat as lost
+			return mtuProbeResult{Status: mtuProbeTimeout}, true
+		}
+	}
+
+	return mtuProbeResult{}, false
+}
+
+// offenderAddr decodes the sockaddr that follows sock_extended_err
+func offenderAddr(b []byte) string {
+	if len(b) < 2 {
+		return ""
+	}
+	switch binary.NativeEndian.Uint16(b[:2]) {
+	case unix.AF_INET:
+		if len(b) >= 8 {
+			return net.IP(b[4:8]).String()
+		}
+	case unix.AF_INET6:
+		if len(b) >= 24 {
+			return net.IP(b[8:24]).String()
+		}
+	}
+	return ""
+}
+
+// drain discards stale replies and errors from earlier probes
+func (p *datagramProber) drain() {
+	buf := make([]byte, maxIPMTU)
+	oob := make([]byte, 512)
+	for {
+		if _, _, _, _, err := unix.Recvmsg(p.fd, buf, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT); err != nil {
+			break
+		}
+	}
+	for {
+		if _, _, err := unix.Recvfrom(p.fd, buf, unix.MSG_DONTWAIT); err != nil {
+			break
+		}
+	}
+}
+
+func (p *datagramProber) routeMTU() (int, error) {
+	if p.v6 {
+		return unix.GetsockoptInt(p.fd, unix.IPPROTO_IPV6, unix.IPV6_MTU)
+	}
+	return unix.GetsockoptInt(p.fd, unix.IPPROTO_IP, unix.IP_MTU)
+}
+
+func (p *datagramProber) Close() error {
+	return unix.Close(p.fd)
+}
+
+// tcpProber is packetization-layer path MTU discovery (RFC 4821) over
+// TCP, for paths that drop or never answer ICMP and UDP probes. Every
+// probe is a fresh DF-flagged connection whose MSS is clamped with
+// TCP_MAXSEG so its first data segment is exactly the probed size; the
+// probe succeeds when the peer acknowledges that segment.
+type tcpProber struct {
+	addr   string
+	v6     bool
+	header int // IP and TCP header bytes
+}
+
+// newTCPProber checks that ip:port accepts connections and returns the
+// largest probe the peer's MSS allows
+func newTCPProber(ctx context.Context, ip net.IP, port int) (mtuProber, int, error) {
+	p := &tcpProber{addr: net.JoinHostPort(ip.String(), strconv.Itoa(port)), v6: ip.To4() == nil, header: 40}
+	if p.v6 {
+		p.header = 60
+	}
+
+	conn, err := p.dial(ctx, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	info, err := tcpConnInfo(conn)
+	if err != nil {
+		return nil, 0, err
+	}
+	return p, p.header + tcpOptionBytes(info) + int(info.Snd_mss), nil
+}
+
+// dial connects with DF set and, when mss is non-zero, the MSS clamped
+func (p *tcpProber) dial(ctx context.Context, mss int) (*net.TCPConn, error) {
+	dialer := &net.Dialer{
+		Timeout: 3 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				if p.v6 {
+					serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_DO)
+				} else {
+					serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO)
+				}
+				if serr == nil && mss > 0 {
+					serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, mss)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.TCPConn), nil
+}
+
+func (p *tcpProber) Probe(ctx context.Context, size int) (mtuProbeResult, error) {
+	// The MSS excludes the fixed headers; options come out of it, so the
+	// segment's packet is size bytes
+	conn, err := p.dial(ctx, size-p.header)
+	if err != nil {
+		return mtuProbeResult{}, err
+	}
+	defer conn.Close()
+	conn.SetNoDelay(true)
+
+	info, err := tcpConnInfo(conn)
+	if err != nil {
+		return mtuProbeResult{}, err
+	}
+	mss := int(info.Snd_mss)
+	if got := p.header + tcpOptionBytes(info) + mss; got < size {
+		return mtuProbeResult{}, fmt.Errorf("peer MSS allows %d byte segments, not %d", got, size)
+	}
+
+	// One full segment of an unfinished request: the peer acknowledges
+	// it and waits for the rest
+	segment := []byte("GET / HTTP/1.1\r\nX-Padding: ")
+	segment = append(segment, strings.Repeat("x", max(mss-len(segment), 0))...)
+	conn.SetWriteDeadline(time.Now().Add(mtuProbeWait))
+	if _, err := conn.Write(segment[:mss]); err != nil {
+		return mtuProbeResult{}, fmt.Errorf("write: %w", err)
+	}
+
+	deadline := time.Now().Add(mtuProbeWait)
+	for {
+		info, err := tcpConnInfo(conn)
+		if err != nil {
+			return mtuProbeResult{}, err
+		}
+		switch {
+		case int(info.Pmtu) < size:
+			// Fragmentation Needed arrived and the kernel shrank the path
+			// MTU; it does not say who sent it
+			return mtuProbeResult{Status: mtuProbeTooBig, MTU: int(info.Pmtu)}, nil
+		case info.Unacked == 0 && int(info.Snd_mss) >= mss:
+			return mtuProbeResult{Status: mtuProbeOK}, nil
+		case info.Unacked == 0:
+			// Acknowledged only after the kernel's own black hole
+			// detection resent it in smaller segments
+			return mtuProbeResult{Status: mtuProbeTimeout}, nil
+		}
+		if time.Now().After(deadline) {
+			return mtuProbeResult{Status: mtuProbeTimeout}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return mtuProbeResult{}, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (p *tcpProber) Close() error {
+	return nil
+}
+
+func tcpConnInfo(conn *net.TCPConn) (*unix.TCPInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var info *unix.TCPInfo
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		info, serr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, fmt.Errorf("TCP_INFO: %w", serr)
+	}
+	return info, nil
+}
+
+// tcpOptionBytes is the option space every data segment carries:
+// timestamps, when negotiated
+func tcpOptionBytes(info *unix.TCPInfo) int {
+	const tcpiOptTimestamps = 1
+	if info.Options&tcpiOptTimestamps != 0 {
+		return 12
+	}
+	return 0
+}
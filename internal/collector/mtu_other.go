@@ -0,0 +1,19 @@
+//go:build !linux
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var errMTUUnsupported = errors.New("path MTU discovery is only supported on Linux")
+
+func newDatagramProber(ip net.IP, method string) (mtuProber, error) {
+	return nil, errMTUUnsupported
+}
+
+func newTCPProber(ctx context.Context, ip net.IP, port int) (mtuProber, int, error) {
+	return nil, 0, errMTUUnsupported
+}
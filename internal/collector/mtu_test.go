@@ -0,0 +1,188 @@
+package collector
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// fakeMTUPath simulates a path with a bottleneck link. When signal is
+// false the bottleneck silently drops oversized packets.
+type fakeMTUPath struct {
+	mtu    int
+	signal bool
+	from   string
+}
+
+func (f *fakeMTUPath) Probe(ctx context.Context, size int) (mtuProbeResult, error) {
+	if size <= f.mtu {
+		return mtuProbeResult{Status: mtuProbeOK}, nil
+	}
+	if !f.signal {
+		return mtuProbeResult{Status: mtuProbeTimeout}, nil
+	}
+	return mtuProbeResult{Status: mtuProbeTooBig, MTU: f.mtu, From: f.from}, nil
+}
+
+func (f *fakeMTUPath) Close() error { return nil }
+
+func TestSearchPathMTU(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          *fakeMTUPath
+		wantMTU       int
+		wantFrom      string
+		wantBlackHole bool
+	}{
+		{
+			name:    "full MTU",
+			path:    &fakeMTUPath{mtu: 1500, signal: true},
+			wantMTU: 1500,
+		},
+		{
+			name:     "tunnel signals fragmentation needed",
+			path:     &fakeMTUPath{mtu: 1420, signal: true, from: "10.0.0.1"},
+			wantMTU:  1420,
+			wantFrom: "10.0.0.1",
+		},
+		{
+			name:          "black hole",
+			path:          &fakeMTUPath{mtu: 1400, signal: false},
+			wantMTU:       1400,
+			wantBlackHole: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchPathMTU(context.Background(), tt.path, minIPv4MTU, 1500)
+			if err != nil {
+				t.Fatalf("searchPathMTU() error = %v", err)
+			}
+			if got.PathMTU != tt.wantMTU {
+				t.Errorf("searchPathMTU() mtu = %d, want %d", got.PathMTU, tt.wantMTU)
+			}
+			if got.FragNeededFrom != tt.wantFrom {
+				t.Errorf("searchPathMTU() from = %q, want %q", got.FragNeededFrom, tt.wantFrom)
+			}
+			if got.BlackHole != tt.wantBlackHole {
+				t.Errorf("searchPathMTU() black hole = %v, want %v", got.BlackHole, tt.wantBlackHole)
+			}
+		})
+	}
+}
+
+func TestSearchPathMTU_Unreachable(t *testing.T) {
+	path := &fakeMTUPath{mtu: 0, signal: false}
+	if _, err := searchPathMTU(context.Background(), path, minIPv4MTU, 1500); err == nil {
+		t.Error("searchPathMTU() expected error when nothing gets through")
+	}
+}
+
+// slowMTUPath answers like its path until the deadline stops it
+type slowMTUPath struct {
+	fakeMTUPath
+	cancel context.CancelFunc
+	budget int // probes before the deadline
+}
+
+func (f *slowMTUPath) Probe(ctx context.Context, size int) (mtuProbeResult, error) {
+	if f.budget--; f.budget < 0 {
+		f.cancel()
+		return mtuProbeResult{}, ctx.Err()
+	}
+	return f.fakeMTUPath.Probe(ctx, size)
+}
+
+func TestSearchPathMTU_Deadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Full size and minimum size take three and one probes, then two of
+	// the binary search fit in the budget
+	path := &slowMTUPath{fakeMTUPath: fakeMTUPath{mtu: 1400}, cancel: cancel, budget: 6}
+
+	got, err := searchPathMTU(ctx, path, minIPv4MTU, 1500)
+	if err != nil {
+		t.Fatalf("searchPathMTU() error = %v, want a partial result", err)
+	}
+	if !got.Incomplete || !got.BlackHole || got.PathMTU < minIPv4MTU || got.PathMTU > 1400 {
+		t.Errorf("searchPathMTU() = %+v", got)
+	}
+
+	// Nothing is known when the deadline hits before the minimum size
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	path = &slowMTUPath{fakeMTUPath: fakeMTUPath{mtu: 1400}, cancel: cancel, budget: 2}
+	if _, err := searchPathMTU(ctx, path, minIPv4MTU, 1500); err == nil {
+		t.Error("searchPathMTU() expected an error")
+	}
+}
+
+func TestTCPProber_Loopback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("path MTU discovery is only supported on Linux")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	ctx := context.Background()
+	prober, peerMax, err := newTCPProber(ctx, net.ParseIP("127.0.0.1"), ln.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		t.Fatalf("newTCPProber() error = %v", err)
+	}
+	defer prober.Close()
+	if peerMax < 1500 {
+		t.Fatalf("newTCPProber() max size = %d on loopback", peerMax)
+	}
+
+	got, err := searchPathMTU(ctx, prober, minIPv4MTU, 1500)
+	if err != nil {
+		t.Fatalf("searchPathMTU() error = %v", err)
+	}
+	if got.PathMTU != 1500 || got.BlackHole {
+		t.Errorf("searchPathMTU() = %+v, want 1500", got)
+	}
+}
+
+func TestCollectMTU_Loopback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("path MTU discovery is only supported on Linux")
+	}
+
+	report := &model.Report{
+		Target: "127.0.0.1",
+		Errors: make(map[string]string),
+	}
+
+	if err := collectMTU(context.Background(), "127.0.0.1", report); err != nil {
+		t.Fatalf("collectMTU() unexpected error = %v", err)
+	}
+	if report.MTU.PathMTU == 0 {
+		t.Fatalf("collectMTU() found no path MTU: %s", report.MTU.Error)
+	}
+	if report.MTU.LocalMTU > 0 && report.MTU.PathMTU > report.MTU.LocalMTU {
+		t.Errorf("collectMTU() path MTU %d exceeds local MTU %d", report.MTU.PathMTU, report.MTU.LocalMTU)
+	}
+}
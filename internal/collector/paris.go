@@ -0,0 +1,267 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Paris traceroute keeps every header field that per-flow load balancers
+// hash on constant for the whole trace, so all probes of a flow follow one
+// path. Probes still need telling apart: UDP probes carry their identity
+// in the UDP checksum, which is steered through the payload, and ICMP
+// probes vary the sequence number while the payload keeps the ICMP
+// checksum constant.
+
+// parisICMPBase is the one's-complement sum that ICMP probes of flow 0
+// add up to; flow n uses parisICMPBase+n
+const parisICMPBase = 0x5a00
+
+// parisPayloadTail follows the two compensation bytes in every probe
+var parisPayloadTail = []byte("netgaze!")
+
+// onesAdd adds two 16-bit words in one's-complement arithmetic
+func onesAdd(a, b uint16) uint16 {
+	sum := uint32(a) + uint32(b)
+	return uint16(sum&0xffff + sum>>16)
+}
+
+// onesSum folds data into a 16-bit one's-complement sum, padding an odd
+// final byte with zero as the Internet checksum does
+func onesSum(data []byte) uint16 {
+	var sum uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		sum = onesAdd(sum, binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum = onesAdd(sum, uint16(data[len(data)-1])<<8)
+	}
+	return sum
+}
+
+// parisICMPPayload returns an echo payload whose first two bytes make
+// ID, sequence and payload sum to the flow's constant, so the ICMP
+// checksum is the same for every probe of the flow
+func parisICMPPayload(id, seq, flow int) []byte {
+	payload := make([]byte, 2+len(parisPayloadTail))
+	copy(payload[2:], parisPayloadTail)
+
+	sum := onesAdd(uint16(id), uint16(seq))
+	sum = onesAdd(sum, onesSum(payload))
+	want := uint16(parisICMPBase + flow)
+	binary.BigEndian.PutUint16(payload, onesAdd(want, ^sum))
+	return payload
+}
+
+// udpChecksum computes the UDP checksum the kernel will send for a
+// datagram, including the IPv4 or IPv6 pseudo-header
+func udpChecksum(src, dst net.IP, srcPort, dstPort int, payload []byte) uint16 {
+	length := 8 + len(payload)
+
+	var pseudo []byte
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		pseudo = append(pseudo, src4...)
+		pseudo = append(pseudo, dst4...)
+		pseudo = append(pseudo, 0, protocolUDP)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(length))
+	} else {
+		pseudo = append(pseudo, src.To16()...)
+		pseudo = append(pseudo, dst.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(length))
+		pseudo = binary.BigEndian.AppendUint32(pseudo, protocolUDP)
+	}
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:], uint16(srcPort))
+	binary.BigEndian.PutUint16(header[2:], uint16(dstPort))
+	binary.BigEndian.PutUint16(header[4:], uint16(length))
+
+	sum := onesAdd(onesSum(pseudo), onesSum(header))
+	sum = onesAdd(sum, onesSum(payload))
+	if sum = ^sum; sum == 0 {
+		return 0xffff
+	}
+	return sum
+}
+
+// parisUDPPayload returns a payload that gives the datagram the UDP
+// checksum probeID, which must be in 1..0xfffe
+func parisUDPPayload(src, dst net.IP, srcPort, dstPort int, probeID uint16) []byte {
+	payload := make([]byte, 2+len(parisPayloadTail))
+	copy(payload[2:], parisPayloadTail)
+
+	// With zero compensation bytes the sum is ^checksum; the
+	// compensation makes up the difference to ^probeID
+	sum := ^udpChecksum(src, dst, srcPort, dstPort, payload)
+	binary.BigEndian.PutUint16(payload, onesAdd(^probeID, ^sum))
+	return payload
+}
+
+// parisUDPKey is the pending-probe key of a Paris UDP probe
+func parisUDPKey(srcPort int, checksum uint16) string {
+	return fmt.Sprintf("udpsum:%d:%d", srcPort, checksum)
+}
+
+// deliverParisLocal handles a Paris UDP reply from a destination on this
+// host. Locally delivered datagrams keep the partial checksum left for
+// offload, so the quoted checksum identifies no probe; any probe still
+// waiting on the same flow socket is answered instead.
+func (t *nativeTracer) deliverParisLocal(key string, reply *traceReply) {
+	prefix := key[:strings.LastIndex(key, ":")+1]
+
+	t.mu.Lock()
+	var match string
+	for k := range t.pending {
+		if strings.HasPrefix(k, prefix) {
+			match = k
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	if match != "" {
+		t.deliver(match, reply)
+	}
+}
+
+// localAddrFor returns the source address the kernel would pick to reach
+// dst. Connecting a UDP socket sends nothing.
+func localAddrFor(dst net.IP) (net.IP, error) {
+	network := "udp4"
+	if dst.To4() == nil {
+		network = "udp6"
+	}
+	conn, err := net.Dial(network, net.JoinHostPort(dst.String(), "9"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find source address: %w", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// flowConn returns the UDP socket that carries every probe of a flow,
+// opening it on first use
+func (t *nativeTracer) flowConn(flow int) (net.PacketConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if conn, ok := t.flowConns[flow]; ok {
+		return conn, nil
+	}
+
+	network := "udp4"
+	if t.v6 {
+		network = "udp6"
+	}
+	conn, err := net.ListenPacket(network, ":0")
+	if err != nil {
+		return nil, err
+	}
+	t.flowConns[flow] = conn
+	return conn, nil
+}
+
+func (t *nativeTracer) probeParisUDP(ctx context.Context, ttl, flow int) *traceReply {
+	conn, err := t.flowConn(flow)
+	if err != nil {
+		return nil
+	}
+	srcPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+	// Checksums 0 and 0xffff are ambiguous on the wire
+	probeID := uint16(t.nextSeq()%0xfffe + 1)
+	payload := parisUDPPayload(t.src, t.dst, srcPort, t.opts.Port, probeID)
+
+	key := parisUDPKey(srcPort, probeID)
+	ch := t.register(key)
+	defer t.unregister(key)
+
+	// The destination port stays fixed; only the TTL changes
+	dst := &net.UDPAddr{IP: t.dst, Port: t.opts.Port}
+
+	t.sendMu.Lock()
+	if t.v6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	start := time.Now()
+	if err == nil {
+		_, err = conn.WriteTo(payload, dst)
+	}
+	t.sendMu.Unlock()
+	if err != nil {
+		return nil
+	}
+
+	return t.await(ctx, ch, start, nil)
+}
+
+// buildTraceGraph links each flow's responders TTL by TTL. Hops where a
+// flow got no answer are bridged, so an edge may span several TTLs.
+func buildTraceGraph(results [][]traceProbeResult, flows int) *model.TraceGraph {
+	graph := &model.TraceGraph{Flows: flows, Nodes: []model.TraceNode{}, Edges: []model.TraceEdge{}}
+
+	type nodeKey struct {
+		hop int
+		ip  string
+	}
+	type edgeKey struct {
+		from, to nodeKey
+	}
+	nodes := make(map[nodeKey]int)
+	edges := make(map[edgeKey]int)
+	last := make(map[int]nodeKey) // last responder seen per flow
+
+	for i, probes := range results {
+		for _, p := range probes {
+			if p.Reply == nil || p.Reply.From == nil {
+				continue
+			}
+			node := nodeKey{hop: i + 1, ip: p.Reply.From.String()}
+
+			n, ok := nodes[node]
+			if !ok {
+				n = len(graph.Nodes)
+				nodes[node] = n
+				graph.Nodes = append(graph.Nodes, model.TraceNode{Hop: node.hop, IP: node.ip})
+			}
+			if !slices.Contains(graph.Nodes[n].Flows, p.Flow) {
+				graph.Nodes[n].Flows = append(graph.Nodes[n].Flows, p.Flow)
+			}
+
+			if prev, ok := last[p.Flow]; ok && prev != node {
+				edge := edgeKey{from: prev, to: node}
+				e, ok := edges[edge]
+				if !ok {
+					e = len(graph.Edges)
+					edges[edge] = e
+					graph.Edges = append(graph.Edges, model.TraceEdge{
+						FromHop: prev.hop, From: prev.ip,
+						ToHop: node.hop, To: node.ip,
+					})
+				}
+				if !slices.Contains(graph.Edges[e].Flows, p.Flow) {
+					graph.Edges[e].Flows = append(graph.Edges[e].Flows, p.Flow)
+				}
+			}
+			last[p.Flow] = node
+		}
+	}
+
+	for i := range graph.Nodes {
+		slices.Sort(graph.Nodes[i].Flows)
+	}
+	for i := range graph.Edges {
+		slices.Sort(graph.Edges[i].Flows)
+	}
+	return graph
+}
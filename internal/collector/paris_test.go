@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestParisICMPChecksumConstant(t *testing.T) {
+	for flow := 0; flow < 3; flow++ {
+		var want []byte
+		for seq := 1; seq < 200; seq += 37 {
+			msg := icmp.Message{
+				Type: ipv4.ICMPTypeEcho,
+				Body: &icmp.Echo{ID: 0x1234, Seq: seq, Data: parisICMPPayload(0x1234, seq, flow)},
+			}
+			packet, err := msg.Marshal(nil)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			// Type, code and checksum are what load balancers hash on
+			if want == nil {
+				want = packet[:4]
+				continue
+			}
+			if string(packet[:4]) != string(want) {
+				t.Errorf("flow %d seq %d: header %x, want %x", flow, seq, packet[:4], want)
+			}
+		}
+	}
+
+	a := parisICMPPayload(1, 1, 0)
+	b := parisICMPPayload(1, 1, 1)
+	if string(a) == string(b) {
+		t.Error("Expected different flows to use different payloads")
+	}
+}
+
+func TestParisUDPChecksum(t *testing.T) {
+	cases := []struct {
+		src, dst string
+	}{
+		{"192.0.2.10", "198.51.100.1"},
+		{"2001:db8::1", "2001:db8::2"},
+	}
+	for _, c := range cases {
+		src, dst := net.ParseIP(c.src), net.ParseIP(c.dst)
+		for _, id := range []uint16{1, 2, 0x1234, 0x8000, 0xfffe} {
+			payload := parisUDPPayload(src, dst, 40000, 33434, id)
+			if got := udpChecksum(src, dst, 40000, 33434, payload); got != id {
+				t.Errorf("%s: checksum %#04x, want %#04x", c.dst, got, id)
+			}
+		}
+	}
+}
+
+func TestUDPChecksumKnownValue(t *testing.T) {
+	// Checksum computed independently for this datagram
+	src, dst := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+	got := udpChecksum(src, dst, 1000, 2000, []byte{0x01, 0x02})
+
+	sum := uint32(0x0a00) + 0x0001 + 0x0a00 + 0x0002 + protocolUDP + 10 +
+		1000 + 2000 + 10 + 0x0102
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	if want := ^uint16(sum); got != want {
+		t.Errorf("checksum %#04x, want %#04x", got, want)
+	}
+}
+
+func TestQuotedProbeKeyParisUDP(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	tracer := &nativeTracer{dst: dst, opts: TraceOptions{Mode: TraceModeParis}}
+
+	quoted := make([]byte, 28)
+	quoted[0] = 0x45
+	quoted[9] = protocolUDP
+	copy(quoted[16:20], dst.To4())
+	binary.BigEndian.PutUint16(quoted[20:], 40000)
+	binary.BigEndian.PutUint16(quoted[22:], 33434)
+	binary.BigEndian.PutUint16(quoted[26:], 0x1234)
+
+	if got, want := tracer.quotedProbeKey(quoted), parisUDPKey(40000, 0x1234); got != want {
+		t.Errorf("key %q, want %q", got, want)
+	}
+
+	tracer.opts.Mode = TraceModeClassic
+	if got := tracer.quotedProbeKey(quoted); got != "udp:40000" {
+		t.Errorf("classic key %q, want udp:40000", got)
+	}
+}
+
+func TestBuildTraceGraph(t *testing.T) {
+	reply := func(ip string) *traceReply {
+		return &traceReply{From: net.ParseIP(ip)}
+	}
+	// Two flows split at hop 2 and merge again at hop 4; flow 1 gets
+	// no answer at hop 3
+	results := [][]traceProbeResult{
+		{{TTL: 1, Flow: 0, Reply: reply("10.0.0.1")}, {TTL: 1, Flow: 1, Reply: reply("10.0.0.1")}},
+		{{TTL: 2, Flow: 0, Reply: reply("10.0.1.1")}, {TTL: 2, Flow: 1, Reply: reply("10.0.2.1")}},
+		{{TTL: 3, Flow: 0, Reply: reply("10.0.1.2")}, {TTL: 3, Flow: 1}},
+		{{TTL: 4, Flow: 0, Reply: reply("192.0.2.1")}, {TTL: 4, Flow: 1, Reply: reply("192.0.2.1")}},
+	}
+
+	graph := buildTraceGraph(results, 2)
+
+	if graph.Flows != 2 {
+		t.Errorf("Expected 2 flows, got %d", graph.Flows)
+	}
+	if len(graph.Nodes) != 5 {
+		t.Fatalf("Expected 5 nodes, got %d: %+v", len(graph.Nodes), graph.Nodes)
+	}
+	if first := graph.Nodes[0]; first.IP != "10.0.0.1" || len(first.Flows) != 2 {
+		t.Errorf("Expected both flows through the first hop, got %+v", first)
+	}
+
+	edges := make(map[string][]int)
+	for _, e := range graph.Edges {
+		edges[e.From+">"+e.To] = e.Flows
+		if e.From == "10.0.2.1" && (e.FromHop != 2 || e.ToHop != 4) {
+			t.Errorf("Expected the edge over the silent hop to span 2 -> 4, got %+v", e)
+		}
+	}
+	if len(graph.Edges) != 5 {
+		t.Errorf("Expected 5 edges, got %d: %+v", len(graph.Edges), graph.Edges)
+	}
+	if f := edges["10.0.0.1>10.0.1.1"]; len(f) != 1 || f[0] != 0 {
+		t.Errorf("unexpected flows on 10.0.0.1>10.0.1.1: %v", f)
+	}
+	if f := edges["10.0.2.1>192.0.2.1"]; len(f) != 1 || f[0] != 1 {
+		t.Errorf("unexpected flows on 10.0.2.1>192.0.2.1: %v", f)
+	}
+}
+
+func TestParisTracerouteLoopback(t *testing.T) {
+	for _, proto := range []string{TraceProtoUDP, TraceProtoICMP} {
+		t.Run(proto, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			hops, graph, err := nativeTraceroute(ctx, net.ParseIP("127.0.0.1"), TraceOptions{
+				Protocol: proto,
+				MaxHops:  3,
+				Wait:     time.Second,
+				Mode:     TraceModeMultipath,
+				Flows:    4,
+			}.withDefaults())
+			if err != nil {
+				// Raw sockets need root or CAP_NET_RAW
+				t.Skipf("native traceroute unavailable: %v", err)
+			}
+
+			if len(hops) != 1 || hops[0].IP != "127.0.0.1" {
+				t.Fatalf("Expected loopback to be reached at hop 1, got %+v", hops)
+			}
+			if hops[0].Sent != 4 || hops[0].Received != 4 {
+				t.Errorf("Expected one answered probe per flow, got %d/%d", hops[0].Received, hops[0].Sent)
+			}
+			if graph == nil || len(graph.Nodes) != 1 || len(graph.Nodes[0].Flows) != 4 {
+				t.Errorf("Expected a single node crossed by 4 flows, got %+v", graph)
+			}
+		})
+	}
+}
+
+func TestNewNativeTracerRejectsParisTCP(t *testing.T) {
+	_, err := newNativeTracer(net.ParseIP("127.0.0.1"), TraceOptions{
+		Protocol: TraceProtoTCP,
+		Mode:     TraceModeParis,
+	}.withDefaults())
+	if err == nil {
+		t.Error("Expected paris mode to reject tcp probes")
+	}
+}
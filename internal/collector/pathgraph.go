@@ -0,0 +1,320 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Path export formats accepted by FormatPaths
+const (
+	PathFormatDOT     = "dot"
+	PathFormatMermaid = "mermaid"
+)
+
+// pathColors tell traces apart when several are drawn together
+var pathColors = []string{"blue", "red", "darkgreen", "orange", "purple", "brown", "magenta", "gray"}
+
+// pathNode is one responder, or a run of silent hops in a single trace
+type pathNode struct {
+	id      string
+	ip      string
+	host    string
+	asn     string
+	rtts    []float64
+	targets []string // traces that end at this node
+
+	// silent hops
+	timeout  bool
+	firstHop int
+	lastHop  int
+}
+
+// pathEdge links two nodes and records which traces used it
+type pathEdge struct {
+	from, to *pathNode
+	traces   []int
+}
+
+// pathGraph is the merged view of one or more traces. Responders shared
+// between traces become a single node; timeouts never merge.
+type pathGraph struct {
+	runs  []*model.TraceRun
+	nodes []*pathNode
+	edges []*pathEdge
+}
+
+func buildPathGraph(runs []*model.TraceRun) *pathGraph {
+	g := &pathGraph{runs: runs}
+	source := &pathNode{id: "src"}
+	byIP := make(map[string]*pathNode)
+	edgeIndex := make(map[[2]*pathNode]*pathEdge)
+
+	addNode := func(n *pathNode) *pathNode {
+		n.id = fmt.Sprintf("n%d", len(g.nodes))
+		g.nodes = append(g.nodes, n)
+		return n
+	}
+	link := func(from, to *pathNode, trace int) {
+		key := [2]*pathNode{from, to}
+		e, ok := edgeIndex[key]
+		if !ok {
+			e = &pathEdge{from: from, to: to}
+			edgeIndex[key] = e
+			g.edges = append(g.edges, e)
+		}
+		if len(e.traces) == 0 || e.traces[len(e.traces)-1] != trace {
+			e.traces = append(e.traces, trace)
+		}
+	}
+
+	for i, run := range runs {
+		prev := []*pathNode{source}
+		var silent *pathNode
+
+		for _, hop := range run.Hops {
+			if hop.Timeout {
+				// Consecutive silent hops collapse into one node
+				if silent == nil {
+					silent = addNode(&pathNode{timeout: true, firstHop: hop.Hop})
+					for _, p := range prev {
+						link(p, silent, i)
+					}
+					prev = []*pathNode{silent}
+				}
+				silent.lastHop = hop.Hop
+				continue
+			}
+			silent = nil
+
+			responders := hopResponders(hop)
+			if len(responders) == 0 && hop.Host != "" {
+				// Imported mtr reports may name a hop without its address
+				responders = []string{hop.Host}
+			}
+
+			var cur []*pathNode
+			for _, ip := range responders {
+				n, ok := byIP[ip]
+				if !ok {
+					n = addNode(&pathNode{ip: ip})
+					byIP[ip] = n
+				}
+				if ip == hop.IP {
+					if n.host == "" {
+						n.host = strings.TrimSuffix(hop.Host, ".")
+					}
+					if n.asn == "" {
+						n.asn = hop.ASN
+					}
+				}
+				if rtt, ok := responderRTTMs(hop, ip); ok {
+					n.rtts = append(n.rtts, rtt)
+				}
+				for _, p := range prev {
+					link(p, n, i)
+				}
+				cur = append(cur, n)
+			}
+			prev = cur
+		}
+
+		last := run.Hops[len(run.Hops)-1]
+		if n, ok := byIP[last.IP]; ok && !last.Timeout && run.Target != "" {
+			n.targets = append(n.targets, run.Target)
+		}
+	}
+
+	return g
+}
+
+// responderRTTMs is the mean RTT of the probes ip answered at hop,
+// falling back to the hop's RTT for traces without per-probe results
+func responderRTTMs(hop model.TraceHop, ip string) (float64, bool) {
+	var sum float64
+	var n int
+	for _, p := range hop.Probes {
+		if !p.Timeout && p.IP == ip {
+			sum += p.RTTMs
+			n++
+		}
+	}
+	if n > 0 {
+		return sum / float64(n), true
+	}
+	if ip == hop.IP {
+		return hopRTTMs(hop)
+	}
+	return 0, false
+}
+
+// labelLines describes a node: address, name, AS and RTT (a range when
+// the traces disagree)
+func (n *pathNode) labelLines() []string {
+	if n.timeout {
+		if n.firstHop == n.lastHop {
+			return []string{"*", fmt.Sprintf("hop %d", n.firstHop)}
+		}
+		return []string{"*", fmt.Sprintf("hops %d-%d", n.firstHop, n.lastHop)}
+	}
+
+	lines := []string{n.ip}
+	if n.host != "" {
+		lines = append(lines, n.host)
+	}
+	if n.asn != "" {
+		lines = append(lines, "AS"+n.asn)
+	}
+	if len(n.rtts) > 0 {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, r := range n.rtts {
+			lo, hi = min(lo, r), max(hi, r)
+		}
+		if hi-lo < 0.05 {
+			lines = append(lines, fmt.Sprintf("%.1fms", lo))
+		} else {
+			lines = append(lines, fmt.Sprintf("%.1f-%.1fms", lo, hi))
+		}
+	}
+	for _, t := range n.targets {
+		if t != n.ip {
+			lines = append(lines, "target: "+t)
+		}
+	}
+	return lines
+}
+
+func (g *pathGraph) traceLabel(i int) string {
+	if g.runs[i].Target != "" {
+		return g.runs[i].Target
+	}
+	return fmt.Sprintf("trace %d", i+1)
+}
+
+func (g *pathGraph) traceColor(i int) string {
+	return pathColors[i%len(pathColors)]
+}
+
+// FormatPaths renders one or more traces as a Graphviz DOT or Mermaid
+// graph. Hops answered by the same address are drawn once; with several
+// traces, edges are coloured per trace.
+func FormatPaths(runs []*model.TraceRun, format string) (string, error) {
+	var withHops []*model.TraceRun
+	for _, run := range runs {
+		if len(run.Hops) > 0 {
+			withHops = append(withHops, run)
+		}
+	}
+	if len(withHops) == 0 {
+		return "", fmt.Errorf("no hops to draw")
+	}
+
+	g := buildPathGraph(withHops)
+	switch format {
+	case PathFormatDOT:
+		return g.dot(), nil
+	case PathFormatMermaid:
+		return g.mermaid(), nil
+	}
+	return "", fmt.Errorf("unknown graph format: %s (valid: dot, mermaid)", format)
+}
+
+func (g *pathGraph) dot() string {
+	var b strings.Builder
+
+	b.WriteString("digraph paths {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\", fontsize=10];\n")
+	if len(g.runs) > 1 {
+		var legend []string
+		for i := range g.runs {
+			legend = append(legend, fmt.Sprintf("%s (%s)", g.traceLabel(i), g.traceColor(i)))
+		}
+		fmt.Fprintf(&b, "  label=%s;\n", dotQuote(strings.Join(legend, ", ")))
+	}
+	b.WriteString("  src [label=\"source\", shape=ellipse];\n")
+
+	for _, n := range g.nodes {
+		attrs := []string{"label=" + dotQuote(strings.Join(n.labelLines(), "\n"))}
+		switch {
+		case n.timeout:
+			attrs = append(attrs, "style=dashed")
+		case len(n.targets) > 0:
+			attrs = append(attrs, "shape=doubleoctagon")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", n.id, strings.Join(attrs, ", "))
+	}
+
+	for _, e := range g.edges {
+		if len(g.runs) == 1 {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.from.id, e.to.id)
+			continue
+		}
+		colors := make([]string, len(e.traces))
+		for i, t := range e.traces {
+			colors[i] = g.traceColor(t)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [color=%s];\n", e.from.id, e.to.id, dotQuote(strings.Join(colors, ":")))
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes a DOT string, turning newlines into line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func (g *pathGraph) mermaid() string {
+	var b strings.Builder
+
+	b.WriteString("graph LR\n")
+	if len(g.runs) > 1 {
+		for i := range g.runs {
+			fmt.Fprintf(&b, "  %%%% %s: %s\n", g.traceColor(i), g.traceLabel(i))
+		}
+	}
+	b.WriteString("  src([\"source\"])\n")
+
+	for _, n := range g.nodes {
+		label := mermaidQuote(strings.Join(n.labelLines(), "<br/>"))
+		switch {
+		case n.timeout:
+			fmt.Fprintf(&b, "  %s(%s)\n", n.id, label)
+		case len(n.targets) > 0:
+			fmt.Fprintf(&b, "  %s[[%s]]\n", n.id, label)
+		default:
+			fmt.Fprintf(&b, "  %s[%s]\n", n.id, label)
+		}
+	}
+
+	var styles []string
+	for i, e := range g.edges {
+		arrow := "-->"
+		if e.to.timeout || e.from.timeout {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", e.from.id, arrow, e.to.id)
+
+		// Edges shared by several traces keep the default colour
+		if len(g.runs) > 1 && len(e.traces) == 1 {
+			styles = append(styles, fmt.Sprintf("  linkStyle %d stroke:%s", i, g.traceColor(e.traces[0])))
+		}
+	}
+	for _, s := range styles {
+		b.WriteString(s + "\n")
+	}
+
+	return b.String()
+}
+
+// mermaidQuote quotes a Mermaid node label
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func pathRun(target string, hops ...model.TraceHop) *model.TraceRun {
+	for i := range hops {
+		hops[i].Hop = i + 1
+		if hops[i].IP == "" {
+			hops[i].Timeout = true
+			hops[i].RTT = "*"
+		}
+	}
+	return &model.TraceRun{Target: target, Hops: hops}
+}
+
+func TestBuildPathGraphMergesSharedHops(t *testing.T) {
+	runs := []*model.TraceRun{
+		pathRun("a.example",
+			model.TraceHop{IP: "192.168.1.1", RTT: "1.0ms"},
+			model.TraceHop{IP: "203.0.113.1", RTT: "5.0ms", ASN: "64500"},
+			model.TraceHop{},
+			model.TraceHop{},
+			model.TraceHop{IP: "198.51.100.1", RTT: "20.0ms"},
+		),
+		pathRun("b.example",
+			model.TraceHop{IP: "192.168.1.1", RTT: "3.0ms"},
+			model.TraceHop{IP: "203.0.113.9", RTT: "6.0ms"},
+			model.TraceHop{IP: "198.51.100.2", RTT: "25.0ms"},
+		),
+	}
+
+	g := buildPathGraph(runs)
+
+	// 192.168.1.1 is shared; the silent hops 3-4 collapse into one node
+	if len(g.nodes) != 6 {
+		t.Fatalf("Expected 6 nodes, got %d", len(g.nodes))
+	}
+	gateway := g.nodes[0]
+	if gateway.ip != "192.168.1.1" || len(gateway.rtts) != 2 {
+		t.Errorf("Expected a merged gateway node with 2 RTTs, got %+v", gateway)
+	}
+	if got := strings.Join(gateway.labelLines(), "|"); got != "192.168.1.1|1.0-3.0ms" {
+		t.Errorf("unexpected gateway label %q", got)
+	}
+
+	silent := g.nodes[2]
+	if !silent.timeout || silent.firstHop != 3 || silent.lastHop != 4 {
+		t.Errorf("Expected a silent node for hops 3-4, got %+v", silent)
+	}
+	if got := strings.Join(silent.labelLines(), "|"); got != "*|hops 3-4" {
+		t.Errorf("unexpected silent label %q", got)
+	}
+
+	if got := strings.Join(g.nodes[1].labelLines(), "|"); got != "203.0.113.1|AS64500|5.0ms" {
+		t.Errorf("unexpected AS hop label %q", got)
+	}
+	if dest := g.nodes[3]; len(dest.targets) != 1 || dest.targets[0] != "a.example" {
+		t.Errorf("Expected the last hop to be marked as a.example, got %+v", dest)
+	}
+
+	// source -> gateway is used by both traces
+	if e := g.edges[0]; e.from.id != "src" || e.to != gateway || len(e.traces) != 2 {
+		t.Errorf("Expected a shared first edge, got %+v", e)
+	}
+}
+
+func TestBuildPathGraphECMP(t *testing.T) {
+	hop := model.TraceHop{
+		IP:         "10.0.0.1",
+		Responders: []string{"10.0.0.1", "10.0.0.2"},
+		Probes: []model.TraceProbe{
+			{IP: "10.0.0.1", RTTMs: 2},
+			{IP: "10.0.0.2", RTTMs: 4},
+		},
+	}
+	g := buildPathGraph([]*model.TraceRun{
+		pathRun("", model.TraceHop{IP: "192.168.1.1", RTT: "1.0ms"}, hop, model.TraceHop{IP: "192.0.2.1", RTT: "9.0ms"}),
+	})
+
+	if len(g.nodes) != 4 {
+		t.Fatalf("Expected 4 nodes, got %d", len(g.nodes))
+	}
+	if got := g.nodes[2].rtts; len(got) != 1 || got[0] != 4 {
+		t.Errorf("Expected the second responder's own RTT, got %v", got)
+	}
+	// gateway fans out to both responders, which both lead on
+	if len(g.edges) != 5 {
+		t.Errorf("Expected 5 edges, got %d", len(g.edges))
+	}
+}
+
+func TestFormatPathsDOT(t *testing.T) {
+	runs := []*model.TraceRun{
+		pathRun("a.example", model.TraceHop{IP: "192.168.1.1", Host: "gw.\"lan\".", RTT: "1.0ms"}, model.TraceHop{IP: "198.51.100.1", RTT: "20.0ms"}),
+	}
+
+	out, err := FormatPaths(runs, PathFormatDOT)
+	if err != nil {
+		t.Fatalf("FormatPaths() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"digraph paths {",
+		`n0 [label="192.168.1.1\ngw.\"lan\"\n1.0ms"];`,
+		`n1 [label="198.51.100.1\n20.0ms\ntarget: a.example", shape=doubleoctagon];`,
+		"src -> n0;",
+		"n0 -> n1;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+
+	runs = append(runs, pathRun("b.example", model.TraceHop{IP: "192.168.1.1", RTT: "1.0ms"}, model.TraceHop{IP: "198.51.100.2", RTT: "30.0ms"}))
+	out, _ = FormatPaths(runs, PathFormatDOT)
+	for _, want := range []string{
+		`label="a.example (blue), b.example (red)";`,
+		`src -> n0 [color="blue:red"];`,
+		`n0 -> n2 [color="red"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestFormatPathsMermaid(t *testing.T) {
+	runs := []*model.TraceRun{
+		pathRun("a.example", model.TraceHop{IP: "192.168.1.1", RTT: "1.0ms"}, model.TraceHop{}, model.TraceHop{IP: "198.51.100.1", RTT: "20.0ms"}),
+		pathRun("b.example", model.TraceHop{IP: "192.168.1.1", RTT: "1.0ms"}, model.TraceHop{IP: "198.51.100.2", RTT: "30.0ms"}),
+	}
+
+	out, err := FormatPaths(runs, PathFormatMermaid)
+	if err != nil {
+		t.Fatalf("FormatPaths() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"graph LR",
+		"%% blue: a.example",
+		`n0["192.168.1.1<br/>1.0ms"]`,
+		`n1("*<br/>hop 2")`,
+		`n2[["198.51.100.1<br/>20.0ms<br/>target: a.example"]]`,
+		"n0 -.-> n1",
+		"src --> n0",
+		"linkStyle 1 stroke:blue",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "linkStyle 0 ") {
+		t.Errorf("Expected the shared edge to keep the default style:\n%s", out)
+	}
+}
+
+func TestFormatPathsErrors(t *testing.T) {
+	if _, err := FormatPaths(nil, PathFormatDOT); err == nil {
+		t.Error("Expected an error without traces")
+	}
+	runs := []*model.TraceRun{pathRun("a", model.TraceHop{IP: "192.0.2.1", RTT: "1.0ms"})}
+	if _, err := FormatPaths(runs, "svg"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -2,71 +2,314 @@ package collector
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
 	"time"
 
 	probing "github.com/prometheus-community/pro-bing"
 	"github.com/typicalfo/netgaze/internal/model"
 )
 
+// Ping methods recorded in report.Ping.Method
+const (
+	PingMethodICMP  = "icmp"
+	PingMethodTCP   = "tcp"
+	PingMethodHTTP  = "http"
+	PingMethodHTTPS = "https"
+)
+
+// Ping modes accepted by PingOptions.Mode
+const (
+	PingModeAuto = "auto"
+	PingModeICMP = "icmp"
+	PingModeTCP  = "tcp"
+	PingModeHTTP = "http"
+)
+
+const (
+	pingCount    = 5
+	pingInterval = 200 * time.Millisecond
+	probeTimeout = 1 * time.Second
+)
+
+// PingOptions controls which ping method is used.
+// The zero value means auto mode: ICMP first, then
+// TCP connect and HTTP(S) HEAD when ICMP gets no replies.
+type PingOptions struct {
+	Mode string // auto, icmp, tcp or http
+	Port int    // TCP port; 0 means discover one
+}
+
+// tcpPingPorts are tried in order when no TCP port is given.
+var tcpPingPorts = []int{443, 80, 22, 53, 8080, 8443}
+
+// pingResult holds raw samples from a single ping method
+type pingResult struct {
+	Method string
+	Port   int
+	Sent   int
+	Rtts   []time.Duration
+}
+
 func collectPing(ctx context.Context, target string, report *model.Report) error {
-	// Create context with 5-second timeout
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	return collectPingWithOptions(ctx, target, PingOptions{}, report)
+}
+
+func collectPingWithOptions(ctx context.Context, target string, opts PingOptions, report *model.Report) error {
+	// Create context with 10-second timeout to leave room for fallbacks
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	mode := opts.Mode
+	if mode == "" {
+		mode = PingModeAuto
+	}
+
+	var methods []func(context.Context, string, PingOptions) (*pingResult, error)
+	switch mode {
+	case PingModeAuto:
+		methods = append(methods, icmpPing, tcpPing, httpPing)
+	case PingModeICMP:
+		methods = append(methods, icmpPing)
+	case PingModeTCP:
+		methods = append(methods, tcpPing)
+	case PingModeHTTP:
+		methods = append(methods, httpPing)
+	default:
+		report.Errors["ping"] = fmt.Sprintf("Unknown ping mode: %s", mode)
+		return fmt.Errorf("unknown ping mode: %s", mode)
+	}
+
+	var result *pingResult
+	var errs []error
+	for _, method := range methods {
+		res, err := method(ctx, target, opts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Keep the first result so a total failure still reports sent packets
+		if result == nil {
+			result = res
+		}
+		if len(res.Rtts) > 0 {
+			result = res
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s ping: no replies", res.Method))
+	}
+
+	if result == nil {
+		err := errors.Join(errs...)
+		report.Errors["ping"] = fmt.Sprintf("Ping failed: %v", err)
+		report.Ping.Error = err.Error()
+		return fmt.Errorf("ping failed: %w", err)
+	}
+
+	applyPingStats(result, report)
+	if !report.Ping.Success && len(errs) > 0 {
+		report.Ping.Error = errors.Join(errs...).Error()
+	}
+
+	return nil
+}
+
+func icmpPing(ctx context.Context, target string, opts PingOptions) (*pingResult, error) {
 	// Create pinger
 	pinger, err := probing.NewPinger(target)
 	if err != nil {
-		report.Errors["ping"] = fmt.Sprintf("Failed to create pinger: %v", err)
-		return fmt.Errorf("failed to create pinger: %w", err)
+		return nil, fmt.Errorf("failed to create pinger: %w", err)
 	}
 
 	// Configure pinger
-	pinger.Count = 5
-	pinger.Interval = 200 * time.Millisecond
+	pinger.Count = pingCount
+	pinger.Interval = pingInterval
 	pinger.Timeout = 4 * time.Second
 	pinger.SetPrivileged(false) // Don't require privileged mode
 
-	// Statistics collection
-	var rtts []time.Duration
-	var packetsSent, packetsReceived int
+	result := &pingResult{Method: PingMethodICMP}
 
-	// Set up handlers
-	pinger.OnRecv = func(pkt *probing.Packet) {
-		packetsReceived++
-		rtts = append(rtts, pkt.Rtt)
+	pinger.OnFinish = func(stats *probing.Statistics) {
+		result.Sent = stats.PacketsSent
+		result.Rtts = stats.Rtts
 	}
 
-	pinger.OnFinish = func(stats *probing.Statistics) {
-		packetsSent = int(stats.PacketsSent)
-		packetsReceived = int(stats.PacketsRecv)
-		if len(stats.Rtts) > 0 {
-			rtts = stats.Rtts
+	// Run ping directly (pro-bing has its own timeout handling)
+	if err := pinger.RunWithContext(ctx); err != nil {
+		return nil, fmt.Errorf("icmp ping: %w", err)
+	}
+
+	return result, nil
+}
+
+func tcpPing(ctx context.Context, target string, opts PingOptions) (*pingResult, error) {
+	ip, err := resolveTargetIP(target)
+	if err != nil {
+		return nil, fmt.Errorf("tcp ping: %w", err)
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port, err = discoverTCPPort(ctx, ip.String(), tcpPingPorts)
+		if err != nil {
+			return nil, fmt.Errorf("tcp ping: %w", err)
 		}
 	}
 
-	// Run ping directly (pro-bing has its own timeout handling)
-	err = pinger.Run()
+	address := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	result := &pingResult{Method: PingMethodTCP, Port: port}
+
+	for i := 0; i < pingCount; i++ {
+		if i > 0 && !sleepContext(ctx, pingInterval) {
+			break
+		}
+		result.Sent++
+		if rtt, ok := tcpProbe(ctx, address); ok {
+			result.Rtts = append(result.Rtts, rtt)
+		}
+	}
+
+	return result, nil
+}
+
+// tcpProbe performs a single TCP connect and reports the handshake time.
+// A refused connection still counts as a reply since the RST proves the
+// host is up.
+func tcpProbe(ctx context.Context, address string) (time.Duration, bool) {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	rtt := time.Since(start)
+
+	if err == nil {
+		conn.Close()
+		return rtt, true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, true
+	}
+	return 0, false
+}
+
+// discoverTCPPort probes candidate ports concurrently and returns the
+// first one in list order that answered.
+func discoverTCPPort(ctx context.Context, ip string, ports []int) (int, error) {
+	answered := make([]chan bool, len(ports))
+	for i, port := range ports {
+		answered[i] = make(chan bool, 1)
+		go func(ch chan bool, port int) {
+			_, ok := tcpProbe(ctx, net.JoinHostPort(ip, strconv.Itoa(port)))
+			ch <- ok
+		}(answered[i], port)
+	}
+
+	for i, ch := range answered {
+		if <-ch {
+			return ports[i], nil
+		}
+	}
+
+	return 0, fmt.Errorf("no responsive TCP port among %v", ports)
+}
+
+func httpPing(ctx context.Context, target string, opts PingOptions) (*pingResult, error) {
+	host := extractHostname(target)
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+
+	client := &http.Client{
+		Timeout: probeTimeout,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true, // every probe pays the full connect cost
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var errs []error
+	for _, scheme := range []string{PingMethodHTTPS, PingMethodHTTP} {
+		url := fmt.Sprintf("%s://%s/", scheme, host)
+		result := &pingResult{Method: scheme, Port: 443}
+		if scheme == PingMethodHTTP {
+			result.Port = 80
+		}
+
+		for i := 0; i < pingCount; i++ {
+			if i > 0 && !sleepContext(ctx, pingInterval) {
+				break
+			}
+			result.Sent++
+			if rtt, err := headProbe(ctx, client, url); err == nil {
+				result.Rtts = append(result.Rtts, rtt)
+			} else if len(result.Rtts) == 0 && i == 0 {
+				// First probe failed outright; try the next scheme
+				errs = append(errs, fmt.Errorf("%s ping: %w", scheme, err))
+				break
+			}
+		}
+
+		if len(result.Rtts) > 0 {
+			return result, nil
+		}
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func headProbe(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
 	if err != nil {
-		report.Errors["ping"] = fmt.Sprintf("Ping failed: %v", err)
-		return fmt.Errorf("ping failed: %w", err)
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "netgaze/1.0")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
+	rtt := time.Since(start)
+	resp.Body.Close()
 
-	// Calculate statistics
-	report.Ping.PacketsSent = packetsSent
-	report.Ping.PacketsReceived = packetsReceived
+	return rtt, nil
+}
 
-	if packetsSent > 0 {
-		report.Ping.PacketLossPct = float64(packetsSent-packetsReceived) / float64(packetsSent) * 100
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
 	}
+}
 
-	if len(rtts) > 0 {
+func applyPingStats(result *pingResult, report *model.Report) {
+	report.Ping.Method = result.Method
+	report.Ping.Port = result.Port
+	report.Ping.PacketsSent = result.Sent
+	report.Ping.PacketsReceived = len(result.Rtts)
+
+	if result.Sent > 0 {
+		report.Ping.PacketLossPct = float64(result.Sent-len(result.Rtts)) / float64(result.Sent) * 100
+	}
+
+	if len(result.Rtts) > 0 {
 		// Calculate RTT statistics
 		var sum, sumSquares float64
-		minRtt, maxRtt := rtts[0], rtts[0]
+		minRtt, maxRtt := result.Rtts[0], result.Rtts[0]
 
-		for _, rtt := range rtts {
+		for _, rtt := range result.Rtts {
 			ms := float64(rtt.Nanoseconds()) / 1e6
 			sum += ms
 			sumSquares += ms * ms
@@ -78,9 +321,9 @@ func collectPing(ctx context.Context, target string, report *model.Report) error
 			}
 		}
 
-		avgRtt := sum / float64(len(rtts))
-		variance := (sumSquares / float64(len(rtts))) - (avgRtt * avgRtt)
-		stdDev := math.Sqrt(variance)
+		avgRtt := sum / float64(len(result.Rtts))
+		variance := (sumSquares / float64(len(result.Rtts))) - (avgRtt * avgRtt)
+		stdDev := math.Sqrt(math.Max(variance, 0))
 
 		report.Ping.MinRtt = formatDuration(minRtt)
 		report.Ping.AvgRtt = formatDuration(time.Duration(avgRtt * 1e6))
@@ -88,9 +331,7 @@ func collectPing(ctx context.Context, target string, report *model.Report) error
 		report.Ping.StdDevRtt = fmt.Sprintf("%.2fms", stdDev)
 	}
 
-	report.Ping.Success = packetsReceived > 0
-
-	return nil
+	report.Ping.Success = len(result.Rtts) > 0
 }
 
 func formatDuration(d time.Duration) string {
@@ -2,6 +2,9 @@ package collector
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -110,3 +113,123 @@ func TestFormatDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectPingTCPMode(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	report := &model.Report{
+		Target: "127.0.0.1",
+		Errors: make(map[string]string),
+	}
+
+	err = collectPingWithOptions(context.Background(), "127.0.0.1", PingOptions{Mode: PingModeTCP, Port: port}, report)
+	if err != nil {
+		t.Fatalf("collectPingWithOptions() error = %v", err)
+	}
+
+	if !report.Ping.Success {
+		t.Error("collectPingWithOptions() expected success")
+	}
+	if report.Ping.Method != PingMethodTCP {
+		t.Errorf("collectPingWithOptions() method = %s, want %s", report.Ping.Method, PingMethodTCP)
+	}
+	if report.Ping.Port != port {
+		t.Errorf("collectPingWithOptions() port = %d, want %d", report.Ping.Port, port)
+	}
+	if report.Ping.PacketsSent != 5 || report.Ping.PacketsReceived != 5 {
+		t.Errorf("collectPingWithOptions() packets = %d/%d, want 5/5", report.Ping.PacketsReceived, report.Ping.PacketsSent)
+	}
+}
+
+func TestCollectPingUnknownMode(t *testing.T) {
+	report := &model.Report{
+		Target: "127.0.0.1",
+		Errors: make(map[string]string),
+	}
+
+	err := collectPingWithOptions(context.Background(), "127.0.0.1", PingOptions{Mode: "bogus"}, report)
+	if err == nil {
+		t.Error("collectPingWithOptions() expected error for unknown mode")
+	}
+	if report.Errors["ping"] == "" {
+		t.Error("collectPingWithOptions() expected ping error in report")
+	}
+}
+
+func TestTCPProbeRefused(t *testing.T) {
+	// Grab a free port and close it so the connect is refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, ok := tcpProbe(context.Background(), addr); !ok {
+		t.Error("tcpProbe() expected refused connection to count as a reply")
+	}
+}
+
+func TestDiscoverTCPPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	got, err := discoverTCPPort(context.Background(), "127.0.0.1", []int{port})
+	if err != nil {
+		t.Fatalf("discoverTCPPort() error = %v", err)
+	}
+	if got != port {
+		t.Errorf("discoverTCPPort() = %d, want %d", got, port)
+	}
+}
+
+func TestHeadProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("headProbe() method = %s, want HEAD", r.Method)
+		}
+	}))
+	defer srv.Close()
+
+	if _, err := headProbe(context.Background(), srv.Client(), srv.URL); err != nil {
+		t.Errorf("headProbe() error = %v", err)
+	}
+}
+
+func TestApplyPingStats(t *testing.T) {
+	report := &model.Report{Errors: make(map[string]string)}
+	applyPingStats(&pingResult{
+		Method: PingMethodHTTPS,
+		Port:   443,
+		Sent:   4,
+		Rtts:   []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond},
+	}, report)
+
+	if report.Ping.PacketLossPct != 25 {
+		t.Errorf("applyPingStats() loss = %.1f, want 25", report.Ping.PacketLossPct)
+	}
+	if report.Ping.MinRtt != "10.0ms" || report.Ping.AvgRtt != "20.0ms" || report.Ping.MaxRtt != "30.0ms" {
+		t.Errorf("applyPingStats() rtt = %s/%s/%s", report.Ping.MinRtt, report.Ping.AvgRtt, report.Ping.MaxRtt)
+	}
+	if report.Ping.Method != PingMethodHTTPS || !report.Ping.Success {
+		t.Errorf("applyPingStats() method = %s, success = %v", report.Ping.Method, report.Ping.Success)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/typicalfo/netgaze/internal/model"
@@ -92,7 +93,7 @@ func scanPorts(ctx context.Context, target string, report *model.Report) (*PortS
 			return result, fmt.Errorf("scan timeout")
 		default:
 			// Try to connect to port
-			address := fmt.Sprintf("%s:%d", target, port)
+			address := net.JoinHostPort(target, strconv.Itoa(port))
 			conn, err := net.DialTimeout("tcp", address, 1*time.Second)
 
 			if err == nil {
@@ -0,0 +1,806 @@
+package collector
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/publicsuffix"
+)
+
+// IANA bootstrap registries (RFC 9224), one file per object type
+const (
+	rdapBootstrapDNS  = "dns"
+	rdapBootstrapIPv4 = "ipv4"
+	rdapBootstrapIPv6 = "ipv6"
+	rdapBootstrapASN  = "asn"
+)
+
+var rdapBootstrapKinds = []string{rdapBootstrapDNS, rdapBootstrapIPv4, rdapBootstrapIPv6, rdapBootstrapASN}
+
+// rdapBootstrapURL is where IANA publishes the bootstrap files
+const rdapBootstrapURL = "https://data.iana.org/rdap/"
+
+// rdapFallbackServer answers IP and ASN queries the bootstrap does not
+// cover; ARIN redirects to the registry that holds the resource
+const rdapFallbackServer = "https://rdap.arin.net/registry/"
+
+// rdapMaxResponse caps how much of a response is read
+const rdapMaxResponse = 4 << 20
+
+// embeddedBootstrap is the snapshot used until --update-bootstrap runs.
+// go generate replaces it with the current IANA files.
+//
+//go:generate go run bootstrap/update.go
+//go:embed bootstrap/*.json
+var embeddedBootstrap embed.FS
+
+var asnTargetRe = regexp.MustCompile(`(?i)^AS(\d+)$`)
+
+// errRDAPNotFound means the server has no record of the object
+var errRDAPNotFound = errors.New("RDAP object not found")
+
+// rdapBootstrapFile is the IANA bootstrap file format. Each service is a
+// pair of [keys, base URLs].
+type rdapBootstrapFile struct {
+	Description string       `json:"description"`
+	Publication string       `json:"publication"`
+	Services    [][][]string `json:"services"`
+	Version     string       `json:"version"`
+}
+
+type rdapPrefix struct {
+	net  *net.IPNet
+	urls []string
+}
+
+type rdapASNRange struct {
+	lo, hi uint32
+	urls   []string
+}
+
+// RDAPRegistry maps domains, addresses and AS numbers to RDAP servers
+type RDAPRegistry struct {
+	domains  map[string][]string // TLD -> base URLs
+	prefixes []rdapPrefix
+	asns     []rdapASNRange
+}
+
+// RDAPBootstrapDir returns $XDG_CACHE_HOME/netgaze/rdap, falling back to
+// ~/.cache/netgaze/rdap. Bootstrap files found there replace the
+// embedded copies.
+func RDAPBootstrapDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "netgaze", "rdap"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "netgaze", "rdap"), nil
+}
+
+// LoadRDAPRegistry reads the bootstrap files from dir, using the embedded
+// snapshot for any that are missing or unreadable. An empty dir uses the
+// embedded files only.
+func LoadRDAPRegistry(dir string) (*RDAPRegistry, error) {
+	reg := &RDAPRegistry{domains: make(map[string][]string)}
+
+	for _, kind := range rdapBootstrapKinds {
+		var file *rdapBootstrapFile
+		if dir != "" {
+			if data, err := os.ReadFile(filepath.Join(dir, kind+".json")); err == nil {
+				file, _ = parseRDAPBootstrap(data)
+			}
+		}
+		if file == nil {
+			data, err := embeddedBootstrap.ReadFile("bootstrap/" + kind + ".json")
+			if err != nil {
+				return nil, fmt.Errorf("missing embedded %s bootstrap: %w", kind, err)
+			}
+			if file, err = parseRDAPBootstrap(data); err != nil {
+				return nil, fmt.Errorf("bad embedded %s bootstrap: %w", kind, err)
+			}
+		}
+		if err := reg.add(kind, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return reg, nil
+}
+
+func parseRDAPBootstrap(data []byte) (*rdapBootstrapFile, error) {
+	var file rdapBootstrapFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Services) == 0 {
+		return nil, fmt.Errorf("no services")
+	}
+	for _, svc := range file.Services {
+		if len(svc) != 2 {
+			return nil, fmt.Errorf("malformed service entry")
+		}
+	}
+	return &file, nil
+}
+
+func (r *RDAPRegistry) add(kind string, file *rdapBootstrapFile) error {
+	for _, svc := range file.Services {
+		keys, urls := svc[0], svc[1]
+		for _, key := range keys {
+			switch kind {
+			case rdapBootstrapDNS:
+				r.domains[strings.ToLower(key)] = urls
+			case rdapBootstrapIPv4, rdapBootstrapIPv6:
+				_, n, err := net.ParseCIDR(key)
+				if err != nil {
+					return fmt.Errorf("bad %s bootstrap prefix %q", kind, key)
+				}
+				r.prefixes = append(r.prefixes, rdapPrefix{net: n, urls: urls})
+			case rdapBootstrapASN:
+				lo, hi, err := parseASNRange(key)
+				if err != nil {
+					return err
+				}
+				r.asns = append(r.asns, rdapASNRange{lo: lo, hi: hi, urls: urls})
+			}
+		}
+	}
+	return nil
+}
+
+// parseASNRange parses "64496-64511" or a single "64496"
+func parseASNRange(key string) (uint32, uint32, error) {
+	loStr, hiStr, found := strings.Cut(key, "-")
+	if !found {
+		hiStr = loStr
+	}
+	lo, err1 := strconv.ParseUint(loStr, 10, 32)
+	hi, err2 := strconv.ParseUint(hiStr, 10, 32)
+	if err1 != nil || err2 != nil || hi < lo {
+		return 0, 0, fmt.Errorf("bad ASN bootstrap range %q", key)
+	}
+	return uint32(lo), uint32(hi), nil
+}
+
+// DomainServer returns the RDAP base URL for a domain, matching the
+// longest registered suffix
+func (r *RDAPRegistry) DomainServer(domain string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	for i := range labels {
+		if urls, ok := r.domains[strings.Join(labels[i:], ".")]; ok {
+			return pickRDAPURL(urls)
+		}
+	}
+	return ""
+}
+
+// IPServer returns the RDAP base URL for the most specific prefix
+// containing ip
+func (r *RDAPRegistry) IPServer(ip net.IP) string {
+	best, bestLen := "", -1
+	for _, p := range r.prefixes {
+		if !p.net.Contains(ip) {
+			continue
+		}
+		if ones, _ := p.net.Mask.Size(); ones > bestLen {
+			best, bestLen = pickRDAPURL(p.urls), ones
+		}
+	}
+	return best
+}
+
+// ASNServer returns the RDAP base URL for an AS number
+func (r *RDAPRegistry) ASNServer(asn uint32) string {
+	for _, a := range r.asns {
+		if asn >= a.lo && asn <= a.hi {
+			return pickRDAPURL(a.urls)
+		}
+	}
+	return ""
+}
+
+// pickRDAPURL prefers an HTTPS base URL and makes sure it ends in a slash
+func pickRDAPURL(urls []string) string {
+	if len(urls) == 0 {
+		return ""
+	}
+	chosen := urls[0]
+	for _, u := range urls {
+		if strings.HasPrefix(u, "https://") {
+			chosen = u
+			break
+		}
+	}
+	if !strings.HasSuffix(chosen, "/") {
+		chosen += "/"
+	}
+	return chosen
+}
+
+// rdapQuery works out the server and path for a domain, IP or "AS123"
+func (r *RDAPRegistry) rdapQuery(target string) (server, path string, err error) {
+	if ip := net.ParseIP(target); ip != nil {
+		server = r.IPServer(ip)
+		if server == "" {
+			server = rdapFallbackServer
+		}
+		return server, "ip/" + ip.String(), nil
+	}
+
+	if m := asnTargetRe.FindStringSubmatch(target); m != nil {
+		asn, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid AS number %q", target)
+		}
+		server = r.ASNServer(uint32(asn))
+		if server == "" {
+			server = rdapFallbackServer
+		}
+		return server, fmt.Sprintf("autnum/%d", asn), nil
+	}
+
+	// Registries only know the registered domain, not its subdomains
+	domain := strings.ToLower(strings.TrimSuffix(target, "."))
+	if registered, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		domain = registered
+	}
+	server = r.DomainServer(domain)
+	if server == "" {
+		tld := domain[strings.LastIndex(domain, ".")+1:]
+		return "", "", fmt.Errorf("no RDAP server for .%s", tld)
+	}
+	return server, "domain/" + domain, nil
+}
+
+var (
+	defaultRDAPOnce     sync.Once
+	defaultRDAPRegistry *RDAPRegistry
+	defaultRDAPErr      error
+)
+
+// defaultRDAP loads the registry from the cache directory once
+func defaultRDAP() (*RDAPRegistry, error) {
+	defaultRDAPOnce.Do(func() {
+		dir, _ := RDAPBootstrapDir()
+		defaultRDAPRegistry, defaultRDAPErr = LoadRDAPRegistry(dir)
+	})
+	return defaultRDAPRegistry, defaultRDAPErr
+}
+
+// LookupRDAP queries RDAP for a domain, IP address or AS number ("AS123"),
+// locating the server through the IANA bootstrap registry
+func LookupRDAP(ctx context.Context, target string) (*model.RDAPResult, error) {
+	reg, err := defaultRDAP()
+	if err != nil {
+		return nil, err
+	}
+	return lookupRDAP(ctx, http.DefaultClient, reg, target)
+}
+
+func lookupRDAP(ctx context.Context, client *http.Client, reg *RDAPRegistry, target string) (*model.RDAPResult, error) {
+	chain, data, err := fetchRDAP(ctx, client, reg, target)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseRDAP(data)
+	if err != nil {
+		return nil, err
+	}
+	result.Server = chain[len(chain)-1].Server
+	return result, nil
+}
+
+// fetchRDAP returns the servers queried, in the WHOIS chain format, and
+// the raw JSON answer of the last one. A redirect, such as ARIN sending
+// an address on to the RIR that holds it, adds a hop.
+func fetchRDAP(ctx context.Context, client *http.Client, reg *RDAPRegistry, target string) ([]model.WhoisResponse, []byte, error) {
+	server, path, err := reg.rdapQuery(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+
+	var chain []model.WhoisResponse
+	start := time.Now()
+	c := *client
+	c.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		chain = append(chain, model.WhoisResponse{
+			Server:     rdapBaseURL(via[len(via)-1].URL.String(), path),
+			Query:      path,
+			Referral:   rdapBaseURL(next.URL.String(), path),
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+		start = time.Now()
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(next, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return chain, nil, err
+	}
+	defer resp.Body.Close()
+
+	// The server that answered, after any redirect to another RIR
+	last := model.WhoisResponse{Server: rdapBaseURL(resp.Request.URL.String(), path), Query: path}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		err = errRDAPNotFound
+	case resp.StatusCode != http.StatusOK:
+		err = fmt.Errorf("RDAP server returned %s", resp.Status)
+	}
+	if err != nil {
+		last.Error = err.Error()
+		last.DurationMs = time.Since(start).Milliseconds()
+		return append(chain, last), nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, rdapMaxResponse))
+	last.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		err = fmt.Errorf("failed to read RDAP response: %w", err)
+		last.Error = err.Error()
+		return append(chain, last), nil, err
+	}
+	return append(chain, last), data, nil
+}
+
+// rdapBaseURL strips the query path from a request URL, leaving the
+// server's base URL
+func rdapBaseURL(u, path string) string {
+	if strings.HasSuffix(u, "/"+path) {
+		return strings.TrimSuffix(u, path)
+	}
+	return u
+}
+
+// rdapObject covers the fields of the domain, ip network and autnum
+// object classes that netgaze uses (RFC 9083)
+type rdapObject struct {
+	ObjectClassName string   `json:"objectClassName"`
+	Handle          string   `json:"handle"`
+	LDHName         string   `json:"ldhName"`
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	Country         string   `json:"country"`
+	StartAddress    string   `json:"startAddress"`
+	EndAddress      string   `json:"endAddress"`
+	ParentHandle    string   `json:"parentHandle"`
+	StartAutnum     uint32   `json:"startAutnum"`
+	EndAutnum       uint32   `json:"endAutnum"`
+	Status          []string `json:"status"`
+	Events          []struct {
+		EventAction string `json:"eventAction"`
+		EventDate   string `json:"eventDate"`
+		EventActor  string `json:"eventActor"`
+	} `json:"events"`
+	Entities    []rdapEntity `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	CIDRs []struct {
+		V4Prefix string `json:"v4prefix"`
+		V6Prefix string `json:"v6prefix"`
+		Length   int    `json:"length"`
+	} `json:"cidr0_cidrs"`
+
+	// Error responses
+	ErrorCode   int      `json:"errorCode"`
+	Title       string   `json:"title"`
+	Description []string `json:"description"`
+}
+
+type rdapEntity struct {
+	Handle     string            `json:"handle"`
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	PublicIDs  []struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	} `json:"publicIds"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// parseRDAP turns an RDAP JSON response into a structured result
+func parseRDAP(data []byte) (*model.RDAPResult, error) {
+	var obj rdapObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid RDAP response: %w", err)
+	}
+	if obj.ErrorCode != 0 {
+		return nil, fmt.Errorf("RDAP error %d: %s", obj.ErrorCode, obj.Title)
+	}
+	if obj.ObjectClassName == "" {
+		return nil, fmt.Errorf("invalid RDAP response: no objectClassName")
+	}
+
+	result := &model.RDAPResult{
+		ObjectClass:  obj.ObjectClassName,
+		Handle:       obj.Handle,
+		Name:         obj.LDHName,
+		Status:       obj.Status,
+		StartAddress: obj.StartAddress,
+		EndAddress:   obj.EndAddress,
+		ParentHandle: obj.ParentHandle,
+		Type:         obj.Type,
+		Country:      obj.Country,
+		StartAutnum:  obj.StartAutnum,
+		EndAutnum:    obj.EndAutnum,
+	}
+	if result.Name == "" {
+		result.Name = obj.Name
+	}
+
+	for _, e := range obj.Events {
+		result.Events = append(result.Events, model.RDAPEvent{Action: e.EventAction, Date: e.EventDate, Actor: e.EventActor})
+	}
+	for _, ns := range obj.Nameservers {
+		if ns.LDHName != "" {
+			result.Nameservers = append(result.Nameservers, strings.ToLower(ns.LDHName))
+		}
+	}
+	for _, c := range obj.CIDRs {
+		prefix := c.V4Prefix
+		if prefix == "" {
+			prefix = c.V6Prefix
+		}
+		if prefix != "" {
+			result.CIDRs = append(result.CIDRs, fmt.Sprintf("%s/%d", prefix, c.Length))
+		}
+	}
+
+	var flatten func([]rdapEntity)
+	flatten = func(entities []rdapEntity) {
+		for _, e := range entities {
+			result.Entities = append(result.Entities, convertRDAPEntity(e))
+			flatten(e.Entities)
+		}
+	}
+	flatten(obj.Entities)
+
+	return result, nil
+}
+
+func convertRDAPEntity(e rdapEntity) model.RDAPEntity {
+	entity := parseVCard(e.VCardArray)
+	entity.Handle = e.Handle
+	entity.Roles = e.Roles
+	if entity.Roles == nil {
+		entity.Roles = []string{}
+	}
+	for _, id := range e.PublicIDs {
+		if strings.EqualFold(id.Type, "IANA Registrar ID") {
+			entity.IANAID = id.Identifier
+		}
+	}
+	return entity
+}
+
+// parseVCard reads the jCard (RFC 7095) properties netgaze shows. A jCard
+// is ["vcard", [[name, params, type, value...], ...]].
+func parseVCard(vcard []json.RawMessage) model.RDAPEntity {
+	var entity model.RDAPEntity
+	if len(vcard) < 2 {
+		return entity
+	}
+
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(vcard[1], &props); err != nil {
+		return entity
+	}
+
+	for _, prop := range props {
+		if len(prop) < 4 {
+			continue
+		}
+		var name string
+		if json.Unmarshal(prop[0], &name) != nil {
+			continue
+		}
+		var params map[string]json.RawMessage
+		_ = json.Unmarshal(prop[1], &params)
+
+		switch strings.ToLower(name) {
+		case "fn":
+			entity.Name = jCardText(prop[3])
+		case "org":
+			entity.Org = jCardText(prop[3])
+		case "kind":
+			entity.Kind = jCardText(prop[3])
+		case "email":
+			if email := jCardText(prop[3]); email != "" {
+				entity.Emails = append(entity.Emails, email)
+			}
+		case "tel":
+			if tel := strings.TrimPrefix(jCardText(prop[3]), "tel:"); tel != "" {
+				entity.Phones = append(entity.Phones, tel)
+			}
+		case "adr":
+			entity.Address, entity.Country = jCardAddress(params, prop[3])
+		}
+	}
+	return entity
+}
+
+// jCardText returns a property value as a string; structured values
+// are joined with spaces
+func jCardText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var parts []any
+	if json.Unmarshal(raw, &parts) == nil {
+		var out []string
+		for _, p := range parts {
+			if s, ok := p.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+		return strings.Join(out, " ")
+	}
+	return ""
+}
+
+// jCardAddress returns a one-line address and the country. Servers send
+// either a "label" parameter or the seven structured adr components,
+// the last of which is the country name.
+func jCardAddress(params map[string]json.RawMessage, raw json.RawMessage) (string, string) {
+	var label, country string
+	if v, ok := params["label"]; ok {
+		_ = json.Unmarshal(v, &label)
+		label = strings.Join(strings.Fields(strings.ReplaceAll(label, "\n", ", ")), " ")
+	}
+	if v, ok := params["cc"]; ok {
+		_ = json.Unmarshal(v, &country)
+	}
+
+	var parts []any
+	if json.Unmarshal(raw, &parts) == nil {
+		var lines []string
+		for _, p := range parts {
+			switch v := p.(type) {
+			case string:
+				if v = strings.TrimSpace(v); v != "" {
+					lines = append(lines, v)
+				}
+			case []any:
+				for _, s := range v {
+					if s, ok := s.(string); ok && strings.TrimSpace(s) != "" {
+						lines = append(lines, strings.TrimSpace(s))
+					}
+				}
+			}
+		}
+		if len(parts) == 7 && country == "" {
+			if s, ok := parts[6].(string); ok {
+				country = strings.TrimSpace(s)
+			}
+		}
+		if label == "" {
+			label = strings.Join(lines, ", ")
+		}
+	}
+	return label, country
+}
+
+// RDAPEntityWithRole returns the first entity holding role, or nil
+func RDAPEntityWithRole(r *model.RDAPResult, role string) *model.RDAPEntity {
+	for i := range r.Entities {
+		for _, got := range r.Entities[i].Roles {
+			if strings.EqualFold(got, role) {
+				return &r.Entities[i]
+			}
+		}
+	}
+	return nil
+}
+
+// RDAPEventDate returns the date of the first event with action, or ""
+func RDAPEventDate(r *model.RDAPResult, action string) string {
+	for _, e := range r.Events {
+		if strings.EqualFold(e.Action, action) {
+			return e.Date
+		}
+	}
+	return ""
+}
+
+// applyRDAPToWhois fills the report's WHOIS summary from an RDAP result
+func applyRDAPToWhois(r *model.RDAPResult, report *model.Report) {
+	w := &report.Whois
+	w.Source = "rdap"
+
+	switch r.ObjectClass {
+	case "domain":
+		w.Domain = strings.ToLower(r.Name)
+		if e := RDAPEntityWithRole(r, "registrar"); e != nil {
+			w.Registrar = entityName(e)
+		}
+		if e := RDAPEntityWithRole(r, "registrant"); e != nil {
+			w.Registrant = entityName(e)
+			if w.Country == "" {
+				w.Country = e.Country
+			}
+		}
+	case "ip network":
+		if r.StartAddress != "" && r.EndAddress != "" {
+			w.NetRange = r.StartAddress + " - " + r.EndAddress
+		}
+		w.NetName = r.Name
+		w.Country = r.Country
+		applyRDAPNetwork(r, report)
+	case "autnum":
+		w.NetName = r.Name
+		w.Country = r.Country
+		applyRDAPOrg(r, report)
+	}
+
+	if w.OrgName == "" && r.ObjectClass != "domain" {
+		if e := RDAPEntityWithRole(r, "registrant"); e != nil {
+			w.OrgName = entityName(e)
+		}
+	}
+	w.Created = RDAPEventDate(r, "registration")
+	w.Expires = RDAPEventDate(r, "expiration")
+
+	seen := make(map[string]bool)
+	w.AbuseEmails = nil
+	for _, e := range r.Entities {
+		for _, role := range e.Roles {
+			if !strings.EqualFold(role, "abuse") {
+				continue
+			}
+			for _, email := range e.Emails {
+				email = strings.ToLower(email)
+				if !seen[email] {
+					seen[email] = true
+					w.AbuseEmails = append(w.AbuseEmails, email)
+				}
+			}
+		}
+	}
+}
+
+// applyRDAPNetwork fills the typed network, organisation and abuse
+// contact the registry WHOIS parsers produce
+func applyRDAPNetwork(r *model.RDAPResult, report *model.Report) {
+	w := &report.Whois
+	w.Network = &model.WhoisNetwork{
+		Handle:  r.Handle,
+		Name:    r.Name,
+		Range:   w.NetRange,
+		CIDRs:   r.CIDRs,
+		Type:    r.Type,
+		Country: r.Country,
+		Created: RDAPEventDate(r, "registration"),
+		Updated: RDAPEventDate(r, "last changed"),
+	}
+	if r.ParentHandle != "" {
+		w.Parent = &model.WhoisNetwork{Handle: r.ParentHandle}
+	}
+	applyRDAPOrg(r, report)
+	if w.Org != nil {
+		w.Network.Org = w.Org.Handle
+	}
+}
+
+// applyRDAPOrg fills the registry, holding organisation and abuse
+// contact of a network or autonomous system
+func applyRDAPOrg(r *model.RDAPResult, report *model.Report) {
+	w := &report.Whois
+	w.Registry = rdapServerRegistry(r.Server)
+	if e := RDAPEntityWithRole(r, "registrant"); e != nil {
+		w.Org = &model.WhoisOrg{Handle: e.Handle, Name: entityName(e), Country: e.Country, Address: e.Address}
+	}
+	if e := RDAPEntityWithRole(r, "abuse"); e != nil {
+		w.Abuse = &model.WhoisContact{Handle: e.Handle, Name: e.Name, Emails: lowerAll(append([]string{}, e.Emails...))}
+		if len(e.Phones) > 0 {
+			w.Abuse.Phone = e.Phones[0]
+		}
+	}
+}
+
+// rdapServerRegistry names the RIR behind an RDAP base URL
+func rdapServerRegistry(server string) string {
+	for _, rir := range []string{RegistryARIN, RegistryRIPE, RegistryAPNIC, RegistryLACNIC, RegistryAFRINIC} {
+		if strings.Contains(server, "."+rir+".") {
+			return rir
+		}
+	}
+	return ""
+}
+
+// entityName prefers the organisation over the formatted name
+func entityName(e *model.RDAPEntity) string {
+	if e.Org != "" {
+		return e.Org
+	}
+	return e.Name
+}
+
+// UpdateRDAPBootstrap downloads the current IANA bootstrap files into
+// dir. Every file is checked before any is written, so a failed update
+// leaves the previous copies in place.
+func UpdateRDAPBootstrap(ctx context.Context, dir string) error {
+	return updateRDAPBootstrap(ctx, http.DefaultClient, rdapBootstrapURL, dir)
+}
+
+func updateRDAPBootstrap(ctx context.Context, client *http.Client, baseURL, dir string) error {
+	files := make(map[string][]byte)
+	for _, kind := range rdapBootstrapKinds {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		data, err := fetchRDAPBootstrap(ctx, client, baseURL+kind+".json")
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s bootstrap: %w", kind, err)
+		}
+		file, err := parseRDAPBootstrap(data)
+		if err != nil {
+			return fmt.Errorf("bad %s bootstrap: %w", kind, err)
+		}
+		if err := (&RDAPRegistry{domains: make(map[string][]string)}).add(kind, file); err != nil {
+			return err
+		}
+		files[kind] = data
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, kind := range rdapBootstrapKinds {
+		path := filepath.Join(dir, kind+".json")
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, files[kind], 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func fetchRDAPBootstrap(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, rdapMaxResponse))
+}
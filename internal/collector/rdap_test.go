@@ -0,0 +1,398 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+const rdapDomainSample = `{
+  "objectClassName": "domain",
+  "handle": "2336799_DOMAIN_COM-VRSN",
+  "ldhName": "EXAMPLE.COM",
+  "status": ["client delete prohibited", "client transfer prohibited"],
+  "events": [
+    {"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+    {"eventAction": "expiration", "eventDate": "2030-08-13T04:00:00Z"},
+    {"eventAction": "last changed", "eventDate": "2026-08-14T07:01:34Z"}
+  ],
+  "entities": [
+    {
+      "objectClassName": "entity",
+      "handle": "376",
+      "roles": ["registrar"],
+      "publicIds": [{"type": "IANA Registrar ID", "identifier": "376"}],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "RESERVED-Internet Assigned Numbers Authority"]]],
+      "entities": [
+        {
+          "objectClassName": "entity",
+          "roles": ["abuse"],
+          "vcardArray": ["vcard", [
+            ["version", {}, "text", "4.0"],
+            ["fn", {}, "text", ""],
+            ["tel", {"type": "voice"}, "uri", "tel:+1.3103015800"],
+            ["email", {}, "text", "Abuse@IANA.org"]
+          ]]
+        }
+      ]
+    },
+    {
+      "objectClassName": "entity",
+      "roles": ["registrant"],
+      "vcardArray": ["vcard", [
+        ["version", {}, "text", "4.0"],
+        ["kind", {}, "text", "org"],
+        ["fn", {}, "text", "Domain Administrator"],
+        ["org", {}, "text", "Internet Assigned Numbers Authority"],
+        ["adr", {"cc": "US"}, "text", ["", "", "12025 Waterfront Drive", "Los Angeles", "CA", "90094", ""]]
+      ]]
+    }
+  ],
+  "nameservers": [
+    {"objectClassName": "nameserver", "ldhName": "A.IANA-SERVERS.NET"},
+    {"objectClassName": "nameserver", "ldhName": "B.IANA-SERVERS.NET"}
+  ]
+}`
+
+const rdapNetworkSample = `{
+  "objectClassName": "ip network",
+  "handle": "NET-8-8-8-0-2",
+  "startAddress": "8.8.8.0",
+  "endAddress": "8.8.8.255",
+  "ipVersion": "v4",
+  "name": "GOGL",
+  "type": "DIRECT ALLOCATION",
+  "parentHandle": "NET-8-0-0-0-0",
+  "cidr0_cidrs": [{"v4prefix": "8.8.8.0", "length": 24}],
+  "events": [{"eventAction": "registration", "eventDate": "2023-12-28T17:24:33-05:00"}],
+  "entities": [
+    {
+      "handle": "GOGL",
+      "roles": ["registrant"],
+      "vcardArray": ["vcard", [
+        ["version", {}, "text", "4.0"],
+        ["fn", {}, "text", "Google LLC"],
+        ["adr", {"label": "1600 Amphitheatre Parkway\nMountain View\nCA\n94043\nUnited States"}, "text", ["", "", "", "", "", "", ""]],
+        ["kind", {}, "text", "org"]
+      ]],
+      "entities": [
+        {
+          "handle": "ABUSE5250-ARIN",
+          "roles": ["abuse"],
+          "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Abuse"], ["email", {}, "text", "network-abuse@google.com"]]]
+        }
+      ]
+    }
+  ]
+}`
+
+// testRDAPRegistry routes everything to server
+func testRDAPRegistry(t *testing.T, server string) *RDAPRegistry {
+	t.Helper()
+	reg := &RDAPRegistry{domains: map[string][]string{"com": {server}}}
+	for _, cidr := range []string{"0.0.0.0/0", "::/0"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		reg.prefixes = append(reg.prefixes, rdapPrefix{net: n, urls: []string{server}})
+	}
+	reg.asns = []rdapASNRange{{lo: 1, hi: 4199999999, urls: []string{server}}}
+	return reg
+}
+
+func TestLoadRDAPRegistryEmbedded(t *testing.T) {
+	reg, err := LoadRDAPRegistry("")
+	if err != nil {
+		t.Fatalf("LoadRDAPRegistry() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"com", reg.DomainServer("example.com"), "https://rdap.verisign.com/com/v1/"},
+		{"subdomain", reg.DomainServer("www.example.org."), "https://rdap.publicinterestregistry.org/rdap/"},
+		{"unknown tld", reg.DomainServer("example.invalid"), ""},
+		{"apnic v4", reg.IPServer(net.ParseIP("1.1.1.1")), "https://rdap.apnic.net/"},
+		{"arin v4", reg.IPServer(net.ParseIP("8.8.8.8")), "https://rdap.arin.net/registry/"},
+		{"ripe v6", reg.IPServer(net.ParseIP("2a00:1450:4001::1")), "https://rdap.db.ripe.net/"},
+		{"ripe asn", reg.ASNServer(3333), "https://rdap.db.ripe.net/"},
+		{"lacnic asn", reg.ASNServer(28000), "https://rdap.lacnic.net/rdap/"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestRDAPRegistryLongestMatch(t *testing.T) {
+	reg := &RDAPRegistry{domains: map[string][]string{
+		"uk":    {"https://tld.example/"},
+		"co.uk": {"http://plain.example/rdap", "https://sld.example/rdap"},
+	}}
+	if got := reg.DomainServer("shop.example.co.uk"); got != "https://sld.example/rdap/" {
+		t.Errorf("Expected the longer suffix over https, got %q", got)
+	}
+
+	for _, p := range []struct{ cidr, url string }{{"10.0.0.0/8", "https://wide/"}, {"10.1.0.0/16", "https://narrow/"}} {
+		_, n, _ := net.ParseCIDR(p.cidr)
+		reg.prefixes = append(reg.prefixes, rdapPrefix{net: n, urls: []string{p.url}})
+	}
+	if got := reg.IPServer(net.ParseIP("10.1.2.3")); got != "https://narrow/" {
+		t.Errorf("Expected the most specific prefix, got %q", got)
+	}
+	if got := reg.IPServer(net.ParseIP("10.2.0.1")); got != "https://wide/" {
+		t.Errorf("Expected the covering prefix, got %q", got)
+	}
+}
+
+func TestRDAPQueryPaths(t *testing.T) {
+	reg := testRDAPRegistry(t, "https://rdap.test/")
+
+	tests := []struct {
+		target string
+		path   string
+	}{
+		{"www.Example.com", "domain/example.com"},
+		{"192.0.2.1", "ip/192.0.2.1"},
+		{"2001:db8::1", "ip/2001:db8::1"},
+		{"as64500", "autnum/64500"},
+	}
+	for _, tt := range tests {
+		_, path, err := reg.rdapQuery(tt.target)
+		if err != nil || path != tt.path {
+			t.Errorf("rdapQuery(%q) = %q, %v, want %q", tt.target, path, err, tt.path)
+		}
+	}
+
+	if _, _, err := reg.rdapQuery("example.invalid"); err == nil {
+		t.Error("Expected an error for a TLD without an RDAP server")
+	}
+
+	// Unlisted addresses go to ARIN, which redirects
+	empty := &RDAPRegistry{domains: map[string][]string{}}
+	if server, _, _ := empty.rdapQuery("192.0.2.1"); server != rdapFallbackServer {
+		t.Errorf("Expected the fallback server, got %q", server)
+	}
+}
+
+func TestParseRDAPDomain(t *testing.T) {
+	r, err := parseRDAP([]byte(rdapDomainSample))
+	if err != nil {
+		t.Fatalf("parseRDAP() error = %v", err)
+	}
+
+	if r.ObjectClass != "domain" || r.Name != "EXAMPLE.COM" || len(r.Status) != 2 {
+		t.Errorf("unexpected header: %+v", r)
+	}
+	if len(r.Nameservers) != 2 || r.Nameservers[0] != "a.iana-servers.net" {
+		t.Errorf("unexpected nameservers: %v", r.Nameservers)
+	}
+	if got := RDAPEventDate(r, "expiration"); got != "2030-08-13T04:00:00Z" {
+		t.Errorf("expiration = %q", got)
+	}
+
+	// The abuse contact nested under the registrar is flattened
+	if len(r.Entities) != 3 {
+		t.Fatalf("Expected 3 entities, got %+v", r.Entities)
+	}
+	registrar := RDAPEntityWithRole(r, "registrar")
+	if registrar == nil || registrar.IANAID != "376" || registrar.Name != "RESERVED-Internet Assigned Numbers Authority" {
+		t.Errorf("unexpected registrar: %+v", registrar)
+	}
+	abuse := RDAPEntityWithRole(r, "abuse")
+	if abuse == nil || len(abuse.Emails) != 1 || len(abuse.Phones) != 1 || abuse.Phones[0] != "+1.3103015800" {
+		t.Errorf("unexpected abuse contact: %+v", abuse)
+	}
+	registrant := RDAPEntityWithRole(r, "registrant")
+	if registrant == nil || registrant.Kind != "org" || registrant.Country != "US" {
+		t.Errorf("unexpected registrant: %+v", registrant)
+	}
+	if registrant != nil && registrant.Address != "12025 Waterfront Drive, Los Angeles, CA, 90094" {
+		t.Errorf("unexpected address %q", registrant.Address)
+	}
+}
+
+func TestParseRDAPErrors(t *testing.T) {
+	for _, bad := range []string{
+		`not json`,
+		`{"handle": "X"}`,
+		`{"errorCode": 404, "title": "Not Found"}`,
+	} {
+		if _, err := parseRDAP([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestApplyRDAPToWhois(t *testing.T) {
+	domain, _ := parseRDAP([]byte(rdapDomainSample))
+	report := &model.Report{}
+	applyRDAPToWhois(domain, report)
+
+	w := report.Whois
+	if w.Source != "rdap" || w.Domain != "example.com" || w.Registrar != "RESERVED-Internet Assigned Numbers Authority" {
+		t.Errorf("unexpected domain summary: %+v", w)
+	}
+	if w.Registrant != "Internet Assigned Numbers Authority" || w.Created != "1995-08-14T04:00:00Z" || w.Country != "US" {
+		t.Errorf("unexpected registrant summary: %+v", w)
+	}
+	if len(w.AbuseEmails) != 1 || w.AbuseEmails[0] != "abuse@iana.org" {
+		t.Errorf("unexpected abuse emails: %v", w.AbuseEmails)
+	}
+
+	network, _ := parseRDAP([]byte(rdapNetworkSample))
+	report = &model.Report{}
+	applyRDAPToWhois(network, report)
+
+	w = report.Whois
+	if w.NetRange != "8.8.8.0 - 8.8.8.255" || w.NetName != "GOGL" || w.OrgName != "Google LLC" {
+		t.Errorf("unexpected network summary: %+v", w)
+	}
+	if len(w.AbuseEmails) != 1 || w.AbuseEmails[0] != "network-abuse@google.com" {
+		t.Errorf("unexpected abuse emails: %v", w.AbuseEmails)
+	}
+	if w.Network == nil || w.Network.Handle != "NET-8-8-8-0-2" || w.Parent == nil || w.Parent.Handle != "NET-8-0-0-0-0" {
+		t.Errorf("unexpected typed network: %+v, parent %+v", w.Network, w.Parent)
+	}
+	if w.Org == nil || w.Org.Handle != "GOGL" || w.Abuse == nil || w.Abuse.Handle != "ABUSE5250-ARIN" {
+		t.Errorf("unexpected org %+v or abuse %+v", w.Org, w.Abuse)
+	}
+	if got := network.CIDRs; len(got) != 1 || got[0] != "8.8.8.0/24" {
+		t.Errorf("unexpected CIDRs: %v", got)
+	}
+	if e := RDAPEntityWithRole(network, "registrant"); e == nil || !strings.HasPrefix(e.Address, "1600 Amphitheatre Parkway, Mountain View") {
+		t.Errorf("Expected the address label, got %+v", e)
+	}
+}
+
+func TestLookupRDAP(t *testing.T) {
+	var accept string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		switch r.URL.Path {
+		case "/domain/example.com":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			w.Write([]byte(rdapDomainSample))
+		case "/ip/8.8.8.8":
+			w.Write([]byte(rdapNetworkSample))
+		case "/autnum/3333":
+			http.Redirect(w, r, "/ripe/autnum/3333", http.StatusMovedPermanently)
+		case "/ripe/autnum/3333":
+			w.Write([]byte(`{"objectClassName": "autnum", "handle": "AS3333", "name": "RIPE-NCC-AS", "startAutnum": 3333, "endAutnum": 3333}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reg := testRDAPRegistry(t, srv.URL+"/")
+
+	r, err := lookupRDAP(context.Background(), srv.Client(), reg, "www.example.com")
+	if err != nil {
+		t.Fatalf("lookupRDAP() error = %v", err)
+	}
+	if r.Server != srv.URL+"/" || r.Name != "EXAMPLE.COM" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if !strings.Contains(accept, "application/rdap+json") {
+		t.Errorf("Expected an RDAP Accept header, got %q", accept)
+	}
+
+	if r, err := lookupRDAP(context.Background(), srv.Client(), reg, "8.8.8.8"); err != nil || r.ObjectClass != "ip network" {
+		t.Errorf("lookupRDAP(8.8.8.8) = %+v, %v", r, err)
+	}
+	// The answering server is recorded after a redirect
+	if r, err := lookupRDAP(context.Background(), srv.Client(), reg, "AS3333"); err != nil || r.Server != srv.URL+"/ripe/" {
+		t.Errorf("lookupRDAP(AS3333) = %+v, %v", r, err)
+	}
+	if _, err := lookupRDAP(context.Background(), srv.Client(), reg, "AS64500"); err != errRDAPNotFound {
+		t.Errorf("Expected errRDAPNotFound, got %v", err)
+	}
+}
+
+func TestFetchRDAPChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ip/193.0.6.139":
+			http.Redirect(w, r, "/ripe/ip/193.0.6.139", http.StatusMovedPermanently)
+		case "/ripe/ip/193.0.6.139":
+			w.Write([]byte(rdapNetworkSample))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reg := testRDAPRegistry(t, srv.URL+"/")
+
+	// The ARIN-style redirect is kept as its own hop
+	chain, data, err := fetchRDAP(context.Background(), srv.Client(), reg, "193.0.6.139")
+	if err != nil || len(data) == 0 {
+		t.Fatalf("fetchRDAP() = %d bytes, %v", len(data), err)
+	}
+	if len(chain) != 2 {
+		t.Fatalf("Expected 2 hops, got %+v", chain)
+	}
+	if chain[0].Server != srv.URL+"/" || chain[0].Referral != srv.URL+"/ripe/" || chain[0].Query != "ip/193.0.6.139" {
+		t.Errorf("unexpected first hop: %+v", chain[0])
+	}
+	if chain[1].Server != srv.URL+"/ripe/" || chain[1].Referral != "" || chain[1].Error != "" {
+		t.Errorf("unexpected last hop: %+v", chain[1])
+	}
+
+	chain, _, err = fetchRDAP(context.Background(), srv.Client(), reg, "192.0.2.1")
+	if err != errRDAPNotFound || len(chain) != 1 || chain[0].Error == "" {
+		t.Errorf("fetchRDAP(192.0.2.1) = %+v, %v", chain, err)
+	}
+}
+
+func TestUpdateRDAPBootstrap(t *testing.T) {
+	files := map[string]string{
+		"/dns.json":  `{"version":"1.0","services":[[["test"],["https://rdap.nic.test/"]]]}`,
+		"/ipv4.json": `{"version":"1.0","services":[[["192.0.2.0/24"],["https://rdap.v4.test/"]]]}`,
+		"/ipv6.json": `{"version":"1.0","services":[[["2001:db8::/32"],["https://rdap.v6.test/"]]]}`,
+		"/asn.json":  `{"version":"1.0","services":[[["64496-64511"],["https://rdap.asn.test/"]]]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body, ok := files[r.URL.Path]; ok {
+			w.Write([]byte(body))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "rdap")
+	if err := updateRDAPBootstrap(context.Background(), srv.Client(), srv.URL+"/", dir); err != nil {
+		t.Fatalf("updateRDAPBootstrap() error = %v", err)
+	}
+
+	reg, err := LoadRDAPRegistry(dir)
+	if err != nil {
+		t.Fatalf("LoadRDAPRegistry() error = %v", err)
+	}
+	if got := reg.DomainServer("nic.test"); got != "https://rdap.nic.test/" {
+		t.Errorf("Expected the downloaded DNS file to be used, got %q", got)
+	}
+	if got := reg.ASNServer(64500); got != "https://rdap.asn.test/" {
+		t.Errorf("Expected the downloaded ASN file to be used, got %q", got)
+	}
+
+	// A bad download leaves the previous files alone
+	files["/asn.json"] = `{"services":[[["not-a-range"],["https://x/"]]]}`
+	if err := updateRDAPBootstrap(context.Background(), srv.Client(), srv.URL+"/", dir); err == nil {
+		t.Error("Expected an error for a bad ASN file")
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "asn.json"))
+	if !strings.Contains(string(data), "64496-64511") {
+		t.Errorf("Expected the previous ASN file to survive, got %s", data)
+	}
+}
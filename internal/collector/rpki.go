@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/rpki"
+)
+
+// RPKIOptions configure route origin validation
+type RPKIOptions struct {
+	// VRPFile is a routinator or rpki-client export (JSON or CSV); no
+	// validation is done without one
+	VRPFile string
+}
+
+// rpkiRouteTimeout bounds the Team Cymru lookup of the announced route
+const rpkiRouteTimeout = 2 * time.Second
+
+// collectRPKI validates the prefix and origin AS found by the ASN
+// collector, so it runs once that has finished. Only Team Cymru's
+// prefixes are announced routes: the offline databases group addresses
+// into ranges of their own, so for those the route is looked up in the
+// origin zone first, and a result without it is marked approximate.
+func collectRPKI(ctx context.Context, report *model.Report, opts RPKIOptions) {
+	collectRPKIWithResolver(ctx, report, opts, net.DefaultResolver)
+}
+
+func collectRPKIWithResolver(ctx context.Context, report *model.Report, opts RPKIOptions, r hopResolver) {
+	if opts.VRPFile == "" {
+		return
+	}
+	if report.Geo.Prefix == "" || report.Geo.ASN == "" {
+		report.Errors["rpki"] = "no announced prefix and origin AS to validate"
+		return
+	}
+
+	vrps, err := rpki.Load(opts.VRPFile)
+	if err != nil {
+		report.Errors["rpki"] = fmt.Sprintf("failed to load VRPs: %v", err)
+		return
+	}
+
+	route := &cymruOrigin{ASN: report.Geo.ASN, Prefix: report.Geo.Prefix}
+	approximate := false
+	if report.Geo.ASNSource != ASNSourceCymru {
+		if announced, ok := announcedRoute(ctx, r, report.Geo.IP); ok {
+			route = announced
+		} else {
+			approximate = true
+		}
+	}
+
+	if err := validateRPKI(report, vrps, route); err != nil {
+		report.Errors["rpki"] = err.Error()
+		return
+	}
+	report.RPKI.Approximate = approximate
+	report.RPKI.VRPFile = opts.VRPFile
+}
+
+// announcedRoute asks Team Cymru for the route covering ip
+func announcedRoute(ctx context.Context, r hopResolver, ip string) (*cymruOrigin, bool) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, rpkiRouteTimeout)
+	defer cancel()
+	origin, err := lookupCymruOrigin(ctx, r, addr)
+	if err != nil || origin.ASN == "" || origin.Prefix == "" {
+		return nil, false
+	}
+	return origin, true
+}
+
+func validateRPKI(report *model.Report, vrps *rpki.Set, route *cymruOrigin) error {
+	prefix, err := netip.ParsePrefix(route.Prefix)
+	if err != nil {
+		return fmt.Errorf("invalid announced prefix %q", route.Prefix)
+	}
+	origin, err := strconv.ParseUint(route.ASN, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid origin AS %q", route.ASN)
+	}
+
+	res := vrps.Validate(prefix, uint32(origin))
+	report.RPKI = &model.RPKIResult{
+		State:  res.State,
+		Reason: res.Reason,
+		Prefix: prefix.Masked().String(),
+		Origin: route.ASN,
+	}
+	for _, v := range res.VRPs {
+		report.RPKI.ROAs = append(report.RPKI.ROAs, model.RPKIROA{
+			ASN:       strconv.FormatUint(uint64(v.ASN), 10),
+			Prefix:    v.Prefix.String(),
+			MaxLength: v.MaxLength,
+			TA:        v.TA,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"github.com/typicalfo/netgaze/internal/rpki"
+)
+
+const vrpCSV = `ASN,IP Prefix,Max Length,Trust Anchor
+AS15169,8.8.8.0/24,24,arin
+AS13335,1.1.1.0/24,24,apnic
+`
+
+func rpkiReport(prefix, asn string) *model.Report {
+	report := &model.Report{Errors: make(map[string]string)}
+	report.Geo.Prefix = prefix
+	report.Geo.ASN = asn
+	report.Geo.ASNSource = ASNSourceCymru
+	return report
+}
+
+func TestCollectRPKI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrps.csv")
+	if err := os.WriteFile(path, []byte(vrpCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RPKIOptions{VRPFile: path}
+
+	report := rpkiReport("8.8.8.0/24", "15169")
+	collectRPKI(context.Background(), report, opts)
+	got := report.RPKI
+	if got == nil || got.State != rpki.StateValid || got.Origin != "15169" || got.VRPFile != path {
+		t.Fatalf("Expected a valid result, got %+v (errors %v)", got, report.Errors)
+	}
+	if len(got.ROAs) != 1 || got.ROAs[0] != (model.RPKIROA{ASN: "15169", Prefix: "8.8.8.0/24", MaxLength: 24, TA: "arin"}) {
+		t.Errorf("Expected the matching ROA, got %+v", got.ROAs)
+	}
+
+	// A hijack of a more specific
+	report = rpkiReport("1.1.1.0/25", "64500")
+	collectRPKI(context.Background(), report, opts)
+	if got := report.RPKI; got == nil || got.State != rpki.StateInvalid || got.Reason != rpki.ReasonASN || len(got.ROAs) != 1 {
+		t.Errorf("Expected an invalid result, got %+v", got)
+	}
+
+	report = rpkiReport("9.9.9.0/24", "19281")
+	collectRPKI(context.Background(), report, opts)
+	if got := report.RPKI; got == nil || got.State != rpki.StateNotFound || len(got.ROAs) != 0 {
+		t.Errorf("Expected not-found, got %+v", got)
+	}
+}
+
+func TestCollectRPKIErrors(t *testing.T) {
+	// Nothing configured, nothing done
+	report := rpkiReport("8.8.8.0/24", "15169")
+	collectRPKI(context.Background(), report, RPKIOptions{})
+	if report.RPKI != nil || len(report.Errors) != 0 {
+		t.Errorf("Expected no validation without a VRP file, got %+v %v", report.RPKI, report.Errors)
+	}
+
+	path := filepath.Join(t.TempDir(), "missing.json")
+	for _, report := range []*model.Report{rpkiReport("", ""), rpkiReport("8.8.8.0/24", "15169")} {
+		collectRPKI(context.Background(), report, RPKIOptions{VRPFile: path})
+		if report.RPKI != nil || report.Errors["rpki"] == "" {
+			t.Errorf("Expected an rpki error, got %+v %v", report.RPKI, report.Errors)
+		}
+	}
+}
+
+func TestCollectRPKIOfflinePrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrps.csv")
+	if err := os.WriteFile(path, []byte(vrpCSV), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := RPKIOptions{VRPFile: path}
+
+	// An ip2asn range cut into prefixes, longer than the announced /24
+	offline := func() *model.Report {
+		report := rpkiReport("8.8.8.0/25", "15169")
+		report.Geo.IP = "8.8.8.8"
+		report.Geo.ASNSource = ASNSourceDB
+		return report
+	}
+
+	r := &fakeHopResolver{
+		txt:     map[string]string{"8.8.8.8.origin.asn.cymru.com": "15169 | 8.8.8.0/24 | US | arin | 2014-03-14"},
+		queries: make(map[string]int),
+	}
+	report := offline()
+	collectRPKIWithResolver(context.Background(), report, opts, r)
+	if got := report.RPKI; got == nil || got.State != rpki.StateValid || got.Prefix != "8.8.8.0/24" || got.Approximate {
+		t.Errorf("Expected the announced route to validate, got %+v (errors %v)", got, report.Errors)
+	}
+
+	// Without the origin zone the database's prefix is used, flagged
+	r = &fakeHopResolver{queries: make(map[string]int)}
+	report = offline()
+	collectRPKIWithResolver(context.Background(), report, opts, r)
+	if got := report.RPKI; got == nil || got.Prefix != "8.8.8.0/25" || !got.Approximate {
+		t.Errorf("Expected an approximate result, got %+v (errors %v)", got, report.Errors)
+	}
+
+	// Team Cymru's own prefix is not looked up again
+	report = rpkiReport("8.8.8.0/24", "15169")
+	report.Geo.IP = "8.8.8.8"
+	collectRPKIWithResolver(context.Background(), report, opts, r)
+	if len(r.queries) != 1 {
+		t.Errorf("Expected no lookup for a Cymru prefix, got %v", r.queries)
+	}
+}
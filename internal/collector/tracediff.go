@@ -0,0 +1,326 @@
+package collector
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Hop change kinds recorded in HopChange.Kind
+const (
+	HopSame       = "same"       // same responder
+	HopChanged    = "changed"    // different responder at this position
+	HopInserted   = "inserted"   // hop only in the new trace
+	HopRemoved    = "removed"    // hop only in the baseline
+	HopSilent     = "silent"     // answered before, times out now
+	HopResponding = "responding" // timed out before, answers now
+)
+
+// TraceDiffOptions sets when a latency increase counts as a regression.
+// Both thresholds must be exceeded; zero values select the defaults.
+type TraceDiffOptions struct {
+	RTTThresholdMs  float64 // absolute increase, default 20ms
+	RTTThresholdPct float64 // relative increase, default 50%
+}
+
+const (
+	defaultRTTThresholdMs  = 20
+	defaultRTTThresholdPct = 50
+)
+
+// HopChange pairs a baseline hop with its aligned counterpart in the new
+// trace. Base or New is nil for removed and inserted hops.
+type HopChange struct {
+	Kind       string          `json:"kind"`
+	Base       *model.TraceHop `json:"base,omitempty"`
+	New        *model.TraceHop `json:"new,omitempty"`
+	BaseRTTMs  float64         `json:"base_rtt_ms,omitempty"`
+	NewRTTMs   float64         `json:"new_rtt_ms,omitempty"`
+	Regression bool            `json:"rtt_regression,omitempty"`
+}
+
+// TraceDiff is the result of aligning two traces of the same target
+type TraceDiff struct {
+	Hops []HopChange `json:"hops"`
+
+	BaseASPath    []string `json:"base_as_path,omitempty"`
+	NewASPath     []string `json:"new_as_path,omitempty"`
+	ASPathChanged bool     `json:"as_path_changed,omitempty"`
+
+	// DestinationLost is set when the baseline's last hop answered
+	// but the new trace ends in timeouts
+	DestinationLost bool `json:"destination_lost,omitempty"`
+
+	// DestinationRegression is set when the final hop's latency
+	// crossed the thresholds
+	DestinationRegression bool `json:"destination_regression,omitempty"`
+}
+
+// Significant reports whether the path or end-to-end latency changed
+// enough to alert on. Latency regressions at intermediate hops are not
+// significant on their own: routers often rate-limit or deprioritise
+// the ICMP errors traceroute relies on.
+func (d *TraceDiff) Significant() bool {
+	if d.ASPathChanged || d.DestinationLost || d.DestinationRegression {
+		return true
+	}
+	for _, h := range d.Hops {
+		switch h.Kind {
+		case HopChanged, HopInserted, HopRemoved:
+			return true
+		}
+	}
+	return false
+}
+
+// Alignment scores: sharing a responder is a strong match, two timeouts a
+// weak one. Substitutions and gaps cost the same, so a single inserted
+// hop is reported as one insertion rather than a cascade of changes.
+const (
+	alignMatch    = 2
+	alignTimeouts = 1
+	alignUnknown  = 0
+	alignMismatch = -1
+	alignGap      = -1
+)
+
+// DiffTraces aligns base and cur with a global sequence alignment and
+// classifies every hop, the AS path and end-to-end latency.
+func DiffTraces(base, cur []model.TraceHop, opts TraceDiffOptions) *TraceDiff {
+	if opts.RTTThresholdMs <= 0 {
+		opts.RTTThresholdMs = defaultRTTThresholdMs
+	}
+	if opts.RTTThresholdPct <= 0 {
+		opts.RTTThresholdPct = defaultRTTThresholdPct
+	}
+
+	diff := &TraceDiff{Hops: alignHops(base, cur)}
+
+	for i := range diff.Hops {
+		h := &diff.Hops[i]
+		if h.Base == nil || h.New == nil || h.Kind != HopSame {
+			continue
+		}
+		baseRTT, okBase := hopRTTMs(*h.Base)
+		newRTT, okNew := hopRTTMs(*h.New)
+		if !okBase || !okNew {
+			continue
+		}
+		h.BaseRTTMs = baseRTT
+		h.NewRTTMs = newRTT
+		h.Regression = isRTTRegression(baseRTT, newRTT, opts)
+	}
+
+	diff.BaseASPath = asPath(base)
+	diff.NewASPath = asPath(cur)
+	// Baselines written before hops were enriched have no AS data
+	if len(diff.BaseASPath) > 0 && len(diff.NewASPath) > 0 {
+		diff.ASPathChanged = !slices.Equal(diff.BaseASPath, diff.NewASPath)
+	}
+
+	if len(base) > 0 && len(cur) > 0 {
+		lastBase, lastNew := base[len(base)-1], cur[len(cur)-1]
+		diff.DestinationLost = !lastBase.Timeout && lastNew.Timeout
+
+		baseRTT, okBase := hopRTTMs(lastBase)
+		newRTT, okNew := hopRTTMs(lastNew)
+		if okBase && okNew && lastBase.IP == lastNew.IP {
+			diff.DestinationRegression = isRTTRegression(baseRTT, newRTT, opts)
+		}
+	}
+
+	return diff
+}
+
+// alignHops runs Needleman-Wunsch over the two hop lists
+func alignHops(base, cur []model.TraceHop) []HopChange {
+	n, m := len(base), len(cur)
+
+	score := make([][]int, n+1)
+	for i := range score {
+		score[i] = make([]int, m+1)
+		score[i][0] = i * alignGap
+	}
+	for j := 0; j <= m; j++ {
+		score[0][j] = j * alignGap
+	}
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			score[i][j] = max(
+				score[i-1][j-1]+hopScore(base[i-1], cur[j-1]),
+				score[i-1][j]+alignGap,
+				score[i][j-1]+alignGap,
+			)
+		}
+	}
+
+	// Walk back from the bottom-right corner, preferring diagonal moves
+	var changes []HopChange
+	i, j := n, m
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && score[i][j] == score[i-1][j-1]+hopScore(base[i-1], cur[j-1]):
+			changes = append(changes, HopChange{Kind: hopKind(base[i-1], cur[j-1]), Base: &base[i-1], New: &cur[j-1]})
+			i--
+			j--
+		case i > 0 && score[i][j] == score[i-1][j]+alignGap:
+			changes = append(changes, HopChange{Kind: HopRemoved, Base: &base[i-1]})
+			i--
+		default:
+			changes = append(changes, HopChange{Kind: HopInserted, New: &cur[j-1]})
+			j--
+		}
+	}
+
+	slices.Reverse(changes)
+	return changes
+}
+
+func hopScore(a, b model.TraceHop) int {
+	switch {
+	case a.Timeout && b.Timeout:
+		return alignTimeouts
+	case a.Timeout || b.Timeout:
+		return alignUnknown
+	case sharesResponder(a, b):
+		return alignMatch
+	}
+	return alignMismatch
+}
+
+func hopKind(a, b model.TraceHop) string {
+	switch {
+	case a.Timeout && b.Timeout:
+		return HopSame
+	case b.Timeout:
+		return HopSilent
+	case a.Timeout:
+		return HopResponding
+	case sharesResponder(a, b):
+		return HopSame
+	}
+	return HopChanged
+}
+
+// sharesResponder reports whether two hops had any responder in common,
+// so ECMP paths alternating between routers are not flagged as changes
+func sharesResponder(a, b model.TraceHop) bool {
+	for _, x := range hopResponders(a) {
+		if slices.Contains(hopResponders(b), x) {
+			return true
+		}
+	}
+	return false
+}
+
+func hopResponders(h model.TraceHop) []string {
+	if len(h.Responders) > 0 {
+		return h.Responders
+	}
+	if h.IP != "" {
+		return []string{h.IP}
+	}
+	return nil
+}
+
+// hopRTTMs returns the mean RTT of a hop's answered probes, falling back
+// to the RTT string of baselines that predate per-probe results
+func hopRTTMs(h model.TraceHop) (float64, bool) {
+	var sum float64
+	var n int
+	for _, p := range h.Probes {
+		if !p.Timeout {
+			sum += p.RTTMs
+			n++
+		}
+	}
+	if n > 0 {
+		return sum / float64(n), true
+	}
+
+	rtt, err := strconv.ParseFloat(strings.TrimSuffix(h.RTT, "ms"), 64)
+	if err != nil {
+		return 0, false
+	}
+	return rtt, true
+}
+
+func isRTTRegression(base, cur float64, opts TraceDiffOptions) bool {
+	increase := cur - base
+	return increase > opts.RTTThresholdMs && increase > base*opts.RTTThresholdPct/100
+}
+
+// asPath lists the ASes a trace crosses, in order, without repeats
+func asPath(hops []model.TraceHop) []string {
+	var path []string
+	for _, h := range hops {
+		if h.ASN != "" && (len(path) == 0 || path[len(path)-1] != h.ASN) {
+			path = append(path, h.ASN)
+		}
+	}
+	return path
+}
+
+// FormatTraceDiff renders a diff as human-readable lines
+func FormatTraceDiff(d *TraceDiff) string {
+	var b strings.Builder
+
+	for _, h := range d.Hops {
+		switch h.Kind {
+		case HopSame:
+			if h.Regression {
+				fmt.Fprintf(&b, "Hop %d: %s latency %.1fms -> %.1fms\n", h.New.Hop, h.New.IP, h.BaseRTTMs, h.NewRTTMs)
+			}
+		case HopChanged:
+			fmt.Fprintf(&b, "Hop %d changed: %s -> %s\n", h.New.Hop, diffHopLabel(h.Base), diffHopLabel(h.New))
+		case HopInserted:
+			fmt.Fprintf(&b, "Hop %d: new (%s)\n", h.New.Hop, diffHopLabel(h.New))
+		case HopRemoved:
+			fmt.Fprintf(&b, "Hop %d: removed (was %s)\n", h.Base.Hop, diffHopLabel(h.Base))
+		case HopSilent:
+			fmt.Fprintf(&b, "Hop %d: no longer answering (was %s)\n", h.New.Hop, diffHopLabel(h.Base))
+		case HopResponding:
+			fmt.Fprintf(&b, "Hop %d: now answering (%s)\n", h.New.Hop, diffHopLabel(h.New))
+		}
+	}
+
+	if d.ASPathChanged {
+		fmt.Fprintf(&b, "AS path changed: %s -> %s\n", formatASPath(d.BaseASPath), formatASPath(d.NewASPath))
+	}
+	if d.DestinationLost {
+		b.WriteString("Destination no longer reached\n")
+	}
+	if d.DestinationRegression {
+		b.WriteString("Destination latency regressed\n")
+	}
+
+	if b.Len() == 0 {
+		return "No changes"
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func diffHopLabel(h *model.TraceHop) string {
+	if h.Timeout {
+		return "*"
+	}
+	if h.ASN != "" {
+		return fmt.Sprintf("%s AS%s", h.IP, h.ASN)
+	}
+	return h.IP
+}
+
+func formatASPath(path []string) string {
+	if len(path) == 0 {
+		return "(none)"
+	}
+	out := make([]string, len(path))
+	for i, asn := range path {
+		out[i] = "AS" + asn
+	}
+	return strings.Join(out, " ")
+}
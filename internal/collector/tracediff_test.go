@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func diffHop(n int, ip, rtt string) model.TraceHop {
+	if ip == "*" {
+		return model.TraceHop{Hop: n, RTT: "*", Timeout: true}
+	}
+	return model.TraceHop{Hop: n, IP: ip, RTT: rtt}
+}
+
+func diffKinds(d *TraceDiff) []string {
+	kinds := make([]string, len(d.Hops))
+	for i, h := range d.Hops {
+		kinds[i] = h.Kind
+	}
+	return kinds
+}
+
+func TestDiffTracesAlignment(t *testing.T) {
+	base := []model.TraceHop{
+		diffHop(1, "10.0.0.1", "1.0ms"),
+		diffHop(2, "10.0.1.1", "5.0ms"),
+		diffHop(3, "10.0.2.1", "8.0ms"),
+		diffHop(4, "192.0.2.1", "10.0ms"),
+	}
+
+	tests := []struct {
+		name        string
+		cur         []model.TraceHop
+		want        []string
+		significant bool
+	}{
+		{
+			name:        "identical",
+			cur:         base,
+			want:        []string{HopSame, HopSame, HopSame, HopSame},
+			significant: false,
+		},
+		{
+			name: "inserted hop",
+			cur: []model.TraceHop{
+				diffHop(1, "10.0.0.1", "1.0ms"),
+				diffHop(2, "10.0.9.9", "3.0ms"),
+				diffHop(3, "10.0.1.1", "5.0ms"),
+				diffHop(4, "10.0.2.1", "8.0ms"),
+				diffHop(5, "192.0.2.1", "10.0ms"),
+			},
+			want:        []string{HopSame, HopInserted, HopSame, HopSame, HopSame},
+			significant: true,
+		},
+		{
+			name: "removed hop",
+			cur: []model.TraceHop{
+				diffHop(1, "10.0.0.1", "1.0ms"),
+				diffHop(2, "10.0.2.1", "8.0ms"),
+				diffHop(3, "192.0.2.1", "10.0ms"),
+			},
+			want:        []string{HopSame, HopRemoved, HopSame, HopSame},
+			significant: true,
+		},
+		{
+			name: "changed hop",
+			cur: []model.TraceHop{
+				diffHop(1, "10.0.0.1", "1.0ms"),
+				diffHop(2, "10.0.7.7", "5.0ms"),
+				diffHop(3, "10.0.2.1", "8.0ms"),
+				diffHop(4, "192.0.2.1", "10.0ms"),
+			},
+			want:        []string{HopSame, HopChanged, HopSame, HopSame},
+			significant: true,
+		},
+		{
+			name: "router stops answering",
+			cur: []model.TraceHop{
+				diffHop(1, "10.0.0.1", "1.0ms"),
+				diffHop(2, "*", ""),
+				diffHop(3, "10.0.2.1", "8.0ms"),
+				diffHop(4, "192.0.2.1", "10.0ms"),
+			},
+			want:        []string{HopSame, HopSilent, HopSame, HopSame},
+			significant: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DiffTraces(base, tt.cur, TraceDiffOptions{})
+			got := diffKinds(d)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("DiffTraces() kinds = %v, want %v", got, tt.want)
+			}
+			if d.Significant() != tt.significant {
+				t.Errorf("Significant() = %v, want %v", d.Significant(), tt.significant)
+			}
+		})
+	}
+}
+
+func TestDiffTracesECMP(t *testing.T) {
+	base := []model.TraceHop{{Hop: 1, IP: "10.0.0.1", Responders: []string{"10.0.0.1", "10.0.0.2"}, RTT: "1.0ms"}}
+	cur := []model.TraceHop{{Hop: 1, IP: "10.0.0.2", Responders: []string{"10.0.0.2"}, RTT: "1.0ms"}}
+
+	d := DiffTraces(base, cur, TraceDiffOptions{})
+	if d.Hops[0].Kind != HopSame || d.Significant() {
+		t.Errorf("Expected a load-balanced responder to count as the same hop: %+v", d.Hops[0])
+	}
+}
+
+func TestDiffTracesLatency(t *testing.T) {
+	base := []model.TraceHop{
+		diffHop(1, "10.0.0.1", "1.0ms"),
+		diffHop(2, "192.0.2.1", "10.0ms"),
+	}
+
+	// Intermediate hop slower, destination unchanged: reported only
+	cur := []model.TraceHop{
+		diffHop(1, "10.0.0.1", "80.0ms"),
+		diffHop(2, "192.0.2.1", "12.0ms"),
+	}
+	d := DiffTraces(base, cur, TraceDiffOptions{})
+	if !d.Hops[0].Regression || d.DestinationRegression || d.Significant() {
+		t.Errorf("Expected an informational regression at hop 1 only: %+v", d)
+	}
+
+	// Destination slower beyond both thresholds
+	cur[1] = diffHop(2, "192.0.2.1", "45.0ms")
+	d = DiffTraces(base, cur, TraceDiffOptions{})
+	if !d.DestinationRegression || !d.Significant() {
+		t.Error("Expected a destination latency regression")
+	}
+
+	// Same increase below a custom absolute threshold
+	d = DiffTraces(base, cur, TraceDiffOptions{RTTThresholdMs: 50})
+	if d.DestinationRegression {
+		t.Error("Expected a 35ms increase to stay under a 50ms threshold")
+	}
+
+	// Per-probe RTTs take precedence over the summary RTT
+	probed := diffHop(2, "192.0.2.1", "10.0ms")
+	probed.Probes = []model.TraceProbe{{IP: "192.0.2.1", RTTMs: 40}, {Timeout: true}, {IP: "192.0.2.1", RTTMs: 50}}
+	if rtt, ok := hopRTTMs(probed); !ok || rtt != 45 {
+		t.Errorf("hopRTTMs() = %v, %v, want 45, true", rtt, ok)
+	}
+}
+
+func TestDiffTracesASPathAndDestination(t *testing.T) {
+	base := []model.TraceHop{
+		{Hop: 1, IP: "203.0.113.1", RTT: "5.0ms", ASN: "64500"},
+		{Hop: 2, IP: "192.0.2.1", RTT: "10.0ms", ASN: "64501"},
+	}
+	cur := []model.TraceHop{
+		{Hop: 1, IP: "203.0.113.1", RTT: "5.0ms", ASN: "64500"},
+		{Hop: 2, IP: "198.51.100.1", RTT: "9.0ms", ASN: "64502"},
+		{Hop: 3, RTT: "*", Timeout: true},
+	}
+
+	d := DiffTraces(base, cur, TraceDiffOptions{})
+	if !d.ASPathChanged || !d.DestinationLost || !d.Significant() {
+		t.Errorf("Expected AS path change and lost destination: %+v", d)
+	}
+
+	text := FormatTraceDiff(d)
+	if !strings.Contains(text, "AS path changed: AS64500 AS64501 -> AS64500 AS64502") {
+		t.Errorf("unexpected diff text:\n%s", text)
+	}
+
+	// Old baselines without AS data never report an AS path change
+	legacy := []model.TraceHop{diffHop(1, "203.0.113.1", "5.0ms"), diffHop(2, "192.0.2.1", "10.0ms")}
+	if d := DiffTraces(legacy, cur[:2], TraceDiffOptions{}); d.ASPathChanged {
+		t.Error("Expected no AS path comparison without baseline AS data")
+	}
+}
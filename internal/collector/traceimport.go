@@ -0,0 +1,381 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Traceroute formats accepted by ImportTraces
+const (
+	TraceFormatMTR        = "mtr"        // mtr --json
+	TraceFormatTracert    = "tracert"    // Windows tracert text
+	TraceFormatTraceroute = "traceroute" // BSD, macOS and Linux traceroute text
+	TraceFormatScamper    = "scamper"    // scamper warts-json (sc_warts2json)
+)
+
+// DetectTraceFormat guesses the format of a saved traceroute
+func DetectTraceFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("empty traceroute file")
+	}
+
+	if trimmed[0] == '{' {
+		if bytes.Contains(trimmed, []byte(`"report"`)) && bytes.Contains(trimmed, []byte(`"hubs"`)) {
+			return TraceFormatMTR, nil
+		}
+		if bytes.Contains(trimmed, []byte(`"type"`)) {
+			return TraceFormatScamper, nil
+		}
+		return "", fmt.Errorf("unrecognised JSON traceroute")
+	}
+
+	if bytes.Contains(trimmed, []byte("Tracing route to")) || bytes.Contains(trimmed, []byte("over a maximum of")) {
+		return TraceFormatTracert, nil
+	}
+	if tracerouteHeader.Match(trimmed) {
+		return TraceFormatTraceroute, nil
+	}
+	// Hop lines without the header line still parse as traceroute
+	if hops, _ := parseTracerouteOutput(string(trimmed)); len(hops) > 0 {
+		return TraceFormatTraceroute, nil
+	}
+	return "", fmt.Errorf("unrecognised traceroute format")
+}
+
+// ImportTraces parses traceroute output saved by another tool. format is
+// one of the TraceFormat constants, or "" to detect it. A file may hold
+// several traces (scamper); each comes back as its own run. Target and
+// timestamp are filled in when the format records them.
+func ImportTraces(data []byte, format string) ([]*model.TraceRun, error) {
+	if format == "" {
+		detected, err := DetectTraceFormat(data)
+		if err != nil {
+			return nil, err
+		}
+		format = detected
+	}
+
+	var runs []*model.TraceRun
+	var err error
+	switch format {
+	case TraceFormatMTR:
+		var run *model.TraceRun
+		run, err = parseMTRJSON(data)
+		runs = []*model.TraceRun{run}
+	case TraceFormatTracert:
+		runs = []*model.TraceRun{parseTracert(string(data))}
+	case TraceFormatTraceroute:
+		runs = []*model.TraceRun{parseTracerouteText(string(data))}
+	case TraceFormatScamper:
+		runs, err = parseScamperJSON(data)
+	default:
+		return nil, fmt.Errorf("unknown traceroute format: %s (valid: mtr, tracert, traceroute, scamper)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, run := range runs {
+		if len(run.Hops) == 0 {
+			return nil, fmt.Errorf("no hops found in %s output", format)
+		}
+	}
+	return runs, nil
+}
+
+// mtrReport mirrors the parts of mtr --json output that are used. Older
+// mtr releases print the hop count as a string.
+type mtrReport struct {
+	Report struct {
+		MTR struct {
+			Dst string `json:"dst"`
+		} `json:"mtr"`
+		Hubs []struct {
+			Count json.RawMessage `json:"count"`
+			Host  string          `json:"host"`
+			ASN   string          `json:"ASN"`
+			Loss  float64         `json:"Loss%"`
+			Sent  int             `json:"Snt"`
+			Avg   float64         `json:"Avg"`
+		} `json:"hubs"`
+	} `json:"report"`
+}
+
+// parseMTRJSON converts an mtr --json report. mtr keeps per-hop totals
+// only, so hops carry loss and average RTT but no individual probes.
+func parseMTRJSON(data []byte) (*model.TraceRun, error) {
+	var r mtrReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse mtr JSON: %w", err)
+	}
+
+	run := &model.TraceRun{Target: r.Report.MTR.Dst}
+	if ip := net.ParseIP(run.Target); ip != nil {
+		run.IP = ip.String()
+	}
+
+	for i, hub := range r.Report.Hubs {
+		hop := model.TraceHop{Hop: i + 1}
+		if n, err := strconv.Atoi(strings.Trim(string(hub.Count), `"`)); err == nil {
+			hop.Hop = n
+		}
+
+		hop.IP, hop.Host = splitHostIP(hub.Host)
+		hop.Sent = hub.Sent
+		hop.LossPct = roundMs(hub.Loss)
+		hop.Received = int(float64(hub.Sent)*(100-hub.Loss)/100 + 0.5)
+
+		if hub.Host == "???" || hop.Received == 0 {
+			hop.IP, hop.Host = "", ""
+			hop.RTT = "*"
+			hop.Timeout = true
+		} else {
+			hop.RTT = fmt.Sprintf("%.1fms", hub.Avg)
+			if hop.IP != "" {
+				hop.Responders = []string{hop.IP}
+			}
+		}
+
+		if asn := strings.TrimPrefix(hub.ASN, "AS"); asn != "" && asn != "???" {
+			hop.ASN = asn
+		}
+		run.Hops = append(run.Hops, hop)
+	}
+
+	return run, nil
+}
+
+// splitHostIP splits "name (ip)", a bare address or a bare name
+func splitHostIP(s string) (ip, host string) {
+	s = strings.TrimSpace(s)
+	if parsed := net.ParseIP(s); parsed != nil {
+		return parsed.String(), ""
+	}
+	if open := strings.LastIndexAny(s, "(["); open > 0 && (strings.HasSuffix(s, ")") || strings.HasSuffix(s, "]")) {
+		if parsed := net.ParseIP(s[open+1 : len(s)-1]); parsed != nil {
+			return parsed.String(), strings.TrimSpace(s[:open])
+		}
+	}
+	return "", s
+}
+
+var (
+	// "traceroute to example.com (93.184.216.34), 64 hops max, 52 byte packets"
+	tracerouteHeader = regexp.MustCompile(`(?m)^traceroute6? to (\S+) \(([^)]+)\)`)
+	// "Tracing route to example.com [93.184.216.34]" or "Tracing route to 8.8.8.8 over ..."
+	tracertHeader = regexp.MustCompile(`Tracing route to (\S+)(?: \[([^\]]+)\])?`)
+)
+
+// parseTracerouteText parses BSD, macOS or Linux traceroute output,
+// taking the target from the header line when present
+func parseTracerouteText(output string) *model.TraceRun {
+	run := &model.TraceRun{}
+	if m := tracerouteHeader.FindStringSubmatch(output); m != nil {
+		run.Target = m[1]
+		if ip := net.ParseIP(m[2]); ip != nil {
+			run.IP = ip.String()
+		}
+	}
+	run.Hops, _ = parseTracerouteOutput(output)
+	return run
+}
+
+// parseTracert parses Windows tracert output:
+//
+//	1    <1 ms    <1 ms    <1 ms  192.168.1.1
+//	2     *        *        *     Request timed out.
+//	3    12 ms    11 ms    13 ms  ae-1.r01.example.net [203.0.113.5]
+//	4     9 ms     *        *     10.0.0.1 reports: Destination host unreachable.
+func parseTracert(output string) *model.TraceRun {
+	run := &model.TraceRun{Protocol: TraceProtoICMP}
+	if m := tracertHeader.FindStringSubmatch(output); m != nil {
+		run.Target = m[1]
+		if ip := net.ParseIP(m[2]); ip != nil {
+			run.IP = ip.String()
+		} else if ip := net.ParseIP(m[1]); ip != nil {
+			run.IP = ip.String()
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		hopNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+
+		hop := model.TraceHop{Hop: hopNum}
+		var rtts []float64
+		var timeouts []bool
+		rest := fields[1:]
+		for len(rest) > 0 {
+			field := rest[0]
+			if field == "*" {
+				rtts = append(rtts, 0)
+				timeouts = append(timeouts, true)
+				rest = rest[1:]
+				continue
+			}
+			// "<1 ms" is below the timer resolution; record the bound
+			rtt, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSuffix(field, "ms"), "<"), 64)
+			if err != nil {
+				break
+			}
+			rtts = append(rtts, rtt)
+			timeouts = append(timeouts, false)
+			rest = rest[1:]
+			if len(rest) > 0 && rest[0] == "ms" {
+				rest = rest[1:]
+			}
+		}
+
+		text := strings.Join(rest, " ")
+		var ip, annotation string
+		switch {
+		case strings.HasPrefix(text, "Request timed out"):
+		case strings.Contains(text, " reports: "):
+			ip, _ = splitHostIP(text[:strings.Index(text, " reports: ")])
+			annotation = tracertAnnotation(text)
+		default:
+			ip, hop.Host = splitHostIP(text)
+		}
+
+		for i, rtt := range rtts {
+			if timeouts[i] || ip == "" {
+				hop.Probes = append(hop.Probes, model.TraceProbe{Timeout: true})
+				continue
+			}
+			hop.Probes = append(hop.Probes, model.TraceProbe{IP: ip, RTTMs: roundMs(rtt), Annotation: annotation})
+		}
+		// An unreachable report may come with no RTT at all
+		if len(hop.Probes) == 0 && ip != "" {
+			hop.Probes = append(hop.Probes, model.TraceProbe{IP: ip, Annotation: annotation})
+		}
+
+		summarizeTraceHop(&hop)
+		run.Hops = append(run.Hops, hop)
+	}
+
+	return run
+}
+
+// tracertAnnotation maps a tracert "reports:" message to the traceroute flag
+func tracertAnnotation(text string) string {
+	switch {
+	case strings.Contains(text, "host unreachable"):
+		return "!H"
+	case strings.Contains(text, "net unreachable"):
+		return "!N"
+	case strings.Contains(text, "protocol unreachable"):
+		return "!P"
+	case strings.Contains(text, "prohibited"):
+		return "!X"
+	}
+	return ""
+}
+
+// scamperTrace mirrors a "trace" object written by sc_warts2json. Only
+// probes that got a reply are listed; TTLs without one timed out.
+type scamperTrace struct {
+	Type     string `json:"type"`
+	Method   string `json:"method"`
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	FirstHop int    `json:"firsthop"`
+	Attempts int    `json:"attempts"`
+	Start    struct {
+		Sec  int64 `json:"sec"`
+		Usec int64 `json:"usec"`
+	} `json:"start"`
+	Hops []struct {
+		Addr     string  `json:"addr"`
+		ProbeTTL int     `json:"probe_ttl"`
+		RTT      float64 `json:"rtt"`
+		ICMPType int     `json:"icmp_type"`
+		ICMPCode int     `json:"icmp_code"`
+	} `json:"hops"`
+}
+
+// parseScamperJSON reads the one-object-per-line output of
+// sc_warts2json and converts every trace object, skipping cycle and
+// list records.
+func parseScamperJSON(data []byte) ([]*model.TraceRun, error) {
+	var runs []*model.TraceRun
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var t scamperTrace
+		if err := dec.Decode(&t); err != nil {
+			return nil, fmt.Errorf("failed to parse scamper JSON: %w", err)
+		}
+		if t.Type != "trace" {
+			continue
+		}
+		runs = append(runs, convertScamperTrace(&t))
+	}
+
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no trace objects in scamper JSON")
+	}
+	return runs, nil
+}
+
+func convertScamperTrace(t *scamperTrace) *model.TraceRun {
+	run := &model.TraceRun{Target: t.Dst, IP: t.Dst, Protocol: scamperProtocol(t.Method)}
+	if t.Start.Sec > 0 {
+		run.Timestamp = time.Unix(t.Start.Sec, t.Start.Usec*1000)
+	}
+	v6 := net.ParseIP(t.Dst) != nil && net.ParseIP(t.Dst).To4() == nil
+
+	byTTL := make(map[int][]model.TraceProbe)
+	maxTTL := 0
+	for _, h := range t.Hops {
+		probe := model.TraceProbe{IP: h.Addr, RTTMs: roundMs(h.RTT)}
+		if (!v6 && h.ICMPType == 3) || (v6 && h.ICMPType == 1) {
+			probe.Annotation = unreachableAnnotation(v6, h.ICMPCode)
+		}
+		byTTL[h.ProbeTTL] = append(byTTL[h.ProbeTTL], probe)
+		maxTTL = max(maxTTL, h.ProbeTTL)
+	}
+
+	for ttl := max(t.FirstHop, 1); ttl <= maxTTL; ttl++ {
+		hop := model.TraceHop{Hop: ttl, Probes: byTTL[ttl]}
+		if len(hop.Probes) == 0 {
+			// scamper does not record unanswered attempts
+			for i := 0; i < max(t.Attempts, 1); i++ {
+				hop.Probes = append(hop.Probes, model.TraceProbe{Timeout: true})
+			}
+		}
+		summarizeTraceHop(&hop)
+		run.Hops = append(run.Hops, hop)
+	}
+	return run
+}
+
+// scamperProtocol maps a scamper trace method such as "udp-paris" or
+// "icmp-echo-paris" to a probe protocol
+func scamperProtocol(method string) string {
+	switch {
+	case strings.HasPrefix(method, "icmp"):
+		return TraceProtoICMP
+	case strings.HasPrefix(method, "tcp"):
+		return TraceProtoTCP
+	case strings.HasPrefix(method, "udp"):
+		return TraceProtoUDP
+	}
+	return ""
+}
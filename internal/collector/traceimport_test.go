@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+const mtrJSONSample = `{
+  "report": {
+    "mtr": {"src": "host", "dst": "example.com", "tos": 0, "tests": 10, "psize": "64"},
+    "hubs": [
+      {"count": 1, "host": "192.168.1.1", "ASN": "AS???", "Loss%": 0.0, "Snt": 10, "Last": 0.5, "Avg": 0.62, "Best": 0.4, "Wrst": 1.0, "StDev": 0.1},
+      {"count": 2, "host": "???", "ASN": "AS???", "Loss%": 100.0, "Snt": 10, "Last": 0.0, "Avg": 0.0, "Best": 0.0, "Wrst": 0.0, "StDev": 0.0},
+      {"count": "3", "host": "core1.example.net (203.0.113.5)", "ASN": "AS64500", "Loss%": 20.0, "Snt": 10, "Last": 12.1, "Avg": 11.94, "Best": 11.0, "Wrst": 13.0, "StDev": 0.5}
+    ]
+  }
+}`
+
+const tracertSample = `
+Tracing route to example.com [93.184.216.34]
+over a maximum of 30 hops:
+
+  1    <1 ms    <1 ms    <1 ms  192.168.1.1
+  2     *        *        *     Request timed out.
+  3    12 ms    11 ms    13 ms  ae-1.r01.example.net [203.0.113.5]
+  4     9 ms     *        *     10.0.0.1 reports: Destination host unreachable.
+
+Trace complete.
+`
+
+const bsdTracerouteSample = `traceroute to example.com (93.184.216.34), 64 hops max, 52 byte packets
+ 1  gateway (192.168.1.1)  1.234 ms  0.981 ms  1.002 ms
+ 2  10.0.0.1 (10.0.0.1)  5.101 ms
+    10.0.0.2 (10.0.0.2)  5.320 ms  5.400 ms
+ 3  * * *
+ 4  93.184.216.34 (93.184.216.34)  20.5 ms  20.1 ms  20.3 ms
+`
+
+const scamperSample = `{"type":"cycle-start", "list_name":"default", "id":1, "hostname":"vp1", "start_time":1700000000}
+{"type":"trace", "version":"0.1", "method":"icmp-echo-paris", "src":"192.0.2.1", "dst":"198.51.100.7", "firsthop":1, "attempts":2, "start":{"sec":1700000001, "usec":500000}, "hop_count":4, "hops":[{"addr":"192.0.2.254", "probe_ttl":1, "probe_id":1, "rtt":0.505, "icmp_type":11, "icmp_code":0}, {"addr":"203.0.113.1", "probe_ttl":2, "probe_id":1, "rtt":4.2, "icmp_type":11, "icmp_code":0}, {"addr":"198.51.100.7", "probe_ttl":4, "probe_id":1, "rtt":9.87, "icmp_type":0, "icmp_code":0}]}
+{"type":"trace", "version":"0.1", "method":"udp-paris", "src":"192.0.2.1", "dst":"198.51.100.8", "firsthop":1, "attempts":1, "start":{"sec":1700000002, "usec":0}, "hops":[{"addr":"192.0.2.254", "probe_ttl":1, "probe_id":1, "rtt":0.6, "icmp_type":11, "icmp_code":0}, {"addr":"203.0.113.9", "probe_ttl":2, "probe_id":1, "rtt":3.1, "icmp_type":3, "icmp_code":1}]}
+{"type":"cycle-stop", "list_name":"default", "id":1, "hostname":"vp1", "stop_time":1700000010}
+`
+
+func TestDetectTraceFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"mtr", mtrJSONSample, TraceFormatMTR},
+		{"tracert", tracertSample, TraceFormatTracert},
+		{"bsd traceroute", bsdTracerouteSample, TraceFormatTraceroute},
+		{"headerless traceroute", " 1  10.0.0.1  1.0 ms\n 2  10.0.0.2  2.0 ms\n", TraceFormatTraceroute},
+		{"scamper", scamperSample, TraceFormatScamper},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectTraceFormat([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DetectTraceFormat() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectTraceFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"", "hello world", `{"foo": 1}`} {
+		if _, err := DetectTraceFormat([]byte(bad)); err == nil {
+			t.Errorf("Expected an error detecting %q", bad)
+		}
+	}
+}
+
+func TestImportMTRJSON(t *testing.T) {
+	runs, err := ImportTraces([]byte(mtrJSONSample), "")
+	if err != nil {
+		t.Fatalf("ImportTraces() error = %v", err)
+	}
+	run := runs[0]
+
+	if run.Target != "example.com" {
+		t.Errorf("Expected target example.com, got %q", run.Target)
+	}
+	if len(run.Hops) != 3 {
+		t.Fatalf("Expected 3 hops, got %d", len(run.Hops))
+	}
+
+	first := run.Hops[0]
+	if first.IP != "192.168.1.1" || first.RTT != "0.6ms" || first.Sent != 10 || first.Received != 10 || first.ASN != "" {
+		t.Errorf("unexpected first hop: %+v", first)
+	}
+	if !run.Hops[1].Timeout || run.Hops[1].LossPct != 100 {
+		t.Errorf("Expected hop 2 to time out, got %+v", run.Hops[1])
+	}
+
+	third := run.Hops[2]
+	if third.Hop != 3 || third.IP != "203.0.113.5" || third.Host != "core1.example.net" {
+		t.Errorf("unexpected third hop: %+v", third)
+	}
+	if third.Received != 8 || third.LossPct != 20 || third.ASN != "64500" {
+		t.Errorf("unexpected third hop stats: %+v", third)
+	}
+}
+
+func TestImportTracert(t *testing.T) {
+	runs, err := ImportTraces([]byte(tracertSample), TraceFormatTracert)
+	if err != nil {
+		t.Fatalf("ImportTraces() error = %v", err)
+	}
+	run := runs[0]
+
+	if run.Target != "example.com" || run.IP != "93.184.216.34" || run.Protocol != TraceProtoICMP {
+		t.Errorf("unexpected run header: %+v", run)
+	}
+	if len(run.Hops) != 4 {
+		t.Fatalf("Expected 4 hops, got %d", len(run.Hops))
+	}
+
+	if h := run.Hops[0]; h.IP != "192.168.1.1" || h.Received != 3 || h.Probes[0].RTTMs != 1 {
+		t.Errorf("unexpected hop 1: %+v", h)
+	}
+	if h := run.Hops[1]; !h.Timeout || h.Sent != 3 {
+		t.Errorf("Expected hop 2 to time out with 3 probes, got %+v", h)
+	}
+	if h := run.Hops[2]; h.IP != "203.0.113.5" || h.Host != "ae-1.r01.example.net" || h.RTT != "12.0ms" {
+		t.Errorf("unexpected hop 3: %+v", h)
+	}
+	h := run.Hops[3]
+	if h.IP != "10.0.0.1" || h.Received != 1 || h.Sent != 3 {
+		t.Errorf("unexpected hop 4: %+v", h)
+	}
+	if h.Probes[0].Annotation != "!H" {
+		t.Errorf("Expected !H on the unreachable report, got %q", h.Probes[0].Annotation)
+	}
+}
+
+func TestImportBSDTraceroute(t *testing.T) {
+	runs, err := ImportTraces([]byte(bsdTracerouteSample), "")
+	if err != nil {
+		t.Fatalf("ImportTraces() error = %v", err)
+	}
+	run := runs[0]
+
+	if run.Target != "example.com" || run.IP != "93.184.216.34" {
+		t.Errorf("unexpected run header: %+v", run)
+	}
+	if len(run.Hops) != 4 {
+		t.Fatalf("Expected 4 hops, got %d", len(run.Hops))
+	}
+
+	// The continuation line belongs to hop 2
+	h := run.Hops[1]
+	if h.Sent != 3 || h.Received != 3 || len(h.Responders) != 2 {
+		t.Errorf("Expected 2 responders over 3 probes at hop 2, got %+v", h)
+	}
+	if h.IP != "10.0.0.1" {
+		t.Errorf("Expected first responder 10.0.0.1, got %q", h.IP)
+	}
+	if !run.Hops[2].Timeout {
+		t.Errorf("Expected hop 3 to time out, got %+v", run.Hops[2])
+	}
+}
+
+func TestImportScamper(t *testing.T) {
+	runs, err := ImportTraces([]byte(scamperSample), "")
+	if err != nil {
+		t.Fatalf("ImportTraces() error = %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 traces, got %d", len(runs))
+	}
+
+	run := runs[0]
+	if run.Target != "198.51.100.7" || run.Protocol != TraceProtoICMP {
+		t.Errorf("unexpected run header: %+v", run)
+	}
+	if want := time.Unix(1700000001, 500000000); !run.Timestamp.Equal(want) {
+		t.Errorf("Expected timestamp %v, got %v", want, run.Timestamp)
+	}
+	if len(run.Hops) != 4 {
+		t.Fatalf("Expected 4 hops, got %d", len(run.Hops))
+	}
+	// TTL 3 never answered; scamper made two attempts
+	if h := run.Hops[2]; !h.Timeout || h.Sent != 2 {
+		t.Errorf("Expected hop 3 to time out after 2 attempts, got %+v", h)
+	}
+	if h := run.Hops[3]; h.IP != "198.51.100.7" || h.RTT != "9.9ms" {
+		t.Errorf("unexpected last hop: %+v", h)
+	}
+
+	second := runs[1]
+	if second.Protocol != TraceProtoUDP {
+		t.Errorf("Expected udp protocol, got %q", second.Protocol)
+	}
+	if got := second.Hops[1].Probes[0].Annotation; got != "!H" {
+		t.Errorf("Expected !H for host unreachable, got %q", got)
+	}
+}
+
+func TestImportTracesErrors(t *testing.T) {
+	if _, err := ImportTraces([]byte(mtrJSONSample), "pcap"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	if _, err := ImportTraces([]byte(`{"type":"cycle-start"}`), TraceFormatScamper); err == nil {
+		t.Error("Expected an error for scamper output without traces")
+	}
+	if _, err := ImportTraces([]byte("Tracing route to example.com\n\nTrace complete.\n"), ""); err == nil {
+		t.Error("Expected an error for a trace without hops")
+	}
+}
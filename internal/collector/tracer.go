@@ -0,0 +1,708 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Traceroute probe protocols accepted by TraceOptions.Protocol
+const (
+	TraceProtoUDP  = "udp"
+	TraceProtoICMP = "icmp"
+	TraceProtoTCP  = "tcp"
+)
+
+// Traceroute probing modes accepted by TraceOptions.Mode
+const (
+	// TraceModeClassic varies the flow identifier per probe like
+	// the traditional traceroute
+	TraceModeClassic = "classic"
+	// TraceModeParis keeps the flow identifier constant so per-flow
+	// load balancers send every probe down the same path
+	TraceModeParis = "paris"
+	// TraceModeMultipath traces several constant flows to enumerate
+	// the branches of load-balanced paths
+	TraceModeMultipath = "multipath"
+)
+
+const (
+	defaultTraceMaxHops = 30
+	defaultTraceProbes  = 3
+	defaultTraceWait    = 3 * time.Second
+	defaultTraceUDPPort = 33434
+	defaultTraceTCPPort = 80
+	defaultTraceFlows   = 16
+
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+// TraceOptions controls how a traceroute is run. Zero values
+// select the defaults noted on each field.
+type TraceOptions struct {
+	Protocol     string        // udp (default), icmp or tcp
+	MaxHops      int           // default 30
+	ProbesPerHop int           // default 3
+	Wait         time.Duration // per-probe reply timeout, default 3s
+	Port         int           // UDP base port (33434) or TCP port (80)
+	Mode         string        // classic (default), paris or multipath
+	Flows        int           // flows traced in multipath mode, default 16
+
+	// NoSystemFallback stops the traceroute binary from being used
+	// when the native tracer cannot open its raw ICMP socket.
+	NoSystemFallback bool
+}
+
+func (o TraceOptions) withDefaults() TraceOptions {
+	if o.Protocol == "" {
+		o.Protocol = TraceProtoUDP
+	}
+	if o.MaxHops <= 0 {
+		o.MaxHops = defaultTraceMaxHops
+	}
+	if o.ProbesPerHop <= 0 {
+		o.ProbesPerHop = defaultTraceProbes
+	}
+	if o.Wait <= 0 {
+		o.Wait = defaultTraceWait
+	}
+	if o.Mode == "" {
+		o.Mode = TraceModeClassic
+	}
+	if o.Flows <= 0 {
+		o.Flows = defaultTraceFlows
+	}
+	if o.Port <= 0 {
+		o.Port = defaultTraceUDPPort
+		if o.Protocol == TraceProtoTCP {
+			o.Port = defaultTraceTCPPort
+		}
+	}
+	return o
+}
+
+// traceReply is what came back for a single probe
+type traceReply struct {
+	From    net.IP
+	RTT     time.Duration
+	Reached bool          // the destination itself answered
+	Type    icmp.Type     // ICMP type of the reply, nil for a TCP answer
+	Code    int           // ICMP code of the reply
+	Message *icmp.Message // full ICMP reply, nil for a TCP answer
+
+	received time.Time
+}
+
+// traceProbeResult is one probe's outcome; Reply is nil on timeout
+type traceProbeResult struct {
+	TTL   int
+	Flow  int // flow index in paris and multipath modes
+	Reply *traceReply
+}
+
+// nativeTracer sends TTL-limited probes and matches the ICMP replies
+// read from a raw socket back to the probe that triggered them.
+type nativeTracer struct {
+	dst  net.IP
+	v6   bool
+	opts TraceOptions
+
+	conn *icmp.PacketConn
+	id   int
+
+	// Paris-mode UDP: one socket (and so one source port) per flow,
+	// and the local address needed for the UDP checksum
+	src       net.IP
+	flowConns map[int]net.PacketConn
+
+	mu      sync.Mutex
+	seq     int
+	pending map[string]chan *traceReply
+	sendMu  sync.Mutex // serialises TTL changes on shared sockets
+}
+
+func newNativeTracer(dst net.IP, opts TraceOptions) (*nativeTracer, error) {
+	v6 := dst.To4() == nil
+
+	if opts.Mode != TraceModeClassic && opts.Protocol == TraceProtoTCP {
+		return nil, fmt.Errorf("%s mode supports udp and icmp probes only", opts.Mode)
+	}
+
+	network, address := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		network, address = "ip6:ipv6-icmp", "::"
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("raw ICMP socket: %w", err)
+	}
+
+	t := &nativeTracer{
+		dst:       dst,
+		v6:        v6,
+		opts:      opts,
+		conn:      conn,
+		id:        os.Getpid() & 0xffff,
+		flowConns: make(map[int]net.PacketConn),
+		pending:   make(map[string]chan *traceReply),
+	}
+
+	if t.paris() && opts.Protocol == TraceProtoUDP {
+		if t.src, err = localAddrFor(dst); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func (t *nativeTracer) Close() error {
+	t.mu.Lock()
+	for _, c := range t.flowConns {
+		c.Close()
+	}
+	t.mu.Unlock()
+	return t.conn.Close()
+}
+
+// paris reports whether probes keep a constant flow identifier
+func (t *nativeTracer) paris() bool {
+	return t.opts.Mode == TraceModeParis || t.opts.Mode == TraceModeMultipath
+}
+
+// flows is the number of distinct flows traced
+func (t *nativeTracer) flows() int {
+	if t.opts.Mode == TraceModeMultipath {
+		return t.opts.Flows
+	}
+	return 1
+}
+
+// probesPerFlow is the number of probes sent per TTL and flow.
+// Multipath mode learns about a hop from its many flows instead.
+func (t *nativeTracer) probesPerFlow() int {
+	if t.opts.Mode == TraceModeMultipath {
+		return 1
+	}
+	return t.opts.ProbesPerHop
+}
+
+// Run probes every TTL (and every flow) concurrently and returns results
+// ordered by TTL. Each flow is cut off after the first TTL at which the
+// destination answered or a router reported it unreachable, and the
+// result is truncated after the longest such flow.
+func (t *nativeTracer) Run(ctx context.Context) ([][]traceProbeResult, error) {
+	go t.receive()
+
+	flows, perFlow := t.flows(), t.probesPerFlow()
+	hops := make([][]traceProbeResult, t.opts.MaxHops)
+	for i := range hops {
+		hops[i] = make([]traceProbeResult, 0, flows*perFlow)
+	}
+
+	// Each goroutine fills its own slots, so no locking is needed
+	slots := make([][]traceProbeResult, t.opts.MaxHops*flows)
+	var wg sync.WaitGroup
+launch:
+	for ttl := 1; ttl <= t.opts.MaxHops; ttl++ {
+		for flow := 0; flow < flows; flow++ {
+			wg.Add(1)
+			go func(ttl, flow int) {
+				defer wg.Done()
+				slot := &slots[(ttl-1)*flows+flow]
+				for i := 0; i < perFlow; i++ {
+					if ctx.Err() != nil {
+						return
+					}
+					*slot = append(*slot, traceProbeResult{TTL: ttl, Flow: flow, Reply: t.probe(ctx, ttl, flow)})
+				}
+			}(ttl, flow)
+			// Stagger sends slightly so routers rate-limiting ICMP keep up
+			if !sleepContext(ctx, 5*time.Millisecond) {
+				break launch
+			}
+		}
+	}
+	wg.Wait()
+
+	for i, slot := range slots {
+		hops[i/flows] = append(hops[i/flows], slot...)
+	}
+
+	if ctx.Err() != nil && len(hops) > 0 && len(hops[0]) == 0 {
+		return nil, ctx.Err()
+	}
+
+	// Keep hops up to the longest flow that reached its end
+	end := 0
+	for flow := 0; flow < flows; flow++ {
+		for i, probes := range hops {
+			if t.flowEnded(probes, flow) {
+				end = max(end, i+1)
+				break
+			}
+		}
+	}
+	if end > 0 {
+		return hops[:end], nil
+	}
+	return hops, nil
+}
+
+// flowEnded reports whether a flow's probes at one TTL reached the
+// destination or were reported unreachable
+func (t *nativeTracer) flowEnded(probes []traceProbeResult, flow int) bool {
+	for _, p := range probes {
+		if p.Flow == flow && p.Reply != nil && (p.Reply.Reached || t.annotation(p.Reply) != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// annotation returns the classic traceroute flag for an unreachable
+// reply ("!H", "!N", ...), or "" for time exceeded, echo replies and the
+// port unreachable that marks a UDP probe reaching the destination.
+func (t *nativeTracer) annotation(reply *traceReply) string {
+	if reply == nil || reply.Type == nil {
+		return ""
+	}
+	if t.v6 && reply.Type != ipv6.ICMPTypeDestinationUnreachable {
+		return ""
+	}
+	if !t.v6 && reply.Type != ipv4.ICMPTypeDestinationUnreachable {
+		return ""
+	}
+	return unreachableAnnotation(t.v6, reply.Code)
+}
+
+// unreachableAnnotation maps a destination unreachable code to its
+// traceroute flag. Port unreachable maps to "" as it means the probe
+// reached the destination.
+func unreachableAnnotation(v6 bool, code int) string {
+	if v6 {
+		switch code {
+		case 0:
+			return "!N"
+		case 1:
+			return "!X"
+		case 3:
+			return "!H"
+		case 4:
+			return ""
+		}
+		return fmt.Sprintf("!<%d>", code)
+	}
+
+	switch code {
+	case 0, 6:
+		return "!N"
+	case 1, 7:
+		return "!H"
+	case 2:
+		return "!P"
+	case 3:
+		return ""
+	case 4:
+		return "!F"
+	case 5:
+		return "!S"
+	case 9, 10, 13:
+		return "!X"
+	}
+	return fmt.Sprintf("!<%d>", code)
+}
+
+// receive dispatches ICMP replies to waiting probes until the socket closes
+func (t *nativeTracer) receive() {
+	proto := protocolICMP
+	if t.v6 {
+		proto = protocolICMPv6
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		received := time.Now()
+
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+
+		key := t.matchReply(msg)
+		if key == "" {
+			continue
+		}
+
+		var from net.IP
+		if addr, ok := peer.(*net.IPAddr); ok {
+			from = addr.IP
+		}
+		// Echo replies and port unreachables come from the destination itself
+		reached := from != nil && from.Equal(t.dst)
+
+		reply := &traceReply{
+			From:     from,
+			Reached:  reached,
+			Type:     msg.Type,
+			Code:     msg.Code,
+			Message:  msg,
+			received: received,
+		}
+		if !t.deliver(key, reply) && reached && strings.HasPrefix(key, "udpsum:") {
+			t.deliverParisLocal(key, reply)
+		}
+	}
+}
+
+// matchReply returns the key of the pending probe an ICMP message answers
+func (t *nativeTracer) matchReply(msg *icmp.Message) string {
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+			return ""
+		}
+		if body.ID != t.id {
+			return ""
+		}
+		return fmt.Sprintf("icmp:%d", body.Seq)
+	case *icmp.TimeExceeded:
+		return t.quotedProbeKey(body.Data)
+	case *icmp.DstUnreach:
+		return t.quotedProbeKey(body.Data)
+	}
+	return ""
+}
+
+// quotedProbeKey rebuilds the probe key from the original datagram that
+// an ICMP error quotes back to us.
+func (t *nativeTracer) quotedProbeKey(data []byte) string {
+	var proto int
+	var dst net.IP
+	var payload []byte
+
+	if t.v6 {
+		if len(data) < 40 {
+			return ""
+		}
+		proto = int(data[6])
+		dst = net.IP(data[24:40])
+		payload = data[40:]
+	} else {
+		if len(data) < 20 {
+			return ""
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < 20 || len(data) < ihl {
+			return ""
+		}
+		proto = int(data[9])
+		dst = net.IP(data[16:20])
+		payload = data[ihl:]
+	}
+
+	if !dst.Equal(t.dst) || len(payload) < 8 {
+		return ""
+	}
+
+	switch proto {
+	case protocolUDP:
+		if t.paris() {
+			// Paris probes share a source port per flow; the
+			// checksum tells them apart
+			return parisUDPKey(int(binary.BigEndian.Uint16(payload[0:2])), binary.BigEndian.Uint16(payload[6:8]))
+		}
+		return fmt.Sprintf("udp:%d", binary.BigEndian.Uint16(payload[0:2]))
+	case protocolTCP:
+		return fmt.Sprintf("tcp:%d", binary.BigEndian.Uint16(payload[0:2]))
+	case protocolICMP, protocolICMPv6:
+		if int(binary.BigEndian.Uint16(payload[4:6])) != t.id {
+			return ""
+		}
+		return fmt.Sprintf("icmp:%d", binary.BigEndian.Uint16(payload[6:8]))
+	}
+	return ""
+}
+
+func (t *nativeTracer) register(key string) chan *traceReply {
+	ch := make(chan *traceReply, 1)
+	t.mu.Lock()
+	t.pending[key] = ch
+	t.mu.Unlock()
+	return ch
+}
+
+func (t *nativeTracer) unregister(key string) {
+	t.mu.Lock()
+	delete(t.pending, key)
+	t.mu.Unlock()
+}
+
+// deliver hands reply to the probe waiting on key and reports whether
+// one was waiting
+func (t *nativeTracer) deliver(key string, reply *traceReply) bool {
+	t.mu.Lock()
+	ch, ok := t.pending[key]
+	if ok {
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+
+	if ok {
+		ch <- reply
+	}
+	return ok
+}
+
+func (t *nativeTracer) nextSeq() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seq++
+	return t.seq & 0xffff
+}
+
+// probe sends one probe with the given TTL and waits for its reply
+func (t *nativeTracer) probe(ctx context.Context, ttl, flow int) *traceReply {
+	switch t.opts.Protocol {
+	case TraceProtoICMP:
+		return t.probeICMP(ctx, ttl, flow)
+	case TraceProtoTCP:
+		return t.probeTCP(ctx, ttl)
+	default:
+		if t.paris() {
+			return t.probeParisUDP(ctx, ttl, flow)
+		}
+		return t.probeUDP(ctx, ttl)
+	}
+}
+
+func (t *nativeTracer) probeICMP(ctx context.Context, ttl, flow int) *traceReply {
+	seq := t.nextSeq()
+	key := fmt.Sprintf("icmp:%d", seq)
+
+	data := []byte("netgaze")
+	if t.paris() {
+		data = parisICMPPayload(t.id, seq, flow)
+	}
+
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if t.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: t.id, Seq: seq, Data: data},
+	}
+	packet, err := msg.Marshal(nil)
+	if err != nil {
+		return nil
+	}
+
+	ch := t.register(key)
+	defer t.unregister(key)
+
+	t.sendMu.Lock()
+	if t.v6 {
+		err = t.conn.IPv6PacketConn().SetHopLimit(ttl)
+	} else {
+		err = t.conn.IPv4PacketConn().SetTTL(ttl)
+	}
+	start := time.Now()
+	if err == nil {
+		_, err = t.conn.WriteTo(packet, &net.IPAddr{IP: t.dst})
+	}
+	t.sendMu.Unlock()
+	if err != nil {
+		return nil
+	}
+
+	return t.await(ctx, ch, start, nil)
+}
+
+func (t *nativeTracer) probeUDP(ctx context.Context, ttl int) *traceReply {
+	network := "udp4"
+	if t.v6 {
+		network = "udp6"
+	}
+
+	conn, err := net.ListenPacket(network, ":0")
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	if t.v6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	if err != nil {
+		return nil
+	}
+
+	key := fmt.Sprintf("udp:%d", conn.LocalAddr().(*net.UDPAddr).Port)
+	ch := t.register(key)
+	defer t.unregister(key)
+
+	// Classic traceroute bumps the destination port for every probe
+	dst := &net.UDPAddr{IP: t.dst, Port: t.opts.Port + ttl - 1}
+	start := time.Now()
+	if _, err := conn.WriteTo([]byte("netgaze"), dst); err != nil {
+		return nil
+	}
+
+	return t.await(ctx, ch, start, nil)
+}
+
+func (t *nativeTracer) probeTCP(ctx context.Context, ttl int) *traceReply {
+	network := "tcp4"
+	if t.v6 {
+		network = "tcp6"
+	}
+
+	// Bind the source port and register it before the SYN goes out, so
+	// the quoted TCP header in an ICMP error can be matched to this probe
+	type boundProbe struct {
+		key string
+		ch  chan *traceReply
+	}
+	bound := make(chan boundProbe, 1)
+	setTTL := ttlControl(ttl, t.v6)
+	dialer := &net.Dialer{
+		Timeout: t.opts.Wait,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if err := setTTL(network, address, c); err != nil {
+				return err
+			}
+			port, err := bindEphemeral(c, t.v6)
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprintf("tcp:%d", port)
+			bound <- boundProbe{key: key, ch: t.register(key)}
+			return nil
+		},
+	}
+
+	dialed := make(chan *traceReply, 1)
+	start := time.Now()
+	go func() {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(t.dst.String(), strconv.Itoa(t.opts.Port)))
+		rtt := time.Since(start)
+		// Nothing was registered if the dial failed before binding
+		select {
+		case bound <- boundProbe{}:
+		default:
+		}
+		if err == nil {
+			conn.Close()
+		}
+		// A SYN-ACK or RST means the SYN reached the destination
+		if err == nil || errors.Is(err, syscall.ECONNREFUSED) {
+			dialed <- &traceReply{From: t.dst, RTT: rtt, Reached: true}
+			return
+		}
+		dialed <- nil
+	}()
+
+	probe := <-bound
+	if probe.ch == nil {
+		return <-dialed
+	}
+	defer t.unregister(probe.key)
+	return t.await(ctx, probe.ch, start, dialed)
+}
+
+// await waits for an ICMP reply on ch or a direct TCP answer on direct
+func (t *nativeTracer) await(ctx context.Context, ch chan *traceReply, start time.Time, direct chan *traceReply) *traceReply {
+	timer := time.NewTimer(t.opts.Wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case reply := <-ch:
+			reply.RTT = reply.received.Sub(start)
+			return reply
+		case reply := <-direct:
+			if reply != nil {
+				return reply
+			}
+			// The dial failed without an answer; an ICMP error may still arrive
+			direct = nil
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// nativeTraceroute runs the native tracer and converts its results into
+// hops, plus a path graph in multipath mode.
+func nativeTraceroute(ctx context.Context, dst net.IP, opts TraceOptions) ([]model.TraceHop, *model.TraceGraph, error) {
+	tracer, err := newNativeTracer(dst, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tracer.Close()
+
+	results, err := tracer.Run(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var graph *model.TraceGraph
+	if opts.Mode == TraceModeMultipath {
+		graph = buildTraceGraph(results, tracer.flows())
+	}
+	return buildTraceHops(tracer, results), graph, nil
+}
+
+// buildTraceHops records every probe's responder and RTT for each TTL
+func buildTraceHops(t *nativeTracer, results [][]traceProbeResult) []model.TraceHop {
+	hops := make([]model.TraceHop, 0, len(results))
+	for i, probes := range results {
+		hop := model.TraceHop{Hop: i + 1}
+		for _, p := range probes {
+			if p.Reply == nil {
+				hop.Probes = append(hop.Probes, model.TraceProbe{Timeout: true})
+				continue
+			}
+			probe := model.TraceProbe{
+				IP:         p.Reply.From.String(),
+				RTTMs:      durationMs(p.Reply.RTT),
+				Annotation: t.annotation(p.Reply),
+			}
+			probe.MPLS, probe.Interfaces = icmpExtensions(p.Reply.Message)
+			if q := quotedTTL(p.Reply.Message, t.v6); q > 1 {
+				probe.QuotedTTL = q
+			}
+			hop.Probes = append(hop.Probes, probe)
+		}
+		summarizeTraceHop(&hop)
+		hops = append(hops, hop)
+	}
+	return hops
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package collector
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ttlControl is not supported here; TCP probes fail and the
+// traceroute falls back to the other probe types or the system binary.
+func ttlControl(ttl int, v6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return errors.New("TTL-limited TCP probes are not supported on this platform")
+	}
+}
+
+// bindEphemeral is not supported here either
+func bindEphemeral(c syscall.RawConn, v6 bool) (int, error) {
+	return 0, errors.New("binding TCP probe sockets is not supported on this platform")
+}
@@ -0,0 +1,261 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestTraceOptionsDefaults(t *testing.T) {
+	opts := TraceOptions{}.withDefaults()
+	if opts.Protocol != TraceProtoUDP || opts.MaxHops != 30 || opts.ProbesPerHop != 3 ||
+		opts.Wait != 3*time.Second || opts.Port != 33434 {
+		t.Errorf("unexpected defaults: %+v", opts)
+	}
+
+	tcp := TraceOptions{Protocol: TraceProtoTCP}.withDefaults()
+	if tcp.Port != 80 {
+		t.Errorf("Expected TCP default port 80, got %d", tcp.Port)
+	}
+
+	custom := TraceOptions{Protocol: TraceProtoICMP, MaxHops: 5, Port: 9}.withDefaults()
+	if custom.MaxHops != 5 || custom.Port != 9 {
+		t.Errorf("custom values overwritten: %+v", custom)
+	}
+}
+
+// quotedIPv4 builds the IPv4 header plus first 8 bytes an ICMP error quotes
+func quotedIPv4(proto byte, dst net.IP, first8 []byte) []byte {
+	b := make([]byte, 20, 28)
+	b[0] = 0x45
+	b[9] = proto
+	copy(b[16:20], dst.To4())
+	return append(b, first8...)
+}
+
+func TestQuotedProbeKey(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	tracer := &nativeTracer{dst: dst, id: 0x1234}
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], 40000)
+	binary.BigEndian.PutUint16(udp[2:4], 33434)
+
+	echo := make([]byte, 8)
+	echo[0] = 8
+	binary.BigEndian.PutUint16(echo[4:6], 0x1234)
+	binary.BigEndian.PutUint16(echo[6:8], 7)
+
+	foreignEcho := append([]byte(nil), echo...)
+	binary.BigEndian.PutUint16(foreignEcho[4:6], 0x9999)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"udp", quotedIPv4(protocolUDP, dst, udp), "udp:40000"},
+		{"tcp", quotedIPv4(protocolTCP, dst, udp), "tcp:40000"},
+		{"icmp", quotedIPv4(protocolICMP, dst, echo), "icmp:7"},
+		{"other process", quotedIPv4(protocolICMP, dst, foreignEcho), ""},
+		{"other destination", quotedIPv4(protocolUDP, net.ParseIP("192.0.2.2"), udp), ""},
+		{"truncated", quotedIPv4(protocolUDP, dst, udp)[:24], ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tracer.quotedProbeKey(tt.data); got != tt.want {
+				t.Errorf("quotedProbeKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchReply(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	tracer := &nativeTracer{dst: dst, id: 0x1234}
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], 40001)
+
+	tests := []struct {
+		name string
+		msg  *icmp.Message
+		want string
+	}{
+		{
+			name: "echo reply",
+			msg:  &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 0x1234, Seq: 3}},
+			want: "icmp:3",
+		},
+		{
+			name: "echo reply for another process",
+			msg:  &icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 1, Seq: 3}},
+			want: "",
+		},
+		{
+			name: "echo request",
+			msg:  &icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: 0x1234, Seq: 3}},
+			want: "",
+		},
+		{
+			name: "time exceeded",
+			msg:  &icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quotedIPv4(protocolUDP, dst, udp)}},
+			want: "udp:40001",
+		},
+		{
+			name: "port unreachable",
+			msg:  &icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: quotedIPv4(protocolUDP, dst, udp)}},
+			want: "udp:40001",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tracer.matchReply(tt.msg); got != tt.want {
+				t.Errorf("matchReply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTraceHops(t *testing.T) {
+	tracer := &nativeTracer{dst: net.ParseIP("192.0.2.1")}
+	unreach := func(from string, code int) *traceReply {
+		return &traceReply{From: net.ParseIP(from), RTT: 20 * time.Millisecond, Type: ipv4.ICMPTypeDestinationUnreachable, Code: code}
+	}
+
+	results := [][]traceProbeResult{
+		{{TTL: 1, Reply: &traceReply{From: net.ParseIP("10.0.0.1"), RTT: 1500 * time.Microsecond}}},
+		{{TTL: 2}, {TTL: 2}},
+		{
+			{TTL: 3, Reply: &traceReply{From: net.ParseIP("10.0.1.1"), RTT: 8 * time.Millisecond}},
+			{TTL: 3},
+			{TTL: 3, Reply: &traceReply{From: net.ParseIP("10.0.1.2"), RTT: 9 * time.Millisecond}},
+		},
+		{{TTL: 4, Reply: unreach("10.0.2.1", 1)}, {TTL: 4, Reply: unreach("192.0.2.1", 3)}},
+	}
+
+	hops := buildTraceHops(tracer, results)
+	if len(hops) != 4 {
+		t.Fatalf("Expected 4 hops, got %d", len(hops))
+	}
+	if hops[0].Hop != 1 || hops[0].IP != "10.0.0.1" || hops[0].RTT != "1.5ms" || hops[0].Timeout {
+		t.Errorf("unexpected hop 1: %+v", hops[0])
+	}
+	if !hops[1].Timeout || hops[1].RTT != "*" || hops[1].IP != "" || hops[1].LossPct != 100 {
+		t.Errorf("Expected hop 2 to time out: %+v", hops[1])
+	}
+
+	ecmp := hops[2]
+	if len(ecmp.Probes) != 3 || ecmp.Sent != 3 || ecmp.Received != 2 {
+		t.Errorf("Expected 3 probes with 2 replies at hop 3: %+v", ecmp)
+	}
+	if len(ecmp.Responders) != 2 || ecmp.Responders[0] != "10.0.1.1" || ecmp.Responders[1] != "10.0.1.2" {
+		t.Errorf("Expected both ECMP responders at hop 3, got %v", ecmp.Responders)
+	}
+	if ecmp.LossPct != 33.33 {
+		t.Errorf("Expected 33.33%% loss at hop 3, got %v", ecmp.LossPct)
+	}
+	if ecmp.Probes[2].RTTMs != 9 {
+		t.Errorf("Expected third probe RTT 9ms, got %v", ecmp.Probes[2].RTTMs)
+	}
+
+	if hops[3].Probes[0].Annotation != "!H" || hops[3].Probes[1].Annotation != "" {
+		t.Errorf("unexpected annotations at hop 4: %+v", hops[3].Probes)
+	}
+}
+
+func TestTraceAnnotation(t *testing.T) {
+	tracer := &nativeTracer{}
+	tests := []struct {
+		typ  icmp.Type
+		code int
+		want string
+	}{
+		{ipv4.ICMPTypeTimeExceeded, 0, ""},
+		{ipv4.ICMPTypeDestinationUnreachable, 0, "!N"},
+		{ipv4.ICMPTypeDestinationUnreachable, 1, "!H"},
+		{ipv4.ICMPTypeDestinationUnreachable, 3, ""},
+		{ipv4.ICMPTypeDestinationUnreachable, 13, "!X"},
+		{ipv4.ICMPTypeDestinationUnreachable, 15, "!<15>"},
+	}
+
+	for _, tt := range tests {
+		got := tracer.annotation(&traceReply{Type: tt.typ, Code: tt.code})
+		if got != tt.want {
+			t.Errorf("annotation(%v, %d) = %q, want %q", tt.typ, tt.code, got, tt.want)
+		}
+	}
+
+	v6 := &nativeTracer{v6: true}
+	if got := v6.annotation(&traceReply{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 3}); got != "!H" {
+		t.Errorf("Expected !H for ICMPv6 address unreachable, got %q", got)
+	}
+	if got := tracer.annotation(nil); got != "" {
+		t.Errorf("Expected no annotation for a timeout, got %q", got)
+	}
+}
+
+func TestNativeTracerouteLoopback(t *testing.T) {
+	for _, proto := range []string{TraceProtoUDP, TraceProtoICMP, TraceProtoTCP} {
+		t.Run(proto, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			hops, _, err := nativeTraceroute(ctx, net.ParseIP("127.0.0.1"), TraceOptions{
+				Protocol:     proto,
+				MaxHops:      3,
+				ProbesPerHop: 1,
+				Wait:         time.Second,
+			}.withDefaults())
+			if err != nil {
+				// Raw sockets need root or CAP_NET_RAW
+				t.Skipf("native traceroute unavailable: %v", err)
+			}
+
+			if len(hops) != 1 {
+				t.Fatalf("Expected loopback to be reached at hop 1, got %d hops: %+v", len(hops), hops)
+			}
+			if hops[0].IP != "127.0.0.1" || hops[0].Timeout {
+				t.Errorf("unexpected loopback hop: %+v", hops[0])
+			}
+		})
+	}
+}
+
+func TestBindEphemeral(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer ln.Close()
+
+	// The port seen before connect is the one the connection uses
+	var bound int
+	dialer := &net.Dialer{
+		Timeout: time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			bound, err = bindEphemeral(c, false)
+			return err
+		},
+	}
+	conn, err := dialer.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Skipf("bindEphemeral unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	if port := conn.LocalAddr().(*net.TCPAddr).Port; bound == 0 || bound != port {
+		t.Errorf("bindEphemeral() = %d, connection uses port %d", bound, port)
+	}
+}
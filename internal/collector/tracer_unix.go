@@ -0,0 +1,57 @@
+//go:build unix
+
+package collector
+
+import (
+	"syscall"
+)
+
+// ttlControl sets the outgoing TTL (or IPv6 hop limit) on a socket
+// before it connects, so the TCP SYN itself is TTL-limited.
+func ttlControl(ttl int, v6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			if v6 {
+				serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+			} else {
+				serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}
+}
+
+// bindEphemeral binds a socket to a free local port before it connects
+// and returns the port, so a probe can be registered under it with no
+// gap in which another socket could take it.
+func bindEphemeral(c syscall.RawConn, v6 bool) (int, error) {
+	var port int
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+		if v6 {
+			sa = &syscall.SockaddrInet6{}
+		}
+		if serr = syscall.Bind(int(fd), sa); serr != nil {
+			return
+		}
+		var local syscall.Sockaddr
+		if local, serr = syscall.Getsockname(int(fd)); serr != nil {
+			return
+		}
+		switch a := local.(type) {
+		case *syscall.SockaddrInet4:
+			port = a.Port
+		case *syscall.SockaddrInet6:
+			port = a.Port
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return port, serr
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,7 +14,11 @@ import (
 )
 
 func collectTraceroute(ctx context.Context, target string, report *model.Report) error {
-	hops, err := Traceroute(ctx, target, 20*time.Second)
+	return collectTracerouteWithOptions(ctx, target, TraceOptions{}, report)
+}
+
+func collectTracerouteWithOptions(ctx context.Context, target string, opts TraceOptions, report *model.Report) error {
+	hops, graph, err := TracePath(ctx, target, 20*time.Second, opts)
 	if err != nil {
 		report.Errors["traceroute"] = fmt.Sprintf("Traceroute failed: %v", err)
 		report.Trace.Error = err.Error()
@@ -22,6 +27,7 @@ func collectTraceroute(ctx context.Context, target string, report *model.Report)
 	}
 
 	report.Trace.Hops = hops
+	report.Trace.Graph = graph
 	report.Trace.Success = len(hops) > 0
 
 	return nil
@@ -30,27 +36,82 @@ func collectTraceroute(ctx context.Context, target string, report *model.Report)
 // Traceroute runs a traceroute for the given target and returns the hop list.
 // It is used by both the collector and CLI subcommands.
 func Traceroute(ctx context.Context, target string, timeout time.Duration) ([]model.TraceHop, error) {
+	return TracerouteWithOptions(ctx, target, timeout, TraceOptions{})
+}
+
+// TracerouteWithOptions runs the native tracer with the given options. When
+// the native tracer cannot run (usually for lack of raw socket privileges)
+// the system traceroute binary is used unless opts.NoSystemFallback is set.
+func TracerouteWithOptions(ctx context.Context, target string, timeout time.Duration, opts TraceOptions) ([]model.TraceHop, error) {
+	hops, _, err := TracePath(ctx, target, timeout, opts)
+	return hops, err
+}
+
+// TracePath is TracerouteWithOptions that also returns the graph of
+// load-balanced paths in multipath mode (nil otherwise).
+func TracePath(ctx context.Context, target string, timeout time.Duration, opts TraceOptions) ([]model.TraceHop, *model.TraceGraph, error) {
 	if timeout <= 0 {
 		timeout = 10 * time.Second
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
+	traceCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	ip, err := resolveTargetIP(target)
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve target: %w", err)
+		return nil, nil, fmt.Errorf("failed to resolve target: %w", err)
 	}
 
-	return runSystemTraceroute(ctx, ip.String())
+	hops, graph, err := traceOnce(traceCtx, ip, opts.withDefaults())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Lookups get their own budget so a slow trace does not starve them
+	enrichCtx, cancelEnrich := context.WithTimeout(ctx, hopEnrichTimeout)
+	defer cancelEnrich()
+	EnrichTraceHops(enrichCtx, hops)
+
+	return hops, graph, nil
 }
 
-func runSystemTraceroute(ctx context.Context, target string) ([]model.TraceHop, error) {
+// traceOnce runs a single trace to ip without name lookups, falling back
+// to the system traceroute binary unless opts.NoSystemFallback is set.
+// The system binary only does classic traces, so paris and multipath
+// modes never fall back.
+func traceOnce(ctx context.Context, ip net.IP, opts TraceOptions) ([]model.TraceHop, *model.TraceGraph, error) {
+	hops, graph, err := nativeTraceroute(ctx, ip, opts)
+	if err == nil {
+		return hops, graph, nil
+	}
+	if opts.NoSystemFallback || opts.Mode != TraceModeClassic {
+		return nil, nil, fmt.Errorf("native traceroute failed: %w", err)
+	}
+
+	hops, sysErr := runSystemTraceroute(ctx, ip.String(), opts)
+	if sysErr != nil {
+		return nil, nil, fmt.Errorf("native traceroute failed: %v; %w", err, sysErr)
+	}
+	return hops, nil, nil
+}
+
+func runSystemTraceroute(ctx context.Context, target string, opts TraceOptions) ([]model.TraceHop, error) {
 	// Try different traceroute commands based on OS
 	var cmd *exec.Cmd
 
 	// On macOS/Linux, use traceroute with -n flag (no DNS resolution) for speed
-	cmd = exec.CommandContext(ctx, "traceroute", "-n", "-m", "15", "-w", "3", target)
+	args := []string{"-n",
+		"-m", strconv.Itoa(opts.MaxHops),
+		"-q", strconv.Itoa(opts.ProbesPerHop),
+		"-w", strconv.Itoa(int(opts.Wait.Round(time.Second).Seconds())),
+	}
+	switch opts.Protocol {
+	case TraceProtoICMP:
+		args = append(args, "-I")
+	case TraceProtoTCP:
+		args = append(args, "-T", "-p", strconv.Itoa(opts.Port))
+	}
+	cmd = exec.CommandContext(ctx, "traceroute", append(args, target)...)
 
 	// Run command
 	output, err := cmd.Output()
@@ -93,18 +154,24 @@ func resolveTargetIP(target string) (net.IP, error) {
 	return ips[0], nil
 }
 
+// parseTracerouteOutput parses classic traceroute output. Each hop line
+// may name several responders, each followed by its RTTs, e.g.
+//
+//	5  10.0.0.1  5.1 ms  10.0.0.2  5.3 ms !H  *
+//	6  core1 (192.0.2.1)  9.8 ms  9.9 ms  core2 (192.0.2.2)  10.2 ms
+//
+// BSD and macOS put further responders on indented continuation lines
+// without a hop number; their probes are added to the hop above.
 func parseTracerouteOutput(output string) ([]model.TraceHop, error) {
 	var hops []model.TraceHop
 	lines := strings.Split(output, "\n")
 
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "traceroute to") {
+		if line == "" || strings.HasPrefix(line, "traceroute to") || strings.HasPrefix(line, "traceroute6 to") {
 			continue
 		}
 
-		// Parse hop line
-		// Format: "1  gateway (192.168.1.1)  1.234 ms  1.567 ms  1.890 ms"
 		fields := strings.Fields(line)
 		if len(fields) < 2 {
 			continue
@@ -112,42 +179,108 @@ func parseTracerouteOutput(output string) ([]model.TraceHop, error) {
 
 		hopNum, err := strconv.Atoi(fields[0])
 		if err != nil {
+			if len(hops) > 0 {
+				last := &hops[len(hops)-1]
+				if probes := parseTraceProbes(fields); len(probes) > 0 {
+					last.Probes = append(last.Probes, probes...)
+					summarizeTraceHop(last)
+				}
+			}
 			continue
 		}
 
 		traceHop := model.TraceHop{
-			Hop: hopNum,
+			Hop:    hopNum,
+			Probes: parseTraceProbes(fields[1:]),
 		}
+		summarizeTraceHop(&traceHop)
+		hops = append(hops, traceHop)
+	}
+
+	return hops, nil
+}
+
+// parseTraceProbes turns the fields after the hop number into probes.
+// An address applies to every RTT that follows it until the next address.
+func parseTraceProbes(fields []string) []model.TraceProbe {
+	var probes []model.TraceProbe
+	var responder string
 
-		// Extract IP address and RTT
-		for j := 1; j < len(fields); j++ {
-			field := fields[j]
+	for _, field := range fields {
+		switch {
+		case field == "*":
+			probes = append(probes, model.TraceProbe{Timeout: true})
 
-			// Check if field looks like an IP address
-			if net.ParseIP(field) != nil {
-				traceHop.IP = field
-				continue
+		case strings.HasPrefix(field, "<MPLS:"):
+			// Label stack printed by traceroute -e for the probe before it
+			if labels, err := parseMPLSField(field); err == nil && len(probes) > 0 {
+				probes[len(probes)-1].MPLS = labels
 			}
 
-			// Check if field looks like RTT (ends with "ms")
-			if strings.HasSuffix(field, "ms") {
-				rttStr := strings.TrimSuffix(field, "ms")
-				if rtt, err := strconv.ParseFloat(rttStr, 64); err == nil {
-					traceHop.RTT = fmt.Sprintf("%.1fms", rtt)
-					break // Use first RTT value
-				}
+		case strings.HasPrefix(field, "!"):
+			// Annotation for the probe just before it
+			if len(probes) > 0 {
+				probes[len(probes)-1].Annotation = field
 			}
-		}
 
-		// Try to resolve hostname if we have IP
-		if traceHop.IP != "" && traceHop.Host == "" {
-			if names, err := net.LookupAddr(traceHop.IP); err == nil && len(names) > 0 {
-				traceHop.Host = names[0]
+		case strings.HasPrefix(field, "(") && strings.HasSuffix(field, ")"):
+			// "name (ip)"; names are looked up again later
+			if ip := net.ParseIP(strings.Trim(field, "()")); ip != nil {
+				responder = ip.String()
+			}
+
+		case net.ParseIP(field) != nil:
+			responder = net.ParseIP(field).String()
+
+		default:
+			// "1.234 ms" or "1.234ms"; bare names and "ms" are skipped
+			if rtt, err := strconv.ParseFloat(strings.TrimSuffix(field, "ms"), 64); err == nil {
+				probes = append(probes, model.TraceProbe{IP: responder, RTTMs: roundMs(rtt)})
 			}
 		}
+	}
 
-		hops = append(hops, traceHop)
+	return probes
+}
+
+// summarizeTraceHop fills in the first-responder fields (ICMP extensions
+// included), the responder set and the loss figures from hop.Probes.
+func summarizeTraceHop(hop *model.TraceHop) {
+	hop.Sent = len(hop.Probes)
+	hop.Received = 0
+	hop.Responders = nil
+	hop.IP, hop.RTT, hop.Timeout = "", "", false
+	hop.MPLS, hop.Interfaces, hop.QuotedTTL = nil, nil, 0
+
+	for _, p := range hop.Probes {
+		if p.Timeout {
+			continue
+		}
+		hop.Received++
+		if p.IP != "" && !slices.Contains(hop.Responders, p.IP) {
+			hop.Responders = append(hop.Responders, p.IP)
+		}
+		if hop.RTT == "" {
+			hop.IP = p.IP
+			hop.RTT = fmt.Sprintf("%.1fms", p.RTTMs)
+		}
+		// Extensions describe the first responder
+		if p.IP == hop.IP {
+			if hop.MPLS == nil {
+				hop.MPLS = p.MPLS
+			}
+			if hop.Interfaces == nil {
+				hop.Interfaces = p.Interfaces
+			}
+			hop.QuotedTTL = max(hop.QuotedTTL, p.QuotedTTL)
+		}
 	}
 
-	return hops, nil
+	if hop.Received == 0 {
+		hop.RTT = "*"
+		hop.Timeout = true
+	}
+	if hop.Sent > 0 {
+		hop.LossPct = roundMs(float64(hop.Sent-hop.Received) / float64(hop.Sent) * 100)
+	}
 }
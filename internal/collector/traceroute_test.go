@@ -148,6 +148,58 @@ func TestParseTracerouteOutput(t *testing.T) {
 	}
 }
 
+func TestParseTracerouteProbes(t *testing.T) {
+	output := `traceroute to 192.0.2.9 (192.0.2.9), 30 hops max, 60 byte packets
+ 1  192.168.1.1  1.234 ms  1.567 ms  1.890 ms
+ 2  * * *
+ 3  10.0.0.1  5.1 ms 10.0.0.2  5.3 ms *
+ 4  core1 (192.0.2.1)  9.8 ms  core2 (192.0.2.2)  10.2 ms  10.4 ms
+ 5  192.0.2.5  20.1 ms !H  *  20.5 ms !N
+ 6  2001:db8::1  7.25ms`
+
+	hops, err := parseTracerouteOutput(output)
+	if err != nil {
+		t.Fatalf("parseTracerouteOutput() error = %v", err)
+	}
+	if len(hops) != 6 {
+		t.Fatalf("Expected 6 hops, got %d", len(hops))
+	}
+
+	first := hops[0]
+	if first.IP != "192.168.1.1" || first.RTT != "1.2ms" || len(first.Probes) != 3 || first.LossPct != 0 {
+		t.Errorf("unexpected hop 1: %+v", first)
+	}
+	if first.Probes[2].RTTMs != 1.89 {
+		t.Errorf("Expected every RTT to be kept, got %+v", first.Probes)
+	}
+
+	if !hops[1].Timeout || hops[1].Sent != 3 || hops[1].Received != 0 || hops[1].LossPct != 100 {
+		t.Errorf("Expected hop 2 to be all timeouts: %+v", hops[1])
+	}
+
+	ecmp := hops[2]
+	if len(ecmp.Responders) != 2 || ecmp.Responders[1] != "10.0.0.2" {
+		t.Errorf("Expected two responders at hop 3, got %v", ecmp.Responders)
+	}
+	if ecmp.Probes[1].IP != "10.0.0.2" || !ecmp.Probes[2].Timeout || ecmp.LossPct != 33.33 {
+		t.Errorf("unexpected hop 3 probes: %+v", ecmp)
+	}
+
+	named := hops[3]
+	if len(named.Responders) != 2 || named.Responders[0] != "192.0.2.1" || named.Probes[2].IP != "192.0.2.2" {
+		t.Errorf("Expected named responders to be parsed at hop 4: %+v", named)
+	}
+
+	flagged := hops[4]
+	if flagged.Probes[0].Annotation != "!H" || !flagged.Probes[1].Timeout || flagged.Probes[2].Annotation != "!N" {
+		t.Errorf("Expected annotations on hop 5 probes: %+v", flagged.Probes)
+	}
+
+	if hops[5].IP != "2001:db8::1" || hops[5].Probes[0].RTTMs != 7.25 {
+		t.Errorf("unexpected hop 6: %+v", hops[5])
+	}
+}
+
 func TestCollectTraceroute_Timeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
 	defer cancel()
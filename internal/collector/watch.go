@@ -0,0 +1,323 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// WatchOptions controls a continuous ping run
+type WatchOptions struct {
+	Mode     string        // auto, icmp or tcp
+	Port     int           // TCP port; 0 means discover one
+	Interval time.Duration // time between probes
+	Window   int           // number of probes kept for rolling stats
+	Count    int           // stop after this many probes; 0 runs until cancelled
+
+	// OutageAfter is the number of consecutive losses that
+	// open an outage window.
+	OutageAfter int
+}
+
+const (
+	defaultWatchInterval = 1 * time.Second
+	defaultWatchWindow   = 300
+	defaultOutageAfter   = 3
+	maxWatchOutages      = 50
+)
+
+// Watcher pings a single target on an interval and keeps rolling statistics
+type Watcher struct {
+	target string
+	ip     net.IP
+	opts   WatchOptions
+	method string
+	port   int
+	stats  *RollingStats
+}
+
+// NewWatcher resolves the target and picks the probe method. In auto mode
+// ICMP is used when a first echo gets through, otherwise TCP connect.
+func NewWatcher(ctx context.Context, target string, opts WatchOptions) (*Watcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultWatchInterval
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultWatchWindow
+	}
+	if opts.OutageAfter <= 0 {
+		opts.OutageAfter = defaultOutageAfter
+	}
+	if opts.Mode == "" {
+		opts.Mode = PingModeAuto
+	}
+
+	ip, err := resolveTargetIP(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve target: %w", err)
+	}
+
+	w := &Watcher{
+		target: target,
+		ip:     ip,
+		opts:   opts,
+		port:   opts.Port,
+		stats:  NewRollingStats(opts.Window, opts.OutageAfter),
+	}
+
+	switch opts.Mode {
+	case PingModeICMP:
+		w.method = PingMethodICMP
+	case PingModeTCP:
+		w.method = PingMethodTCP
+	case PingModeAuto:
+		w.method = PingMethodICMP
+		if _, ok := w.icmpProbe(ctx); !ok {
+			w.method = PingMethodTCP
+		}
+	default:
+		return nil, fmt.Errorf("unknown watch mode: %s", opts.Mode)
+	}
+
+	if w.method == PingMethodTCP && w.port == 0 {
+		port, err := discoverTCPPort(ctx, ip.String(), tcpPingPorts)
+		if err != nil {
+			if opts.Mode == PingModeTCP {
+				return nil, err
+			}
+			// Nothing answers on TCP either; keep using ICMP
+			w.method = PingMethodICMP
+		} else {
+			w.port = port
+		}
+	}
+
+	return w, nil
+}
+
+// Method returns the probe method in use (icmp or tcp)
+func (w *Watcher) Method() string {
+	return w.method
+}
+
+// Run probes until ctx is cancelled or opts.Count probes were sent.
+// onUpdate is called with a fresh snapshot after every probe.
+func (w *Watcher) Run(ctx context.Context, onUpdate func(model.WatchStats)) error {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for sent := 0; w.opts.Count == 0 || sent < w.opts.Count; sent++ {
+		if sent > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		now := time.Now()
+		rtt, ok := w.probe(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		w.stats.Add(now, rtt, ok)
+
+		if onUpdate != nil {
+			onUpdate(w.Snapshot())
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns the current rolling statistics
+func (w *Watcher) Snapshot() model.WatchStats {
+	snap := w.stats.Snapshot()
+	snap.Target = w.target
+	snap.IP = w.ip.String()
+	snap.Method = w.method
+	if w.method == PingMethodTCP {
+		snap.Port = w.port
+	}
+	return snap
+}
+
+func (w *Watcher) probe(ctx context.Context) (time.Duration, bool) {
+	if w.method == PingMethodTCP {
+		return tcpProbe(ctx, net.JoinHostPort(w.ip.String(), strconv.Itoa(w.port)))
+	}
+	return w.icmpProbe(ctx)
+}
+
+func (w *Watcher) icmpProbe(ctx context.Context) (time.Duration, bool) {
+	pinger, err := probing.NewPinger(w.ip.String())
+	if err != nil {
+		return 0, false
+	}
+	pinger.Count = 1
+	pinger.Timeout = probeTimeout
+	pinger.SetPrivileged(false)
+
+	if err := pinger.RunWithContext(ctx); err != nil {
+		return 0, false
+	}
+
+	stats := pinger.Statistics()
+	if stats.PacketsRecv == 0 || len(stats.Rtts) == 0 {
+		return 0, false
+	}
+	return stats.Rtts[0], true
+}
+
+// RollingStats keeps loss, latency percentiles and outage windows over
+// the most recent probes of a continuous run.
+type RollingStats struct {
+	window      int
+	outageAfter int
+
+	samples []watchSample // ring buffer of the last window probes
+	next    int
+
+	sent     int
+	received int
+	last     watchSample
+
+	lostRun   int
+	lostStart time.Time
+	outages   []model.Outage
+}
+
+type watchSample struct {
+	at  time.Time
+	rtt time.Duration
+	ok  bool
+}
+
+// NewRollingStats creates rolling statistics over window probes. A run of
+// outageAfter consecutive losses is recorded as an outage.
+func NewRollingStats(window, outageAfter int) *RollingStats {
+	if window <= 0 {
+		window = defaultWatchWindow
+	}
+	if outageAfter <= 0 {
+		outageAfter = defaultOutageAfter
+	}
+	return &RollingStats{
+		window:      window,
+		outageAfter: outageAfter,
+		samples:     make([]watchSample, 0, window),
+	}
+}
+
+// Add records the result of one probe
+func (s *RollingStats) Add(at time.Time, rtt time.Duration, ok bool) {
+	sample := watchSample{at: at, rtt: rtt, ok: ok}
+	if len(s.samples) < s.window {
+		s.samples = append(s.samples, sample)
+	} else {
+		s.samples[s.next] = sample
+	}
+	s.next = (s.next + 1) % s.window
+
+	s.sent++
+	s.last = sample
+
+	if ok {
+		s.received++
+		if s.lostRun >= s.outageAfter && len(s.outages) > 0 {
+			s.outages[len(s.outages)-1].End = at
+		}
+		s.lostRun = 0
+		return
+	}
+
+	if s.lostRun == 0 {
+		s.lostStart = at
+	}
+	s.lostRun++
+
+	switch {
+	case s.lostRun == s.outageAfter:
+		s.outages = append(s.outages, model.Outage{Start: s.lostStart, Lost: s.lostRun})
+		if len(s.outages) > maxWatchOutages {
+			s.outages = s.outages[len(s.outages)-maxWatchOutages:]
+		}
+	case s.lostRun > s.outageAfter:
+		s.outages[len(s.outages)-1].Lost = s.lostRun
+	}
+}
+
+// Snapshot summarises the current window
+func (s *RollingStats) Snapshot() model.WatchStats {
+	snap := model.WatchStats{
+		Timestamp: time.Now().UTC(),
+		Sent:      s.sent,
+		Received:  s.received,
+		Window:    len(s.samples),
+		LastOK:    s.last.ok,
+	}
+	if s.last.ok {
+		snap.LastMs = durationMs(s.last.rtt)
+	}
+
+	var rtts []float64
+	for _, sample := range s.samples {
+		if sample.ok {
+			rtts = append(rtts, durationMs(sample.rtt))
+		}
+	}
+
+	if len(s.samples) > 0 {
+		snap.LossPct = float64(len(s.samples)-len(rtts)) / float64(len(s.samples)) * 100
+	}
+
+	if len(rtts) > 0 {
+		sort.Float64s(rtts)
+		var sum float64
+		for _, rtt := range rtts {
+			sum += rtt
+		}
+		snap.MinMs = rtts[0]
+		snap.MaxMs = rtts[len(rtts)-1]
+		snap.AvgMs = roundMs(sum / float64(len(rtts)))
+		snap.P50Ms = percentile(rtts, 50)
+		snap.P90Ms = percentile(rtts, 90)
+		snap.P99Ms = percentile(rtts, 99)
+	}
+
+	if len(s.outages) > 0 {
+		snap.Outages = append([]model.Outage(nil), s.outages...)
+	}
+
+	return snap
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func durationMs(d time.Duration) float64 {
+	return roundMs(float64(d.Nanoseconds()) / 1e6)
+}
+
+func roundMs(ms float64) float64 {
+	return math.Round(ms*100) / 100
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func TestRollingStats(t *testing.T) {
+	stats := NewRollingStats(4, 2)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	results := []struct {
+		rtt time.Duration
+		ok  bool
+	}{
+		{10 * time.Millisecond, true},
+		{0, false},
+		{0, false},
+		{0, false},
+		{20 * time.Millisecond, true},
+		{30 * time.Millisecond, true},
+	}
+	for i, r := range results {
+		stats.Add(start.Add(time.Duration(i)*time.Second), r.rtt, r.ok)
+	}
+
+	snap := stats.Snapshot()
+
+	if snap.Sent != 6 || snap.Received != 3 {
+		t.Errorf("Snapshot() sent/received = %d/%d, want 6/3", snap.Sent, snap.Received)
+	}
+	// Window holds the last 4 probes: lost, lost, 20ms, 30ms
+	if snap.Window != 4 || snap.LossPct != 50 {
+		t.Errorf("Snapshot() window = %d loss = %.1f, want 4 and 50", snap.Window, snap.LossPct)
+	}
+	if snap.MinMs != 20 || snap.MaxMs != 30 || snap.AvgMs != 25 {
+		t.Errorf("Snapshot() min/avg/max = %.2f/%.2f/%.2f", snap.MinMs, snap.AvgMs, snap.MaxMs)
+	}
+	if !snap.LastOK || snap.LastMs != 30 {
+		t.Errorf("Snapshot() last = %.2f ok = %v", snap.LastMs, snap.LastOK)
+	}
+
+	if len(snap.Outages) != 1 {
+		t.Fatalf("Snapshot() outages = %d, want 1", len(snap.Outages))
+	}
+	outage := snap.Outages[0]
+	if outage.Lost != 3 || !outage.Start.Equal(start.Add(time.Second)) || !outage.End.Equal(start.Add(4*time.Second)) {
+		t.Errorf("Snapshot() outage = %+v", outage)
+	}
+}
+
+func TestRollingStats_OngoingOutage(t *testing.T) {
+	stats := NewRollingStats(10, 3)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		stats.Add(now, 0, false)
+	}
+	if got := len(stats.Snapshot().Outages); got != 0 {
+		t.Errorf("Snapshot() outages = %d before threshold, want 0", got)
+	}
+
+	stats.Add(now, 0, false)
+	outages := stats.Snapshot().Outages
+	if len(outages) != 1 || !outages[0].End.IsZero() {
+		t.Errorf("Snapshot() outages = %+v, want one ongoing", outages)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{50, 5},
+		{90, 9},
+		{99, 10},
+		{0, 1},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(values, tt.p); got != tt.want {
+			t.Errorf("percentile(%.0f) = %.1f, want %.1f", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestWatcherTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	ctx := context.Background()
+	watcher, err := NewWatcher(ctx, "127.0.0.1", WatchOptions{
+		Mode:     PingModeTCP,
+		Port:     ln.Addr().(*net.TCPAddr).Port,
+		Interval: 10 * time.Millisecond,
+		Count:    3,
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+
+	var updates []model.WatchStats
+	if err := watcher.Run(ctx, func(s model.WatchStats) { updates = append(updates, s) }); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(updates) != 3 {
+		t.Fatalf("Run() updates = %d, want 3", len(updates))
+	}
+	last := updates[len(updates)-1]
+	if last.Method != PingMethodTCP || last.Received != 3 || last.LossPct != 0 {
+		t.Errorf("Run() last update = %+v", last)
+	}
+}
@@ -3,47 +3,71 @@ package collector
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/likexian/whois"
 	"github.com/typicalfo/netgaze/internal/model"
 )
 
 func collectWhois(ctx context.Context, target string, report *model.Report) error {
-	// Create context with 10-second timeout
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
+	return collectWhoisWithOptions(ctx, target, WhoisOptions{}, report)
+}
 
-	// Run WHOIS in goroutine to respect context
-	resultChan := make(chan string, 1)
-	errorChan := make(chan error, 1)
+func collectWhoisWithOptions(ctx context.Context, target string, opts WhoisOptions, report *model.Report) error {
+	// Create context with 12-second timeout for RDAP and the referral chain
+	ctx, cancel := context.WithTimeout(ctx, 12*time.Second)
+	defer cancel()
 
-	go func() {
-		result, err := whois.Whois(target)
-		if err != nil {
-			errorChan <- err
-			return
-		}
-		resultChan <- result
-	}()
-
-	// Wait for completion or timeout
-	select {
-	case result := <-resultChan:
-		report.WhoisRaw = result
-		parseWhoisData(result, report)
-	case err := <-errorChan:
-		report.Errors["whois"] = fmt.Sprintf("WHOIS failed: %v", err)
-		// Don't return error for WHOIS - it's optional
+	// RDAP first: structured data straight from the registry
+	rdapErr := collectRDAP(ctx, target, report)
+	if rdapErr == nil {
+		annotateWhoisDates(report, opts, time.Now())
 		return nil
-	case <-ctx.Done():
-		report.Errors["whois"] = "WHOIS timeout"
+	}
+
+	// Port-43 WHOIS, following referrals from IANA down
+	chain, err := newWhoisClient(opts).lookup(ctx, target)
+	if err != nil {
+		report.WhoisChain = chain
+		report.Errors["whois"] = fmt.Sprintf("RDAP failed: %v; WHOIS failed: %v", rdapErr, err)
 		// Don't return error for WHOIS - it's optional
 		return nil
 	}
 
+	applyWhoisChain(chain, report)
+	report.Whois.Source = "whois"
+	annotateWhoisDates(report, opts, time.Now())
+	return nil
+}
+
+// collectRDAP looks the target up over RDAP, leaving part of the WHOIS
+// budget for the port-43 fallback
+func collectRDAP(ctx context.Context, target string, report *model.Report) error {
+	reg, err := defaultRDAP()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	chain, data, err := fetchRDAP(ctx, http.DefaultClient, reg, target)
+	if err != nil {
+		return err
+	}
+	result, err := parseRDAP(data)
+	if err != nil {
+		return err
+	}
+	result.Server = chain[len(chain)-1].Server
+
+	report.WhoisChain = chain
+	report.WhoisRaw = string(data)
+	report.RDAP = result
+	applyRDAPToWhois(result, report)
 	return nil
 }
 
@@ -107,17 +131,36 @@ func parseWhoisData(data string, report *model.Report) {
 		`registrant country:\s*(.+)`,
 	})
 
-	// Extract abuse emails
-	report.Whois.AbuseEmails = extractEmails(data)
+	// Extract abuse emails; admin and tech contacts are not for abuse reports
+	report.Whois.AbuseEmails = abuseLineEmails(data)
+
+	// IP registrations: use the registry's own objects rather than the
+	// first match anywhere in the response
+	if rec := parseRegistryWhois(data); rec != nil {
+		applyRegistryWhois(rec, report)
+	}
+}
+
+// whoisPatterns caches compiled field patterns; extractField is called
+// for every field of every response
+var whoisPatterns sync.Map // pattern -> *regexp.Regexp
+
+func whoisPattern(pattern string) *regexp.Regexp {
+	if re, ok := whoisPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(`(?i)` + pattern) // Case-insensitive
+	whoisPatterns.Store(pattern, re)
+	return re
 }
 
 func extractField(data, lowerData string, patterns []string) string {
 	for _, pattern := range patterns {
-		re := regexp.MustCompile(`(?i)` + pattern) // Case-insensitive
+		re := whoisPattern(pattern)
 		matches := re.FindStringSubmatch(lowerData)
 		if len(matches) > 1 {
 			// Try to get original case from data
-			originalMatch := regexp.MustCompile(`(?i)` + pattern).FindStringSubmatch(data)
+			originalMatch := re.FindStringSubmatch(data)
 			if len(originalMatch) > 1 {
 				return strings.TrimSpace(originalMatch[1])
 			}
@@ -127,20 +170,19 @@ func extractField(data, lowerData string, patterns []string) string {
 	return ""
 }
 
-func extractEmails(data string) []string {
-	// Common email patterns in WHOIS
-	emailPatterns := []string{
-		`abuse.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`,
-		`admin.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`,
-		`technical.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`,
-		`([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`,
-	}
+// Common email patterns in WHOIS, most relevant first
+var emailPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)abuse.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`),
+	regexp.MustCompile(`(?i)admin.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`),
+	regexp.MustCompile(`(?i)technical.*?([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`),
+	regexp.MustCompile(`(?i)([a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`),
+}
 
+func extractEmails(data string) []string {
 	var emails []string
 	seen := make(map[string]bool)
 
-	for _, pattern := range emailPatterns {
-		re := regexp.MustCompile(`(?i)` + pattern)
+	for _, re := range emailPatterns {
 		matches := re.FindAllStringSubmatch(data, -1)
 
 		for _, match := range matches {
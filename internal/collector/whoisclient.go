@@ -0,0 +1,301 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+	"golang.org/x/net/publicsuffix"
+)
+
+// WHOIS client defaults
+const (
+	whoisRootServer      = "whois.iana.org"
+	whoisPort            = "43"
+	defaultWhoisHop      = 3 * time.Second
+	defaultWhoisReferral = 3
+	whoisMaxResponse     = 1 << 20
+)
+
+// WhoisOptions configures the port-43 client
+type WhoisOptions struct {
+	// Servers overrides where queries go. A TLD ("io"), "ipv4", "ipv6" or
+	// "asn" key picks the first server instead of IANA; a server host
+	// key redirects every query for that server, e.g. to a mirror.
+	// Values are host or host:port.
+	Servers map[string]string
+
+	// HopTimeout bounds each server in the chain (default 3s)
+	HopTimeout time.Duration
+
+	// MaxReferrals limits how many referrals are followed (default 3)
+	MaxReferrals int
+
+	// A domain younger than NewDomainDays is flagged as newly registered,
+	// one expiring within ExpiryWarnDays as expiring soon (default 30 each)
+	NewDomainDays  int
+	ExpiryWarnDays int
+}
+
+var whoisHostRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:\d{1,5})?$`)
+
+// Lines that name the next server to ask: IANA, thick registries, ARIN
+var whoisReferralPrefixes = []string{
+	"refer:",
+	"whois:",
+	"registrar whois server:",
+	"whois server:",
+	"referralserver:",
+	"%referral",
+}
+
+type whoisClient struct {
+	opts WhoisOptions
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newWhoisClient(opts WhoisOptions) *whoisClient {
+	if opts.HopTimeout <= 0 {
+		opts.HopTimeout = defaultWhoisHop
+	}
+	if opts.MaxReferrals <= 0 {
+		opts.MaxReferrals = defaultWhoisReferral
+	}
+	var d net.Dialer
+	return &whoisClient{opts: opts, dial: d.DialContext}
+}
+
+// lookup queries the first server for target and follows referrals,
+// returning every server's answer in order. The error is set only when
+// no server answered.
+func (c *whoisClient) lookup(ctx context.Context, target string) ([]model.WhoisResponse, error) {
+	query, kind := whoisQueryTarget(target)
+
+	server := whoisRootServer
+	if override, ok := c.opts.Servers[kind]; ok && override != "" {
+		server = strings.ToLower(override)
+	}
+
+	var chain []model.WhoisResponse
+	visited := make(map[string]bool)
+	for hop := 0; hop <= c.opts.MaxReferrals && server != ""; hop++ {
+		if visited[server] {
+			break
+		}
+		visited[server] = true
+
+		resp := c.queryServer(ctx, server, query)
+		chain = append(chain, resp)
+		if resp.Error != "" || ctx.Err() != nil {
+			break
+		}
+		server = resp.Referral
+	}
+
+	for _, resp := range chain {
+		if resp.Error == "" {
+			return chain, nil
+		}
+	}
+	return chain, fmt.Errorf("%s", chain[0].Error)
+}
+
+// queryServer sends one query with its own timeout
+func (c *whoisClient) queryServer(ctx context.Context, server, query string) model.WhoisResponse {
+	host, addr := whoisAddress(server)
+	if override, ok := c.opts.Servers[host]; ok && override != "" {
+		_, addr = whoisAddress(strings.ToLower(override))
+	}
+
+	resp := model.WhoisResponse{Server: server, Query: whoisServerQuery(host, query)}
+	start := time.Now()
+	defer func() { resp.DurationMs = time.Since(start).Milliseconds() }()
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.HopTimeout)
+	defer cancel()
+
+	conn, err := c.dial(ctx, "tcp", addr)
+	if err != nil {
+		resp.Error = fmt.Sprintf("connect to %s failed: %v", server, err)
+		return resp
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte(resp.Query + "\r\n")); err != nil {
+		resp.Error = fmt.Sprintf("send to %s failed: %v", server, err)
+		return resp
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, whoisMaxResponse))
+	// Servers that refuse a query often say why before closing
+	if len(data) == 0 && err != nil {
+		resp.Error = fmt.Sprintf("read from %s failed: %v", server, err)
+		return resp
+	}
+	resp.Raw = strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n"))
+	if resp.Raw == "" {
+		resp.Error = fmt.Sprintf("empty response from %s", server)
+		return resp
+	}
+
+	if ref := whoisReferral(resp.Raw); ref != "" && ref != server && ref != host {
+		resp.Referral = ref
+	}
+	return resp
+}
+
+// whoisQueryTarget returns what to ask for and which kind of Servers
+// override applies: "ipv4", "ipv6", "asn" or the domain's TLD
+func whoisQueryTarget(target string) (string, string) {
+	target = strings.TrimSuffix(strings.TrimSpace(target), ".")
+
+	if ip := net.ParseIP(target); ip != nil {
+		if ip.To4() != nil {
+			return ip.String(), "ipv4"
+		}
+		return ip.String(), "ipv6"
+	}
+	if m := asnTargetRe.FindStringSubmatch(target); m != nil {
+		return "AS" + m[1], "asn"
+	}
+
+	// Registries only know the registered domain, not its subdomains
+	domain := strings.ToLower(target)
+	if registered, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		domain = registered
+	}
+	return domain, domain[strings.LastIndex(domain, ".")+1:]
+}
+
+// whoisServerQuery adapts the query to servers that need flags
+func whoisServerQuery(host, query string) string {
+	switch host {
+	case "whois.arin.net":
+		// Without a flag ARIN matches every record type
+		if strings.HasPrefix(query, "AS") {
+			return "a + " + strings.TrimPrefix(query, "AS")
+		}
+		if net.ParseIP(query) != nil {
+			return "n + " + query
+		}
+	case "whois.denic.de":
+		return "-T dn,ace " + query
+	case "whois.jprs.jp":
+		return query + "/e"
+	}
+	return query
+}
+
+// whoisAddress splits "host[:port]" into the host and a dialable address
+func whoisAddress(server string) (string, string) {
+	if host, port, err := net.SplitHostPort(server); err == nil {
+		return host, net.JoinHostPort(host, port)
+	}
+	return server, net.JoinHostPort(server, whoisPort)
+}
+
+// whoisReferral returns the next server named in a response, if any
+func whoisReferral(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		for _, prefix := range whoisReferralPrefixes {
+			if strings.HasPrefix(lower, prefix) {
+				if host := referralHost(line[len(prefix):]); host != "" {
+					return host
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// referralHost normalises "whois://whois.ripe.net", "rwhois://host:4321/"
+// or a bare host name to host[:port]
+func referralHost(value string) string {
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 0 {
+		return ""
+	}
+	value = fields[0]
+	if i := strings.Index(value, "://"); i >= 0 {
+		value = value[i+3:]
+	}
+	if i := strings.Index(value, "/"); i >= 0 {
+		value = value[:i]
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, ":"+whoisPort), ".")
+	if !strings.Contains(value, ".") || !whoisHostRe.MatchString(value) {
+		return ""
+	}
+	return value
+}
+
+// isRootWhois reports whether a chain entry is the IANA pointer to the
+// real registry
+func isRootWhois(resp model.WhoisResponse) bool {
+	host, _ := whoisAddress(resp.Server)
+	return host == whoisRootServer && resp.Referral != ""
+}
+
+// applyWhoisChain fills the report from a referral chain. Answers are
+// parsed from the most specific server back, so a registrar's details
+// win over the registry's and the RIR's objects over ARIN's pointer.
+func applyWhoisChain(chain []model.WhoisResponse, report *model.Report) {
+	report.WhoisChain = chain
+
+	var raws []string
+	for _, resp := range chain {
+		if resp.Error == "" && !isRootWhois(resp) {
+			raws = append(raws, resp.Raw)
+		}
+	}
+	if len(raws) == 0 {
+		// IANA answered itself (reserved space, .int, ...)
+		for _, resp := range chain {
+			if resp.Error == "" {
+				raws = append(raws, resp.Raw)
+			}
+		}
+	}
+	report.WhoisRaw = strings.Join(raws, "\n\n")
+
+	for i := len(raws) - 1; i >= 0; i-- {
+		var parsed model.Report
+		parseWhoisData(raws[i], &parsed)
+		mergeWhois(report, &parsed)
+	}
+}
+
+// mergeWhois copies fields dst does not have yet
+func mergeWhois(dst, src *model.Report) {
+	d, s := &dst.Whois, &src.Whois
+	for _, f := range []struct{ to, from *string }{
+		{&d.Domain, &s.Domain},
+		{&d.Registrar, &s.Registrar},
+		{&d.Created, &s.Created},
+		{&d.Expires, &s.Expires},
+		{&d.Registrant, &s.Registrant},
+		{&d.NetRange, &s.NetRange},
+		{&d.NetName, &s.NetName},
+		{&d.OrgName, &s.OrgName},
+		{&d.Country, &s.Country},
+		{&d.Registry, &s.Registry},
+	} {
+		if *f.to == "" {
+			*f.to = *f.from
+		}
+	}
+	if d.Network == nil {
+		d.Network, d.Parent, d.Org, d.Abuse = s.Network, s.Parent, s.Org, s.Abuse
+	}
+	d.AbuseEmails = dedupe(append(d.AbuseEmails, s.AbuseEmails...))
+}
@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// fakeWhoisServer answers each query with respond(query) and records
+// the queries it saw
+func fakeWhoisServer(t *testing.T, respond func(query string) string) (string, *[]string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var queries []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			query := strings.TrimRight(line, "\r\n")
+			queries = append(queries, query)
+			if answer := respond(query); answer != "" {
+				conn.Write([]byte(strings.ReplaceAll(answer, "\n", "\r\n")))
+			}
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String(), &queries
+}
+
+func TestWhoisClientFollowsReferrals(t *testing.T) {
+	iana, ianaQueries := fakeWhoisServer(t, func(string) string {
+		return "% IANA WHOIS server\n\nrefer:        whois.verisign-grs.com\n\ndomain:       COM\nwhois:        whois.verisign-grs.com\n"
+	})
+	registry, registryQueries := fakeWhoisServer(t, func(string) string {
+		return "   Domain Name: EXAMPLE.COM\n   Registrar WHOIS Server: whois.example-registrar.com\n   Registrar: Example Registrar, Inc.\n   Creation Date: 1995-08-14T04:00:00Z\n   Registry Expiry Date: 2030-08-13T04:00:00Z\n"
+	})
+	registrar, _ := fakeWhoisServer(t, func(string) string {
+		return "Domain Name: example.com\nRegistrar WHOIS Server: whois.example-registrar.com\nRegistrant Organization: Example Org\nRegistrar Abuse Contact Email: abuse@registrar.example\n"
+	})
+
+	client := newWhoisClient(WhoisOptions{Servers: map[string]string{
+		"whois.iana.org":              iana,
+		"whois.verisign-grs.com":      registry,
+		"whois.example-registrar.com": registrar,
+	}})
+
+	chain, err := client.lookup(context.Background(), "www.example.com")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("Expected 3 servers in the chain, got %+v", chain)
+	}
+
+	var servers []string
+	for _, resp := range chain {
+		servers = append(servers, resp.Server)
+	}
+	if got := strings.Join(servers, " -> "); got != "whois.iana.org -> whois.verisign-grs.com -> whois.example-registrar.com" {
+		t.Errorf("unexpected chain: %s", got)
+	}
+	if (*ianaQueries)[0] != "example.com" || (*registryQueries)[0] != "example.com" {
+		t.Errorf("Expected the registered domain to be queried, got %v %v", *ianaQueries, *registryQueries)
+	}
+	if chain[2].Referral != "" {
+		t.Errorf("Expected the registrar's self-reference to end the chain, got %q", chain[2].Referral)
+	}
+
+	report := &model.Report{}
+	applyWhoisChain(chain, report)
+	w := report.Whois
+	if w.Registrar != "Example Registrar, Inc." || w.Expires != "2030-08-13T04:00:00Z" || w.Registrant != "Example Org" {
+		t.Errorf("Expected registry and registrar fields merged, got %+v", w)
+	}
+	if strings.Contains(report.WhoisRaw, "IANA WHOIS server") || !strings.Contains(report.WhoisRaw, "Example Org") {
+		t.Errorf("Expected the raw text without the IANA pointer, got %q", report.WhoisRaw)
+	}
+	if len(report.WhoisChain) != 3 {
+		t.Errorf("Expected the chain in the report, got %d entries", len(report.WhoisChain))
+	}
+}
+
+func TestWhoisClientARINReferral(t *testing.T) {
+	ripe, _ := fakeWhoisServer(t, func(string) string { return ripeWhoisSample })
+	arin, arinQueries := fakeWhoisServer(t, func(string) string {
+		return "NetRange:       193.0.0.0 - 193.255.255.255\nCIDR:           193.0.0.0/8\nNetName:        RIPE-CBLK2\nNetHandle:      NET-193-0-0-0-1\nOrganization:   RIPE Network Coordination Centre (RIPE)\nReferralServer:  whois://whois.ripe.net\n"
+	})
+
+	// Starting at ARIN instead of IANA for IPv4
+	client := newWhoisClient(WhoisOptions{Servers: map[string]string{
+		"ipv4":           "whois.arin.net",
+		"whois.arin.net": arin,
+		"whois.ripe.net": ripe,
+	}})
+
+	chain, err := client.lookup(context.Background(), "193.0.6.139")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(chain) != 2 || chain[1].Server != "whois.ripe.net" {
+		t.Fatalf("Expected ARIN to refer to RIPE, got %+v", chain)
+	}
+	if (*arinQueries)[0] != "n + 193.0.6.139" {
+		t.Errorf("Expected an ARIN network query, got %q", (*arinQueries)[0])
+	}
+
+	report := &model.Report{}
+	applyWhoisChain(chain, report)
+	if report.Whois.Registry != RegistryRIPE || report.Whois.NetName != "RIPE-NCC" {
+		t.Errorf("Expected RIPE's own network to win over ARIN's pointer, got %+v", report.Whois)
+	}
+}
+
+func TestWhoisClientHopFailure(t *testing.T) {
+	iana, _ := fakeWhoisServer(t, func(string) string {
+		return "refer:        whois.nic.test\n"
+	})
+	silent, _ := fakeWhoisServer(t, func(string) string {
+		time.Sleep(500 * time.Millisecond)
+		return ""
+	})
+
+	client := newWhoisClient(WhoisOptions{
+		HopTimeout: 100 * time.Millisecond,
+		Servers:    map[string]string{"whois.iana.org": iana, "whois.nic.test": silent},
+	})
+
+	start := time.Now()
+	chain, err := client.lookup(context.Background(), "example.test")
+	if time.Since(start) > 400*time.Millisecond {
+		t.Errorf("Expected the hop timeout to cut the slow server off, took %v", time.Since(start))
+	}
+	// IANA answered, so the lookup still succeeds with the failure recorded
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(chain) != 2 || chain[1].Error == "" {
+		t.Errorf("Expected the failed hop in the chain, got %+v", chain)
+	}
+
+	report := &model.Report{}
+	applyWhoisChain(chain, report)
+	if !strings.Contains(report.WhoisRaw, "refer:") {
+		t.Errorf("Expected IANA's answer as the only raw text, got %q", report.WhoisRaw)
+	}
+}
+
+func TestWhoisClientReferralLoop(t *testing.T) {
+	var a, b string
+	a, _ = fakeWhoisServer(t, func(string) string { return "refer: whois.b.test\n" })
+	b, _ = fakeWhoisServer(t, func(string) string { return "refer: whois.a.test\n" })
+
+	client := newWhoisClient(WhoisOptions{Servers: map[string]string{
+		"test":         "whois.a.test",
+		"whois.a.test": a,
+		"whois.b.test": b,
+	}})
+	chain, err := client.lookup(context.Background(), "example.test")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(chain) != 2 {
+		t.Errorf("Expected the loop to stop after both servers, got %d", len(chain))
+	}
+}
+
+func TestWhoisClientNoServer(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := newWhoisClient(WhoisOptions{Servers: map[string]string{"whois.iana.org": addr}})
+	chain, err := client.lookup(context.Background(), "example.com")
+	if err == nil {
+		t.Fatal("Expected an error when no server answers")
+	}
+	if len(chain) != 1 || chain[0].Error == "" {
+		t.Errorf("Expected the failed server in the chain, got %+v", chain)
+	}
+}
+
+func TestWhoisReferral(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"refer:        whois.arin.net", "whois.arin.net"},
+		{"   Registrar WHOIS Server: whois.markmonitor.com", "whois.markmonitor.com"},
+		{"ReferralServer:  whois://whois.ripe.net", "whois.ripe.net"},
+		{"ReferralServer:  rwhois://rwhois.example.net:4321/", "rwhois.example.net:4321"},
+		{"%referral rwhois://root.rwhois.net:4321/auth-area=.", "root.rwhois.net:4321"},
+		{"whois:        whois.nic.io:43", "whois.nic.io"},
+		{"   Registrar WHOIS Server: \n", ""},
+		{"Registrar WHOIS Server: see http://example.com", ""},
+		{"Domain Name: EXAMPLE.COM", ""},
+	}
+	for _, tt := range tests {
+		if got := whoisReferral(tt.data); got != tt.want {
+			t.Errorf("whoisReferral(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestWhoisServerQuery(t *testing.T) {
+	tests := []struct {
+		host, query, want string
+	}{
+		{"whois.arin.net", "8.8.8.8", "n + 8.8.8.8"},
+		{"whois.arin.net", "AS15169", "a + 15169"},
+		{"whois.denic.de", "example.de", "-T dn,ace example.de"},
+		{"whois.ripe.net", "AS3333", "AS3333"},
+	}
+	for _, tt := range tests {
+		if got := whoisServerQuery(tt.host, tt.query); got != tt.want {
+			t.Errorf("whoisServerQuery(%q, %q) = %q, want %q", tt.host, tt.query, got, tt.want)
+		}
+	}
+
+	for target, kind := range map[string]string{"8.8.8.8": "ipv4", "2001:db8::1": "ipv6", "as64500": "asn", "a.b.example.co.uk": "uk"} {
+		if _, got := whoisQueryTarget(target); got != kind {
+			t.Errorf("whoisQueryTarget(%q) kind = %q, want %q", target, got, kind)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Domain age and expiry thresholds
+const (
+	defaultNewDomainDays  = 30
+	defaultExpiryWarnDays = 30
+)
+
+// Date formats seen in registry and registrar responses, most common first
+var whoisDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"02-Jan-2006 15:04:05 MST",
+	"02-Jan-2006 15:04:05",
+	"02-Jan-2006",
+	"02-January-2006",
+	"2006-Jan-02",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"2006/01/02 15:04:05 (MST)",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	"Mon Jan _2 15:04:05 MST 2006",
+	"Mon Jan _2 15:04:05 2006",
+	"January _2 2006",
+	"_2 January 2006",
+	"Jan _2 2006",
+	"20060102",
+}
+
+var (
+	// Trailing notes such as "(JST)", "# registry time" or "(UTC+8)"
+	whoisDateNoteRe = regexp.MustCompile(`\s*(\(.*\)|#.*)$`)
+	// Month and weekday names printed in capitals ("14-JAN-1999")
+	whoisDateWordRe = regexp.MustCompile(`[A-Za-z]{3,}`)
+)
+
+// parseWhoisDate reads a registry date in any of the common formats and
+// returns it in UTC
+func parseWhoisDate(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return time.Time{}, false
+	}
+	s = strings.TrimSuffix(s, ".")
+
+	candidates := []string{s}
+	if trimmed := whoisDateNoteRe.ReplaceAllString(s, ""); trimmed != s && trimmed != "" {
+		candidates = append(candidates, trimmed)
+	}
+	for _, c := range candidates {
+		titled := whoisDateWordRe.ReplaceAllStringFunc(c, func(w string) string {
+			if w == "UTC" || w == "GMT" || len(w) == 3 && strings.ToUpper(w) == w && !isMonthOrDay(w) {
+				// Zone abbreviations stay upper case
+				return w
+			}
+			return strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+		})
+		for _, layout := range whoisDateLayouts {
+			if t, err := time.Parse(layout, titled); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func isMonthOrDay(w string) bool {
+	switch strings.ToLower(w) {
+	case "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+		"mon", "tue", "wed", "thu", "fri", "sat", "sun":
+		return true
+	}
+	return false
+}
+
+// annotateWhoisDates normalises Created and Expires and, for domain
+// registrations, works out the age and remaining days against the
+// thresholds in opts
+func annotateWhoisDates(report *model.Report, opts WhoisOptions, now time.Time) {
+	w := &report.Whois
+	if t, ok := parseWhoisDate(w.Created); ok {
+		w.CreatedAt = t
+	}
+	if t, ok := parseWhoisDate(w.Expires); ok {
+		w.ExpiresAt = t
+	}
+
+	// Networks and AS numbers are allocated, not registered for a term
+	if w.Domain == "" {
+		return
+	}
+
+	newDays := opts.NewDomainDays
+	if newDays <= 0 {
+		newDays = defaultNewDomainDays
+	}
+	warnDays := opts.ExpiryWarnDays
+	if warnDays <= 0 {
+		warnDays = defaultExpiryWarnDays
+	}
+
+	if !w.CreatedAt.IsZero() {
+		age := int(max(now.Sub(w.CreatedAt).Hours(), 0) / 24)
+		w.AgeDays = &age
+		w.NewlyRegistered = age < newDays
+	}
+	if !w.ExpiresAt.IsZero() {
+		days := int(math.Floor(w.ExpiresAt.Sub(now).Hours() / 24))
+		w.DaysToExpiry = &days
+		w.ExpiringSoon = days < warnDays
+	}
+}
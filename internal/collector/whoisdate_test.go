@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func TestParseWhoisDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1995-08-14T04:00:00Z", "1995-08-14T04:00:00Z"},
+		{"2024-08-13T04:00:00.000Z", "2024-08-13T04:00:00Z"},
+		{"2011-03-22T10:15:30+0100", "2011-03-22T09:15:30Z"},
+		{"2019-07-01T00:00:00", "2019-07-01T00:00:00Z"},
+		{"2020-02-29 12:00:00+08:00", "2020-02-29T04:00:00Z"},
+		{"2015-06-01 00:00:00 UTC", "2015-06-01T00:00:00Z"},
+		{"2003-03-17", "2003-03-17T00:00:00Z"},
+		{"14-Jan-1999", "1999-01-14T00:00:00Z"},
+		{"14-JAN-1999", "1999-01-14T00:00:00Z"},
+		{"01-Aug-2026 12:30:00 UTC", "2026-08-01T12:30:00Z"},
+		{"2001/08/01", "2001-08-01T00:00:00Z"},
+		{"2001/08/01 01:02:03 (JST)", "2001-08-01T01:02:03Z"},
+		{"2012.05.04", "2012-05-04T00:00:00Z"},
+		{"04.05.2012", "2012-05-04T00:00:00Z"},
+		{"20100730", "2010-07-30T00:00:00Z"},
+		{"Tue Mar  5 10:00:00 GMT 2002", "2002-03-05T10:00:00Z"},
+		{"2018-10-02 # before registry migration", "2018-10-02T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, ok := parseWhoisDate(tt.input)
+		if !ok {
+			t.Errorf("parseWhoisDate(%q) failed", tt.input)
+			continue
+		}
+		if got.Format(time.RFC3339) != tt.want {
+			t.Errorf("parseWhoisDate(%q) = %s, want %s", tt.input, got.Format(time.RFC3339), tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "before Aug-1996", "not available", "2024-13-45"} {
+		if got, ok := parseWhoisDate(bad); ok {
+			t.Errorf("parseWhoisDate(%q) = %v, want failure", bad, got)
+		}
+	}
+}
+
+func TestAnnotateWhoisDates(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	t.Run("new domain", func(t *testing.T) {
+		report := &model.Report{}
+		report.Whois.Domain = "fresh.example"
+		report.Whois.Created = "2026-10-06T09:00:00Z"
+		report.Whois.Expires = "2027-10-06T09:00:00Z"
+
+		annotateWhoisDates(report, WhoisOptions{}, now)
+		w := report.Whois
+		if w.AgeDays == nil || *w.AgeDays != 12 {
+			t.Fatalf("Expected an age of 12 days, got %v", w.AgeDays)
+		}
+		if !w.NewlyRegistered || w.ExpiringSoon {
+			t.Errorf("Expected only the newly registered flag, got new=%v expiring=%v", w.NewlyRegistered, w.ExpiringSoon)
+		}
+		if w.DaysToExpiry == nil || *w.DaysToExpiry != 352 {
+			t.Errorf("Expected 352 days to expiry, got %v", w.DaysToExpiry)
+		}
+	})
+
+	t.Run("thresholds", func(t *testing.T) {
+		report := &model.Report{}
+		report.Whois.Domain = "fresh.example"
+		report.Whois.Created = "2026-10-06"
+
+		annotateWhoisDates(report, WhoisOptions{NewDomainDays: 7}, now)
+		if report.Whois.NewlyRegistered {
+			t.Error("Expected a 12-day-old domain not to be new with a 7-day threshold")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		report := &model.Report{}
+		report.Whois.Domain = "lapsed.example"
+		report.Whois.Created = "14-Jan-1999"
+		report.Whois.Expires = "2026-10-15"
+
+		annotateWhoisDates(report, WhoisOptions{}, now)
+		w := report.Whois
+		if w.DaysToExpiry == nil || *w.DaysToExpiry != -4 {
+			t.Fatalf("Expected expiry 4 days ago, got %v", w.DaysToExpiry)
+		}
+		if !w.ExpiringSoon || w.NewlyRegistered {
+			t.Errorf("Expected only the expiring flag, got new=%v expiring=%v", w.NewlyRegistered, w.ExpiringSoon)
+		}
+	})
+
+	t.Run("network", func(t *testing.T) {
+		report := &model.Report{}
+		report.Whois.NetName = "EXAMPLE-NET"
+		report.Whois.Created = "2026-10-10"
+
+		annotateWhoisDates(report, WhoisOptions{}, now)
+		w := report.Whois
+		if w.CreatedAt.IsZero() {
+			t.Error("Expected the network's date to be normalised")
+		}
+		if w.AgeDays != nil || w.NewlyRegistered {
+			t.Error("Expected no domain age for a network allocation")
+		}
+	})
+}
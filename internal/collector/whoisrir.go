@@ -0,0 +1,661 @@
+package collector
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/asndb"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Regional Internet Registries, as recorded in Whois.Registry
+const (
+	RegistryARIN    = "arin"
+	RegistryRIPE    = "ripe"
+	RegistryAPNIC   = "apnic"
+	RegistryLACNIC  = "lacnic"
+	RegistryAFRINIC = "afrinic"
+)
+
+// rirParsers turn a registry's objects into a record. RIPE, APNIC and
+// AFRINIC all speak RPSL; ARIN and LACNIC have their own vocabularies.
+var rirParsers = map[string]func([]rpslObject) *rirRecord{
+	RegistryARIN:    parseARINWhois,
+	RegistryRIPE:    parseRPSLWhois,
+	RegistryAPNIC:   parseRPSLWhois,
+	RegistryAFRINIC: parseRPSLWhois,
+	RegistryLACNIC:  parseLACNICWhois,
+}
+
+var (
+	rpslLineRe    = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_-]*):\s*(.*)$`)
+	ripeAbuseRe   = regexp.MustCompile(`(?i)^%\s*abuse contact for '[^']*' is '([^']+)'`)
+	arinHandleRe  = regexp.MustCompile(`^(.*?)\s*\(([^()]+)\)\s*$`)
+	lacnicShortV4 = regexp.MustCompile(`^(\d+(?:\.\d+){0,2})/(\d+)$`)
+)
+
+// whoisMaxCIDRs caps the prefixes listed for one range
+const whoisMaxCIDRs = 32
+
+// rpslAttr is one "key: value" line, with continuation lines folded in
+type rpslAttr struct {
+	key   string // lower case
+	value string
+}
+
+// rpslObject is one blank-line separated paragraph of a WHOIS response.
+// Its class is the first attribute's key (inetnum, organisation, role,
+// route; NetRange or OrgName at ARIN).
+type rpslObject struct {
+	class string
+	attrs []rpslAttr
+}
+
+// key returns the object's primary key, the first attribute's value
+func (o *rpslObject) key() string {
+	return o.attrs[0].value
+}
+
+// get returns the first value of an attribute, or ""
+func (o *rpslObject) get(key string) string {
+	for _, a := range o.attrs {
+		if a.key == key {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// all returns every non-empty value of an attribute
+func (o *rpslObject) all(key string) []string {
+	var values []string
+	for _, a := range o.attrs {
+		if a.key == key && a.value != "" {
+			values = append(values, a.value)
+		}
+	}
+	return values
+}
+
+// splitRPSL splits a WHOIS response into objects. Comment lines (% and #)
+// are dropped; lines starting with whitespace or "+" continue the
+// previous attribute.
+func splitRPSL(data string) []rpslObject {
+	var objs []rpslObject
+	var cur rpslObject
+
+	flush := func() {
+		if len(cur.attrs) > 0 {
+			objs = append(objs, cur)
+		}
+		cur = rpslObject{}
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "#"):
+			continue
+		case (line[0] == ' ' || line[0] == '\t' || line[0] == '+') && len(cur.attrs) > 0:
+			last := &cur.attrs[len(cur.attrs)-1]
+			last.value = strings.TrimSpace(last.value + " " + strings.TrimPrefix(trimmed, "+"))
+		default:
+			m := rpslLineRe.FindStringSubmatch(trimmed)
+			if m == nil {
+				continue
+			}
+			key := strings.ToLower(m[1])
+			if len(cur.attrs) == 0 {
+				cur.class = key
+			}
+			cur.attrs = append(cur.attrs, rpslAttr{key: key, value: strings.TrimSpace(m[2])})
+		}
+	}
+	flush()
+
+	return objs
+}
+
+// detectWhoisRegistry names the RIR that wrote a response, from the
+// objects' source attribute or the server's banner
+func detectWhoisRegistry(data string, objs []rpslObject) string {
+	for _, o := range objs {
+		source := strings.ToLower(o.get("source"))
+		for _, rir := range []string{RegistryRIPE, RegistryAPNIC, RegistryAFRINIC, RegistryLACNIC, RegistryARIN} {
+			if strings.HasPrefix(source, rir) {
+				return rir
+			}
+		}
+	}
+
+	lower := strings.ToLower(data)
+	for _, o := range objs {
+		switch {
+		case o.class == "netrange":
+			return RegistryARIN
+		case o.get("ownerid") != "":
+			return RegistryLACNIC
+		}
+	}
+	switch {
+	case strings.Contains(lower, "arin whois data"):
+		return RegistryARIN
+	case strings.Contains(lower, "lacnic"):
+		return RegistryLACNIC
+	case strings.Contains(lower, "afrinic"):
+		return RegistryAFRINIC
+	case strings.Contains(lower, "apnic"):
+		return RegistryAPNIC
+	case strings.Contains(lower, "ripe"):
+		return RegistryRIPE
+	}
+	return ""
+}
+
+// rirRecord is what a registry parser extracts from one response
+type rirRecord struct {
+	registry string
+	network  *model.WhoisNetwork
+	parent   *model.WhoisNetwork
+	org      *model.WhoisOrg
+	abuse    *model.WhoisContact
+}
+
+// rirNet is a network with the address span used to rank it
+type rirNet struct {
+	net        *model.WhoisNetwork
+	start, end netip.Addr
+	orgRef     string          // organisation handle
+	abuseRef   string          // abuse contact handle
+	irtRef     string          // incident response team (mnt-irt)
+	parentRef  string          // ARIN "Parent: NAME (HANDLE)"
+	org        *model.WhoisOrg // LACNIC keeps the holder in the network
+}
+
+// parseRegistryWhois splits an IP WHOIS response into the registry's
+// objects and keeps the most specific network, its parent, the holding
+// organisation and the abuse contact. It returns nil when the response
+// holds no network.
+func parseRegistryWhois(data string) *rirRecord {
+	objs := splitRPSL(data)
+	registry := detectWhoisRegistry(data, objs)
+
+	parse, ok := rirParsers[registry]
+	if !ok {
+		// National registries below APNIC and LACNIC still use RPSL
+		parse = parseRPSLWhois
+	}
+	rec := parse(objs)
+	if rec == nil {
+		return nil
+	}
+	rec.registry = registry
+
+	// RIPE and AFRINIC print the abuse mailbox as a comment when the
+	// role object itself is filtered from the output
+	if rec.abuse == nil {
+		for _, line := range strings.Split(data, "\n") {
+			if m := ripeAbuseRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+				rec.abuse = &model.WhoisContact{Emails: []string{strings.ToLower(m[1])}, Source: AbuseSourceAbuseC}
+				break
+			}
+		}
+	}
+	return rec
+}
+
+// parseRPSLWhois handles RIPE, APNIC and AFRINIC responses
+func parseRPSLWhois(objs []rpslObject) *rirRecord {
+	var nets []*rirNet
+	for i := range objs {
+		o := &objs[i]
+		if o.class != "inetnum" && o.class != "inet6num" {
+			continue
+		}
+		n := newRIRNet(o.key())
+		if n == nil {
+			continue
+		}
+		n.net.Name = o.get("netname")
+		n.net.Type = o.get("status")
+		n.net.Descr = o.get("descr")
+		n.net.Country = o.get("country")
+		n.net.Org = o.get("org")
+		n.net.Created = o.get("created")
+		n.net.Updated = o.get("last-modified")
+		n.orgRef = n.net.Org
+		n.abuseRef = o.get("abuse-c")
+		n.irtRef = o.get("mnt-irt")
+		nets = append(nets, n)
+	}
+
+	rec, chosen := rankRIRNets(nets)
+	if rec == nil {
+		return nil
+	}
+
+	for i := range objs {
+		o := &objs[i]
+		if (o.class == "route" || o.class == "route6") && rec.network.Origin == "" {
+			rec.network.Origin = strings.TrimPrefix(strings.ToUpper(o.get("origin")), "AS")
+		}
+	}
+
+	if chosen.orgRef != "" {
+		if o := findRPSLObject(objs, "organisation", chosen.orgRef); o != nil {
+			rec.org = &model.WhoisOrg{
+				Handle:  o.key(),
+				Name:    o.get("org-name"),
+				Country: o.get("country"),
+				Address: strings.Join(o.all("address"), ", "),
+			}
+			if chosen.abuseRef == "" {
+				chosen.abuseRef = o.get("abuse-c")
+			}
+		}
+	}
+
+	// The abuse handle names a role (RIPE, AFRINIC) or an irt (APNIC)
+	source := AbuseSourceAbuseC
+	if chosen.abuseRef == "" {
+		chosen.abuseRef = chosen.irtRef
+		source = AbuseSourceIRT
+	}
+	if chosen.abuseRef != "" {
+		for i := range objs {
+			o := &objs[i]
+			if strings.EqualFold(o.get("nic-hdl"), chosen.abuseRef) || (o.class == "irt" && strings.EqualFold(o.key(), chosen.abuseRef)) {
+				rec.abuse = rpslContact(o)
+				if o.class == "irt" {
+					source = AbuseSourceIRT
+				}
+				rec.abuse.Source = source
+				break
+			}
+		}
+	}
+	if rec.abuse == nil {
+		if o := findRPSLObject(objs, "irt", ""); o != nil {
+			rec.abuse = rpslContact(o)
+			rec.abuse.Source = AbuseSourceIRT
+		}
+	}
+	// Older organisations carry the mailbox themselves
+	if rec.abuse == nil && rec.org != nil {
+		if o := findRPSLObject(objs, "organisation", rec.org.Handle); o != nil && o.get("abuse-mailbox") != "" {
+			rec.abuse = &model.WhoisContact{
+				Handle: o.key(),
+				Name:   o.get("org-name"),
+				Emails: lowerAll(o.all("abuse-mailbox")),
+				Source: AbuseSourceMailbox,
+			}
+		}
+	}
+
+	return rec
+}
+
+// parseLACNICWhois handles LACNIC (and registro.br) responses, which name
+// the holder inside the inetnum with owner/ownerid and give contacts an
+// e-mail attribute
+func parseLACNICWhois(objs []rpslObject) *rirRecord {
+	var nets []*rirNet
+	for i := range objs {
+		o := &objs[i]
+		if o.class != "inetnum" && o.class != "inet6num" {
+			continue
+		}
+		n := newRIRNet(o.key())
+		if n == nil {
+			continue
+		}
+		n.net.Name = o.get("owner")
+		n.net.Type = o.get("status")
+		n.net.Country = o.get("country")
+		n.net.Org = o.get("ownerid")
+		n.net.Origin = strings.TrimPrefix(strings.ToUpper(o.get("aut-num")), "AS")
+		n.net.Created = o.get("created")
+		n.net.Updated = o.get("changed")
+		n.abuseRef = o.get("abuse-c")
+		if n.net.Name != "" {
+			n.org = &model.WhoisOrg{
+				Handle:  n.net.Org,
+				Name:    n.net.Name,
+				Country: n.net.Country,
+				Address: strings.Join(o.all("address"), ", "),
+			}
+		}
+		nets = append(nets, n)
+	}
+
+	rec, chosen := rankRIRNets(nets)
+	if rec == nil {
+		return nil
+	}
+	rec.org = chosen.org
+
+	if chosen.abuseRef != "" {
+		for i := range objs {
+			o := &objs[i]
+			if strings.EqualFold(o.get("nic-hdl"), chosen.abuseRef) || strings.EqualFold(o.get("nic-hdl-br"), chosen.abuseRef) {
+				rec.abuse = rpslContact(o)
+				rec.abuse.Source = AbuseSourceAbuseC
+				break
+			}
+		}
+	}
+
+	return rec
+}
+
+// parseARINWhois handles ARIN responses: NetRange blocks for networks,
+// OrgName or CustName blocks for holders and OrgAbuse/RAbuse blocks for
+// abuse contacts
+func parseARINWhois(objs []rpslObject) *rirRecord {
+	var nets []*rirNet
+	orgs := make(map[string]*model.WhoisOrg)
+	abuse := make(map[string]*model.WhoisContact) // by org handle
+	var resourceAbuse *model.WhoisContact
+	lastOrg := ""
+
+	for i := range objs {
+		o := &objs[i]
+		switch o.class {
+		case "netrange":
+			n := newRIRNet(o.key())
+			if n == nil {
+				continue
+			}
+			n.net.Name = o.get("netname")
+			n.net.Handle = o.get("nethandle")
+			n.net.Type = o.get("nettype")
+			n.net.Origin = strings.TrimPrefix(strings.ToUpper(o.get("originas")), "AS")
+			n.net.Created = o.get("regdate")
+			n.net.Updated = o.get("updated")
+			if cidr := o.get("cidr"); cidr != "" {
+				n.net.CIDRs = splitList(cidr)
+			}
+			holder := o.get("organization")
+			if holder == "" {
+				holder = o.get("customer")
+			}
+			if m := arinHandleRe.FindStringSubmatch(holder); m != nil {
+				n.orgRef = m[2]
+			}
+			n.net.Org = n.orgRef
+			n.parentRef = o.get("parent")
+			nets = append(nets, n)
+
+		case "orgname", "custname":
+			org := &model.WhoisOrg{
+				Handle:  o.get("orgid"),
+				Name:    o.key(),
+				Country: o.get("country"),
+			}
+			if org.Handle == "" {
+				// Customers are only identified by their RDAP reference
+				ref := o.get("ref")
+				org.Handle = ref[strings.LastIndex(ref, "/")+1:]
+			}
+			var addr []string
+			addr = append(addr, o.all("address")...)
+			for _, key := range []string{"city", "stateprov", "postalcode"} {
+				if v := o.get(key); v != "" {
+					addr = append(addr, v)
+				}
+			}
+			org.Address = strings.Join(addr, ", ")
+			orgs[org.Handle] = org
+			lastOrg = org.Handle
+
+		case "orgabusehandle":
+			// Points of contact follow the organisation they belong to
+			abuse[lastOrg] = &model.WhoisContact{
+				Handle: o.key(),
+				Name:   o.get("orgabusename"),
+				Emails: lowerAll(o.all("orgabuseemail")),
+				Phone:  o.get("orgabusephone"),
+				Source: AbuseSourceOrgAbuse,
+			}
+
+		case "rabusehandle":
+			resourceAbuse = &model.WhoisContact{
+				Handle: o.key(),
+				Name:   o.get("rabusename"),
+				Emails: lowerAll(o.all("rabuseemail")),
+				Phone:  o.get("rabusephone"),
+				Source: AbuseSourceResourceAbuse,
+			}
+		}
+	}
+
+	rec, chosen := rankRIRNets(nets)
+	if rec == nil {
+		return nil
+	}
+
+	if rec.parent == nil && chosen.parentRef != "" {
+		parent := &model.WhoisNetwork{Name: chosen.parentRef}
+		if m := arinHandleRe.FindStringSubmatch(chosen.parentRef); m != nil {
+			parent.Name, parent.Handle = m[1], m[2]
+		}
+		rec.parent = parent
+	}
+
+	rec.org = orgs[chosen.orgRef]
+	if rec.org != nil {
+		rec.network.Country = rec.org.Country
+	}
+
+	switch {
+	case resourceAbuse != nil:
+		rec.abuse = resourceAbuse
+	case abuse[chosen.orgRef] != nil:
+		rec.abuse = abuse[chosen.orgRef]
+	default:
+		for _, o := range nets {
+			if c := abuse[o.orgRef]; c != nil {
+				rec.abuse = c
+				break
+			}
+		}
+	}
+
+	return rec
+}
+
+// rankRIRNets picks the most specific network and the smallest one
+// enclosing it
+func rankRIRNets(nets []*rirNet) (*rirRecord, *rirNet) {
+	if len(nets) == 0 {
+		return nil, nil
+	}
+
+	chosen := nets[0]
+	for _, n := range nets[1:] {
+		if n.within(chosen) {
+			chosen = n
+		}
+	}
+
+	var parent *rirNet
+	for _, n := range nets {
+		if n == chosen || n.start == chosen.start && n.end == chosen.end {
+			continue
+		}
+		if chosen.within(n) && (parent == nil || n.within(parent)) {
+			parent = n
+		}
+	}
+
+	rec := &rirRecord{network: chosen.net}
+	if parent != nil {
+		rec.parent = parent.net
+	}
+	return rec, chosen
+}
+
+// within reports whether n lies inside other
+func (n *rirNet) within(other *rirNet) bool {
+	return n.start.BitLen() == other.start.BitLen() &&
+		n.start.Compare(other.start) >= 0 && n.end.Compare(other.end) <= 0
+}
+
+// newRIRNet parses a network's range; nil if it cannot be read
+func newRIRNet(value string) *rirNet {
+	start, end, ok := parseWhoisRange(value)
+	if !ok {
+		return nil
+	}
+	return &rirNet{
+		net: &model.WhoisNetwork{
+			Range: start.String() + " - " + end.String(),
+			CIDRs: rangeToCIDRs(start, end),
+		},
+		start: start,
+		end:   end,
+	}
+}
+
+// parseWhoisRange reads "first - last", a CIDR prefix (including
+// LACNIC's shortened "200.160/20") or a single address
+func parseWhoisRange(value string) (netip.Addr, netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+
+	if first, last, found := strings.Cut(value, "-"); found {
+		start, err1 := netip.ParseAddr(strings.TrimSpace(first))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil || start.BitLen() != end.BitLen() || end.Less(start) {
+			return netip.Addr{}, netip.Addr{}, false
+		}
+		return start, end, true
+	}
+
+	if strings.Contains(value, "/") {
+		if m := lacnicShortV4.FindStringSubmatch(value); m != nil {
+			octets := strings.Count(m[1], ".") + 1
+			value = m[1] + strings.Repeat(".0", 4-octets) + "/" + m[2]
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, false
+		}
+		prefix = prefix.Masked()
+		return prefix.Addr(), asndb.LastAddr(prefix), true
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+	return addr, addr, true
+}
+
+// rangeToCIDRs splits an address range into the fewest prefixes
+func rangeToCIDRs(start, end netip.Addr) []string {
+	prefixes := asndb.RangePrefixes(start, end)
+	if len(prefixes) > whoisMaxCIDRs {
+		return nil
+	}
+	cidrs := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		cidrs[i] = p.String()
+	}
+	return cidrs
+}
+
+// findRPSLObject returns the first object of class with the given primary
+// key; an empty key matches any
+func findRPSLObject(objs []rpslObject, class, key string) *rpslObject {
+	for i := range objs {
+		if objs[i].class == class && (key == "" || strings.EqualFold(objs[i].key(), key)) {
+			return &objs[i]
+		}
+	}
+	return nil
+}
+
+// rpslContact reads a role, person or irt object
+func rpslContact(o *rpslObject) *model.WhoisContact {
+	c := &model.WhoisContact{
+		Handle: o.get("nic-hdl"),
+		Name:   o.key(),
+		Phone:  o.get("phone"),
+	}
+	if c.Handle == "" {
+		c.Handle = o.get("nic-hdl-br")
+	}
+	if o.class == "irt" {
+		c.Handle = o.key()
+	}
+	for _, key := range []string{"abuse-mailbox", "e-mail", "email"} {
+		c.Emails = append(c.Emails, lowerAll(o.all(key))...)
+	}
+	c.Emails = dedupe(c.Emails)
+	return c
+}
+
+// applyRegistryWhois replaces the flattened WHOIS fields with the values
+// from the registry's own objects
+func applyRegistryWhois(rec *rirRecord, report *model.Report) {
+	w := &report.Whois
+	w.Registry = rec.registry
+	w.Network = rec.network
+	w.Parent = rec.parent
+	w.Org = rec.org
+	w.Abuse = rec.abuse
+
+	// An IP registration has no domain, registrar or expiry
+	w.Domain, w.Registrar, w.Registrant, w.Expires = "", "", "", ""
+
+	w.NetRange = rec.network.Range
+	w.NetName = rec.network.Name
+	w.Created = rec.network.Created
+	w.Country = rec.network.Country
+	switch {
+	case rec.org != nil && rec.org.Name != "":
+		w.OrgName = rec.org.Name
+		if w.Country == "" {
+			w.Country = rec.org.Country
+		}
+	case rec.network.Descr != "":
+		w.OrgName = rec.network.Descr
+	}
+
+	if rec.abuse != nil {
+		w.AbuseEmails = dedupe(append(append([]string{}, rec.abuse.Emails...), w.AbuseEmails...))
+	}
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func lowerAll(values []string) []string {
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return values
+}
+
+func dedupe(values []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
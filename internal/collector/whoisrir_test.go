@@ -0,0 +1,421 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+const arinWhoisSample = `
+#
+# ARIN WHOIS data and services are subject to the Terms of Use
+#
+
+NetRange:       8.0.0.0 - 8.127.255.255
+CIDR:           8.0.0.0/9
+NetName:        LVLT-ORG-8-8
+NetHandle:      NET-8-0-0-0-1
+Parent:          ()
+NetType:        Direct Allocation
+Organization:   Level 3 Parent, LLC (LPL-141)
+RegDate:        1992-12-01
+Updated:        2018-04-23
+
+OrgName:        Level 3 Parent, LLC
+OrgId:          LPL-141
+Address:        100 CenturyLink Drive
+City:           Monroe
+StateProv:      LA
+PostalCode:     71203
+Country:        US
+
+OrgAbuseHandle: IPADD5-ARIN
+OrgAbuseName:   ipaddressing
+OrgAbusePhone:  +1-877-453-8353
+OrgAbuseEmail:  ipaddressing@lumen.com
+
+NetRange:       8.8.8.0 - 8.8.8.255
+CIDR:           8.8.8.0/24
+NetName:        GOGL
+NetHandle:      NET-8-8-8-0-2
+Parent:         NET8 (NET-8-0-0-0-0)
+NetType:        Direct Allocation
+OriginAS:
+Organization:   Google LLC (GOGL)
+RegDate:        2023-12-28
+Updated:        2023-12-28
+Ref:            https://rdap.arin.net/registry/ip/8.8.8.0
+
+OrgName:        Google LLC
+OrgId:          GOGL
+Address:        1600 Amphitheatre Parkway
+City:           Mountain View
+StateProv:      CA
+PostalCode:     94043
+Country:        US
+
+OrgTechHandle: ZG39-ARIN
+OrgTechName:   Google LLC
+OrgTechEmail:  arin-contact@google.com
+
+OrgAbuseHandle: ABUSE5250-ARIN
+OrgAbuseName:   Abuse
+OrgAbusePhone:  +1-650-253-0000
+OrgAbuseEmail:  network-abuse@google.com
+`
+
+const ripeWhoisSample = `% This is the RIPE Database query service.
+% Information related to '193.0.0.0 - 193.0.7.255'
+
+% Abuse contact for '193.0.0.0 - 193.0.7.255' is 'abuse@ripe.net'
+
+inetnum:        193.0.0.0 - 193.0.7.255
+netname:        RIPE-NCC
+descr:          RIPE Network Coordination Centre
+descr:          Amsterdam, Netherlands
+org:            ORG-RIEN1-RIPE
+country:        NL
+admin-c:        BRD-RIPE
+abuse-c:        ops4-ripe
+status:         ASSIGNED PA
+mnt-by:         RIPE-NCC-MNT
+created:        2003-03-17T12:15:57Z
+last-modified:  2017-12-04T14:42:31Z
+source:         RIPE
+
+organisation:   ORG-RIEN1-RIPE
+org-name:       Reseaux IP Europeens Network Coordination Centre (RIPE NCC)
+country:        NL
+org-type:       RIR
+address:        P.O. Box 10096
+address:        1001EB
+address:        Amsterdam
+abuse-c:        ops4-ripe
+source:         RIPE
+
+role:           RIPE NCC Operations
+address:        Stationsplein 11
+                Amsterdam
+nic-hdl:        OPS4-RIPE
+abuse-mailbox:  abuse@ripe.net
+source:         RIPE
+
+% Information related to '193.0.0.0/21AS3333'
+
+route:          193.0.0.0/21
+origin:         AS3333
+source:         RIPE
+`
+
+const apnicWhoisSample = `% [whois.apnic.net]
+
+inetnum:        1.1.1.0 - 1.1.1.255
+netname:        APNIC-LABS
+descr:          APNIC and Cloudflare DNS Resolver project
+country:        AU
+org:            ORG-ARAD1-AP
+admin-c:        AIC3-AP
+abuse-c:        AA1412-AP
+status:         ASSIGNED PORTABLE
+mnt-irt:        IRT-APNICRANDNET-AU
+last-modified:  2023-04-26T22:57:58Z
+source:         APNIC
+
+irt:            IRT-APNICRANDNET-AU
+e-mail:         helpdesk@apnic.net
+abuse-mailbox:  helpdesk@apnic.net
+source:         APNIC
+
+organisation:   ORG-ARAD1-AP
+org-name:       APNIC Research and Development
+country:        AU
+address:        6 Cordelia St
+source:         APNIC
+
+role:           ABUSE APNICRANDNETAU
+address:        PO Box 3646
+e-mail:         helpdesk@apnic.net
+abuse-mailbox:  helpdesk@apnic.net
+nic-hdl:        AA1412-AP
+source:         APNIC
+
+% Information related to '1.1.1.0/24AS13335'
+
+route:          1.1.1.0/24
+origin:         AS13335
+source:         APNIC
+`
+
+const lacnicWhoisSample = `
+% Joint Whois - whois.lacnic.net
+%  This server accepts single ASN, IPv4 or IPv6 queries
+
+% LACNIC resource: whois.lacnic.net
+
+
+% Copyright LACNIC lacnic.net
+%  The use of the data below is only permitted as described in
+%  full by the LACNIC Resource Terms of Service
+
+inetnum:     200.160/20
+status:      allocated
+aut-num:     AS22548
+owner:       Nucleo de Inf. e Coord. do Ponto BR - NIC.BR
+ownerid:     BR-NUCL-LACNIC
+responsible: Frederico Neves
+address:     Av. das Nacoes Unidas, 11541, 7
+address:     04578-000 - Sao Paulo - SP
+country:     BR
+owner-c:     NIB
+tech-c:      NIB
+abuse-c:     NIB
+created:     19980101
+changed:     20171207
+
+nic-hdl:     NIB
+person:      NIC.BR
+e-mail:      lacnic-contact@NIC.BR
+address:     Av. das Nacoes Unidas, 11541, 7
+country:     BR
+created:     20020902
+changed:     20230419
+`
+
+const afrinicWhoisSample = `% This is the AfriNIC Whois server.
+
+inetnum:        196.216.2.0 - 196.216.3.255
+netname:        AFRINIC-SERVICES
+descr:          AFRINIC - Mauritius
+country:        MU
+org:            ORG-AFNC1-AFRINIC
+admin-c:        IT15-AFRINIC
+status:         ASSIGNED PI
+mnt-irt:        IRT-AFRINIC-CSIRT
+source:         AFRINIC
+
+irt:            IRT-AFRINIC-CSIRT
+e-mail:         csirt@afrinic.net
+abuse-mailbox:  abuse@afrinic.net
+source:         AFRINIC
+
+organisation:   ORG-AFNC1-AFRINIC
+org-name:       African Network Information Center - ( AfriNIC Ltd )
+country:        MU
+source:         AFRINIC
+`
+
+func TestSplitRPSL(t *testing.T) {
+	objs := splitRPSL(ripeWhoisSample)
+
+	var classes []string
+	for _, o := range objs {
+		classes = append(classes, o.class)
+	}
+	if got := strings.Join(classes, ","); got != "inetnum,organisation,role,route" {
+		t.Fatalf("unexpected objects: %s", got)
+	}
+
+	inetnum := objs[0]
+	if inetnum.key() != "193.0.0.0 - 193.0.7.255" || len(inetnum.all("descr")) != 2 {
+		t.Errorf("unexpected inetnum: %+v", inetnum)
+	}
+	// Indented lines continue the previous attribute
+	if got := objs[2].get("address"); got != "Stationsplein 11 Amsterdam" {
+		t.Errorf("Expected a folded continuation line, got %q", got)
+	}
+}
+
+func TestDetectWhoisRegistry(t *testing.T) {
+	tests := map[string]string{
+		arinWhoisSample:    RegistryARIN,
+		ripeWhoisSample:    RegistryRIPE,
+		apnicWhoisSample:   RegistryAPNIC,
+		lacnicWhoisSample:  RegistryLACNIC,
+		afrinicWhoisSample: RegistryAFRINIC,
+		"Domain Name: X":   "",
+	}
+	for data, want := range tests {
+		if got := detectWhoisRegistry(data, splitRPSL(data)); got != want {
+			t.Errorf("detectWhoisRegistry() = %q, want %q for %.40q", got, want, data)
+		}
+	}
+}
+
+func TestParseARINWhois(t *testing.T) {
+	rec := parseRegistryWhois(arinWhoisSample)
+	if rec == nil {
+		t.Fatal("Expected a record")
+	}
+
+	if rec.network.Handle != "NET-8-8-8-0-2" || rec.network.Name != "GOGL" || rec.network.Range != "8.8.8.0 - 8.8.8.255" {
+		t.Errorf("Expected the /24 as the most specific network, got %+v", rec.network)
+	}
+	if rec.network.Country != "US" || rec.network.Org != "GOGL" {
+		t.Errorf("unexpected network details: %+v", rec.network)
+	}
+	if rec.parent == nil || rec.parent.Handle != "NET-8-0-0-0-1" {
+		t.Errorf("Expected the enclosing /9 as the parent, got %+v", rec.parent)
+	}
+	if rec.org == nil || rec.org.Name != "Google LLC" || !strings.HasPrefix(rec.org.Address, "1600 Amphitheatre Parkway, Mountain View") {
+		t.Errorf("unexpected org: %+v", rec.org)
+	}
+	// The abuse contact belongs to Google, not to Level 3
+	if rec.abuse == nil || rec.abuse.Handle != "ABUSE5250-ARIN" || rec.abuse.Emails[0] != "network-abuse@google.com" {
+		t.Errorf("unexpected abuse contact: %+v", rec.abuse)
+	}
+}
+
+func TestParseARINParentReference(t *testing.T) {
+	single := strings.SplitN(arinWhoisSample, "NetRange:       8.8.8.0", 2)[1]
+	rec := parseRegistryWhois("NetRange:       8.8.8.0" + single)
+	if rec == nil || rec.parent == nil {
+		t.Fatal("Expected a parent from the Parent attribute")
+	}
+	if rec.parent.Name != "NET8" || rec.parent.Handle != "NET-8-0-0-0-0" {
+		t.Errorf("unexpected parent: %+v", rec.parent)
+	}
+}
+
+func TestParseRIPEWhois(t *testing.T) {
+	rec := parseRegistryWhois(ripeWhoisSample)
+	if rec == nil {
+		t.Fatal("Expected a record")
+	}
+
+	n := rec.network
+	if n.Name != "RIPE-NCC" || n.Type != "ASSIGNED PA" || n.Origin != "3333" || n.Created != "2003-03-17T12:15:57Z" {
+		t.Errorf("unexpected network: %+v", n)
+	}
+	if len(n.CIDRs) != 1 || n.CIDRs[0] != "193.0.0.0/21" {
+		t.Errorf("unexpected CIDRs: %v", n.CIDRs)
+	}
+	if rec.org == nil || rec.org.Handle != "ORG-RIEN1-RIPE" || rec.org.Address != "P.O. Box 10096, 1001EB, Amsterdam" {
+		t.Errorf("unexpected org: %+v", rec.org)
+	}
+	// abuse-c is matched against nic-hdl regardless of case
+	if rec.abuse == nil || rec.abuse.Name != "RIPE NCC Operations" || rec.abuse.Emails[0] != "abuse@ripe.net" {
+		t.Errorf("unexpected abuse contact: %+v", rec.abuse)
+	}
+}
+
+func TestParseRIPEAbuseComment(t *testing.T) {
+	// Without the role object the banner comment still names the mailbox
+	data := strings.Split(ripeWhoisSample, "role:")[0]
+	rec := parseRegistryWhois(data)
+	if rec == nil || rec.abuse == nil || rec.abuse.Emails[0] != "abuse@ripe.net" {
+		t.Errorf("Expected the abuse mailbox from the comment, got %+v", rec)
+	}
+}
+
+func TestParseAPNICWhois(t *testing.T) {
+	rec := parseRegistryWhois(apnicWhoisSample)
+	if rec == nil {
+		t.Fatal("Expected a record")
+	}
+	if rec.network.Name != "APNIC-LABS" || rec.network.Origin != "13335" {
+		t.Errorf("unexpected network: %+v", rec.network)
+	}
+	if rec.abuse == nil || rec.abuse.Handle != "AA1412-AP" || rec.abuse.Emails[0] != "helpdesk@apnic.net" {
+		t.Errorf("Expected the abuse-c role over the irt, got %+v", rec.abuse)
+	}
+	if rec.org == nil || rec.org.Name != "APNIC Research and Development" {
+		t.Errorf("unexpected org: %+v", rec.org)
+	}
+}
+
+func TestParseLACNICWhois(t *testing.T) {
+	rec := parseRegistryWhois(lacnicWhoisSample)
+	if rec == nil {
+		t.Fatal("Expected a record")
+	}
+
+	n := rec.network
+	if n.Range != "200.160.0.0 - 200.160.15.255" || n.Origin != "22548" || n.Org != "BR-NUCL-LACNIC" {
+		t.Errorf("unexpected network: %+v", n)
+	}
+	if rec.org == nil || rec.org.Name != "Nucleo de Inf. e Coord. do Ponto BR - NIC.BR" || rec.org.Country != "BR" {
+		t.Errorf("unexpected org: %+v", rec.org)
+	}
+	if rec.abuse == nil || rec.abuse.Handle != "NIB" || rec.abuse.Emails[0] != "lacnic-contact@nic.br" {
+		t.Errorf("unexpected abuse contact: %+v", rec.abuse)
+	}
+}
+
+func TestParseAFRINICWhois(t *testing.T) {
+	rec := parseRegistryWhois(afrinicWhoisSample)
+	if rec == nil {
+		t.Fatal("Expected a record")
+	}
+	if rec.network.Name != "AFRINIC-SERVICES" || len(rec.network.CIDRs) != 1 || rec.network.CIDRs[0] != "196.216.2.0/23" {
+		t.Errorf("unexpected network: %+v", rec.network)
+	}
+	// No abuse-c: the irt named by mnt-irt is used
+	if rec.abuse == nil || rec.abuse.Handle != "IRT-AFRINIC-CSIRT" {
+		t.Fatalf("unexpected abuse contact: %+v", rec.abuse)
+	}
+	if got := strings.Join(rec.abuse.Emails, ","); got != "abuse@afrinic.net,csirt@afrinic.net" {
+		t.Errorf("Expected the abuse mailbox first, got %s", got)
+	}
+}
+
+func TestParseRegistryWhoisDomain(t *testing.T) {
+	if rec := parseRegistryWhois("Domain Name: EXAMPLE.COM\nRegistrar: Example Registrar\n"); rec != nil {
+		t.Errorf("Expected no record for a domain response, got %+v", rec)
+	}
+}
+
+func TestParseWhoisRange(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end string
+	}{
+		{"192.0.2.0 - 192.0.2.255", "192.0.2.0", "192.0.2.255"},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{"200.160/20", "200.160.0.0", "200.160.15.255"},
+		{"192.0.2.77", "192.0.2.77", "192.0.2.77"},
+	}
+	for _, tt := range tests {
+		start, end, ok := parseWhoisRange(tt.in)
+		if !ok || start.String() != tt.start || end.String() != tt.end {
+			t.Errorf("parseWhoisRange(%q) = %v - %v, %v", tt.in, start, end, ok)
+		}
+	}
+	for _, bad := range []string{"", "10.0.0.9 - 10.0.0.1", "10.0.0.0 - 2001:db8::", "not a range"} {
+		if _, _, ok := parseWhoisRange(bad); ok {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	start, end, _ := parseWhoisRange("192.0.2.0 - 192.0.4.255")
+	if got := strings.Join(rangeToCIDRs(start, end), " "); got != "192.0.2.0/23 192.0.4.0/24" {
+		t.Errorf("rangeToCIDRs() = %s", got)
+	}
+	start, end, _ = parseWhoisRange("0.0.0.0 - 255.255.255.255")
+	if got := rangeToCIDRs(start, end); len(got) != 1 || got[0] != "0.0.0.0/0" {
+		t.Errorf("rangeToCIDRs() = %v for the whole space", got)
+	}
+}
+
+func TestParseWhoisDataUsesRegistryObjects(t *testing.T) {
+	report := &model.Report{}
+	parseWhoisData(ripeWhoisSample, report)
+
+	w := report.Whois
+	if w.Registry != RegistryRIPE || w.NetRange != "193.0.0.0 - 193.0.7.255" {
+		t.Errorf("unexpected registry summary: %+v", w)
+	}
+	// The organisation's name, not the first descr line
+	if w.OrgName != "Reseaux IP Europeens Network Coordination Centre (RIPE NCC)" {
+		t.Errorf("unexpected org name %q", w.OrgName)
+	}
+	// RIPE's reverse-DNS "domain" objects are not a domain registration
+	if w.Domain != "" {
+		t.Errorf("Expected no domain, got %q", w.Domain)
+	}
+	if len(w.AbuseEmails) == 0 || w.AbuseEmails[0] != "abuse@ripe.net" {
+		t.Errorf("Expected the abuse mailbox first, got %v", w.AbuseEmails)
+	}
+}
@@ -0,0 +1,212 @@
+// Package history stores traceroute runs on disk, one directory per
+// target, so paths can be compared over time.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// timestampFormat names run files so they sort chronologically
+const timestampFormat = "20060102T150405.000000000Z"
+
+// ErrNoRuns is returned by Latest when a target has no stored runs
+var ErrNoRuns = errors.New("no stored traceroute runs")
+
+// Store is a traceroute history rooted at a directory:
+//
+//	<dir>/<target>/<timestamp>.json
+type Store struct {
+	dir string
+}
+
+// Entry describes one stored run without loading its hops
+type Entry struct {
+	Target    string
+	Timestamp time.Time
+	Path      string
+}
+
+// DefaultDir returns $XDG_DATA_HOME/netgaze/traces, falling back to
+// ~/.local/share/netgaze/traces
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return filepath.Join(dir, "netgaze", "traces"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "netgaze", "traces"), nil
+}
+
+// Open returns a store rooted at dir. The directory is created on the
+// first Save.
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the store's root directory
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save writes run under its target and returns the file path
+func (s *Store) Save(run *model.TraceRun) (string, error) {
+	if run.Target == "" {
+		return "", fmt.Errorf("traceroute run has no target")
+	}
+	if run.Timestamp.IsZero() {
+		run.Timestamp = time.Now()
+	}
+
+	dir := filepath.Join(s.dir, targetDir(run.Target))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal traceroute run: %w", err)
+	}
+
+	path := filepath.Join(dir, run.Timestamp.UTC().Format(timestampFormat)+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write traceroute run: %w", err)
+	}
+
+	return path, nil
+}
+
+// List returns the stored runs for target, oldest first
+func (s *Store) List(target string) ([]Entry, error) {
+	return listRuns(filepath.Join(s.dir, targetDir(target)), target)
+}
+
+func listRuns(dir, target string) ([]Entry, error) {
+	files, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var entries []Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ts, err := time.Parse(timestampFormat, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			// Not one of ours
+			continue
+		}
+		entries = append(entries, Entry{
+			Target:    target,
+			Timestamp: ts,
+			Path:      filepath.Join(dir, name),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries, nil
+}
+
+// Latest loads the most recent run for target
+func (s *Store) Latest(target string) (*model.TraceRun, string, error) {
+	entries, err := s.List(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(entries) == 0 {
+		return nil, "", ErrNoRuns
+	}
+
+	last := entries[len(entries)-1]
+	run, err := Load(last.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	return run, last.Path, nil
+}
+
+// Targets lists every target with stored runs. Directory names are
+// sanitised, so each target is read back from its newest run.
+func (s *Store) Targets() ([]string, error) {
+	dirs, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	var targets []string
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entries, err := listRuns(filepath.Join(s.dir, d.Name()), "")
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+		target := d.Name()
+		if run, err := Load(entries[len(entries)-1].Path); err == nil && run.Target != "" {
+			target = run.Target
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Load reads a traceroute file. Both stored runs and the bare hop arrays
+// written by `ng to -o` are accepted.
+func Load(path string) (*model.TraceRun, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traceroute file: %w", err)
+	}
+
+	var hops []model.TraceHop
+	if err := json.Unmarshal(data, &hops); err == nil {
+		run := &model.TraceRun{Hops: hops}
+		if info, err := os.Stat(path); err == nil {
+			run.Timestamp = info.ModTime()
+		}
+		return run, nil
+	}
+
+	var run model.TraceRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to parse traceroute file: %w", err)
+	}
+	return &run, nil
+}
+
+// targetDir turns a target into a safe directory name. Hostnames and
+// IPv4 addresses are kept as-is; IPv6 colons become underscores.
+func targetDir(target string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(target) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
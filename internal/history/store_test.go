@@ -0,0 +1,116 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+func testRun(target string, at time.Time, ips ...string) *model.TraceRun {
+	run := &model.TraceRun{Target: target, Timestamp: at}
+	for i, ip := range ips {
+		run.Hops = append(run.Hops, model.TraceHop{Hop: i + 1, IP: ip, RTT: "1.0ms"})
+	}
+	return run
+}
+
+func TestStoreSaveListLatest(t *testing.T) {
+	store := Open(t.TempDir())
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if _, _, err := store.Latest("example.com"); !errors.Is(err, ErrNoRuns) {
+		t.Errorf("Latest() on empty store error = %v, want ErrNoRuns", err)
+	}
+
+	// Saved out of order on purpose
+	for _, offset := range []time.Duration{2 * time.Hour, 0, time.Hour} {
+		if _, err := store.Save(testRun("example.com", start.Add(offset), "10.0.0.1")); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if _, err := store.Save(testRun("2001:db8::1", start, "10.0.0.1")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	entries, err := store.List("example.com")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 runs, got %d", len(entries))
+	}
+	for i, e := range entries {
+		if want := start.Add(time.Duration(i) * time.Hour); !e.Timestamp.Equal(want) {
+			t.Errorf("entry %d timestamp = %v, want %v", i, e.Timestamp, want)
+		}
+	}
+
+	latest, path, err := store.Latest("example.com")
+	if err != nil {
+		t.Fatalf("Latest() error = %v", err)
+	}
+	if !latest.Timestamp.Equal(start.Add(2*time.Hour)) || path != entries[2].Path {
+		t.Errorf("Latest() returned %v from %s", latest.Timestamp, path)
+	}
+
+	targets, err := store.Targets()
+	if err != nil {
+		t.Fatalf("Targets() error = %v", err)
+	}
+	if len(targets) != 2 || targets[0] != "2001:db8::1" || targets[1] != "example.com" {
+		t.Errorf("Targets() = %v", targets)
+	}
+
+	if entries, _ := store.List("missing.example"); len(entries) != 0 {
+		t.Errorf("Expected no runs for an unknown target, got %d", len(entries))
+	}
+}
+
+func TestLoadLegacyHopArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traceroute-example.com-20260101-000000.json")
+	if err := os.WriteFile(path, []byte(`[{"hop":1,"ip":"10.0.0.1","rtt":"1.0ms"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(run.Hops) != 1 || run.Hops[0].IP != "10.0.0.1" {
+		t.Errorf("unexpected hops: %+v", run.Hops)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for a malformed file")
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	runs := []*model.TraceRun{
+		testRun("example.com", start, "10.0.0.1", "192.0.2.1"),
+		testRun("example.com", start.Add(time.Hour), "10.0.0.1", "192.0.2.1"),
+		testRun("example.com", start.Add(2*time.Hour), "10.0.0.1", "10.0.5.5", "192.0.2.1"),
+		testRun("example.com", start.Add(3*time.Hour), "10.0.0.1", "10.0.5.5", "192.0.2.1"),
+		testRun("example.com", start.Add(4*time.Hour), "10.0.0.1", "192.0.2.1"),
+	}
+
+	changes := Timeline(runs, collector.TraceDiffOptions{})
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(changes))
+	}
+	if changes[0].Index != 2 || !changes[0].From.Equal(start.Add(time.Hour)) || !changes[0].To.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected first change: %+v", changes[0])
+	}
+	if changes[1].Index != 4 {
+		t.Errorf("Expected second change at run 4, got %d", changes[1].Index)
+	}
+}
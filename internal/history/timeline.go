@@ -0,0 +1,35 @@
+package history
+
+import (
+	"time"
+
+	"github.com/typicalfo/netgaze/internal/collector"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// Change is a path difference between two consecutive stored runs
+type Change struct {
+	Index int // position of the later run in the runs passed to Timeline
+	From  time.Time
+	To    time.Time
+	Diff  *collector.TraceDiff
+}
+
+// Timeline diffs every run against the one before it and returns the
+// transitions where something significant changed, oldest first.
+func Timeline(runs []*model.TraceRun, opts collector.TraceDiffOptions) []Change {
+	var changes []Change
+	for i := 1; i < len(runs); i++ {
+		diff := collector.DiffTraces(runs[i-1].Hops, runs[i].Hops, opts)
+		if !diff.Significant() {
+			continue
+		}
+		changes = append(changes, Change{
+			Index: i,
+			From:  runs[i-1].Timestamp,
+			To:    runs[i].Timestamp,
+			Diff:  diff,
+		})
+	}
+	return changes
+}
@@ -0,0 +1,222 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Data section field types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEnd       = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDepth bounds nested maps and arrays so a corrupt file cannot
+// exhaust the stack
+const maxDepth = 64
+
+var errTruncated = errors.New("unexpected end of data")
+
+// decoder turns data section values into Go values: map[string]any,
+// []any, string, []byte, float64, float32, uint64 (every unsigned
+// type; uint128 only when it fits), int32 and bool
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns the offset after it
+func (d *decoder) decode(offset int) (any, int, error) {
+	return d.decodeDepth(offset, 0)
+}
+
+func (d *decoder) decodeDepth(offset, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		// A pointer's target is decoded in place; reading continues
+		// after the pointer itself
+		v, _, err := d.decodeDepth(size, depth+1)
+		return v, offset, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for i := 0; i < size; i++ {
+			var k, v any
+			if k, offset, err = d.decodeDepth(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key of type %T", k)
+			}
+			if v, offset, err = d.decodeDepth(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for i := 0; i < size; i++ {
+			var v any
+			if v, offset, err = d.decodeDepth(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid boolean size %d", size)
+		}
+		return size == 1, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64, typeUint128:
+		if size > uintSize(typ) {
+			return nil, 0, fmt.Errorf("invalid unsigned integer size %d", size)
+		}
+		if typ == typeUint128 && size > 8 {
+			// Nothing netgaze reads is this large; keep the raw bytes
+			return append([]byte(nil), b...), offset, nil
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// uintSize is the largest encoding of an unsigned type, in bytes
+func uintSize(typ int) int {
+	switch typ {
+	case typeUint16:
+		return 2
+	case typeUint32:
+		return 4
+	case typeUint64:
+		return 8
+	}
+	return 16
+}
+
+// control reads a control byte and any extended type and size bytes.
+// For pointers the returned size is the target offset.
+func (d *decoder) control(offset int) (typ, size, next int, err error) {
+	if offset >= len(d.buf) {
+		return 0, 0, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ = int(ctrl >> 5)
+
+	if typ == typePointer {
+		n := int(ctrl>>3) & 0x3
+		if offset+n+1 > len(d.buf) {
+			return 0, 0, 0, errTruncated
+		}
+		b := d.buf[offset : offset+n+1]
+		vvv := int(ctrl & 0x7)
+		var ptr int
+		switch n {
+		case 0:
+			ptr = vvv<<8 | int(b[0])
+		case 1:
+			ptr = (vvv<<16 | int(b[0])<<8 | int(b[1])) + 2048
+		case 2:
+			ptr = (vvv<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+		default:
+			ptr = int(binary.BigEndian.Uint32(b))
+		}
+		if ptr >= len(d.buf) {
+			return 0, 0, 0, fmt.Errorf("pointer %d out of range", ptr)
+		}
+		return typ, ptr, offset + n + 1, nil
+	}
+
+	if typ == typeExtended {
+		if offset >= len(d.buf) {
+			return 0, 0, 0, errTruncated
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+		if typ < typeInt32 || typ > typeFloat || typ == typeContainer || typ == typeEnd {
+			return 0, 0, 0, fmt.Errorf("unsupported extended type %d", typ)
+		}
+	}
+
+	size = int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return 0, 0, 0, errTruncated
+		}
+		b := d.buf[offset : offset+n]
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + int(b[0])
+		case 2:
+			size = 285 + (int(b[0])<<8 | int(b[1]))
+		default:
+			size = 65821 + (int(b[0])<<16 | int(b[1])<<8 | int(b[2]))
+		}
+	}
+	return typ, size, offset, nil
+}
@@ -0,0 +1,210 @@
+// Package mmdb reads MaxMind DB files (GeoLite2, GeoIP2 and the DB-IP
+// lite databases share the format) without cgo or third-party code.
+//
+// A file is a binary search tree over address bits, followed by a data
+// section of typed values and a metadata map:
+// https://maxmind.github.io/MaxMind-DB/
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// Metadata describes a database
+type Metadata struct {
+	DatabaseType string
+	Description  string // English description, when present
+	Languages    []string
+	IPVersion    int
+	NodeCount    int
+	RecordSize   int
+	Built        time.Time
+}
+
+// Reader is an opened database
+type Reader struct {
+	tree     []byte
+	data     []byte
+	meta     Metadata
+	ipv4Root int // node reached after the 96 zero bits of ::/96
+}
+
+// Open reads a database file into memory
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// FromBytes parses a database image
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file (no metadata marker)")
+	}
+	metaStart := i + len(metadataMarker)
+	d := decoder{buf: buf[metaStart:]}
+	v, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+
+	meta := Metadata{
+		DatabaseType: asString(m["database_type"]),
+		IPVersion:    int(asUint(m["ip_version"])),
+		NodeCount:    int(asUint(m["node_count"])),
+		RecordSize:   int(asUint(m["record_size"])),
+		Built:        time.Unix(int64(asUint(m["build_epoch"])), 0).UTC(),
+	}
+	if desc, ok := m["description"].(map[string]any); ok {
+		meta.Description = asString(desc["en"])
+	}
+	if langs, ok := m["languages"].([]any); ok {
+		for _, l := range langs {
+			meta.Languages = append(meta.Languages, asString(l))
+		}
+	}
+	if major := asUint(m["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("unsupported format version %d", major)
+	}
+	switch meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", meta.RecordSize)
+	}
+	if meta.IPVersion != 4 && meta.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", meta.IPVersion)
+	}
+
+	treeSize := meta.NodeCount * meta.RecordSize / 4
+	if meta.NodeCount == 0 || treeSize+16 > i {
+		return nil, fmt.Errorf("search tree of %d nodes does not fit in the file", meta.NodeCount)
+	}
+
+	r := &Reader{
+		tree: buf[:treeSize],
+		data: buf[treeSize+16 : i],
+		meta: meta,
+	}
+	if meta.IPVersion == 6 {
+		node := 0
+		for bit := 0; bit < 96 && node < meta.NodeCount; bit++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Root = node
+	}
+	return r, nil
+}
+
+// Metadata describes the database
+func (r *Reader) Metadata() Metadata {
+	return r.meta
+}
+
+// Lookup returns the record for addr and the network it applies to.
+// ok is false when the database has no data for the address.
+func (r *Reader) Lookup(addr netip.Addr) (record map[string]any, network netip.Prefix, ok bool, err error) {
+	offset, network, ok, err := r.lookupOffset(addr)
+	if err != nil || !ok {
+		return nil, network, false, err
+	}
+	d := decoder{buf: r.data}
+	v, _, err := d.decode(offset)
+	if err != nil {
+		return nil, network, false, fmt.Errorf("corrupt data at offset %d: %w", offset, err)
+	}
+	record, isMap := v.(map[string]any)
+	if !isMap {
+		return nil, network, false, fmt.Errorf("record at offset %d is not a map", offset)
+	}
+	return record, network, true, nil
+}
+
+// lookupOffset walks the search tree to the data section offset for addr
+func (r *Reader) lookupOffset(addr netip.Addr) (int, netip.Prefix, bool, error) {
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return 0, netip.Prefix{}, false, errors.New("invalid address")
+	}
+	if addr.Is6() && r.meta.IPVersion == 4 {
+		return 0, netip.Prefix{}, false, errors.New("IPv6 lookup in an IPv4-only database")
+	}
+
+	key := addr.AsSlice()
+	node := 0
+	if addr.Is4() && r.meta.IPVersion == 6 {
+		node = r.ipv4Root
+	}
+
+	bits := len(key) * 8
+	depth := 0
+	for ; depth < bits && node < r.meta.NodeCount; depth++ {
+		bit := int(key[depth/8]>>(7-depth%8)) & 1
+		node = r.record(node, bit)
+	}
+
+	network, _ := addr.Prefix(depth)
+	switch {
+	case node == r.meta.NodeCount:
+		return 0, network, false, nil
+	case node < r.meta.NodeCount:
+		return 0, network, false, errors.New("search tree deeper than the address")
+	}
+	offset := node - r.meta.NodeCount - 16
+	if offset < 0 || offset >= len(r.data) {
+		return 0, network, false, fmt.Errorf("corrupt search tree: data pointer %d out of range", node)
+	}
+	return offset, network, true, nil
+}
+
+// record returns the left (0) or right (1) record of a node
+func (r *Reader) record(node, side int) int {
+	switch r.meta.RecordSize {
+	case 24:
+		b := r.tree[node*6+side*3:]
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if side == 0 {
+			return int(b[3]&0xf0)<<20 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		}
+		return int(b[3]&0x0f)<<24 | int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	default:
+		b := r.tree[node*8+side*4:]
+		return int(b[0])<<24 | int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+	}
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func asUint(v any) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int32:
+		if n >= 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,460 @@
+package mmdb
+
+import (
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// The encoder below writes just enough of the format to build test
+// databases; networks must not overlap
+
+func encodeCtrl(typ, size int) []byte {
+	var out []byte
+	var first byte
+	if typ > 7 {
+		first = 0
+	} else {
+		first = byte(typ) << 5
+	}
+	var extra []byte
+	switch {
+	case size < 29:
+		first |= byte(size)
+	case size < 285:
+		first |= 29
+		extra = []byte{byte(size - 29)}
+	case size < 65821:
+		first |= 30
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		first |= 31
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	out = append(out, first)
+	if typ > 7 {
+		out = append(out, byte(typ-7))
+	}
+	return append(out, extra...)
+}
+
+func encodeUint(typ int, n uint64) []byte {
+	var b []byte
+	for n > 0 {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+	}
+	return append(encodeCtrl(typ, len(b)), b...)
+}
+
+func encodePointer(ptr int) []byte {
+	switch {
+	case ptr < 2048:
+		return []byte{typePointer<<5 | byte(ptr>>8), byte(ptr)}
+	case ptr < 526336:
+		p := ptr - 2048
+		return []byte{typePointer<<5 | 1<<3 | byte(p>>16), byte(p >> 8), byte(p)}
+	default:
+		b := []byte{typePointer<<5 | 3<<3, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(ptr))
+		return b
+	}
+}
+
+// pointerTo marks a value to be written as a pointer to an offset
+type pointerTo int
+
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case pointerTo:
+		return encodePointer(int(v))
+	case string:
+		return append(encodeCtrl(typeString, len(v)), v...)
+	case float64:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v))
+		return append(encodeCtrl(typeDouble, 8), b...)
+	case float32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, math.Float32bits(v))
+		return append(encodeCtrl(typeFloat, 4), b...)
+	case uint16:
+		return encodeUint(typeUint16, uint64(v))
+	case uint32:
+		return encodeUint(typeUint32, uint64(v))
+	case uint64:
+		return encodeUint(typeUint64, v)
+	case int32:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return append(encodeCtrl(typeInt32, 4), b...)
+	case bool:
+		if v {
+			return encodeCtrl(typeBool, 1)
+		}
+		return encodeCtrl(typeBool, 0)
+	case []byte:
+		return append(encodeCtrl(typeBytes, len(v)), v...)
+	case []any:
+		out := encodeCtrl(typeArray, len(v))
+		for _, e := range v {
+			out = append(out, encode(e)...)
+		}
+		return out
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := encodeCtrl(typeMap, len(v))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	}
+	panic("cannot encode value")
+}
+
+type testNode struct {
+	child [2]*testNode
+	data  [2]int // offset+1 into the data section, 0 for none
+}
+
+// buildDB writes an IPv6 database (IPv4 under ::/96) or an IPv4 one
+func buildDB(t testing.TB, recordSize, ipVersion int, records map[string]map[string]any, shared []byte) []byte {
+	t.Helper()
+	root := &testNode{}
+	data := append([]byte{}, shared...)
+
+	networks := make([]string, 0, len(records))
+	for n := range records {
+		networks = append(networks, n)
+	}
+	sort.Strings(networks)
+	for _, n := range networks {
+		p := netip.MustParsePrefix(n)
+		key := p.Addr().AsSlice()
+		bits := p.Bits()
+		if p.Addr().Is4() && ipVersion == 6 {
+			key = append(make([]byte, 12), key...)
+			bits += 96
+		}
+		offset := len(data)
+		data = append(data, encode(records[n])...)
+
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := int(key[i/8]>>(7-i%8)) & 1
+			if i == bits-1 {
+				node.data[bit] = offset + 1
+				break
+			}
+			if node.child[bit] == nil {
+				node.child[bit] = &testNode{}
+			}
+			node = node.child[bit]
+		}
+	}
+
+	var order []*testNode
+	index := map[*testNode]int{}
+	var walk func(n *testNode)
+	walk = func(n *testNode) {
+		index[n] = len(order)
+		order = append(order, n)
+		for _, c := range n.child {
+			if c != nil {
+				walk(c)
+			}
+		}
+	}
+	walk(root)
+	nodeCount := len(order)
+
+	var tree []byte
+	for _, n := range order {
+		var rec [2]int
+		for side := 0; side < 2; side++ {
+			switch {
+			case n.child[side] != nil:
+				rec[side] = index[n.child[side]]
+			case n.data[side] != 0:
+				rec[side] = nodeCount + 16 + n.data[side] - 1
+			default:
+				rec[side] = nodeCount
+			}
+		}
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(rec[0]>>16), byte(rec[0]>>8), byte(rec[0]), byte(rec[1]>>16), byte(rec[1]>>8), byte(rec[1]))
+		case 28:
+			tree = append(tree, byte(rec[0]>>16), byte(rec[0]>>8), byte(rec[0]),
+				byte(rec[0]>>20)&0xf0|byte(rec[1]>>24)&0x0f,
+				byte(rec[1]>>16), byte(rec[1]>>8), byte(rec[1]))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, uint32(rec[0]))
+			tree = binary.BigEndian.AppendUint32(tree, uint32(rec[1]))
+		}
+	}
+
+	meta := map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1760745600),
+		"database_type":               "GeoLite2-City",
+		"description":                 map[string]any{"en": "Test database"},
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []any{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	}
+	out := append(tree, make([]byte, 16)...)
+	out = append(out, data...)
+	out = append(out, metadataMarker...)
+	return append(out, encode(meta)...)
+}
+
+// sharedNames is data written ahead of the records so they can point
+// at it, as real databases do for repeated values
+var sharedNames = encode(map[string]any{"en": "United States", "de": "USA"})
+
+var testRecords = map[string]map[string]any{
+	"8.8.8.0/24": {
+		"city":     map[string]any{"names": map[string]any{"en": "Mountain View"}},
+		"country":  map[string]any{"iso_code": "US", "names": pointerTo(0)},
+		"location": map[string]any{"latitude": 37.4056, "longitude": -122.0775, "time_zone": "America/Los_Angeles", "accuracy_radius": uint16(1000)},
+	},
+	"1.1.1.0/24": {
+		"country":    map[string]any{"iso_code": "AU"},
+		"registered": map[string]any{"is_in_european_union": false},
+		"anycast":    true,
+		"rank":       int32(-3),
+		"score":      float32(0.5),
+		"raw":        []byte{1, 2},
+		"big":        uint64(1) << 40,
+		"subdivisions": []any{
+			map[string]any{"iso_code": "NSW", "names": map[string]any{"en": "New South Wales"}},
+		},
+	},
+	"2001:4860::/32": {
+		"country": map[string]any{"iso_code": "US", "names": pointerTo(0)},
+	},
+}
+
+func TestLookup(t *testing.T) {
+	for _, size := range []int{24, 28, 32} {
+		r, err := FromBytes(buildDB(t, size, 6, testRecords, sharedNames))
+		if err != nil {
+			t.Fatalf("record size %d: FromBytes() error = %v", size, err)
+		}
+
+		rec, network, ok, err := r.Lookup(netip.MustParseAddr("8.8.8.8"))
+		if err != nil || !ok {
+			t.Fatalf("record size %d: Lookup(8.8.8.8) = %v, %v", size, ok, err)
+		}
+		if network.String() != "8.8.8.0/24" {
+			t.Errorf("record size %d: network = %s, want 8.8.8.0/24", size, network)
+		}
+		city := rec["city"].(map[string]any)["names"].(map[string]any)["en"]
+		country := rec["country"].(map[string]any)["names"].(map[string]any)["en"]
+		lat := rec["location"].(map[string]any)["latitude"]
+		if city != "Mountain View" || country != "United States" || lat != 37.4056 {
+			t.Errorf("record size %d: unexpected record %v", size, rec)
+		}
+
+		if rec, _, ok, _ := r.Lookup(netip.MustParseAddr("2001:4860:4860::8888")); !ok || rec["country"].(map[string]any)["iso_code"] != "US" {
+			t.Errorf("record size %d: IPv6 lookup = %v", size, rec)
+		}
+		// IPv4-mapped addresses are looked up as IPv4
+		if _, network, ok, _ := r.Lookup(netip.MustParseAddr("::ffff:1.1.1.1")); !ok || network.String() != "1.1.1.0/24" {
+			t.Errorf("record size %d: mapped lookup = %v %s", size, ok, network)
+		}
+		if _, _, ok, err := r.Lookup(netip.MustParseAddr("9.9.9.9")); ok || err != nil {
+			t.Errorf("record size %d: expected no data for 9.9.9.9, got %v %v", size, ok, err)
+		}
+	}
+}
+
+func TestDecodeTypes(t *testing.T) {
+	r, err := FromBytes(buildDB(t, 24, 6, testRecords, sharedNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, _, _, err := r.Lookup(netip.MustParseAddr("1.1.1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec["anycast"] != true || rec["rank"] != int32(-3) || rec["score"] != float32(0.5) || rec["big"] != uint64(1)<<40 {
+		t.Errorf("unexpected scalars: %v", rec)
+	}
+	if raw, _ := rec["raw"].([]byte); len(raw) != 2 || raw[1] != 2 {
+		t.Errorf("unexpected bytes: %v", rec["raw"])
+	}
+	if eu := rec["registered"].(map[string]any)["is_in_european_union"]; eu != false {
+		t.Errorf("unexpected bool: %v", eu)
+	}
+	subs := rec["subdivisions"].([]any)
+	if len(subs) != 1 || subs[0].(map[string]any)["iso_code"] != "NSW" {
+		t.Errorf("unexpected array: %v", subs)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	r, err := FromBytes(buildDB(t, 28, 6, testRecords, sharedNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := r.Metadata()
+	if m.DatabaseType != "GeoLite2-City" || m.Description != "Test database" || m.IPVersion != 6 || m.RecordSize != 28 ||
+		m.Built.Unix() != 1760745600 || len(m.Languages) != 1 || m.NodeCount == 0 {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+}
+
+func TestIPv4Database(t *testing.T) {
+	records := map[string]map[string]any{"10.0.0.0/8": {"country": map[string]any{"iso_code": "ZZ"}}}
+	r, err := FromBytes(buildDB(t, 24, 4, records, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, network, ok, err := r.Lookup(netip.MustParseAddr("10.1.2.3")); !ok || err != nil || network.String() != "10.0.0.0/8" {
+		t.Errorf("Lookup(10.1.2.3) = %s %v %v", network, ok, err)
+	}
+	if _, _, _, err := r.Lookup(netip.MustParseAddr("2001:db8::1")); err == nil {
+		t.Error("Expected an error looking up IPv6 in an IPv4 database")
+	}
+}
+
+func TestLongStringsAndPointers(t *testing.T) {
+	// Sizes past the one-, two- and three-byte thresholds
+	for _, n := range []int{28, 29, 284, 285, 65820, 65821, 70000} {
+		s := string(make([]byte, n))
+		d := decoder{buf: encode(s)}
+		v, next, err := d.decode(0)
+		if err != nil || len(v.(string)) != n || next != len(d.buf) {
+			t.Errorf("string of %d bytes: got %d bytes, next %d, err %v", n, len(v.(string)), next, err)
+		}
+	}
+
+	// Pointers of each width, resolved against a padded buffer
+	for _, ptr := range []int{10, 3000, 600000} {
+		buf := make([]byte, ptr+16)
+		copy(buf[ptr:], encode("target"))
+		p := encodePointer(ptr)
+		buf = append(buf, p...)
+		d := decoder{buf: buf}
+		v, next, err := d.decode(ptr + 16)
+		if err != nil || v != "target" || next != len(buf) {
+			t.Errorf("pointer %d: got %v, next %d, err %v", ptr, v, next, err)
+		}
+	}
+}
+
+func TestCorruptFiles(t *testing.T) {
+	good := buildDB(t, 24, 6, testRecords, sharedNames)
+
+	if _, err := FromBytes([]byte("not a database")); err == nil {
+		t.Error("Expected an error without a metadata marker")
+	}
+	if _, err := FromBytes(good[len(good)-40:]); err == nil {
+		t.Error("Expected an error when the tree does not fit")
+	}
+
+	// Point the root's right record past the data section
+	bad := append([]byte{}, good...)
+	bad[3], bad[4], bad[5] = 0xff, 0xff, 0xff
+	r, err := FromBytes(bad)
+	if err == nil {
+		if _, _, _, err := r.Lookup(netip.MustParseAddr("8000::1")); err == nil {
+			t.Error("Expected an error for an out-of-range data pointer")
+		}
+	}
+
+	// Deeply nested arrays
+	deep := []byte{}
+	for i := 0; i < maxDepth+2; i++ {
+		deep = append(deep, encodeCtrl(typeArray, 1)...)
+	}
+	deep = append(deep, encode("x")...)
+	if _, _, err := (&decoder{buf: deep}).decode(0); err == nil {
+		t.Error("Expected an error for data nested too deeply")
+	}
+	if _, _, err := (&decoder{buf: encodeCtrl(typeString, 10)}).decode(0); err == nil {
+		t.Error("Expected an error for a truncated string")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	if err := os.WriteFile(path, buildDB(t, 24, 6, testRecords, sharedNames), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Errorf("Open() error = %v", err)
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+// benchmarkDB builds an IPv6 database of 2^16 /24 networks with City
+// records, the layout and record size of GeoLite2-City
+func benchmarkDB(b *testing.B) *Reader {
+	records := make(map[string]map[string]any, 1<<16)
+	for i := 0; i < 1<<16; i++ {
+		network := netip.PrefixFrom(netip.AddrFrom4([4]byte{byte(i >> 8), byte(i), 0, 0}), 24)
+		records[network.String()] = map[string]any{
+			"city":     map[string]any{"names": map[string]any{"en": "Mountain View"}},
+			"country":  map[string]any{"iso_code": "US", "names": pointerTo(0)},
+			"location": map[string]any{"latitude": 37.4056, "longitude": -122.0775, "time_zone": "America/Los_Angeles"},
+		}
+	}
+	r, err := FromBytes(buildDB(b, 28, 6, records, sharedNames))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return r
+}
+
+// benchmarkAddrs are spread over the networks of benchmarkDB
+func benchmarkAddrs() []netip.Addr {
+	addrs := make([]netip.Addr, 1024)
+	for i := range addrs {
+		addrs[i] = netip.AddrFrom4([4]byte{byte(i * 7), byte(i * 13), 0, byte(i)})
+	}
+	return addrs
+}
+
+// BenchmarkLookup measures a full lookup: the tree walk and decoding
+// the record
+func BenchmarkLookup(b *testing.B) {
+	r := benchmarkDB(b)
+	addrs := benchmarkAddrs()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := r.Lookup(addrs[i%len(addrs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLookupTree measures the tree walk alone
+func BenchmarkLookupTree(b *testing.B) {
+	r := benchmarkDB(b)
+	addrs := benchmarkAddrs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := r.lookupOffset(addrs[i%len(addrs)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
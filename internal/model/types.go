@@ -37,11 +37,27 @@ type Report struct {
 		ISP         string  `json:"isp,omitempty"`
 		ASN         string  `json:"asn,omitempty"`
 		ASName      string  `json:"as_name,omitempty"`
+		Prefix      string  `json:"prefix,omitempty"`     // announced BGP prefix
+		Registry    string  `json:"registry,omitempty"`   // RIR that allocated it
+		Allocated   string  `json:"allocated,omitempty"`  // allocation date
+		ASNSource   string  `json:"asn_source,omitempty"` // cymru, asndb or mmdb
 		Latitude    float64 `json:"lat,omitempty"`
 		Longitude   float64 `json:"lon,omitempty"`
 		Timezone    string  `json:"timezone,omitempty"`
+
+		// Geolocation provider behind each field, keyed by its JSON name
+		Sources map[string]string `json:"sources,omitempty"`
 	} `json:"geo"`
 
+	// How far the geolocation can be trusted
+	GeoCheck *GeoCheck `json:"geo_check,omitempty"`
+
+	// Autonomous system, when the target is an AS number ("AS15169")
+	AS *ASInfo `json:"as,omitempty"`
+
+	// RPKI origin validation of the announced prefix (needs a VRP file)
+	RPKI *RPKIResult `json:"rpki,omitempty"`
+
 	// WHOIS (raw + parsed top fields)
 	WhoisRaw string `json:"whois_raw,omitempty"`
 	Whois    struct {
@@ -55,8 +71,34 @@ type Report struct {
 		OrgName     string   `json:"org_name,omitempty"`
 		Country     string   `json:"country,omitempty"`
 		AbuseEmails []string `json:"abuse_emails,omitempty"`
+		Source      string   `json:"source,omitempty"` // rdap or whois
+
+		// Created and Expires parsed from whatever format the registry
+		// printed, with the domain's age and remaining registration
+		CreatedAt       time.Time `json:"created_at,omitzero"`
+		ExpiresAt       time.Time `json:"expires_at,omitzero"`
+		AgeDays         *int      `json:"age_days,omitempty"`
+		DaysToExpiry    *int      `json:"days_to_expiry,omitempty"` // negative once expired
+		NewlyRegistered bool      `json:"newly_registered,omitempty"`
+		ExpiringSoon    bool      `json:"expiring_soon,omitempty"` // includes expired
+
+		// IP registrations, from the RIR's objects
+		Registry string        `json:"registry,omitempty"` // arin, ripe, apnic, lacnic or afrinic
+		Network  *WhoisNetwork `json:"network,omitempty"`  // most specific network
+		Parent   *WhoisNetwork `json:"parent,omitempty"`
+		Org      *WhoisOrg     `json:"org,omitempty"`
+		Abuse    *WhoisContact `json:"abuse,omitempty"`
 	} `json:"whois"`
 
+	// Port-43 servers queried for the WHOIS data, in referral order
+	WhoisChain []WhoisResponse `json:"whois_chain,omitempty"`
+
+	// RDAP (structured registration data, when the registry answered)
+	RDAP *RDAPResult `json:"rdap,omitempty"`
+
+	// Abuse contacts from every source, most authoritative first
+	AbuseContacts []AbuseContact `json:"abuse_contacts,omitempty"`
+
 	// Ping
 	Ping struct {
 		PacketsSent     int     `json:"sent"`
@@ -66,17 +108,33 @@ type Report struct {
 		AvgRtt          string  `json:"avg_rtt"`
 		MaxRtt          string  `json:"max_rtt"`
 		StdDevRtt       string  `json:"stddev_rtt,omitempty"`
+		Method          string  `json:"method,omitempty"` // icmp, tcp, http or https
+		Port            int     `json:"port,omitempty"`   // destination port for tcp/http pings
 		Success         bool    `json:"success"`
 		Error           string  `json:"error,omitempty"`
 	} `json:"ping"`
 
 	// Traceroute
 	Trace struct {
-		Hops    []TraceHop `json:"hops,omitempty"`
-		Success bool       `json:"success"`
-		Error   string     `json:"error,omitempty"`
+		Hops    []TraceHop  `json:"hops,omitempty"`
+		Graph   *TraceGraph `json:"graph,omitempty"` // multipath mode only
+		Success bool        `json:"success"`
+		Error   string      `json:"error,omitempty"`
 	}
 
+	// Path MTU discovery (only when --mtu)
+	MTU struct {
+		PathMTU        int    `json:"path_mtu,omitempty"`
+		LocalMTU       int    `json:"local_mtu,omitempty"`
+		Method         string `json:"method,omitempty"`           // icmp, udp or tcp
+		FragNeededFrom string `json:"frag_needed_from,omitempty"` // hop that sent Fragmentation Needed
+		FragNeededMTU  int    `json:"frag_needed_mtu,omitempty"`
+		BlackHole      bool   `json:"black_hole,omitempty"` // large probes dropped without an ICMP error
+		Incomplete     bool   `json:"incomplete,omitempty"` // out of time; path_mtu is a lower bound
+		Probes         int    `json:"probes,omitempty"`
+		Error          string `json:"error,omitempty"`
+	} `json:"mtu,omitzero"`
+
 	// Port scan (only when --ports)
 	Ports struct {
 		Scanned  []int  `json:"scanned_ports,omitempty"`
@@ -106,10 +164,323 @@ type Report struct {
 // Helper types
 type TraceHop struct {
 	Hop     int    `json:"hop"`
-	IP      string `json:"ip,omitempty"`
-	Host    string `json:"host,omitempty"`
-	RTT     string `json:"rtt,omitempty"` // "12.4ms" or "*"
+	IP      string `json:"ip,omitempty"`   // first responder
+	Host    string `json:"host,omitempty"` // PTR name of the first responder
+	RTT     string `json:"rtt,omitempty"`  // first RTT, "12.4ms" or "*"
 	Timeout bool   `json:"timeout,omitempty"`
+
+	// Every probe sent at this TTL, in send order
+	Probes []TraceProbe `json:"probes,omitempty"`
+	// Distinct responders in the order first seen; more than one
+	// means load-balanced (ECMP) paths at this TTL
+	Responders []string `json:"responders,omitempty"`
+	Sent       int      `json:"sent,omitempty"`
+	Received   int      `json:"received,omitempty"`
+	LossPct    float64  `json:"loss_percent,omitempty"`
+
+	// ICMP extensions (RFC 4950, RFC 5837) from the first responder
+	MPLS       []MPLSLabel    `json:"mpls,omitempty"`
+	Interfaces []HopInterface `json:"interfaces,omitempty"`
+	// QuotedTTL > 1 means the probe crossed routers that did not
+	// decrement its IP TTL, typically inside an MPLS tunnel
+	QuotedTTL int `json:"quoted_ttl,omitempty"`
+
+	// Enrichment for the first responder
+	ASN        string `json:"asn,omitempty"`
+	ASName     string `json:"as_name,omitempty"`
+	Country    string `json:"country,omitempty"`     // ISO code from the ASN registry
+	Class      string `json:"class,omitempty"`       // public, private, cgnat, loopback, link-local
+	ASBoundary bool   `json:"as_boundary,omitempty"` // first hop seen in this AS
+}
+
+// TraceRun is one stored traceroute (ng trace history)
+type TraceRun struct {
+	Target    string     `json:"target"`
+	IP        string     `json:"ip,omitempty"`
+	Protocol  string     `json:"protocol,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+	Hops      []TraceHop `json:"hops"`
+}
+
+// TraceGraph is the set of load-balanced paths found by a multipath
+// traceroute. Each flow is one constant flow identifier (source port or
+// ICMP checksum); nodes and edges list the flows that crossed them.
+type TraceGraph struct {
+	Flows int         `json:"flows"`
+	Nodes []TraceNode `json:"nodes"`
+	Edges []TraceEdge `json:"edges"`
+}
+
+// TraceNode is one responder at one TTL
+type TraceNode struct {
+	Hop   int    `json:"hop"`
+	IP    string `json:"ip"`
+	Flows []int  `json:"flows"`
+}
+
+// TraceEdge links consecutive responders of a flow. ToHop - FromHop is
+// more than one when the hops in between did not answer.
+type TraceEdge struct {
+	FromHop int    `json:"from_hop"`
+	From    string `json:"from"`
+	ToHop   int    `json:"to_hop"`
+	To      string `json:"to"`
+	Flows   []int  `json:"flows"`
+}
+
+// TraceProbe is the outcome of a single probe at one TTL
+type TraceProbe struct {
+	IP         string  `json:"ip,omitempty"`
+	RTTMs      float64 `json:"rtt_ms,omitempty"`
+	Timeout    bool    `json:"timeout,omitempty"`
+	Annotation string  `json:"annotation,omitempty"` // traceroute flag such as "!H" or "!N"
+
+	MPLS       []MPLSLabel    `json:"mpls,omitempty"`
+	Interfaces []HopInterface `json:"interfaces,omitempty"`
+	QuotedTTL  int            `json:"quoted_ttl,omitempty"` // only recorded when above 1
+}
+
+// MPLSLabel is one entry of an RFC 4950 label stack, outermost first
+type MPLSLabel struct {
+	Label  int  `json:"label"`
+	TC     int  `json:"tc,omitempty"`
+	Bottom bool `json:"bottom,omitempty"` // S bit
+	TTL    int  `json:"ttl"`
+}
+
+// HopInterface is RFC 5837 interface information from an ICMP error
+type HopInterface struct {
+	Role  string `json:"role"` // incoming, sub-ip, outgoing or next-hop
+	Index int    `json:"ifindex,omitempty"`
+	Name  string `json:"name,omitempty"`
+	IP    string `json:"ip,omitempty"`
+	MTU   int    `json:"mtu,omitempty"`
+}
+
+// WhoisResponse is one server's answer in a WHOIS referral chain, or
+// one hop of an RDAP lookup
+type WhoisResponse struct {
+	Server     string `json:"server"` // host:port, or an RDAP base URL
+	Query      string `json:"query"`
+	Raw        string `json:"raw,omitempty"`
+	Referral   string `json:"referral,omitempty"` // next server named in the answer
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WhoisNetwork is an address block registration (inetnum, inet6num or
+// an ARIN NetRange)
+type WhoisNetwork struct {
+	Handle  string   `json:"handle,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Range   string   `json:"range,omitempty"` // "first - last"
+	CIDRs   []string `json:"cidrs,omitempty"`
+	Type    string   `json:"type,omitempty"` // allocation status, e.g. ASSIGNED PA
+	Descr   string   `json:"descr,omitempty"`
+	Country string   `json:"country,omitempty"`
+	Org     string   `json:"org,omitempty"`    // handle of the holding organisation
+	Origin  string   `json:"origin,omitempty"` // announcing AS, from a route object
+	Created string   `json:"created,omitempty"`
+	Updated string   `json:"updated,omitempty"`
+}
+
+// WhoisOrg is the organisation holding a network
+type WhoisOrg struct {
+	Handle  string `json:"handle,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Country string `json:"country,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// WhoisContact is a person or role object, such as the abuse contact
+type WhoisContact struct {
+	Handle string   `json:"handle,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Emails []string `json:"emails,omitempty"`
+	Phone  string   `json:"phone,omitempty"`
+	Source string   `json:"source,omitempty"` // how an abuse contact was found
+}
+
+// AbuseContact is one address to send abuse reports to. Report lists
+// them most authoritative first.
+type AbuseContact struct {
+	Email    string `json:"email"`
+	Source   string `json:"source"` // rdap, abuse-c, irt, registrar, abuse.net, ...
+	Handle   string `json:"handle,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Registry string `json:"registry,omitempty"`
+}
+
+// ASInfo describes an autonomous system given as the target
+type ASInfo struct {
+	ASN          string   `json:"asn"`
+	Name         string   `json:"name,omitempty"`
+	Country      string   `json:"country,omitempty"`
+	Registry     string   `json:"registry,omitempty"` // RIR that allocated it
+	Allocated    string   `json:"allocated,omitempty"`
+	Prefixes     []string `json:"prefixes,omitempty"` // originated prefixes, from the offline ASN database
+	IPv4Prefixes int      `json:"ipv4_prefixes"`
+	IPv6Prefixes int      `json:"ipv6_prefixes"`
+}
+
+// GeoCheck weighs the geolocation against the other providers' answers
+// and against the ping RTT measured from a known vantage point
+type GeoCheck struct {
+	Confidence  int         `json:"confidence"`            // 0-100
+	Implausible bool        `json:"implausible,omitempty"` // the RTT rules the location out
+	Findings    []string    `json:"findings,omitempty"`
+	Answers     []GeoAnswer `json:"answers,omitempty"`   // each provider's answer, when several were compared
+	SpreadKm    float64     `json:"spread_km,omitempty"` // farthest apart two answers are
+
+	// Speed-of-light check
+	Vantage       string  `json:"vantage,omitempty"`
+	MinRTTMs      float64 `json:"min_rtt_ms,omitempty"`
+	MaxDistanceKm float64 `json:"max_distance_km,omitempty"` // farthest the RTT allows
+	DistanceKm    float64 `json:"distance_km,omitempty"`     // vantage to the claimed location
+}
+
+// GeoAnswer is what one geolocation provider said
+type GeoAnswer struct {
+	Provider    string  `json:"provider"`
+	City        string  `json:"city,omitempty"`
+	CountryCode string  `json:"country_code,omitempty"`
+	Latitude    float64 `json:"lat,omitempty"`
+	Longitude   float64 `json:"lon,omitempty"`
+}
+
+// RPKIResult is the route origin validation (RFC 6811) of the target's
+// announced prefix and origin AS against a local VRP export
+type RPKIResult struct {
+	State   string    `json:"state"`            // valid, invalid or not-found
+	Reason  string    `json:"reason,omitempty"` // why an invalid route is invalid
+	Prefix  string    `json:"prefix"`
+	Origin  string    `json:"origin_asn"`
+	ROAs    []RPKIROA `json:"roas,omitempty"` // the matching ROAs, or every covering one when invalid
+	VRPFile string    `json:"vrp_file"`
+
+	// Approximate is set when Prefix is an offline database's range, as
+	// the announced route could not be looked up
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// RPKIROA is one validated ROA payload
+type RPKIROA struct {
+	ASN       string `json:"asn"`
+	Prefix    string `json:"prefix"`
+	MaxLength int    `json:"max_length"`
+	TA        string `json:"ta,omitempty"` // trust anchor
+}
+
+// RDAPResult is the structured answer from an RDAP server for a domain,
+// IP network or autonomous system
+type RDAPResult struct {
+	Server      string       `json:"server"`       // base URL that was queried
+	ObjectClass string       `json:"object_class"` // domain, ip network or autnum
+	Handle      string       `json:"handle,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Status      []string     `json:"status,omitempty"`
+	Events      []RDAPEvent  `json:"events,omitempty"`
+	Entities    []RDAPEntity `json:"entities,omitempty"`
+	Nameservers []string     `json:"nameservers,omitempty"`
+
+	// IP networks
+	StartAddress string   `json:"start_address,omitempty"`
+	EndAddress   string   `json:"end_address,omitempty"`
+	CIDRs        []string `json:"cidrs,omitempty"`
+	ParentHandle string   `json:"parent_handle,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Country      string   `json:"country,omitempty"`
+
+	// Autonomous systems
+	StartAutnum uint32 `json:"start_autnum,omitempty"`
+	EndAutnum   uint32 `json:"end_autnum,omitempty"`
+}
+
+// RDAPEvent is a dated lifecycle event such as registration or expiration
+type RDAPEvent struct {
+	Action string `json:"action"`
+	Date   string `json:"date"`
+	Actor  string `json:"actor,omitempty"`
+}
+
+// RDAPEntity is a contact attached to an RDAP object. Nested entities
+// are flattened into the result's list.
+type RDAPEntity struct {
+	Handle  string   `json:"handle,omitempty"`
+	Roles   []string `json:"roles"`
+	Kind    string   `json:"kind,omitempty"` // individual, org, group
+	Name    string   `json:"name,omitempty"`
+	Org     string   `json:"org,omitempty"`
+	Emails  []string `json:"emails,omitempty"`
+	Phones  []string `json:"phones,omitempty"`
+	Address string   `json:"address,omitempty"`
+	Country string   `json:"country,omitempty"`
+	IANAID  string   `json:"iana_id,omitempty"` // registrar IANA ID
+}
+
+// WatchStats is a rolling summary of a continuous ping run (ng watch).
+// Loss and latency figures cover the most recent Window probes; Sent
+// and Received are totals since the run started.
+type WatchStats struct {
+	Target    string    `json:"target"`
+	IP        string    `json:"ip"`
+	Method    string    `json:"method"` // icmp or tcp
+	Port      int       `json:"port,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Window   int     `json:"window"`
+	LossPct  float64 `json:"loss_percent"`
+
+	LastOK bool    `json:"last_ok"`
+	LastMs float64 `json:"last_ms,omitempty"`
+	MinMs  float64 `json:"min_ms,omitempty"`
+	AvgMs  float64 `json:"avg_ms,omitempty"`
+	MaxMs  float64 `json:"max_ms,omitempty"`
+	P50Ms  float64 `json:"p50_ms,omitempty"`
+	P90Ms  float64 `json:"p90_ms,omitempty"`
+	P99Ms  float64 `json:"p99_ms,omitempty"`
+
+	Outages []Outage `json:"outages,omitempty"`
+}
+
+// MTRReport is the per-hop summary of a continuous traceroute (ng mtr)
+type MTRReport struct {
+	Target    string    `json:"target"`
+	IP        string    `json:"ip"`
+	Protocol  string    `json:"protocol"` // udp, icmp or tcp
+	Timestamp time.Time `json:"timestamp"`
+	Cycles    int       `json:"cycles"`
+	Hops      []MTRHop  `json:"hops"`
+}
+
+// MTRHop holds the statistics for one TTL across all cycles so far
+type MTRHop struct {
+	Hop        int      `json:"hop"`
+	IP         string   `json:"ip,omitempty"` // most recent responder
+	Host       string   `json:"host,omitempty"`
+	ASN        string   `json:"asn,omitempty"`
+	ASName     string   `json:"as_name,omitempty"`
+	Responders []string `json:"responders,omitempty"`
+
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	LossPct  float64 `json:"loss_percent"`
+
+	LastMs   float64 `json:"last_ms,omitempty"`
+	AvgMs    float64 `json:"avg_ms,omitempty"`
+	BestMs   float64 `json:"best_ms,omitempty"`
+	WorstMs  float64 `json:"worst_ms,omitempty"`
+	StdDevMs float64 `json:"stddev_ms,omitempty"`
+}
+
+// Outage is a run of consecutive lost probes.
+// End is zero while the outage is still ongoing.
+type Outage struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end,omitzero"`
+	Lost  int       `json:"lost"`
 }
 
 // ValidateTarget validates and normalizes the input target
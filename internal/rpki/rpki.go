@@ -0,0 +1,281 @@
+// Package rpki performs route origin validation (RFC 6811) against a
+// local export of validated ROA payloads, as written by routinator or
+// rpki-client in their JSON and CSV formats.
+package rpki
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Validation states
+const (
+	StateValid    = "valid"
+	StateInvalid  = "invalid"
+	StateNotFound = "not-found"
+)
+
+// Why a route is invalid
+const (
+	ReasonASN    = "origin AS not authorised"      // no covering ROA names the origin
+	ReasonLength = "prefix longer than max length" // a ROA names the origin, but not this specific
+)
+
+// VRP is one validated ROA payload: asn may originate prefix and any
+// more specific route up to MaxLength bits
+type VRP struct {
+	ASN       uint32
+	Prefix    netip.Prefix
+	MaxLength int
+	TA        string // trust anchor, e.g. ripe or arin
+}
+
+func (v VRP) String() string {
+	return fmt.Sprintf("AS%d %s max /%d", v.ASN, v.Prefix, v.MaxLength)
+}
+
+// Set is a loaded VRP export, indexed by prefix
+type Set struct {
+	byPrefix map[netip.Prefix][]VRP
+	count    int
+}
+
+// Result is the outcome of validating one route
+type Result struct {
+	State  string
+	Reason string // invalid routes only
+	// The ROAs that made the route valid, or for an invalid route every
+	// ROA covering it
+	VRPs []VRP
+}
+
+// Len returns how many VRPs the set holds
+func (s *Set) Len() int {
+	return s.count
+}
+
+// Load reads a VRP export from path
+func Load(path string) (*Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse reads a routinator or rpki-client export, JSON or CSV
+func Parse(r io.Reader) (*Set, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("empty VRP file")
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == 0xef || b == 0xbb || b == 0xbf {
+			continue // whitespace and a UTF-8 BOM
+		}
+		br.UnreadByte()
+		if b == '{' {
+			return parseJSON(br)
+		}
+		return parseCSV(br)
+	}
+}
+
+// jsonASN accepts both "AS13335" (routinator) and 13335 (rpki-client)
+type jsonASN uint32
+
+func (a *jsonASN) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	asn, err := parseASN(s)
+	if err != nil {
+		return err
+	}
+	*a = jsonASN(asn)
+	return nil
+}
+
+func parseJSON(r io.Reader) (*Set, error) {
+	var export struct {
+		ROAs []struct {
+			ASN       jsonASN `json:"asn"`
+			Prefix    string  `json:"prefix"`
+			MaxLength int     `json:"maxLength"`
+			TA        string  `json:"ta"`
+		} `json:"roas"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid VRP JSON: %w", err)
+	}
+	s := newSet()
+	for i, roa := range export.ROAs {
+		if err := s.add(uint32(roa.ASN), roa.Prefix, roa.MaxLength, roa.TA); err != nil {
+			return nil, fmt.Errorf("roa %d: %w", i, err)
+		}
+	}
+	if s.count == 0 {
+		return nil, fmt.Errorf("no VRPs found")
+	}
+	return s, nil
+}
+
+func parseCSV(r io.Reader) (*Set, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+
+	// Column order of both tools' plain CSV; a header row can reorder it
+	asnCol, prefixCol, maxCol, taCol := 0, 1, 2, 3
+
+	s := newSet()
+	for first := true; ; first = false {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid VRP CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		if first {
+			if _, err := parseASN(rec[0]); err != nil {
+				asnCol, prefixCol, maxCol, taCol = -1, -1, -1, -1
+				for i, name := range rec {
+					switch strings.ToLower(strings.TrimSpace(name)) {
+					case "asn":
+						asnCol = i
+					case "ip prefix", "prefix":
+						prefixCol = i
+					case "max length", "maxlength":
+						maxCol = i
+					case "trust anchor", "ta":
+						taCol = i
+					}
+				}
+				if asnCol < 0 || prefixCol < 0 {
+					return nil, fmt.Errorf("line %d: not a VRP export header: %q", line, strings.Join(rec, ","))
+				}
+				continue
+			}
+		}
+
+		field := func(i int) string {
+			if i < 0 || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		asn, err := parseASN(field(asnCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		maxLength := 0
+		if v := field(maxCol); v != "" {
+			if maxLength, err = strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid max length %q", line, v)
+			}
+		}
+		if err := s.add(asn, field(prefixCol), maxLength, field(taCol)); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if s.count == 0 {
+		return nil, fmt.Errorf("no VRPs found")
+	}
+	return s, nil
+}
+
+func parseASN(s string) (uint32, error) {
+	v := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	asn, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return uint32(asn), nil
+}
+
+func newSet() *Set {
+	return &Set{byPrefix: make(map[netip.Prefix][]VRP)}
+}
+
+func (s *Set) add(asn uint32, prefix string, maxLength int, ta string) error {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return fmt.Errorf("invalid prefix %q", prefix)
+	}
+	p = p.Masked()
+	if maxLength == 0 {
+		maxLength = p.Bits()
+	}
+	if maxLength < p.Bits() || maxLength > p.Addr().BitLen() {
+		return fmt.Errorf("invalid max length %d for %s", maxLength, p)
+	}
+	s.byPrefix[p] = append(s.byPrefix[p], VRP{ASN: asn, Prefix: p, MaxLength: maxLength, TA: ta})
+	s.count++
+	return nil
+}
+
+// Covering returns every VRP whose prefix contains route, least
+// specific first
+func (s *Set) Covering(route netip.Prefix) []VRP {
+	route = route.Masked()
+	var out []VRP
+	for bits := 0; bits <= route.Bits(); bits++ {
+		p, _ := route.Addr().Prefix(bits)
+		out = append(out, s.byPrefix[p]...)
+	}
+	return out
+}
+
+// Validate decides whether origin may announce route. A route is valid
+// when a covering VRP names the origin and allows its length, invalid
+// when VRPs cover it but none match, and not-found otherwise. AS0 VRPs
+// never match (RFC 7607).
+func (s *Set) Validate(route netip.Prefix, origin uint32) Result {
+	covering := s.Covering(route)
+	if len(covering) == 0 {
+		return Result{State: StateNotFound}
+	}
+
+	var matched []VRP
+	originListed := false
+	for _, v := range covering {
+		if v.ASN != origin || origin == 0 {
+			continue
+		}
+		originListed = true
+		if route.Bits() <= v.MaxLength {
+			matched = append(matched, v)
+		}
+	}
+	if len(matched) > 0 {
+		return Result{State: StateValid, VRPs: matched}
+	}
+
+	// Most specific first, as the likeliest explanation
+	sort.SliceStable(covering, func(i, j int) bool {
+		return covering[i].Prefix.Bits() > covering[j].Prefix.Bits()
+	})
+	res := Result{State: StateInvalid, Reason: ReasonASN, VRPs: covering}
+	if originListed {
+		res.Reason = ReasonLength
+	}
+	return res
+}
@@ -0,0 +1,140 @@
+package rpki
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const routinatorJSON = `{
+  "metadata": {"generated": 1760745600, "generatedTime": "2025-10-18T00:00:00Z"},
+  "roas": [
+    {"asn": "AS13335", "prefix": "1.1.1.0/24", "maxLength": 24, "ta": "apnic"},
+    {"asn": "AS15169", "prefix": "8.8.8.0/24", "maxLength": 24, "ta": "arin"},
+    {"asn": "AS3333", "prefix": "193.0.0.0/21", "maxLength": 21, "ta": "ripe"},
+    {"asn": "AS0", "prefix": "192.0.2.0/24", "maxLength": 32, "ta": "arin"},
+    {"asn": "AS15169", "prefix": "2001:4860::/32", "maxLength": 48, "ta": "arin"}
+  ]
+}`
+
+const rpkiClientJSON = `{
+  "metadata": {"buildtime": "2025-10-18T00:00:00Z", "vrps": 1},
+  "roas": [
+    {"asn": 13335, "prefix": "1.1.1.0/24", "maxLength": 24, "ta": "apnic", "expires": 1760832000}
+  ]
+}`
+
+const routinatorCSV = `ASN,IP Prefix,Max Length,Trust Anchor
+AS13335,1.1.1.0/24,24,apnic
+AS15169,8.8.8.0/24,24,arin
+AS15169,2001:4860::/32,48,arin
+`
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{"routinator json", routinatorJSON, 5},
+		{"rpki-client json", rpkiClientJSON, 1},
+		{"routinator csv", routinatorCSV, 3},
+		{"rpki-client csv", "ASN,IP Prefix,Max Length,Trust Anchor,Expires\nAS13335,1.1.1.0/24,24,apnic,1760832000\n", 1},
+		{"csv without header", "AS13335,1.1.1.0/24,24,apnic\n", 1},
+		{"reordered header", "\ufeffTrust Anchor,IP Prefix,ASN\napnic,1.1.1.0/24,13335\n", 1},
+	}
+	for _, tt := range tests {
+		s, err := Parse(strings.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: Parse() error = %v", tt.name, err)
+			continue
+		}
+		if s.Len() != tt.want {
+			t.Errorf("%s: got %d VRPs, want %d", tt.name, s.Len(), tt.want)
+		}
+		if res := s.Validate(netip.MustParsePrefix("1.1.1.0/24"), 13335); res.State != StateValid {
+			t.Errorf("%s: 1.1.1.0/24 AS13335 = %+v, want valid", tt.name, res)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		`{"roas": []}`,
+		`{"roas": [{"asn": "ASX", "prefix": "1.1.1.0/24", "maxLength": 24}]}`,
+		`{"roas": [{"asn": "AS1", "prefix": "1.1.1.0/24", "maxLength": 16}]}`,
+		"Name,Value\nfoo,bar\n",
+		"AS13335,1.1.1.0/33,24,apnic\n",
+		"AS13335,1.1.1.0/24,twenty,apnic\n",
+	} {
+		if _, err := Parse(strings.NewReader(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", input)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Parse(strings.NewReader(routinatorJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		route  string
+		origin uint32
+		state  string
+		reason string
+		vrps   int
+	}{
+		{"8.8.8.0/24", 15169, StateValid, "", 1},
+		{"8.8.8.0/24", 64500, StateInvalid, ReasonASN, 1},
+		{"193.0.4.0/24", 3333, StateInvalid, ReasonLength, 1},
+		{"192.0.2.0/24", 0, StateInvalid, ReasonASN, 1},
+		{"2001:4860:4860::/48", 15169, StateValid, "", 1},
+		{"2001:4860:4860::/64", 15169, StateInvalid, ReasonLength, 1},
+		{"9.9.9.0/24", 19281, StateNotFound, "", 0},
+		{"2a00::/16", 15169, StateNotFound, "", 0},
+	}
+	for _, tt := range tests {
+		res := s.Validate(netip.MustParsePrefix(tt.route), tt.origin)
+		if res.State != tt.state || res.Reason != tt.reason || len(res.VRPs) != tt.vrps {
+			t.Errorf("Validate(%s, AS%d) = %+v, want %s %q with %d VRPs", tt.route, tt.origin, res, tt.state, tt.reason, tt.vrps)
+		}
+	}
+}
+
+func TestValidateCoveringOrder(t *testing.T) {
+	s, err := Parse(strings.NewReader("AS64500,10.0.0.0/8,8\nAS64501,10.1.0.0/16,24\nAS64502,10.1.0.0/16,16\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The /16 ROA for AS64501 allows the /24 even though the /8 does not
+	if res := s.Validate(netip.MustParsePrefix("10.1.2.0/24"), 64501); res.State != StateValid || res.VRPs[0].Prefix.String() != "10.1.0.0/16" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	res := s.Validate(netip.MustParsePrefix("10.1.2.0/24"), 64999)
+	if res.State != StateInvalid || len(res.VRPs) != 3 || res.VRPs[0].Prefix.Bits() != 16 || res.VRPs[2].Prefix.Bits() != 8 {
+		t.Errorf("Expected every covering VRP, most specific first, got %+v", res)
+	}
+	if got := res.VRPs[2].String(); got != "AS64500 10.0.0.0/8 max /8" {
+		t.Errorf("VRP.String() = %q", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vrps.json")
+	if err := os.WriteFile(path, []byte(routinatorJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := Load(path); err != nil || s.Len() != 5 {
+		t.Errorf("Load() = %v, %v", s, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
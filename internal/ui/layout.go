@@ -186,9 +186,41 @@ func (l *Layout) Connectivity(report *model.Report) string {
 			report.Ping.AvgRtt, report.Ping.MinRtt, report.Ping.MaxRtt)
 	}
 
+	if report.MTU.PathMTU > 0 {
+		mtu := fmt.Sprintf("%d (%s)", report.MTU.PathMTU, report.MTU.Method)
+		if report.MTU.FragNeededFrom != "" {
+			mtu += " limited at " + report.MTU.FragNeededFrom
+		}
+		if report.MTU.BlackHole {
+			mtu += " " + l.styles.StatusWarning.Render("black hole")
+		}
+		pairs["Path MTU"] = mtu
+	}
+
+	if report.Ping.Method != "" {
+		method := report.Ping.Method
+		if report.Ping.Port > 0 {
+			method = fmt.Sprintf("%s/%d", method, report.Ping.Port)
+		}
+		pairs["Method"] = method
+	}
+
 	return l.RenderSection("Connectivity", l.RenderKeyValuePairs(pairs))
 }
 
+// Path shows the traceroute hops with AS boundaries marked
+func (l *Layout) Path(report *model.Report) string {
+	if len(report.Trace.Hops) == 0 {
+		return ""
+	}
+
+	body := FormatTraceHops(report.Trace.Hops)
+	if report.Trace.Graph != nil {
+		body = "Multipath: " + MultipathSummary(report.Trace.Graph) + "\n" + body
+	}
+	return l.RenderSection("Path", body)
+}
+
 // Services and ports
 func (l *Layout) Services(report *model.Report) string {
 	var pairs map[string]string
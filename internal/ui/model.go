@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -55,6 +56,7 @@ type Model struct {
 	// Collection options
 	enablePorts bool
 	timeout     time.Duration
+	collect     CollectFunc
 
 	// AI mode specific (placeholder for future)
 	messages  []ChatMessage
@@ -64,6 +66,12 @@ type Model struct {
 	fatalError error
 }
 
+// reportMsg carries the finished collection
+type reportMsg struct {
+	report *model.Report
+	err    error
+}
+
 type ChatMessage struct {
 	Role    string // "user" or "assistant"
 	Content string
@@ -115,7 +123,19 @@ func InitialModel(target string, noAgent bool, enablePorts bool, timeout time.Du
 }
 
 func (m Model) Init() tea.Cmd {
-	return nil
+	if m.collect == nil {
+		return nil
+	}
+	return tea.Batch(m.spinner.Tick, m.collectReport())
+}
+
+// collectReport runs the collection in the background
+func (m Model) collectReport() tea.Cmd {
+	collect := m.collect
+	return func() tea.Msg {
+		report, err := collect(context.Background())
+		return reportMsg{report: report, err: err}
+	}
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -129,6 +149,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.layout = NewLayout(msg.Width, msg.Height)
 		return m, nil
 
+	case reportMsg:
+		if msg.err != nil {
+			m.fatalError = msg.err
+			m.state = StateComplete
+			return m, nil
+		}
+		m.SetReport(msg.report)
+		return m, nil
+
 	case spinner.TickMsg:
 		if m.state == StateCollecting {
 			m.spinner, cmd = m.spinner.Update(msg)
@@ -264,6 +293,9 @@ func (m Model) renderCurrentTab() string {
 
 func (m Model) renderSummary() string {
 	if m.report == nil {
+		if m.fatalError != nil {
+			return m.layout.RenderSection("Status", fmt.Sprintf("Collection failed: %v", m.fatalError))
+		}
 		return m.layout.RenderSection("Status", "Collecting network data...")
 	}
 
@@ -284,6 +316,11 @@ func (m Model) renderSummary() string {
 		sections = append(sections, connectivity)
 	}
 
+	// Path section
+	if path := m.layout.Path(m.report); path != "" {
+		sections = append(sections, path)
+	}
+
 	// Services section
 	if services := m.layout.Services(m.report); services != "" {
 		sections = append(sections, services)
@@ -376,6 +413,9 @@ func (m *Model) updateRawDataTable() {
 		rows = append(rows, table.Row{"Ping Success", "Yes"})
 		rows = append(rows, table.Row{"Packet Loss", fmt.Sprintf("%.1f%%", m.report.Ping.PacketLossPct)})
 		rows = append(rows, table.Row{"Average RTT", m.report.Ping.AvgRtt})
+		if m.report.Ping.Method != "" {
+			rows = append(rows, table.Row{"Ping Method", m.report.Ping.Method})
+		}
 	} else {
 		rows = append(rows, table.Row{"Ping Success", "No"})
 		if m.report.Ping.Error != "" {
@@ -383,6 +423,14 @@ func (m *Model) updateRawDataTable() {
 		}
 	}
 
+	// Path MTU
+	if m.report.MTU.PathMTU > 0 {
+		rows = append(rows, table.Row{"Path MTU", fmt.Sprintf("%d (%s)", m.report.MTU.PathMTU, m.report.MTU.Method)})
+		if m.report.MTU.FragNeededFrom != "" {
+			rows = append(rows, table.Row{"Frag Needed From", m.report.MTU.FragNeededFrom})
+		}
+	}
+
 	// Port scan results
 	if len(m.report.Ports.Open) > 0 {
 		var ports []string
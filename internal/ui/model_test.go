@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// collectedModel runs a TUI model through its collection command
+func collectedModel(t *testing.T, collect CollectFunc) Model {
+	t.Helper()
+	m := InitialModel("example.com", true, false, time.Second)
+	m.collect = collect
+	if m.Init() == nil {
+		t.Fatal("Init() started no collection")
+	}
+
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 160, Height: 60})
+	next, _ = next.Update(next.(Model).collectReport()())
+	return next.(Model)
+}
+
+func TestTUIShowsTraceHops(t *testing.T) {
+	report := &model.Report{Target: "example.com", Errors: map[string]string{}}
+	report.Trace.Hops = []model.TraceHop{
+		{Hop: 1, IP: "192.168.1.1", RTT: "1.2ms", Class: "private"},
+		{
+			Hop: 2, IP: "203.0.113.9", RTT: "9.8ms", ASN: "64500", ASName: "EXAMPLE-TRANSIT", ASBoundary: true,
+			MPLS:       []model.MPLSLabel{{Label: 24000, TTL: 1, Bottom: true}},
+			Interfaces: []model.HopInterface{{Role: "incoming", Name: "xe-0/0/1", IP: "10.1.1.1", MTU: 9000}},
+		},
+	}
+
+	m := collectedModel(t, func(ctx context.Context) (*model.Report, error) { return report, nil })
+	if m.state != StateComplete {
+		t.Fatalf("state = %v after collection", m.state)
+	}
+
+	view := m.View()
+	for _, want := range []string{
+		"== AS64500 EXAMPLE-TRANSIT ==",
+		"MPLS 24000 ttl=1 S",
+		"incoming if xe-0/0/1 10.1.1.1 mtu 9000",
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("View() is missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestTUICollectionFailure(t *testing.T) {
+	m := collectedModel(t, func(ctx context.Context) (*model.Report, error) {
+		return nil, errors.New("DNS resolution failed")
+	})
+	if !m.ShouldExitWithError() || !strings.Contains(m.View(), "Collection failed: DNS resolution failed") {
+		t.Errorf("Expected the failure to be shown, got:\n%s", m.View())
+	}
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+type mtrUpdateMsg model.MTRReport
+
+type mtrDoneMsg struct{}
+
+// MTRModel renders the live per-hop table for `ng mtr`
+type MTRModel struct {
+	target  string
+	updates <-chan model.MTRReport
+	report  model.MTRReport
+	started time.Time
+	layout  *Layout
+	styles  Styles
+	done    bool
+}
+
+// RunMTRTUI shows the per-hop table until the user quits or the
+// updates channel is closed.
+func RunMTRTUI(target string, updates <-chan model.MTRReport) error {
+	m := MTRModel{
+		target:  target,
+		updates: updates,
+		started: time.Now(),
+		styles:  DefaultStyles(),
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("failed to start TUI: %w", err)
+	}
+
+	return nil
+}
+
+func (m MTRModel) Init() tea.Cmd {
+	return m.waitForUpdate()
+}
+
+func (m MTRModel) waitForUpdate() tea.Cmd {
+	return func() tea.Msg {
+		report, ok := <-m.updates
+		if !ok {
+			return mtrDoneMsg{}
+		}
+		return mtrUpdateMsg(report)
+	}
+}
+
+func (m MTRModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.layout = NewLayout(msg.Width, msg.Height)
+
+	case mtrUpdateMsg:
+		m.report = model.MTRReport(msg)
+		return m, m.waitForUpdate()
+
+	case mtrDoneMsg:
+		m.done = true
+	}
+
+	return m, nil
+}
+
+func (m MTRModel) View() string {
+	if m.layout == nil {
+		m.layout = NewLayout(80, 24)
+	}
+
+	status := fmt.Sprintf("Tracing (%s)", time.Since(m.started).Truncate(time.Second))
+	if m.done {
+		status = "Finished"
+	}
+
+	title := fmt.Sprintf("netgaze mtr: %s", m.target)
+	if m.report.IP != "" && m.report.IP != m.target {
+		title += fmt.Sprintf(" (%s)", m.report.IP)
+	}
+	header := m.layout.RenderHeader(title, status)
+
+	if m.report.Cycles == 0 {
+		return m.styles.App.Render(lipgloss.JoinVertical(lipgloss.Left,
+			header,
+			m.layout.RenderSection("Status", "Waiting for first trace..."),
+		))
+	}
+
+	section := fmt.Sprintf("Path (%s, %d cycles)", m.report.Protocol, m.report.Cycles)
+
+	return m.styles.App.Render(lipgloss.JoinVertical(lipgloss.Left,
+		header,
+		m.layout.RenderSection(section, FormatMTRTable(m.report)),
+		m.layout.RenderFooter([]string{"q/ctrl+c: quit"}),
+	))
+}
+
+// FormatMTRTable renders per-hop statistics as a plain text table.
+// Extra load-balanced responders are listed under their hop.
+func FormatMTRTable(report model.MTRReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%3s  %-40s %6s %5s %7s %7s %7s %7s %7s\n",
+		"Hop", "Host", "Loss%", "Snt", "Last", "Avg", "Best", "Wrst", "StDev")
+
+	for _, hop := range report.Hops {
+		if hop.Received == 0 {
+			fmt.Fprintf(&b, "%3d  %-40s %6.1f %5d\n", hop.Hop, "???", hop.LossPct, hop.Sent)
+			continue
+		}
+
+		fmt.Fprintf(&b, "%3d  %-40s %6.1f %5d %7.1f %7.1f %7.1f %7.1f %7.1f\n",
+			hop.Hop, truncate(mtrHostLabel(hop), 40), hop.LossPct, hop.Sent,
+			hop.LastMs, hop.AvgMs, hop.BestMs, hop.WorstMs, hop.StdDevMs)
+
+		for _, ip := range hop.Responders {
+			if ip != hop.IP {
+				fmt.Fprintf(&b, "     %s\n", ip)
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// mtrHostLabel names a hop as mtr -z does, with its origin AS first
+func mtrHostLabel(hop model.MTRHop) string {
+	label := hop.IP
+	if hop.Host != "" {
+		label = fmt.Sprintf("%s (%s)", strings.TrimSuffix(hop.Host, "."), hop.IP)
+	}
+	if hop.ASN != "" {
+		label = "AS" + hop.ASN + " " + label
+	}
+	return label
+}
+
+func truncate(s string, width int) string {
+	if len(s) <= width {
+		return s
+	}
+	return s[:width-3] + "..."
+}
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// FormatRDAP renders an RDAP result as plain text: the object, its
+// lifecycle events and every contact with its roles
+func FormatRDAP(r *model.RDAPResult) string {
+	var b strings.Builder
+
+	field := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "  %-13s %s\n", label+":", value)
+		}
+	}
+
+	fmt.Fprintf(&b, "%s (%s)\n", r.ObjectClass, r.Server)
+	field("Name", r.Name)
+	field("Handle", r.Handle)
+	switch r.ObjectClass {
+	case "ip network":
+		if r.StartAddress != "" {
+			field("Range", r.StartAddress+" - "+r.EndAddress)
+		}
+		field("CIDR", strings.Join(r.CIDRs, ", "))
+		field("Type", r.Type)
+		field("Parent", r.ParentHandle)
+	case "autnum":
+		if r.StartAutnum != 0 {
+			asns := fmt.Sprintf("AS%d", r.StartAutnum)
+			if r.EndAutnum > r.StartAutnum {
+				asns += fmt.Sprintf(" - AS%d", r.EndAutnum)
+			}
+			field("Range", asns)
+		}
+		field("Type", r.Type)
+	}
+	field("Country", r.Country)
+	field("Status", strings.Join(r.Status, ", "))
+	field("Nameservers", strings.Join(r.Nameservers, ", "))
+
+	if len(r.Events) > 0 {
+		b.WriteString("\nEvents:\n")
+		for _, e := range r.Events {
+			line := fmt.Sprintf("  %-20s %s", e.Action, e.Date)
+			if e.Actor != "" {
+				line += " by " + e.Actor
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if len(r.Entities) > 0 {
+		b.WriteString("\nContacts:\n")
+		for _, e := range r.Entities {
+			name := e.Org
+			if name == "" {
+				name = e.Name
+			} else if e.Name != "" && e.Name != e.Org {
+				name += " (" + e.Name + ")"
+			}
+			if name == "" {
+				name = e.Handle
+			}
+			fmt.Fprintf(&b, "  [%s] %s\n", strings.Join(e.Roles, ", "), name)
+			if e.Handle != "" && e.Handle != name {
+				fmt.Fprintf(&b, "      handle %s\n", e.Handle)
+			}
+			if e.IANAID != "" {
+				fmt.Fprintf(&b, "      IANA ID %s\n", e.IANAID)
+			}
+			for _, email := range e.Emails {
+				fmt.Fprintf(&b, "      %s\n", email)
+			}
+			for _, phone := range e.Phones {
+				fmt.Fprintf(&b, "      %s\n", phone)
+			}
+			if e.Address != "" {
+				fmt.Fprintf(&b, "      %s\n", e.Address)
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// FormatTraceHops renders trace hops as plain text lines. A marker line
+// is inserted wherever the path enters a new AS.
+func FormatTraceHops(hops []model.TraceHop) string {
+	var b strings.Builder
+
+	for _, hop := range hops {
+		if hop.ASBoundary {
+			fmt.Fprintf(&b, "     == %s ==\n", ASLabel(hop))
+		}
+
+		if hop.Timeout {
+			fmt.Fprintf(&b, "%3d  *\n", hop.Hop)
+			continue
+		}
+
+		var notes []string
+		switch {
+		case hop.ASN != "":
+			notes = append(notes, "AS"+hop.ASN)
+			if hop.Country != "" {
+				notes = append(notes, hop.Country)
+			}
+		case hop.Class != "" && hop.Class != "public":
+			notes = append(notes, hop.Class)
+		}
+		if hop.LossPct > 0 {
+			notes = append(notes, fmt.Sprintf("%.0f%% loss", hop.LossPct))
+		}
+		if hop.QuotedTTL > 1 {
+			// Routers before this one did not decrement the TTL
+			notes = append(notes, fmt.Sprintf("q-TTL %d, hidden hops?", hop.QuotedTTL))
+		}
+
+		line := fmt.Sprintf("%3d  %-40s %9s  %s", hop.Hop, truncate(traceHostLabel(hop), 40), hop.RTT, strings.Join(notes, " "))
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+
+		if len(hop.MPLS) > 0 {
+			fmt.Fprintf(&b, "     MPLS %s\n", FormatMPLS(hop.MPLS))
+		}
+		for _, iface := range hop.Interfaces {
+			fmt.Fprintf(&b, "     %s\n", formatHopInterface(iface))
+		}
+		for _, ip := range hop.Responders {
+			if ip != hop.IP {
+				fmt.Fprintf(&b, "     %s\n", ip)
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// MultipathSummary describes a multipath graph in one line, e.g.
+// "16 flows, branches at hops 3, 7"
+func MultipathSummary(graph *model.TraceGraph) string {
+	counts := make(map[int]int)
+	for _, n := range graph.Nodes {
+		counts[n.Hop]++
+	}
+	var hops []int
+	for hop, c := range counts {
+		if c > 1 {
+			hops = append(hops, hop)
+		}
+	}
+	if len(hops) == 0 {
+		return fmt.Sprintf("%d flows, single path", graph.Flows)
+	}
+	slices.Sort(hops)
+
+	branches := make([]string, len(hops))
+	for i, hop := range hops {
+		branches[i] = fmt.Sprint(hop)
+	}
+	return fmt.Sprintf("%d flows, branches at hops %s", graph.Flows, strings.Join(branches, ", "))
+}
+
+// FormatMPLS renders a label stack outermost first, e.g.
+// "24000 ttl=1 / 16 ttl=1 S"
+func FormatMPLS(labels []model.MPLSLabel) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("%d ttl=%d", l.Label, l.TTL)
+		if l.TC != 0 {
+			parts[i] += fmt.Sprintf(" tc=%d", l.TC)
+		}
+		if l.Bottom {
+			parts[i] += " S"
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// formatHopInterface renders RFC 5837 interface information, e.g.
+// "incoming if xe-0/0/1 10.1.1.1 mtu 9000 (ifindex 512)"
+func formatHopInterface(iface model.HopInterface) string {
+	parts := []string{iface.Role, "if"}
+	if iface.Name != "" {
+		parts = append(parts, iface.Name)
+	}
+	if iface.IP != "" {
+		parts = append(parts, iface.IP)
+	}
+	if iface.MTU > 0 {
+		parts = append(parts, fmt.Sprintf("mtu %d", iface.MTU))
+	}
+	if iface.Index > 0 {
+		parts = append(parts, fmt.Sprintf("(ifindex %d)", iface.Index))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ASLabel describes a hop's AS, e.g. "AS15169 GOOGLE, US"
+func ASLabel(hop model.TraceHop) string {
+	if hop.ASName == "" {
+		return "AS" + hop.ASN
+	}
+	return fmt.Sprintf("AS%s %s", hop.ASN, hop.ASName)
+}
+
+func traceHostLabel(hop model.TraceHop) string {
+	if hop.Host == "" {
+		return hop.IP
+	}
+	return fmt.Sprintf("%s (%s)", strings.TrimSuffix(hop.Host, "."), hop.IP)
+}
@@ -1,21 +1,22 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/typicalfo/netgaze/internal/model"
 	"time"
 )
 
-// RunTUI starts the terminal user interface
-func RunTUI(target string, noAgent bool, enablePorts bool, timeout time.Duration, report *model.Report) error {
+// CollectFunc gathers the report the TUI shows
+type CollectFunc func(ctx context.Context) (*model.Report, error)
+
+// RunTUI starts the terminal user interface and runs collect behind
+// the progress spinner
+func RunTUI(target string, noAgent bool, enablePorts bool, timeout time.Duration, collect CollectFunc) error {
 	// Create initial model
 	m := InitialModel(target, noAgent, enablePorts, timeout)
-
-	// If report is already available, set it
-	if report != nil {
-		m.SetReport(report)
-	}
+	m.collect = collect
 
 	// Create and run program
 	p := tea.NewProgram(
@@ -31,8 +32,8 @@ func RunTUI(target string, noAgent bool, enablePorts bool, timeout time.Duration
 	}
 
 	// Check if we should exit with an error
-	if finalModel.(Model).ShouldExitWithError() {
-		return fmt.Errorf("TUI exited with error")
+	if final := finalModel.(Model); final.ShouldExitWithError() {
+		return fmt.Errorf("collection failed: %w", final.fatalError)
 	}
 
 	return nil
@@ -0,0 +1,219 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/typicalfo/netgaze/internal/model"
+)
+
+// sparkLevels are plain ASCII glyphs from lowest to highest latency
+const sparkLevels = " .:-=+*#%@"
+
+// sparkLost marks a lost probe in the sparkline
+const sparkLost = 'x'
+
+type watchUpdateMsg model.WatchStats
+
+type watchDoneMsg struct{}
+
+// WatchModel renders live statistics for `ng watch`
+type WatchModel struct {
+	target  string
+	updates <-chan model.WatchStats
+	stats   model.WatchStats
+	history []sparkSample
+	started time.Time
+	layout  *Layout
+	styles  Styles
+	done    bool
+}
+
+type sparkSample struct {
+	ms float64
+	ok bool
+}
+
+// RunWatchTUI shows rolling ping statistics until the user quits
+// or the updates channel is closed.
+func RunWatchTUI(target string, updates <-chan model.WatchStats) error {
+	m := WatchModel{
+		target:  target,
+		updates: updates,
+		started: time.Now(),
+		styles:  DefaultStyles(),
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("failed to start TUI: %w", err)
+	}
+
+	return nil
+}
+
+func (m WatchModel) Init() tea.Cmd {
+	return m.waitForUpdate()
+}
+
+func (m WatchModel) waitForUpdate() tea.Cmd {
+	return func() tea.Msg {
+		stats, ok := <-m.updates
+		if !ok {
+			return watchDoneMsg{}
+		}
+		return watchUpdateMsg(stats)
+	}
+}
+
+func (m WatchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		m.layout = NewLayout(msg.Width, msg.Height)
+
+	case watchUpdateMsg:
+		m.stats = model.WatchStats(msg)
+		m.history = append(m.history, sparkSample{ms: m.stats.LastMs, ok: m.stats.LastOK})
+		if len(m.history) > 1000 {
+			m.history = m.history[len(m.history)-1000:]
+		}
+		return m, m.waitForUpdate()
+
+	case watchDoneMsg:
+		m.done = true
+	}
+
+	return m, nil
+}
+
+func (m WatchModel) View() string {
+	if m.layout == nil {
+		m.layout = NewLayout(80, 24)
+	}
+
+	status := fmt.Sprintf("Watching (%s)", time.Since(m.started).Truncate(time.Second))
+	if m.done {
+		status = "Finished"
+	}
+
+	title := fmt.Sprintf("netgaze watch: %s", m.target)
+	if m.stats.IP != "" && m.stats.IP != m.target {
+		title += fmt.Sprintf(" (%s)", m.stats.IP)
+	}
+	header := m.layout.RenderHeader(title, status)
+
+	if m.stats.Sent == 0 {
+		return m.styles.App.Render(lipgloss.JoinVertical(lipgloss.Left,
+			header,
+			m.layout.RenderSection("Status", "Waiting for first probe..."),
+		))
+	}
+
+	method := m.stats.Method
+	if m.stats.Port > 0 {
+		method = fmt.Sprintf("%s/%d", method, m.stats.Port)
+	}
+
+	lossStyle := m.styles.StatusSuccess
+	if m.stats.LossPct > 0 {
+		lossStyle = m.styles.StatusWarning
+	}
+	if !m.stats.LastOK {
+		lossStyle = m.styles.StatusError
+	}
+
+	last := "lost"
+	if m.stats.LastOK {
+		last = fmt.Sprintf("%.2fms", m.stats.LastMs)
+	}
+
+	stats := strings.Join([]string{
+		fmt.Sprintf("Method:   %s", method),
+		fmt.Sprintf("Probes:   %d sent, %d received", m.stats.Sent, m.stats.Received),
+		fmt.Sprintf("Loss:     %s (last %d probes)", lossStyle.Render(fmt.Sprintf("%.1f%%", m.stats.LossPct)), m.stats.Window),
+		fmt.Sprintf("Last:     %s", last),
+		fmt.Sprintf("RTT:      min %.2fms, avg %.2fms, max %.2fms", m.stats.MinMs, m.stats.AvgMs, m.stats.MaxMs),
+		fmt.Sprintf("Pctl:     p50 %.2fms, p90 %.2fms, p99 %.2fms", m.stats.P50Ms, m.stats.P90Ms, m.stats.P99Ms),
+	}, "\n")
+
+	width := m.layout.width - 16
+	if width < 10 {
+		width = 10
+	}
+
+	sections := []string{
+		header,
+		m.layout.RenderSection("Statistics", stats),
+		m.layout.RenderSection("Latency", sparkline(m.history, width)+"\n"+
+			m.styles.Subtitle.Render(fmt.Sprintf("scale: %.2fms - %.2fms, %c = lost", m.stats.MinMs, m.stats.MaxMs, sparkLost))),
+	}
+
+	if len(m.stats.Outages) > 0 {
+		sections = append(sections, m.layout.RenderSection("Outages", renderOutages(m.stats.Outages, 5)))
+	}
+
+	sections = append(sections, m.layout.RenderFooter([]string{"q/ctrl+c: quit"}))
+
+	return m.styles.App.Render(lipgloss.JoinVertical(lipgloss.Left, sections...))
+}
+
+// sparkline renders the most recent samples as a single line of ASCII
+// glyphs scaled between the lowest and highest RTT shown.
+func sparkline(samples []sparkSample, width int) string {
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		if s.ok {
+			lo = math.Min(lo, s.ms)
+			hi = math.Max(hi, s.ms)
+		}
+	}
+
+	var b strings.Builder
+	top := len(sparkLevels) - 1
+	for _, s := range samples {
+		switch {
+		case !s.ok:
+			b.WriteByte(sparkLost)
+		case hi <= lo:
+			b.WriteByte(sparkLevels[1])
+		default:
+			level := 1 + int(math.Round((s.ms-lo)/(hi-lo)*float64(top-1)))
+			b.WriteByte(sparkLevels[level])
+		}
+	}
+
+	return b.String()
+}
+
+func renderOutages(outages []model.Outage, limit int) string {
+	if len(outages) > limit {
+		outages = outages[len(outages)-limit:]
+	}
+
+	var lines []string
+	for _, o := range outages {
+		start := o.Start.Local().Format("15:04:05")
+		if o.End.IsZero() {
+			lines = append(lines, fmt.Sprintf("%s - ongoing, %d probes lost", start, o.Lost))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s - %s (%s), %d probes lost",
+			start, o.End.Local().Format("15:04:05"), o.End.Sub(o.Start).Truncate(time.Millisecond), o.Lost))
+	}
+
+	return strings.Join(lines, "\n")
+}